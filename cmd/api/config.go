@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/api/approval"
+)
+
+const configUsage = `usage: api config validate [flags]
+
+Loads the configuration as the API would, from the defaults, the configuration
+file, the environment and the flags, and reports every problem found.`
+
+// runConfig runs the config subcommands, returning the exit code.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return exitStartupFailure
+	}
+
+	c, err := config.Load(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return exitOK
+	}
+	if err == nil {
+		err = checkPolicies(c)
+	}
+	if err != nil {
+		// one problem per line
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		return exitStartupFailure
+	}
+
+	fmt.Println("configuration is valid")
+	return exitOK
+}
+
+// checkPolicies reports the approval policies and the policy file of c that
+// do not compile, as config.Validate reports the other settings, since it does
+// not depend on the packages compiling them.
+func checkPolicies(c *config.Conf) error {
+	var errs []error
+	if _, err := approval.ParsePolicies(c.Approval.Policies); err != nil {
+		errs = append(errs, fmt.Errorf("APPROVAL_POLICIES: %w", err))
+	}
+	if _, err := loadPolicies(c.Policy); err != nil {
+		errs = append(errs, fmt.Errorf("POLICY_FILE: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/policy"
+)
+
+// loadPolicies returns the policies of the file of c, none when it is not set.
+func loadPolicies(c config.ConfPolicy) (*policy.Engine, error) {
+	if c.File == "" {
+		return &policy.Engine{}, nil
+	}
+
+	return policy.Load(c.File)
+}
+
+// deviceHooks returns the built-in hooks enabled by the settings of c:
+// checking the names of devices against a pattern, and sending the changes
+// of devices to a webhook.
+func deviceHooks(c config.ConfHook) ([]device.Hook, error) {
+	var hooks []device.Hook
+
+	if c.NamePattern != "" {
+		re, err := regexp.Compile(c.NamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("HOOK_NAME_PATTERN: %w", err)
+		}
+		hooks = append(hooks, namingHook(re, c.Timeout))
+	}
+
+	if c.WebhookURL != "" {
+		hooks = append(hooks, webhookHook(c.WebhookURL, &http.Client{Timeout: c.Timeout}, c.Timeout))
+	}
+
+	return hooks, nil
+}
+
+// namingHook vetoes the devices created or renamed with a name not matching
+// re. Devices keeping their name are left alone, even if it does not match.
+func namingHook(re *regexp.Regexp, timeout time.Duration) device.Hook {
+	return device.Hook{
+		Name:       "naming",
+		Operations: []string{device.OperationCreate, device.OperationUpdate},
+		Timeout:    timeout,
+		Before: func(_ context.Context, m device.Mutation) error {
+			if m.Before != nil && m.Before.Name == m.After.Name {
+				return nil
+			}
+
+			if !re.MatchString(m.After.Name) {
+				return fmt.Errorf("device name %q does not match %s", m.After.Name, re)
+			}
+
+			return nil
+		},
+	}
+}
+
+// webhookPayload is sent to the webhook of webhookHook.
+type webhookPayload struct {
+	Operation string      `json:"operation"`
+	Before    *device.DTO `json:"before,omitempty"`
+	After     *device.DTO `json:"after,omitempty"`
+	At        time.Time   `json:"at"`
+}
+
+// webhookHook sends the changes of devices, once stored, to url as JSON
+// POSTs, after the other hooks.
+func webhookHook(url string, client *http.Client, timeout time.Duration) device.Hook {
+	return device.Hook{
+		Name:    "webhook",
+		Order:   100,
+		Timeout: timeout,
+		After: func(ctx context.Context, m device.Mutation) error {
+			payload := webhookPayload{Operation: m.Operation, At: time.Now().UTC()}
+			if m.Before != nil {
+				payload.Before = m.Before.ToDto()
+			}
+			if m.After != nil {
+				payload.After = m.After.ToDto()
+			}
+
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return fmt.Errorf("webhook responded %s", resp.Status)
+			}
+
+			return nil
+		},
+	}
+}
@@ -1,23 +1,58 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"gorm.io/driver/postgres"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 
 	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/api/approval"
+	"github.com/hferr/device-manager/internal/api/auth"
 	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/idempotency"
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/api/policy"
+	"github.com/hferr/device-manager/internal/api/reservation"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+	"github.com/hferr/device-manager/internal/database"
+	"github.com/hferr/device-manager/internal/health"
+	"github.com/hferr/device-manager/internal/lifecycle"
+	"github.com/hferr/device-manager/internal/metrics"
 	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/internal/tlsconfig"
+	"github.com/hferr/device-manager/internal/tracing"
 	"github.com/hferr/device-manager/migrations"
+	"github.com/hferr/device-manager/utils/logger"
 	"github.com/hferr/device-manager/utils/validator"
 
 	_ "github.com/hferr/device-manager/docs" // generated swagger docs
 )
 
-const fmtDBConnString = "host=%s user=%s password=%s dbname=%s port=%d sslmode=disable"
+// exit codes
+const (
+	exitOK = iota
+	exitStartupFailure
+	exitServerFailure
+	exitShutdownFailure
+)
+
+// cacheInvalidationChannel is the PostgreSQL channel replicas broadcast
+// device cache invalidations on.
+const cacheInvalidationChannel = "device_cache_invalidation"
 
 // @title           Device Manager API
 // @version         1.0
@@ -25,59 +60,565 @@ const fmtDBConnString = "host=%s user=%s password=%s dbname=%s port=%d sslmode=d
 
 // @servers.url  localhost:8080
 func main() {
-	c := config.New()
-	v := validator.New()
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the subcommand named by the first argument, or starts the API.
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "config":
+			return runConfig(args[1:])
+		case "migrate":
+			return runMigrate(args[1:])
+		case "schema":
+			return runSchema(args[1:])
+		}
+	}
+
+	return serve(args)
+}
 
-	db, err := setupDB(&c.DB)
+// serve starts the API and blocks until it is shut down, returning the exit
+// code.
+func serve(args []string) int {
+	c, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err == nil {
+		err = checkPolicies(c)
+	}
 	if err != nil {
-		log.Fatalf("failed to setup database: %v", err)
+		slog.Error("invalid configuration", slog.Any("error", err))
+		return exitStartupFailure
 	}
 
-	// setup repos
-	deviceRepo := device.NewRepository(db)
+	v := validator.New()
 
-	// setup services
-	deviceSvs := device.NewService(deviceRepo)
+	l, err := logger.New(os.Stdout, c.Log.Format, c.Log.Level)
+	if err != nil {
+		slog.Error("invalid log configuration", slog.Any("error", err))
+		return exitStartupFailure
+	}
+	slog.SetDefault(l)
 
-	// setup handlers
-	handler := httpjson.NewHandler(deviceSvs, v)
+	tp, shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    c.Tracing.Exporter,
+		Endpoint:    c.Tracing.OTLPEndpoint,
+		Insecure:    c.Tracing.OTLPInsecure,
+		SampleRatio: c.Tracing.SampleRatio,
+		ServiceName: c.Tracing.ServiceName,
+	})
+	if err != nil {
+		l.Error("failed to setup tracing", slog.Any("error", err))
+		return exitStartupFailure
+	}
+
+	var m *metrics.Metrics
+	if c.Metrics.Enabled {
+		m = metrics.New()
+	}
 
 	s := &http.Server{
 		Addr:         fmt.Sprintf(":%d", c.Server.Port),
-		Handler:      handler.NewRouter(),
 		ReadTimeout:  c.Server.TimeoutRead,
 		WriteTimeout: c.Server.TimeoutWrite,
 		IdleTimeout:  c.Server.TimeoutIdle,
 	}
 
-	if err := s.ListenAndServe(); err != nil {
-		log.Fatalf("server failed to start: %v", err)
+	var reloader *tlsconfig.Reloader
+	if c.Server.TLSCertFile != "" {
+		reloader, err = tlsconfig.New(tlsconfig.Config{
+			CertFile:     c.Server.TLSCertFile,
+			KeyFile:      c.Server.TLSKeyFile,
+			MinVersion:   c.Server.TLSMinVersion,
+			CipherPolicy: c.Server.TLSCipherPolicy,
+			ClientCAFile: c.Server.TLSClientCAFile,
+			ClientAuth:   c.Server.TLSClientAuth,
+		}, tlsconfig.WithLogger(l))
+		if err != nil {
+			l.Error("failed to setup TLS", slog.Any("error", err))
+			return exitStartupFailure
+		}
+		s.TLSConfig = reloader.TLSConfig()
 	}
-}
 
-func setupDB(cfg *config.ConfDB) (*gorm.DB, error) {
-	dbConnString := fmt.Sprintf(
-		fmtDBConnString,
-		cfg.Host,
-		cfg.Username,
-		cfg.Password,
-		cfg.DBName,
-		cfg.Port,
+	app := lifecycle.New(s,
+		lifecycle.WithLogger(l),
+		lifecycle.WithShutdownTimeout(c.Server.ShutdownTimeout),
+		lifecycle.WithShutdownDelay(c.Server.ShutdownDelay),
 	)
 
-	db, err := gorm.Open(postgres.Open(dbConnString))
+	// hooks run in reverse order, so the database is closed before the
+	// remaining spans are flushed
+	app.OnShutdown("tracing", shutdownTracing)
+
+	if reloader != nil {
+		app.AddWorker(lifecycle.WorkerFunc("tls-reload", func(ctx context.Context) error {
+			reloader.Run(ctx, c.Server.TLSReloadInterval)
+			return nil
+		}))
+	}
+
+	checker := health.NewChecker(c.Health.Timeout)
+	checker.Add("shutdown", app.CheckShutdown)
+	checker.Add("workers", app.CheckWorkers)
+
+	var (
+		deviceRepo      device.DeviceRepository
+		stateRepo       devicestate.StateRepository
+		reservationRepo reservation.ReservationRepository
+		waitlistRepo    waitlist.WaitlistRepository
+		notifRepo       notification.NotificationRepository
+		approvalRepo    approval.ChangeRequestRepository
+		stateOpts       []devicestate.ServiceOption
+		dbHandle        *sql.DB
+		handlerOpts     []httpjson.HandlerOption
+	)
+
+	switch c.Storage.Backend {
+	case config.StorageBackendMemory:
+		l.Warn("using in-memory storage, data is lost on restart and organizations and idempotency keys are disabled")
+		stateRepo = devicestate.NewMemoryRepository()
+		memoryRepo := device.NewMemoryRepository(device.WithMemoryStates(stateRepo))
+		deviceRepo = memoryRepo
+		reservationRepo = reservation.NewMemoryRepository()
+		waitlistRepo = waitlist.NewMemoryRepository()
+		notifRepo = notification.NewMemoryRepository()
+		approvalRepo = approval.NewMemoryRepository()
+
+		// without a foreign key, states devices are in are protected by the service
+		stateOpts = append(stateOpts, devicestate.WithUsage(device.DevicesInState(memoryRepo.(device.DeviceCounter))))
+	case config.StorageBackendDatabase:
+		var dbOpts []database.OpenOption
+		if !c.DB.AutoMigrate {
+			dbOpts = append(dbOpts, database.WithoutMigrations())
+		}
+
+		db, err := setupDB(&c.DB, l, dbOpts...)
+		if err != nil {
+			l.Error("failed to setup database", slog.Any("error", err))
+			return exitStartupFailure
+		}
+
+		dbHandle, err = db.DB()
+		if err != nil {
+			l.Error("failed to get database handle", slog.Any("error", err))
+			return exitStartupFailure
+		}
+		app.OnShutdown("database", func(context.Context) error {
+			return dbHandle.Close()
+		})
+
+		if err := tracing.InstrumentDB(db, tp); err != nil {
+			l.Error("failed to instrument database", slog.Any("error", err))
+			return exitStartupFailure
+		}
+
+		if m != nil {
+			dbName := c.DB.DBName
+			if c.DB.Driver == config.DBDriverSQLite {
+				dbName = c.DB.Path
+			}
+
+			if err := m.InstrumentDB(db, dbName); err != nil {
+				l.Error("failed to instrument database", slog.Any("error", err))
+				return exitStartupFailure
+			}
+		}
+
+		versionChecker, err := migrations.NewVersionChecker(dbHandle, db.Dialector.Name())
+		if err != nil {
+			l.Error("failed to load migrations", slog.Any("error", err))
+			return exitStartupFailure
+		}
+		checker.Add("database", health.PingDB(dbHandle))
+		checker.Add("migrations", versionChecker.Check)
+
+		if !c.DB.AutoMigrate {
+			if err := versionChecker.Check(context.Background()); err != nil {
+				l.Warn("automatic migrations are disabled, the server is not ready until they are applied", slog.Any("error", err))
+			}
+		}
+
+		if c.DB.SchemaCheck != config.SchemaCheckOff && c.DB.Driver == config.DBDriverPostgres {
+			if err := device.VerifySchema(context.Background(), db); err != nil {
+				if c.DB.SchemaCheck == config.SchemaCheckFail {
+					l.Error("database schema does not match the model", slog.Any("error", err))
+					return exitStartupFailure
+				}
+				l.Warn("database schema does not match the model", slog.Any("error", err))
+			}
+		}
+
+		if err := device.SetupRowLevelSecurity(context.Background(), db, c.DB.RowLevelSecurity); err != nil {
+			l.Error("failed to set up row-level security on the devices table", slog.Any("error", err))
+			return exitStartupFailure
+		}
+
+		// setup repos
+		var deviceRepoOpts []device.RepositoryOption
+		if c.DB.RowLevelSecurity {
+			deviceRepoOpts = append(deviceRepoOpts, device.WithRowLevelSecurity())
+		}
+		deviceRepo = device.NewRepository(db, deviceRepoOpts...)
+		stateRepo = devicestate.NewRepository(db)
+		reservationRepo = reservation.NewRepository(db)
+		waitlistRepo = waitlist.NewRepository(db)
+		notifRepo = notification.NewRepository(db)
+		approvalRepo = approval.NewRepository(db)
+		organizationRepo := organization.NewRepository(db)
+		idempotencyRepo := idempotency.NewRepository(db)
+
+		// setup services
+		organizationSvs := organization.NewService(organizationRepo)
+		idempotencySvs := idempotency.NewService(idempotencyRepo, c.Idempotency.TTL)
+
+		app.AddWorker(lifecycle.WorkerFunc("idempotency-purge", func(ctx context.Context) error {
+			purgeIdempotencyKeys(ctx, idempotencySvs, c.Idempotency.PurgeInterval, l)
+			return nil
+		}))
+
+		handlerOpts = append(handlerOpts,
+			httpjson.WithOrganizationService(organizationSvs),
+			httpjson.WithIdempotencyService(idempotencySvs),
+			httpjson.WithIdempotencyMaxBodySize(int64(c.Idempotency.MaxBodySize)),
+		)
+	default:
+		l.Error("invalid configuration", slog.String("error", fmt.Sprintf("STORAGE_BACKEND: unknown backend %q", c.Storage.Backend)))
+		return exitStartupFailure
+	}
+
+	repo := device.NewTracedRepository(deviceRepo, tp)
+	if c.Cache.Enabled {
+		cacheOpts := []device.CacheOption{
+			device.WithCacheSize(c.Cache.Size),
+			device.WithCacheTTL(c.Cache.TTL),
+		}
+		if m != nil {
+			cacheOpts = append(cacheOpts, device.WithCacheObserver(m))
+		}
+		if c.Cache.Notify {
+			cacheOpts = append(cacheOpts, device.WithCacheBroadcaster(cacheBroadcaster{db: dbHandle, l: l}))
+		}
+
+		// the cache wraps the traced repository, so spans are only recorded
+		// for the lookups reaching the database
+		cache := device.NewCachedRepository(repo, cacheOpts...)
+		repo = cache
+
+		if c.Cache.Notify {
+			app.AddWorker(lifecycle.WorkerFunc("cache-invalidation", func(ctx context.Context) error {
+				listenCacheInvalidations(ctx, dbHandle, cache, l)
+				return nil
+			}))
+		}
+	}
+
+	stateSvs := devicestate.NewService(stateRepo, stateOpts...)
+	hooks, err := deviceHooks(c.Hook)
 	if err != nil {
-		return nil, err
+		l.Error("invalid configuration", slog.Any("error", err))
+		return exitStartupFailure
+	}
+	policies, err := loadPolicies(c.Policy)
+	if err != nil {
+		l.Error("invalid configuration", slog.Any("error", err))
+		return exitStartupFailure
+	}
+	approvalPolicies, err := approval.ParsePolicies(c.Approval.Policies)
+	if err != nil {
+		l.Error("invalid configuration", slog.Any("error", err))
+		return exitStartupFailure
+	}
+	// added before the workers changing devices, so that it stops after them
+	// and runs the hooks of their changes
+	hookQueue := device.NewHookQueue(c.Hook.QueueSize)
+	app.AddWorker(lifecycle.WorkerFunc("device-hooks", func(ctx context.Context) error {
+		hookQueue.Run(ctx)
+		return nil
+	}))
+	deviceOpts := []device.ServiceOption{
+		device.WithStates(stateSvs),
+		device.WithPolicies(policies),
+		device.WithApprover(approval.NewApprover(approvalRepo, approvalPolicies)),
+		device.WithHooks(hooks...),
+		device.WithHookQueue(hookQueue),
+		device.WithLogger(l),
+	}
+	if m != nil {
+		deviceOpts = append(deviceOpts, device.WithHookObserver(m))
 	}
+	deviceSvs := device.NewTracedService(device.NewService(repo, deviceOpts...), tp)
 
-	dbHandle, err := db.DB()
+	notifOpts, err := notificationOptions(c.Notification)
 	if err != nil {
-		return nil, err
+		l.Error("invalid configuration", slog.Any("error", err))
+		return exitStartupFailure
 	}
+	notificationSvs := notification.NewService(notifRepo, notifOpts...)
+
+	app.AddWorker(lifecycle.WorkerFunc("notification-delivery", func(ctx context.Context) error {
+		deliverNotifications(ctx, notificationSvs, c.Notification.DeliveryInterval, l)
+		return nil
+	}))
+
+	reservationSvs := reservation.NewService(reservationRepo, deviceSvs, stateSvs,
+		reservation.WithEvents(notifyReservationEvents(notificationSvs, l)),
+	)
+
+	app.AddWorker(lifecycle.WorkerFunc("reservation-start", func(ctx context.Context) error {
+		startReservations(ctx, reservationSvs, c.Reservation.StartInterval, l)
+		return nil
+	}))
+
+	waitlistSvs := waitlist.NewService(waitlistRepo, deviceSvs, stateSvs,
+		waitlist.WithOfferTTL(c.Waitlist.OfferTTL),
+		waitlist.WithReservations(reservationSvs),
+		waitlist.WithEvents(notifyWaitlistEvents(notificationSvs, l)),
+	)
 
-	if err := migrations.MaybeApplyMigrations(dbHandle); err != nil {
+	app.AddWorker(lifecycle.WorkerFunc("waitlist-dispatch", func(ctx context.Context) error {
+		dispatchWaitlist(ctx, waitlistSvs, c.Waitlist.DispatchInterval, l)
+		return nil
+	}))
+
+	if counter, ok := deviceRepo.(device.DeviceCounter); ok && m != nil {
+		app.AddWorker(lifecycle.WorkerFunc("inventory-metrics", func(ctx context.Context) error {
+			m.RunInventoryRefresh(ctx, counter, c.Metrics.InventoryInterval, l)
+			return nil
+		}))
+	}
+
+	// setup handlers
+	opts, err := handlerOptions(c)
+	if err != nil {
+		l.Error("invalid configuration", slog.Any("error", err))
+		return exitStartupFailure
+	}
+	handlerOpts = append(handlerOpts, opts...)
+	handlerOpts = append(handlerOpts,
+		httpjson.WithLogger(l),
+		httpjson.WithTracerProvider(tp),
+		httpjson.WithHealthChecker(checker),
+		httpjson.WithConfig(c),
+		httpjson.WithStateService(stateSvs),
+		httpjson.WithReservationService(reservationSvs),
+		httpjson.WithWaitlistService(waitlistSvs),
+		httpjson.WithNotificationService(notificationSvs),
+	)
+	if m != nil {
+		handlerOpts = append(handlerOpts, httpjson.WithMetrics(m))
+	}
+
+	// the watchers of the devices deleted through the API are notified
+	handlerDeviceSvs := notification.NewDeviceService(deviceSvs, notificationSvs, deviceWatchers(reservationSvs, waitlistSvs), l)
+	// approved changes are applied as if made through the API
+	handlerOpts = append(handlerOpts, httpjson.WithApprovalService(
+		approval.NewService(approvalRepo, handlerDeviceSvs),
+	))
+	handlerOpts = append(handlerOpts, httpjson.WithPolicyService(
+		policy.NewService(policies, handlerDeviceSvs),
+	))
+
+	handler := httpjson.NewHandler(handlerDeviceSvs, v, handlerOpts...)
+	s.Handler = handler.NewRouter()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// restore the default behaviour once shutdown begins, so a second signal
+	// terminates the process immediately
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := app.Run(ctx); err != nil {
+		if errors.Is(err, lifecycle.ErrServerFailed) {
+			return exitServerFailure
+		}
+		return exitShutdownFailure
+	}
+
+	return exitOK
+}
+
+func handlerOptions(c *config.Conf) ([]httpjson.HandlerOption, error) {
+	var opts []httpjson.HandlerOption
+
+	defaultTenantID := uuid.Nil
+	if !c.Tenancy.Required {
+		ID, err := uuid.Parse(c.Tenancy.DefaultID)
+		if err != nil {
+			return nil, fmt.Errorf("TENANT_DEFAULT_ID: %w", err)
+		}
+		defaultTenantID = ID
+	}
+	opts = append(opts, httpjson.WithDefaultTenant(defaultTenantID))
+
+	// a verified client certificate takes precedence over the identity
+	// headers
+	var authenticators auth.Chain
+	if c.Server.TLSCertFile != "" && c.Server.TLSClientAuth != tlsconfig.ClientAuthNone {
+		authenticators = append(authenticators, auth.CertificateAuthenticator{})
+	}
+	if c.Auth.TrustHeaders {
+		authenticators = append(authenticators, auth.HeaderAuthenticator{})
+	}
+	if len(authenticators) > 0 {
+		opts = append(opts, httpjson.WithAuthenticator(authenticators))
+	}
+
+	return opts, nil
+}
+
+// purgeIdempotencyKeys periodically deletes the idempotency keys older than
+// the replay window until ctx is done.
+func purgeIdempotencyKeys(ctx context.Context, s idempotency.IdempotencyService, interval time.Duration, l *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		n, err := s.PurgeExpired(ctx)
+		if err != nil {
+			l.ErrorContext(ctx, "failed to purge expired idempotency keys", slog.Any("error", err))
+			continue
+		}
+		l.DebugContext(ctx, "purged expired idempotency keys", slog.Int64("count", n))
+	}
+}
+
+// startReservations periodically moves the devices of the reservations that
+// started to in_use, and reports the reservations that ended while their
+// device is still in use, until ctx is done.
+func startReservations(ctx context.Context, s reservation.ReservationService, interval time.Duration, l *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		rs, err := s.StartDue(ctx)
+		if err != nil {
+			l.ErrorContext(ctx, "failed to start reservations", slog.Any("error", err))
+		}
+		if len(rs) > 0 {
+			l.InfoContext(ctx, "started reservations", slog.Int("count", len(rs)))
+		}
+
+		rs, err = s.ReportOverdue(ctx)
+		if err != nil {
+			l.ErrorContext(ctx, "failed to report overdue reservations", slog.Any("error", err))
+		}
+		if len(rs) > 0 {
+			l.InfoContext(ctx, "reported overdue reservations", slog.Int("count", len(rs)))
+		}
+	}
+}
+
+// dispatchWaitlist periodically hands out the devices that became available
+// to the waiters, and expires the unclaimed offers, until ctx is done.
+func dispatchWaitlist(ctx context.Context, s waitlist.WaitlistService, interval time.Duration, l *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		evs, err := s.Dispatch(ctx)
+		if err != nil {
+			l.ErrorContext(ctx, "failed to dispatch waitlist", slog.Any("error", err))
+		}
+		if len(evs) > 0 {
+			l.DebugContext(ctx, "dispatched waitlist", slog.Int("events", len(evs)))
+		}
+	}
+}
+
+// cacheBroadcaster notifies the other replicas of device changes through
+// PostgreSQL NOTIFY.
+type cacheBroadcaster struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func (b cacheBroadcaster) Broadcast(ctx context.Context, ID uuid.UUID) {
+	if err := database.Notify(ctx, b.db, cacheInvalidationChannel, ID.String()); err != nil {
+		b.l.ErrorContext(ctx, "failed to broadcast device cache invalidation", slog.Any("error", err), slog.String("device_id", ID.String()))
+	}
+}
+
+// listenCacheInvalidations invalidates the devices changed by other replicas
+// until ctx is done, reconnecting with a capped exponential backoff when the
+// connection fails.
+func listenCacheInvalidations(ctx context.Context, db *sql.DB, cache *device.CachedRepository, l *slog.Logger) {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+
+	for {
+		err := database.Listen(ctx, db, cacheInvalidationChannel, func() {
+			// invalidations broadcast while not listening were missed
+			cache.Purge()
+			backoff = time.Second
+		}, func(payload string) {
+			ID, err := uuid.Parse(payload)
+			if err != nil {
+				l.WarnContext(ctx, "ignoring invalid device cache invalidation", slog.String("payload", payload))
+				return
+			}
+			cache.Invalidate(ID)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		l.ErrorContext(ctx, "device cache invalidation listener failed", slog.Any("error", err), slog.Duration("retry_in", backoff))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func setupDB(cfg *config.ConfDB, l *slog.Logger, opts ...database.OpenOption) (*gorm.DB, error) {
+	dialector, err := database.Dialector(cfg)
+	if err != nil {
 		return nil, err
 	}
 
-	return db, nil
+	return database.Open(dialector, &gorm.Config{
+		Logger: gormlogger.New(gormLogWriter{l}, gormlogger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormlogger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	}, opts...)
+}
+
+// gormLogWriter routes gorm's slow query and error logs to the structured logger.
+type gormLogWriter struct {
+	l *slog.Logger
+}
+
+func (w gormLogWriter) Printf(format string, args ...any) {
+	w.l.Warn(fmt.Sprintf(format, args...), slog.String("component", "gorm"))
 }
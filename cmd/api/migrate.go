@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"gorm.io/gorm"
+
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/database"
+	"github.com/hferr/device-manager/migrations"
+	"github.com/hferr/device-manager/utils/logger"
+)
+
+const migrateUsage = `usage: api migrate <command> [flags]
+
+Commands:
+  up       apply every pending migration
+  down     roll back the latest migration
+  redo     roll back the latest migration and apply it again
+  status   list the migrations and when they were applied
+  version  print the version of the database and of the latest migration
+  create   create an empty migration for every driver:
+           api migrate create [-dir migrations] <name>
+
+The flags of every command but create configure the database connection, as
+for the API.`
+
+// migrateCommands run a migrate command against the database, writing their
+// report to w.
+var migrateCommands = map[string]func(ctx context.Context, m *migrations.Migrator, w io.Writer) error{
+	"up": func(ctx context.Context, m *migrations.Migrator, w io.Writer) error {
+		results, err := m.Up(ctx)
+		printResults(w, results...)
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(w, "no migrations to apply")
+		}
+		return err
+	},
+	"down": func(ctx context.Context, m *migrations.Migrator, w io.Writer) error {
+		result, err := m.Down(ctx)
+		if result != nil {
+			printResults(w, result)
+		}
+		return err
+	},
+	"redo": func(ctx context.Context, m *migrations.Migrator, w io.Writer) error {
+		results, err := m.Redo(ctx)
+		printResults(w, results...)
+		return err
+	},
+	"status": func(ctx context.Context, m *migrations.Migrator, w io.Writer) error {
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "APPLIED AT\tMIGRATION")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.State == goose.StateApplied {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", appliedAt, s.Source.Path)
+		}
+		return tw.Flush()
+	},
+	"version": func(ctx context.Context, m *migrations.Migrator, w io.Writer) error {
+		current, latest, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "database version %d, latest migration %d\n", current, latest)
+		return nil
+	},
+}
+
+// runMigrate runs the migrate subcommands, returning the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return exitStartupFailure
+	}
+
+	if args[0] == "create" {
+		return runMigrateCreate(args[1:])
+	}
+
+	cmd, ok := migrateCommands[args[0]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return exitStartupFailure
+	}
+
+	db, code := openDB(args[1:], migrateUsage)
+	if db == nil {
+		return code
+	}
+
+	dbHandle, err := db.DB()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get database handle: %s\n", err)
+		return exitStartupFailure
+	}
+	defer dbHandle.Close()
+
+	m, err := migrations.NewMigrator(dbHandle, db.Dialector.Name())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load migrations: %s\n", err)
+		return exitStartupFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd(ctx, m, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s: %s\n", args[0], err)
+		return exitStartupFailure
+	}
+
+	return exitOK
+}
+
+func runMigrateCreate(args []string) int {
+	set := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+	dir := set.String("dir", "migrations", "directory holding the migrations of every driver")
+
+	if err := set.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitStartupFailure
+	}
+
+	if set.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return exitStartupFailure
+	}
+
+	paths, err := migrations.Create(*dir, set.Arg(0), time.Now())
+	for _, path := range paths {
+		fmt.Println("created", path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate create: %s\n", err)
+		return exitStartupFailure
+	}
+
+	return exitOK
+}
+
+func printResults(w io.Writer, results ...*goose.MigrationResult) {
+	for _, r := range results {
+		status := "OK"
+		if r.Error != nil {
+			status = "FAILED"
+		}
+		fmt.Fprintf(w, "%-6s %-4s %s (%s)\n", status, r.Direction, r.Source.Path, r.Duration.Round(time.Millisecond))
+	}
+}
+
+// openDB loads the configuration from args and connects to the database,
+// without migrating it, for the subcommands working on the database. On
+// failure, it reports the problem and returns a nil database along with the
+// exit code.
+func openDB(args []string, usage string) (*gorm.DB, int) {
+	c, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, usage)
+		return nil, exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err)
+		return nil, exitStartupFailure
+	}
+
+	if c.Storage.Backend != config.StorageBackendDatabase {
+		fmt.Fprintf(os.Stderr, "a database is required, set STORAGE_BACKEND=%s\n", config.StorageBackendDatabase)
+		return nil, exitStartupFailure
+	}
+
+	l, err := logger.New(os.Stderr, c.Log.Format, c.Log.Level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log configuration: %s\n", err)
+		return nil, exitStartupFailure
+	}
+
+	db, err := setupDB(&c.DB, l, database.WithoutMigrations())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to the database: %s\n", err)
+		return nil, exitStartupFailure
+	}
+
+	return db, exitOK
+}
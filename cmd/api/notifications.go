@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/internal/api/reservation"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+
+	"github.com/google/uuid"
+)
+
+// notificationOptions returns the options of the notification service for
+// the settings of c. The webhook channel is only enabled with
+// NOTIFICATION_WEBHOOK_ENABLED, the email channel with an SMTP host.
+func notificationOptions(c config.ConfNotification) ([]notification.ServiceOption, error) {
+	templates := notification.DefaultTemplates()
+	if c.TemplatesDir != "" {
+		var err error
+		if templates, err = notification.LoadTemplates(c.TemplatesDir); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := []notification.ServiceOption{
+		notification.WithTemplates(templates),
+		notification.WithDigestInterval(c.DigestInterval),
+	}
+
+	if c.WebhookEnabled {
+		opts = append(opts, notification.WithChannel(notification.ChannelWebhook, notification.NewWebhookSender(
+			notification.WithWebhookTimeout(c.Timeout),
+			notification.WithWebhookSecret(c.WebhookSecret),
+		)))
+	}
+
+	if c.SMTPHost != "" {
+		smtpOpts := []notification.SMTPOption{notification.WithSMTPTimeout(c.Timeout)}
+		if c.SMTPUsername != "" {
+			smtpOpts = append(smtpOpts, notification.WithSMTPAuth(c.SMTPUsername, c.SMTPPassword))
+		}
+
+		opts = append(opts, notification.WithChannel(notification.ChannelEmail,
+			notification.NewSMTPSender(c.SMTPHost, c.SMTPPort, c.SMTPFrom, smtpOpts...),
+		))
+	}
+
+	return opts, nil
+}
+
+// deliverNotifications periodically sends the queued notifications and the
+// digests that are due until ctx is done.
+func deliverNotifications(ctx context.Context, s notification.NotificationService, interval time.Duration, l *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		n, err := s.Deliver(ctx)
+		if err != nil {
+			l.ErrorContext(ctx, "failed to deliver notifications", slog.Any("error", err))
+		}
+		if n > 0 {
+			l.DebugContext(ctx, "delivered notifications", slog.Int("count", n))
+		}
+	}
+}
+
+// notifyWaitlistEvents returns the sink notifying the holders of the
+// waitlist entries of their events.
+func notifyWaitlistEvents(n notification.Notifier, l *slog.Logger) waitlist.EventSink {
+	return waitlist.EventSinkFunc(func(ctx context.Context, ev waitlist.Event) {
+		data := map[string]any{"entry_id": ev.Entry.ID.String()}
+		if ev.Entry.OfferedDeviceID != nil {
+			data["device_id"] = ev.Entry.OfferedDeviceID.String()
+		}
+		if ev.Entry.OfferExpiresAt != nil {
+			data["offer_expires_at"] = ev.Entry.OfferExpiresAt.UTC().Format(time.RFC3339)
+		}
+
+		err := n.Notify(ctx, notification.Event{Topic: ev.Type, Recipient: ev.Entry.Holder, Data: data})
+		if err != nil {
+			l.ErrorContext(ctx, "failed to notify of a waitlist event", slog.String("event", ev.Type), slog.Any("error", err))
+		}
+	})
+}
+
+// notifyReservationEvents returns the sink notifying the holders of the
+// reservations of their events.
+func notifyReservationEvents(n notification.Notifier, l *slog.Logger) reservation.EventSink {
+	return reservation.EventSinkFunc(func(ctx context.Context, ev reservation.Event) {
+		data := map[string]any{
+			"reservation_id": ev.Reservation.ID.String(),
+			"device_id":      ev.Reservation.DeviceID.String(),
+			"ends_at":        ev.Reservation.EndsAt.UTC().Format(time.RFC3339),
+		}
+		if ev.Reservation.Failure != "" {
+			data["failure"] = ev.Reservation.Failure
+		}
+
+		err := n.Notify(ctx, notification.Event{Topic: ev.Type, Recipient: ev.Reservation.Holder, Data: data})
+		if err != nil {
+			l.ErrorContext(ctx, "failed to notify of a reservation event", slog.String("event", ev.Type), slog.Any("error", err))
+		}
+	})
+}
+
+// deviceWatchers returns the holders of the reservations of a device that
+// have not ended, and of its pending waitlist entries.
+func deviceWatchers(rs reservation.ReservationService, ws waitlist.WaitlistService) notification.WatchersFunc {
+	return func(ctx context.Context, deviceID uuid.UUID) ([]string, error) {
+		reservations, err := rs.ListReservations(ctx, reservation.Filter{DeviceID: deviceID, From: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := ws.ListEntries(ctx, waitlist.Filter{DeviceID: deviceID})
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		var watchers []string
+		watch := func(holder string) {
+			if !seen[holder] {
+				seen[holder] = true
+				watchers = append(watchers, holder)
+			}
+		}
+
+		for _, r := range reservations {
+			watch(r.Holder)
+		}
+		for _, e := range entries {
+			if e.IsPending() {
+				watch(e.Holder)
+			}
+		}
+
+		return watchers, nil
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hferr/device-manager/internal/api/device"
+)
+
+const schemaUsage = `usage: api schema check [flags]
+
+Compares the devices table of the PostgreSQL database with the Device model,
+reporting every mismatch. The flags configure the database connection, as for
+the API.`
+
+// runSchema runs the schema subcommands, returning the exit code.
+func runSchema(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, schemaUsage)
+		return exitStartupFailure
+	}
+
+	db, code := openDB(args[1:], schemaUsage)
+	if db == nil {
+		return code
+	}
+
+	if dbHandle, err := db.DB(); err == nil {
+		defer dbHandle.Close()
+	}
+
+	err := device.VerifySchema(context.Background(), db)
+	if errors.Is(err, device.ErrSchemaDrift) {
+		// one mismatch per line
+		fmt.Fprintf(os.Stderr, "database schema does not match the model:\n  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		return exitStartupFailure
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to check the schema: %s\n", err)
+		return exitStartupFailure
+	}
+
+	fmt.Println("database schema matches the model")
+	return exitOK
+}
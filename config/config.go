@@ -1,37 +1,227 @@
 package config
 
 import (
-	"log"
 	"time"
+)
+
+const (
+	StorageBackendDatabase = "database"
+	StorageBackendMemory   = "memory"
 
-	"github.com/joeshaw/envdecode"
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+
+	SchemaCheckOff  = "off"
+	SchemaCheckWarn = "warn"
+	SchemaCheckFail = "fail"
 )
 
+// Conf is the configuration of the API. Every setting is named by its
+// environment variable, declared in the env tag of its field along with its
+// default and whether it holds a secret.
 type Conf struct {
-	Server ConfServer
-	DB     ConfDB
+	Server       ConfServer
+	Storage      ConfStorage
+	DB           ConfDB
+	Tenancy      ConfTenancy
+	Auth         ConfAuth
+	Idempotency  ConfIdempotency
+	Log          ConfLog
+	Metrics      ConfMetrics
+	Tracing      ConfTracing
+	Health       ConfHealth
+	Cache        ConfCache
+	Reservation  ConfReservation
+	Waitlist     ConfWaitlist
+	Notification ConfNotification
+	Approval     ConfApproval
+	Hook         ConfHook
+	Policy       ConfPolicy
+
+	// sources records where each setting was read from.
+	sources map[string]Source
 }
 
 type ConfServer struct {
-	Port         int           `env:"SERVER_PORT,required"`
-	TimeoutRead  time.Duration `env:"SERVER_TIMEOUT_READ,required"`
-	TimeoutWrite time.Duration `env:"SERVER_TIMEOUT_WRITE,required"`
-	TimeoutIdle  time.Duration `env:"SERVER_TIMEOUT_IDLE,required"`
+	Port         int           `env:"SERVER_PORT,default=8080"`
+	TimeoutRead  time.Duration `env:"SERVER_TIMEOUT_READ,default=3s"`
+	TimeoutWrite time.Duration `env:"SERVER_TIMEOUT_WRITE,default=5s"`
+	TimeoutIdle  time.Duration `env:"SERVER_TIMEOUT_IDLE,default=5s"`
+	// ShutdownTimeout bounds how long in-flight requests are drained, and then
+	// how long background workers are given to stop, on SIGINT/SIGTERM.
+	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT,default=30s"`
+	// ShutdownDelay keeps serving requests, with the health check failing, for
+	// this long after the shutdown signal before draining starts.
+	ShutdownDelay time.Duration `env:"SERVER_SHUTDOWN_DELAY,default=0s"`
+	// TLSCertFile and TLSKeyFile enable TLS. Both are read again, along with
+	// TLSClientCAFile, every TLSReloadInterval when they changed, so rotated
+	// certificates are served without a restart.
+	TLSCertFile       string        `env:"SERVER_TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"SERVER_TLS_KEY_FILE"`
+	TLSReloadInterval time.Duration `env:"SERVER_TLS_RELOAD_INTERVAL,default=1m"`
+	// TLSMinVersion is "1.2" or "1.3".
+	TLSMinVersion string `env:"SERVER_TLS_MIN_VERSION,default=1.2"`
+	// TLSCipherPolicy is "intermediate", only allowing ECDHE key exchanges
+	// with AEAD ciphers, "modern", only allowing TLS 1.3, or "default", Go's
+	// defaults.
+	TLSCipherPolicy string `env:"SERVER_TLS_CIPHER_POLICY,default=intermediate"`
+	// TLSClientAuth is "none", "optional" or "required". Client certificates
+	// are verified against the TLSClientCAFile bundle and identify the
+	// principal of the request.
+	TLSClientAuth   string `env:"SERVER_TLS_CLIENT_AUTH,default=none"`
+	TLSClientCAFile string `env:"SERVER_TLS_CLIENT_CA_FILE"`
+}
+
+type ConfStorage struct {
+	// Backend is "database", or "memory" to run without any dependency, in
+	// which case devices are lost on restart and organizations and idempotency
+	// keys are disabled.
+	Backend string `env:"STORAGE_BACKEND,default=database"`
 }
 
 type ConfDB struct {
-	Host     string `env:"DB_HOST,required"`
-	Port     int    `env:"DB_PORT,required"`
-	Username string `env:"DB_USER,required"`
-	Password string `env:"DB_PASS,required"`
-	DBName   string `env:"DB_NAME,required"`
+	// Driver is "postgres" or "sqlite". The connection settings below only
+	// apply to PostgreSQL, while SQLite stores the database in the Path file.
+	Driver           string `env:"DB_DRIVER,default=postgres"`
+	Path             string `env:"DB_PATH,default=device_manager.db"`
+	Host             string `env:"DB_HOST,default=localhost"`
+	Port             int    `env:"DB_PORT,default=5432"`
+	Username         string `env:"DB_USER,default=postgres"`
+	Password         string `env:"DB_PASS,secret"`
+	DBName           string `env:"DB_NAME,default=device_manager"`
+	RowLevelSecurity bool   `env:"DB_ROW_LEVEL_SECURITY,default=false"`
+	// AutoMigrate applies the pending migrations at startup. Without it they
+	// must be applied with the migrate command, and the readiness probe fails
+	// until they are.
+	AutoMigrate bool `env:"DB_AUTO_MIGRATE,default=true"`
+	// SchemaCheck compares the devices table with the Device model at startup,
+	// and either logs the mismatches or refuses to start. PostgreSQL only.
+	SchemaCheck string `env:"DB_SCHEMA_CHECK,default=warn"`
+}
+
+type ConfTenancy struct {
+	// DefaultID is the tenant used for requests that do not name one.
+	DefaultID string `env:"TENANT_DEFAULT_ID,default=00000000-0000-0000-0000-000000000001"`
+	// Required rejects requests that do not name a tenant instead of falling
+	// back to DefaultID.
+	Required bool `env:"TENANT_REQUIRED,default=false"`
+}
+
+type ConfAuth struct {
+	// TrustHeaders enables reading the principal from the X-Auth-* headers set
+	// by an authenticating reverse proxy.
+	TrustHeaders bool `env:"AUTH_TRUST_HEADERS,default=false"`
+}
+
+type ConfIdempotency struct {
+	// TTL is how long the response of a request made with an Idempotency-Key
+	// is replayed to retries.
+	TTL           time.Duration `env:"IDEMPOTENCY_TTL,default=24h"`
+	PurgeInterval time.Duration `env:"IDEMPOTENCY_PURGE_INTERVAL,default=1h"`
+	// MaxBodySize bounds, in bytes, the bodies of the requests made with an
+	// Idempotency-Key, which are buffered to be fingerprinted.
+	MaxBodySize int `env:"IDEMPOTENCY_MAX_BODY_SIZE,default=1048576"`
+}
+
+type ConfLog struct {
+	Format string `env:"LOG_FORMAT,default=json"`
+	Level  string `env:"LOG_LEVEL,default=info"`
+}
+
+type ConfMetrics struct {
+	Enabled bool `env:"METRICS_ENABLED,default=true"`
+	// InventoryInterval is how often the device inventory gauges are refreshed.
+	InventoryInterval time.Duration `env:"METRICS_INVENTORY_INTERVAL,default=1m"`
 }
 
-func New() *Conf {
-	var c Conf
-	if err := envdecode.StrictDecode(&c); err != nil {
-		log.Fatalf("Failed to decode: %s", err)
-	}
+type ConfTracing struct {
+	// Exporter is one of "none", "otlp" or "stdout".
+	Exporter string `env:"TRACING_EXPORTER,default=none"`
+	// OTLPEndpoint is the host:port of the OTLP/HTTP collector.
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT,default=localhost:4318"`
+	OTLPInsecure bool    `env:"TRACING_OTLP_INSECURE,default=false"`
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO,default=1"`
+	ServiceName  string  `env:"TRACING_SERVICE_NAME,default=device-manager"`
+}
+
+type ConfHealth struct {
+	// Timeout bounds each readiness check.
+	Timeout time.Duration `env:"HEALTH_CHECK_TIMEOUT,default=2s"`
+}
+
+type ConfCache struct {
+	// Enabled serves device lookups by ID from an in-process LRU cache.
+	Enabled bool          `env:"CACHE_ENABLED,default=false"`
+	Size    int           `env:"CACHE_SIZE,default=10000"`
+	TTL     time.Duration `env:"CACHE_TTL,default=30s"`
+	// Notify broadcasts invalidations to the other replicas through
+	// PostgreSQL LISTEN/NOTIFY. Without it a replica may serve a device
+	// changed by another one for up to TTL.
+	Notify bool `env:"CACHE_NOTIFY,default=false"`
+}
+
+type ConfReservation struct {
+	// StartInterval is how often the reservations that started are looked
+	// for, to move their devices to in_use.
+	StartInterval time.Duration `env:"RESERVATION_START_INTERVAL,default=30s"`
+}
+
+type ConfWaitlist struct {
+	// DispatchInterval is how often the devices that became available are
+	// handed out to the waiters, and the unclaimed offers expired.
+	DispatchInterval time.Duration `env:"WAITLIST_DISPATCH_INTERVAL,default=10s"`
+	// OfferTTL is how long waiters have to claim the devices offered to them.
+	OfferTTL time.Duration `env:"WAITLIST_OFFER_TTL,default=15m"`
+}
+
+type ConfNotification struct {
+	// DeliveryInterval is how often the queued notifications are sent.
+	DeliveryInterval time.Duration `env:"NOTIFICATION_DELIVERY_INTERVAL,default=10s"`
+	// DigestInterval is how long the notifications of the recipients asking
+	// for digests are batched.
+	DigestInterval time.Duration `env:"NOTIFICATION_DIGEST_INTERVAL,default=1h"`
+	// TemplatesDir holds templates overriding the built-in ones, named after
+	// their topic, such as waitlist.offered.tmpl.
+	TemplatesDir string `env:"NOTIFICATION_TEMPLATES_DIR"`
+	// Timeout bounds the time sending a notification takes.
+	Timeout time.Duration `env:"NOTIFICATION_TIMEOUT,default=10s"`
+	// SMTPHost enables the email channel, sending from SMTPFrom.
+	SMTPHost     string `env:"NOTIFICATION_SMTP_HOST"`
+	SMTPPort     int    `env:"NOTIFICATION_SMTP_PORT,default=587"`
+	SMTPFrom     string `env:"NOTIFICATION_SMTP_FROM,default=device-manager@localhost"`
+	SMTPUsername string `env:"NOTIFICATION_SMTP_USERNAME"`
+	SMTPPassword string `env:"NOTIFICATION_SMTP_PASSWORD,secret"`
+	// WebhookEnabled enables the webhook channel, posting the notifications
+	// to the URLs the recipients set, as long as they are public.
+	WebhookEnabled bool `env:"NOTIFICATION_WEBHOOK_ENABLED,default=false"`
+	// WebhookSecret signs the webhooks with an HMAC-SHA256 of their body.
+	WebhookSecret string `env:"NOTIFICATION_WEBHOOK_SECRET,secret"`
+}
+
+type ConfApproval struct {
+	// Policies is the comma-separated list of the device updates and
+	// deletions that require approval, e.g. delete,update:*->inactive. See
+	// approval.ParsePolicies for their syntax.
+	Policies string `env:"APPROVAL_POLICIES"`
+}
+
+type ConfHook struct {
+	// NamePattern is a regular expression the names of the devices created
+	// or renamed must match, any name by default.
+	NamePattern string `env:"HOOK_NAME_PATTERN"`
+	// WebhookURL is sent the changes of devices once stored, e.g. to sync
+	// them to another inventory.
+	WebhookURL string `env:"HOOK_WEBHOOK_URL"`
+	// Timeout bounds each run of the hooks.
+	Timeout time.Duration `env:"HOOK_TIMEOUT,default=5s"`
+	// QueueSize is how many changes can wait for their After hooks to run in
+	// the background, the hooks of the changes made beyond it are dropped.
+	QueueSize int `env:"HOOK_QUEUE_SIZE,default=1024"`
+}
 
-	return &c
+type ConfPolicy struct {
+	// File is a YAML file of CEL policies every change of a device is
+	// checked against, none by default. See policy.NewEngine for the
+	// variables of their expressions.
+	File string `env:"POLICY_FILE"`
 }
@@ -0,0 +1,281 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/config"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func setting(t *testing.T, c *config.Conf, name string) config.Setting {
+	t.Helper()
+
+	for _, s := range c.Settings() {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	t.Fatalf("expected setting %s", name)
+	return config.Setting{}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if c.Server.Port != 8080 || c.Server.TimeoutWrite != 5*time.Second || c.Storage.Backend != config.StorageBackendDatabase {
+		t.Fatalf("expected the defaults, got: %+v", c)
+	}
+
+	if s := setting(t, c, "SERVER_PORT"); s.Source != config.SourceDefault || s.Key != "server.port" {
+		t.Fatalf("expected server.port from the defaults, got: %+v", s)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	var testCases = map[string]struct {
+		file string
+		body string
+	}{
+		"yaml": {
+			file: "config.yaml",
+			body: "server:\n  port: 9000\n  timeout_read: 10s\nlog:\n  level: debug\ntracing:\n  sample_ratio: 0.5\n",
+		},
+		"toml": {
+			file: "config.toml",
+			body: "[server]\nport = 9000\ntimeout_read = \"10s\"\n\n[log]\nlevel = \"debug\"\n\n[tracing]\nsample_ratio = 0.5\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, tc.file, tc.body)
+
+			t.Setenv(config.EnvConfigFile, path)
+			t.Setenv("SERVER_PORT", "9100")
+			t.Setenv("LOG_FORMAT", "text")
+
+			c, err := config.Load([]string{"-server-port", "9200"})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// assert flags override the environment, which overrides the file
+			if c.Server.Port != 9200 {
+				t.Fatalf("expected port %d, got: %d", 9200, c.Server.Port)
+			}
+
+			if c.Log.Format != "text" || c.Log.Level != "debug" || c.Server.TimeoutRead != 10*time.Second || c.Tracing.SampleRatio != 0.5 {
+				t.Fatalf("expected the file and env settings, got: %+v", c)
+			}
+
+			for name, want := range map[string]config.Source{
+				"SERVER_PORT":         config.SourceFlag,
+				"LOG_FORMAT":          config.SourceEnv,
+				"LOG_LEVEL":           config.SourceFile,
+				"SERVER_TIMEOUT_IDLE": config.SourceDefault,
+			} {
+				if s := setting(t, c, name); s.Source != want {
+					t.Fatalf("expected %s from %s, got: %s", name, want, s.Source)
+				}
+			}
+		})
+	}
+
+	// assert the -config flag overrides CONFIG_FILE
+	t.Setenv(config.EnvConfigFile, filepath.Join(t.TempDir(), "missing.yaml"))
+
+	c, err := config.Load([]string{"-config", writeFile(t, "config.yml", "db:\n  driver: sqlite\n")})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if c.DB.Driver != config.DBDriverSQLite {
+		t.Fatalf("expected driver %q, got: %q", config.DBDriverSQLite, c.DB.Driver)
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	t.Setenv("DB_PASS_FILE", writeFile(t, "db_pass", "topsecretpassword\n"))
+
+	c, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if c.DB.Password != "topsecretpassword" {
+		t.Fatalf("expected the password of the secret file, got: %q", c.DB.Password)
+	}
+
+	// assert secrets are redacted from the settings
+	if s := setting(t, c, "DB_PASS"); s.Value == c.DB.Password || s.Source != config.SourceEnv {
+		t.Fatalf("expected a redacted password from env, got: %+v", s)
+	}
+
+	// assert a secret cannot be set twice in the same source
+	t.Setenv("DB_PASS", "other")
+
+	if _, err := config.Load(nil); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+func TestLoadProblems(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server:\n  port: 8080\n  colour: blue\nstorage: memory\n")
+
+	t.Setenv("SERVER_PORT", "eighty")
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("CACHE_ENABLED", "true")
+	t.Setenv("CACHE_TTL", "0s")
+	t.Setenv("HOOK_NAME_PATTERN", "[")
+	t.Setenv("HOOK_QUEUE_SIZE", "0")
+
+	_, err := config.Load([]string{"-config", path, "-tracing-sample-ratio", "2"})
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	// assert every problem is reported
+	for _, want := range []string{
+		"unknown setting server.colour",
+		"storage must be a section",
+		"SERVER_PORT: invalid value from env",
+		"LOG_LEVEL: must be one of",
+		"CACHE_TTL: must be positive",
+		"TRACING_SAMPLE_RATIO: must be between 0 and 1",
+		"HOOK_NAME_PATTERN: error parsing regexp",
+		"HOOK_QUEUE_SIZE: must be at least 1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error %q, got: %v", want, err)
+		}
+	}
+
+	// assert a value failing to decode is not reported twice
+	if strings.Contains(err.Error(), "SERVER_PORT: must be") {
+		t.Fatalf("expected SERVER_PORT to be reported once, got: %v", err)
+	}
+}
+
+func TestLoadFlags(t *testing.T) {
+	if _, err := config.Load([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("expected %v, got: %v", flag.ErrHelp, err)
+	}
+
+	if _, err := config.Load([]string{"-unknown"}); err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	if _, err := config.Load([]string{"serve"}); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var testCases = map[string]struct {
+		conf    func(c *config.Conf)
+		wantErr string
+	}{
+		"valid": {
+			conf: func(c *config.Conf) {},
+		},
+		"unknown storage backend": {
+			conf:    func(c *config.Conf) { c.Storage.Backend = "disk" },
+			wantErr: "STORAGE_BACKEND",
+		},
+		"row-level security with sqlite": {
+			conf: func(c *config.Conf) {
+				c.DB.Driver = config.DBDriverSQLite
+				c.DB.RowLevelSecurity = true
+			},
+			wantErr: "DB_ROW_LEVEL_SECURITY",
+		},
+		"unknown schema check": {
+			conf:    func(c *config.Conf) { c.DB.SchemaCheck = "strict" },
+			wantErr: "DB_SCHEMA_CHECK",
+		},
+		"cache notify without a database": {
+			conf: func(c *config.Conf) {
+				c.Storage.Backend = config.StorageBackendMemory
+				c.Cache.Enabled = true
+				c.Cache.Notify = true
+			},
+			wantErr: "CACHE_NOTIFY",
+		},
+		"tls key without a certificate": {
+			conf:    func(c *config.Conf) { c.Server.TLSKeyFile = "tls.key" },
+			wantErr: "SERVER_TLS_CERT_FILE",
+		},
+		"tls client auth without a CA bundle": {
+			conf: func(c *config.Conf) {
+				c.Server.TLSCertFile = "tls.crt"
+				c.Server.TLSKeyFile = "tls.key"
+				c.Server.TLSClientAuth = "required"
+			},
+			wantErr: "SERVER_TLS_CLIENT_CA_FILE",
+		},
+		"tls client auth without tls": {
+			conf: func(c *config.Conf) {
+				c.Server.TLSClientAuth = "optional"
+				c.Server.TLSClientCAFile = "ca.crt"
+			},
+			wantErr: "SERVER_TLS_CLIENT_AUTH",
+		},
+		"unknown tls version": {
+			conf: func(c *config.Conf) {
+				c.Server.TLSCertFile = "tls.crt"
+				c.Server.TLSKeyFile = "tls.key"
+				c.Server.TLSMinVersion = "1.1"
+			},
+			wantErr: "SERVER_TLS_MIN_VERSION",
+		},
+		"invalid default tenant": {
+			conf:    func(c *config.Conf) { c.Tenancy.DefaultID = "default" },
+			wantErr: "TENANT_DEFAULT_ID",
+		},
+		"invalid default tenant is ignored when a tenant is required": {
+			conf: func(c *config.Conf) {
+				c.Tenancy.DefaultID = ""
+				c.Tenancy.Required = true
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, err := config.Load(nil)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			tc.conf(c)
+
+			err = c.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("expected error %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigFile names the configuration file when the -config flag is not
+// given.
+const EnvConfigFile = "CONFIG_FILE"
+
+// secretFileSuffix is appended to the name of a secret setting to read its
+// value from a file instead, e.g. DB_PASS_FILE.
+const secretFileSuffix = "_FILE"
+
+// redacted replaces the value of secrets in Settings.
+const redacted = "[REDACTED]"
+
+// Source is where the value of a setting was read from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Setting is the value of a setting and where it was read from.
+type Setting struct {
+	// Name is the environment variable of the setting.
+	Name string `json:"name" example:"SERVER_PORT"`
+	// Key is the key of the setting in a configuration file.
+	Key    string `json:"key" example:"server.port"`
+	Value  string `json:"value" example:"8080"`
+	Source Source `json:"source,omitempty" example:"env"`
+}
+
+// field is a setting of Conf.
+type field struct {
+	name   string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// key returns the key of the setting in a configuration file: its name in
+// lower case, the first underscore separating the section from the key.
+func (f field) key() string {
+	return strings.Replace(strings.ToLower(f.name), "_", ".", 1)
+}
+
+// flagName returns the command-line flag of the setting.
+func (f field) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(f.name), "_", "-")
+}
+
+// fields returns the settings of c, in declaration order.
+func fields(c *Conf) []field {
+	var fs []field
+
+	sections := reflect.ValueOf(c).Elem()
+	for i := range sections.NumField() {
+		section := sections.Field(i)
+		if !sections.Type().Field(i).IsExported() || section.Kind() != reflect.Struct {
+			continue
+		}
+
+		for j := range section.NumField() {
+			tag, ok := section.Type().Field(j).Tag.Lookup("env")
+			if !ok {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(tag, ",")
+			f := field{name: name, value: section.Field(j)}
+			for _, opt := range strings.Split(opts, ",") {
+				switch {
+				case opt == "secret":
+					f.secret = true
+				case strings.HasPrefix(opt, "default="):
+					f.def = strings.TrimPrefix(opt, "default=")
+				}
+			}
+			fs = append(fs, f)
+		}
+	}
+
+	return fs
+}
+
+// layer holds the raw settings read from a source, by name.
+type layer struct {
+	source Source
+	values map[string]string
+}
+
+// Load reads the configuration from, in increasing order of precedence, the
+// defaults, the configuration file named by the -config flag or CONFIG_FILE,
+// the environment and the command-line flags in args. Secrets may also be
+// read from the file named by their _FILE variant, e.g. DB_PASS_FILE.
+//
+// Every problem found, from unknown file keys to invalid values, is reported
+// at once in the returned error, one per line. flag.ErrHelp is returned when
+// args ask for the usage.
+func Load(args []string) (*Conf, error) {
+	c := &Conf{sources: map[string]Source{}}
+	fs := fields(c)
+
+	flags, configFile, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	layers := []layer{{source: SourceDefault, values: map[string]string{}}}
+	for _, f := range fs {
+		layers[0].values[f.name] = f.def
+	}
+
+	if configFile == "" {
+		configFile = os.Getenv(EnvConfigFile)
+	}
+	if configFile != "" {
+		values, err := readFile(configFile, fs)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		layers = append(layers, layer{source: SourceFile, values: values})
+	}
+
+	env := map[string]string{}
+	for _, f := range fs {
+		for _, name := range names(f) {
+			if v, ok := os.LookupEnv(name); ok {
+				env[name] = v
+			}
+		}
+	}
+	layers = append(layers, layer{source: SourceEnv, values: env}, layer{source: SourceFlag, values: flags})
+
+	for _, f := range fs {
+		// decode the default first, so a setting failing to decode is not
+		// reported again by Validate
+		if err := decode(f, f.def); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid default %q: %w", f.name, f.def, err))
+			continue
+		}
+		c.sources[f.name] = SourceDefault
+
+		// only the layer of highest precedence setting a value is read, so
+		// the values it overrides are not reported
+		for _, l := range slices.Backward(layers[1:]) {
+			v, ok, err := resolve(f, l)
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			if !ok {
+				continue
+			}
+
+			if err := decode(f, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value from %s: %w", f.name, l.source, err))
+				break
+			}
+			c.sources[f.name] = l.source
+			break
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return c, errors.Join(errs...)
+}
+
+// names returns the names a setting may be given under.
+func names(f field) []string {
+	if f.secret {
+		return []string{f.name, f.name + secretFileSuffix}
+	}
+
+	return []string{f.name}
+}
+
+// resolve returns the value a layer gives to a setting, reading secrets from
+// the file named by their _FILE variant.
+func resolve(f field, l layer) (string, bool, error) {
+	v, ok := l.values[f.name]
+	if !f.secret {
+		return v, ok, nil
+	}
+
+	path, fromFile := l.values[f.name+secretFileSuffix]
+	if !fromFile {
+		return v, ok, nil
+	}
+	if ok {
+		return "", false, fmt.Errorf("%s: both %[1]s and %[1]s%s are set in %s", f.name, secretFileSuffix, l.source)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s%s: %w", f.name, secretFileSuffix, err)
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+// decode sets the field of a setting from its raw value.
+func decode(f field, v string) error {
+	switch {
+	case f.value.Type() == reflect.TypeOf(time.Duration(0)):
+		if v == "" {
+			v = "0s"
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(v)
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", v)
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		f.value.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+// parseFlags returns the settings given in args, by name, and the -config
+// flag.
+func parseFlags(fs []field, args []string) (map[string]string, string, error) {
+	set := flag.NewFlagSet("api", flag.ContinueOnError)
+
+	configFile := set.String("config", "", "path of the YAML or TOML configuration file, "+EnvConfigFile+" by default")
+
+	byFlag := map[string]string{}
+	for _, f := range fs {
+		byFlag[f.flagName()] = f.name
+		set.String(f.flagName(), "", fmt.Sprintf("%s (default %q)", f.name, f.def))
+
+		if f.secret {
+			byFlag[f.flagName()+"-file"] = f.name + secretFileSuffix
+			set.String(f.flagName()+"-file", "", fmt.Sprintf("%s%s, the file holding %[1]s", f.name, secretFileSuffix))
+		}
+	}
+
+	if err := set.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if set.NArg() > 0 {
+		return nil, "", fmt.Errorf("unexpected arguments: %s", strings.Join(set.Args(), " "))
+	}
+
+	values := map[string]string{}
+	set.Visit(func(fl *flag.Flag) {
+		if name, ok := byFlag[fl.Name]; ok {
+			values[name] = fl.Value.String()
+		}
+	})
+
+	return values, *configFile, nil
+}
+
+// readFile returns the settings of a YAML or TOML configuration file, by
+// name. Sections hold the settings sharing the first word of their name, e.g.
+// server.port is SERVER_PORT.
+func readFile(path string, fs []field) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("config file: unsupported format %q, use .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	known := map[string]bool{}
+	for _, f := range fs {
+		for _, name := range names(f) {
+			known[name] = true
+		}
+	}
+
+	var errs []error
+	values := map[string]string{}
+	for _, section := range slices.Sorted(maps.Keys(doc)) {
+		m, ok := doc[section].(map[string]any)
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file: %s must be a section", section))
+			continue
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			v := m[key]
+			name := strings.ToUpper(section + "_" + key)
+			if !known[name] {
+				errs = append(errs, fmt.Errorf("config file: unknown setting %s.%s", section, key))
+				continue
+			}
+
+			switch v.(type) {
+			case string, bool, int, int64, uint64, float64:
+				values[name] = fmt.Sprint(v)
+			default:
+				errs = append(errs, fmt.Errorf("config file: %s.%s must be a scalar", section, key))
+			}
+		}
+	}
+
+	return values, errors.Join(errs...)
+}
+
+// Settings returns every setting with its value and source, with the value
+// of secrets redacted.
+func (c *Conf) Settings() []Setting {
+	var settings []Setting
+	for _, f := range fields(c) {
+		v := fmt.Sprint(f.value.Interface())
+		if f.secret && v != "" {
+			v = redacted
+		}
+
+		settings = append(settings, Setting{
+			Name:   f.name,
+			Key:    f.key(),
+			Value:  v,
+			Source: c.sources[f.name],
+		})
+	}
+
+	return settings
+}
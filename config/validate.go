@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	logFormats        = []string{"json", "text"}
+	tracingExporters  = []string{"none", "otlp", "stdout"}
+	tlsVersions       = []string{"1.2", "1.3"}
+	tlsCipherPolicies = []string{"intermediate", "modern", "default"}
+	tlsClientAuths    = []string{"none", "optional", "required"}
+)
+
+// Validate reports every invalid setting, one per line.
+func (c *Conf) Validate() error {
+	var errs []error
+	problem := func(name, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+	oneOf := func(name, v string, allowed ...string) {
+		if !slices.Contains(allowed, v) {
+			problem(name, "must be one of %s, got %q", strings.Join(allowed, ", "), v)
+		}
+	}
+	positive := func(name string, d time.Duration) {
+		if d <= 0 {
+			problem(name, "must be positive, got %s", d)
+		}
+	}
+	notNegative := func(name string, d time.Duration) {
+		if d < 0 {
+			problem(name, "must not be negative, got %s", d)
+		}
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		problem("SERVER_PORT", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	notNegative("SERVER_TIMEOUT_READ", c.Server.TimeoutRead)
+	notNegative("SERVER_TIMEOUT_WRITE", c.Server.TimeoutWrite)
+	notNegative("SERVER_TIMEOUT_IDLE", c.Server.TimeoutIdle)
+	positive("SERVER_SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+	notNegative("SERVER_SHUTDOWN_DELAY", c.Server.ShutdownDelay)
+
+	switch {
+	case c.Server.TLSCertFile != "" && c.Server.TLSKeyFile == "":
+		problem("SERVER_TLS_KEY_FILE", "must be set along with SERVER_TLS_CERT_FILE")
+	case c.Server.TLSCertFile == "" && c.Server.TLSKeyFile != "":
+		problem("SERVER_TLS_CERT_FILE", "must be set along with SERVER_TLS_KEY_FILE")
+	case c.Server.TLSCertFile != "":
+		positive("SERVER_TLS_RELOAD_INTERVAL", c.Server.TLSReloadInterval)
+		oneOf("SERVER_TLS_MIN_VERSION", c.Server.TLSMinVersion, tlsVersions...)
+		oneOf("SERVER_TLS_CIPHER_POLICY", c.Server.TLSCipherPolicy, tlsCipherPolicies...)
+		oneOf("SERVER_TLS_CLIENT_AUTH", c.Server.TLSClientAuth, tlsClientAuths...)
+
+		if c.Server.TLSClientAuth != "none" && c.Server.TLSClientCAFile == "" {
+			problem("SERVER_TLS_CLIENT_CA_FILE", "must be set when SERVER_TLS_CLIENT_AUTH is %q", c.Server.TLSClientAuth)
+		}
+	case c.Server.TLSClientAuth != "none" || c.Server.TLSClientCAFile != "":
+		problem("SERVER_TLS_CLIENT_AUTH", "requires TLS, set SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE")
+	}
+
+	oneOf("STORAGE_BACKEND", c.Storage.Backend, StorageBackendDatabase, StorageBackendMemory)
+
+	if c.Storage.Backend == StorageBackendDatabase {
+		oneOf("DB_DRIVER", c.DB.Driver, DBDriverPostgres, DBDriverSQLite)
+
+		switch c.DB.Driver {
+		case DBDriverPostgres:
+			if c.DB.Port < 1 || c.DB.Port > 65535 {
+				problem("DB_PORT", "must be between 1 and 65535, got %d", c.DB.Port)
+			}
+		case DBDriverSQLite:
+			if c.DB.Path == "" {
+				problem("DB_PATH", "must be set with the %s driver", DBDriverSQLite)
+			}
+		}
+
+		oneOf("DB_SCHEMA_CHECK", c.DB.SchemaCheck, SchemaCheckOff, SchemaCheckWarn, SchemaCheckFail)
+
+		if c.DB.RowLevelSecurity && c.DB.Driver != DBDriverPostgres {
+			problem("DB_ROW_LEVEL_SECURITY", "requires the %s driver", DBDriverPostgres)
+		}
+	}
+
+	if !c.Tenancy.Required {
+		if _, err := uuid.Parse(c.Tenancy.DefaultID); err != nil {
+			problem("TENANT_DEFAULT_ID", "must be a UUID, got %q", c.Tenancy.DefaultID)
+		}
+	}
+
+	positive("IDEMPOTENCY_TTL", c.Idempotency.TTL)
+	positive("IDEMPOTENCY_PURGE_INTERVAL", c.Idempotency.PurgeInterval)
+	if c.Idempotency.MaxBodySize < 1 {
+		problem("IDEMPOTENCY_MAX_BODY_SIZE", "must be positive, got %d", c.Idempotency.MaxBodySize)
+	}
+
+	oneOf("LOG_FORMAT", strings.ToLower(c.Log.Format), logFormats...)
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		problem("LOG_LEVEL", "must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+
+	positive("METRICS_INVENTORY_INTERVAL", c.Metrics.InventoryInterval)
+
+	oneOf("TRACING_EXPORTER", c.Tracing.Exporter, tracingExporters...)
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problem("TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	positive("HEALTH_CHECK_TIMEOUT", c.Health.Timeout)
+
+	if c.Cache.Enabled {
+		if c.Cache.Size < 1 {
+			problem("CACHE_SIZE", "must be positive, got %d", c.Cache.Size)
+		}
+		positive("CACHE_TTL", c.Cache.TTL)
+
+		if c.Cache.Notify && (c.Storage.Backend != StorageBackendDatabase || c.DB.Driver != DBDriverPostgres) {
+			problem("CACHE_NOTIFY", "requires the %s driver", DBDriverPostgres)
+		}
+	}
+
+	positive("RESERVATION_START_INTERVAL", c.Reservation.StartInterval)
+	positive("WAITLIST_DISPATCH_INTERVAL", c.Waitlist.DispatchInterval)
+	positive("WAITLIST_OFFER_TTL", c.Waitlist.OfferTTL)
+
+	positive("NOTIFICATION_DELIVERY_INTERVAL", c.Notification.DeliveryInterval)
+	positive("NOTIFICATION_DIGEST_INTERVAL", c.Notification.DigestInterval)
+	positive("NOTIFICATION_TIMEOUT", c.Notification.Timeout)
+	if c.Notification.SMTPHost != "" && (c.Notification.SMTPPort < 1 || c.Notification.SMTPPort > 65535) {
+		problem("NOTIFICATION_SMTP_PORT", "must be between 1 and 65535, got %d", c.Notification.SMTPPort)
+	}
+
+	if _, err := regexp.Compile(c.Hook.NamePattern); err != nil {
+		problem("HOOK_NAME_PATTERN", "%v", err)
+	}
+	if c.Hook.WebhookURL != "" {
+		if u, err := url.Parse(c.Hook.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem("HOOK_WEBHOOK_URL", "must be an http or https URL, got %q", c.Hook.WebhookURL)
+		}
+	}
+	positive("HOOK_TIMEOUT", c.Hook.Timeout)
+	if c.Hook.QueueSize < 1 {
+		problem("HOOK_QUEUE_SIZE", "must be at least 1, got %d", c.Hook.QueueSize)
+	}
+
+	return errors.Join(errs...)
+}
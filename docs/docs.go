@@ -15,36 +15,77 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/devices": {
+        "/admin/config": {
             "get": {
-                "description": "Get a list of all devices in the system",
+                "description": "Get every setting of the running API with its value and source (default, file, env or flag), with secrets redacted, admin only",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "admin"
                 ],
-                "summary": "List all devices",
+                "summary": "Dump the configuration",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/device.DTO"
+                                "$ref": "#/definitions/config.Setting"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/organizations": {
+            "get": {
+                "description": "Get a list of all organizations (tenants), admin only",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List all organizations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/organization.DTO"
                             }
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new device in the system.",
+                "description": "Create a new organization (tenant), admin only",
                 "consumes": [
                     "application/json"
                 ],
@@ -52,17 +93,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "admin"
                 ],
-                "summary": "Create a new device",
+                "summary": "Create a new organization",
                 "parameters": [
                     {
-                        "description": "Create device request object",
-                        "name": "device",
+                        "type": "string",
+                        "description": "Key making retries of the request replay the original response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Create organization request object",
+                        "name": "organization",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/device.CreateDeviceRequest"
+                            "$ref": "#/definitions/organization.CreateOrganizationRequest"
                         }
                     }
                 ],
@@ -70,45 +117,63 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/device.DTO"
+                            "$ref": "#/definitions/organization.DTO"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
-                            "$ref": "#/definitions/err.Errors"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     }
                 }
             }
         },
-        "/devices/brand/{brand}": {
+        "/admin/organizations/{id}": {
             "get": {
-                "description": "Get all devices from a specific brand",
+                "description": "Get a single organization (tenant) by its ID, admin only",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "admin"
                 ],
-                "summary": "Find devices by brand",
+                "summary": "Get organization by ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Device brand",
-                        "name": "brand",
+                        "description": "Organization ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -117,45 +182,105 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/device.DTO"
+                            "$ref": "#/definitions/organization.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete an organization (tenant) by its ID, admin only. Organizations\nthat still own devices and the default organization cannot be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete an organization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     }
                 }
             }
         },
-        "/devices/state/{state}": {
+        "/admin/organizations/{id}/devices": {
             "get": {
-                "description": "Get all devices with a specific state",
+                "description": "Get all devices owned by the given organization (tenant), admin only",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "admin"
                 ],
-                "summary": "Find devices by state",
+                "summary": "List devices of an organization",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Device state",
-                        "name": "state",
+                        "description": "Organization ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -164,44 +289,121 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/device.DTO"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/device.DTO"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     }
                 }
             }
         },
-        "/devices/{id}": {
+        "/change-requests": {
             "get": {
-                "description": "Get a single device by its ID",
+                "description": "Get the change requests held back by approval policies, oldest first, optionally\nonly those of a device or with a status.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "approvals"
                 ],
-                "summary": "Get device by ID",
+                "summary": "List change requests",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
                     {
                         "type": "string",
                         "description": "Device ID",
+                        "name": "device_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "approved",
+                            "applied",
+                            "failed",
+                            "rejected"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/approval.DTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/change-requests/{id}": {
+            "get": {
+                "description": "Get a single change request by its ID, decided or not",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "approvals"
+                ],
+                "summary": "Get change request by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Change request ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -211,79 +413,115 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/device.DTO"
+                            "$ref": "#/definitions/approval.DTO"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a device by its ID",
+            }
+        },
+        "/change-requests/{id}/approve": {
+            "post": {
+                "description": "Approve a pending change request, applying its change to the device on behalf of\nits requester. A change that can no longer be applied fails the request, with the\nerror of the change. Requires the admin role, and someone other than the requester.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "approvals"
                 ],
-                "summary": "Delete a device",
+                "summary": "Approve a change request",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Device ID",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Change request ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Decision request object",
+                        "name": "decision",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/approval.DecisionRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/approval.DTO"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Update an existing device by its ID, only devices that are not in the\nstate 'in_use' can be updated.",
+            }
+        },
+        "/change-requests/{id}/reject": {
+            "post": {
+                "description": "Reject a pending change request, leaving the device as is. Requires the admin role.",
                 "consumes": [
                     "application/json"
                 ],
@@ -291,155 +529,2498 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "approvals"
                 ],
-                "summary": "Update a device",
+                "summary": "Reject a change request",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Device ID",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Change request ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Updated device request object",
-                        "name": "device",
+                        "description": "Decision request object",
+                        "name": "decision",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/device.UpdateDeviceRequest"
+                            "$ref": "#/definitions/approval.DecisionRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/approval.DTO"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
-                            "$ref": "#/definitions/err.Errors"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/err.Error"
+                            "$ref": "#/definitions/err.Problem"
                         }
                     }
                 }
             }
         },
-        "/health": {
+        "/devices": {
             "get": {
-                "description": "Endpoint to perform a health check on the system",
+                "description": "Get a list of all devices in the system",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
                 "tags": [
-                    "Health"
+                    "devices"
+                ],
+                "summary": "List all devices",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/device.DTO"
+                            }
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
                     }
                 }
-            }
-        }
+            },
+            "post": {
+                "description": "Create a new device in the system.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Create a new device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key making retries of the request replay the original response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Create device request object",
+                        "name": "device",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/device.CreateDeviceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/device.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/available": {
+            "get": {
+                "description": "Get the devices in assignable states without reservations overlapping the period from from to to",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "List available devices",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the period, RFC 3339",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period, RFC 3339",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/device.DTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/brand/{brand}": {
+            "get": {
+                "description": "Get all devices from a specific brand",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Find devices by brand",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device brand",
+                        "name": "brand",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/device.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/state/{state}": {
+            "get": {
+                "description": "Get all devices with a specific state",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Find devices by state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device state",
+                        "name": "state",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/device.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}": {
+            "get": {
+                "description": "Get a single device by its ID",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Get device by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/device.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a device by its ID. Allowed deletions matching an approval policy are\nnot applied but held in a pending change request, returned with 202 Accepted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Delete a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/approval.DTO"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update an existing device by its ID, only devices that are not in the\nstate 'in_use' can be updated. Allowed updates matching an approval policy are\nnot applied but held in a pending change request, returned with 202 Accepted.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/yaml",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Update a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated device request object",
+                        "name": "device",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/device.UpdateDeviceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/approval.DTO"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/live": {
+            "get": {
+                "description": "Reports whether the server is running. ` + "`" + `GET /health` + "`" + ` is kept as an alias.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Reports whether the server is ready to serve requests: the database is reachable and migrated, the background workers are running and the server is not shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications": {
+            "get": {
+                "description": "Get the log of the notifications, newest first, optionally only those of a\nrecipient, topic or status. Principals other than admins only get theirs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "List notifications",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient",
+                        "name": "recipient",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Topic",
+                        "name": "topic",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "queued",
+                            "sending",
+                            "sent",
+                            "failed",
+                            "skipped"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of notifications, 100 by default, up to 1000",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/notification.DTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/preferences/{recipient}": {
+            "get": {
+                "description": "Get how a recipient is notified. Principals other than admins only get theirs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Get notification preferences",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient",
+                        "name": "recipient",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/notification.PreferenceDTO"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace how a recipient is notified: by email and/or webhook, enabled by\nsetting their address, and right away or in digests. Principals other than\nadmins only update theirs, and anonymous callers none.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Update notification preferences",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient",
+                        "name": "recipient",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update preference request object",
+                        "name": "preference",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/notification.UpdatePreferenceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/notification.PreferenceDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/policies": {
+            "get": {
+                "description": "Get the policies every change of a device is checked against, in the order they\nare evaluated.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "policies"
+                ],
+                "summary": "List policies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/policy.DTO"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/policies/evaluate": {
+            "post": {
+                "description": "Dry-run the policies applying to a change of a device on behalf of the caller,\nwithout making it. Each policy reports whether it denies the change, a policy\nthat cannot be evaluated denying it. The rules of the device states are not\nchecked.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "policies"
+                ],
+                "summary": "Evaluate policies",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Evaluate request object",
+                        "name": "evaluation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/policy.EvaluateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/policy.Evaluation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations": {
+            "get": {
+                "description": "Get the reservations that are not cancelled, ordered by start, optionally\nonly those of a device or holder, or overlapping the period from from to to.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "List reservations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "device_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Holder",
+                        "name": "holder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the period, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period, RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/reservation.DTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Reserve a device for a period, which must not overlap the other reservations\nof the device. The holder defaults to the authenticated principal, and only\nadmins reserve devices for someone else. The device is moved to the state\n'in_use' when the reservation starts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "Reserve a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key making retries of the request replay the original response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Create reservation request object",
+                        "name": "reservation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/reservation.CreateReservationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/reservation.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}": {
+            "get": {
+                "description": "Get a single reservation by its ID, cancelled or not",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "Get reservation by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reservation.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}/cancel": {
+            "post": {
+                "description": "Cancel a reservation that has not ended, freeing the rest of its period.\nA device already moved to 'in_use' keeps its state. Only admins cancel the\nreservations of someone else.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "Cancel a reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reservation.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/states": {
+            "get": {
+                "description": "Get the states devices can be in, along with their rules",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "states"
+                ],
+                "summary": "List device states",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/devicestate.DTO"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a state devices can be in, admin only",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "states"
+                ],
+                "summary": "Create a device state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key making retries of the request replay the original response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Create state request object",
+                        "name": "state",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/devicestate.CreateStateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/devicestate.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/states/{name}": {
+            "get": {
+                "description": "Get a single device state by its name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "states"
+                ],
+                "summary": "Get device state by name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "State name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/devicestate.DTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a device state by its name, admin only. The built-in states\nand the states devices are still in cannot be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "states"
+                ],
+                "summary": "Delete a device state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "State name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update the description and rules of a device state, admin only.\nThe rules apply to the devices already in the state.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "states"
+                ],
+                "summary": "Update a device state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "State name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated state request object",
+                        "name": "state",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/devicestate.UpdateStateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/devicestate.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/waitlist": {
+            "get": {
+                "description": "Get the waitlist entries, first come first, optionally only those waiting\nfor a device, of a holder or with a status.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waitlist"
+                ],
+                "summary": "List waitlist entries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "device_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Holder",
+                        "name": "holder",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "waiting",
+                            "offered",
+                            "assigned",
+                            "expired",
+                            "cancelled"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/waitlist.DTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Wait for a device, by device_id, or for any device of a pool, by brand and\nname. When a device in an assignable state matches, it is offered to the\nfirst waiter, who must claim it before the offer expires, or assigned to it\nright away with auto_assign. The holder defaults to the authenticated principal,\nand only admins put someone else on a waitlist.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waitlist"
+                ],
+                "summary": "Join a waitlist",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key making retries of the request replay the original response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Join waitlist request object",
+                        "name": "entry",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/waitlist.CreateEntryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/waitlist.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/waitlist/{id}": {
+            "get": {
+                "description": "Get a single waitlist entry by its ID, pending or not",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waitlist"
+                ],
+                "summary": "Get waitlist entry by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Waitlist entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/waitlist.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/waitlist/{id}/cancel": {
+            "post": {
+                "description": "Cancel a pending waitlist entry, passing its offer, if any, on to the next waiter.\nOnly admins cancel the entries of someone else.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waitlist"
+                ],
+                "summary": "Leave a waitlist",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Waitlist entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/waitlist.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/waitlist/{id}/claim": {
+            "post": {
+                "description": "Claim the device offered to a waitlist entry, moving it to 'in_use'. When the\ndevice is no longer available, the entry goes back to waiting with its place.\nOnly admins claim the offers of someone else.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waitlist"
+                ],
+                "summary": "Claim a waitlist offer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant (organization) ID",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Waitlist entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/waitlist.DTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/err.Problem"
+                        }
+                    }
+                }
+            }
+        }
     },
     "definitions": {
+        "approval.DTO": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "decided_at": {
+                    "type": "string"
+                },
+                "decided_by": {
+                    "type": "string"
+                },
+                "device_id": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "operation": {
+                    "type": "string"
+                },
+                "payload": {
+                    "$ref": "#/definitions/device.UpdateDeviceRequest"
+                },
+                "policy": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "approval.DecisionRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "maxLength": 1024
+                }
+            }
+        },
+        "config.Setting": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "description": "Key is the key of the setting in a configuration file.",
+                    "type": "string",
+                    "example": "server.port"
+                },
+                "name": {
+                    "description": "Name is the environment variable of the setting.",
+                    "type": "string",
+                    "example": "SERVER_PORT"
+                },
+                "source": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.Source"
+                        }
+                    ],
+                    "example": "env"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "8080"
+                }
+            }
+        },
+        "config.Source": {
+            "type": "string",
+            "enum": [
+                "default",
+                "file",
+                "env",
+                "flag"
+            ],
+            "x-enum-varnames": [
+                "SourceDefault",
+                "SourceFile",
+                "SourceEnv",
+                "SourceFlag"
+            ]
+        },
         "device.CreateDeviceRequest": {
             "type": "object",
-            "required": [
-                "brand",
-                "name",
-                "state"
-            ],
+            "required": [
+                "brand",
+                "name",
+                "state"
+            ],
+            "properties": {
+                "brand": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "state": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "device.DTO": {
+            "type": "object",
+            "properties": {
+                "brand": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "device.UpdateDeviceRequest": {
+            "type": "object",
+            "properties": {
+                "brand": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "devicestate.CreateStateRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "blocks_delete": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "is_assignable": {
+                    "type": "boolean"
+                },
+                "is_terminal": {
+                    "type": "boolean"
+                },
+                "locks_fields": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "devicestate.DTO": {
+            "type": "object",
+            "properties": {
+                "blocks_delete": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "is_assignable": {
+                    "type": "boolean"
+                },
+                "is_terminal": {
+                    "type": "boolean"
+                },
+                "locks_fields": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "devicestate.UpdateStateRequest": {
+            "type": "object",
             "properties": {
-                "brand": {
+                "blocks_delete": {
+                    "type": "boolean"
+                },
+                "description": {
                     "type": "string",
                     "maxLength": 255
                 },
-                "name": {
+                "is_assignable": {
+                    "type": "boolean"
+                },
+                "is_terminal": {
+                    "type": "boolean"
+                },
+                "locks_fields": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "err.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
                     "type": "string",
-                    "maxLength": 255
+                    "example": "device not found"
                 },
-                "state": {
+                "errors": {
+                    "description": "Errors lists the invalid fields of a request failing validation.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validator.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "Instance is the path of the request the problem occurred on.",
                     "type": "string",
-                    "enum": [
-                        "available",
-                        "in_use",
-                        "inactive"
-                    ]
+                    "example": "/devices/8f7e3a52-5d1c-4b8a-9f0e-6b1f2a3c4d5e"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "3b1f0c2e-7a4d-4e8b-9c6f-1d2e3f4a5b6c"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Device not found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/device-not-found"
                 }
             }
         },
-        "device.DTO": {
+        "health.Report": {
             "type": "object",
             "properties": {
-                "brand": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.Result"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "up"
+                }
+            }
+        },
+        "health.Result": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "context deadline exceeded"
+                },
+                "latency_ms": {
+                    "type": "number",
+                    "example": 1.25
+                },
+                "status": {
+                    "type": "string",
+                    "example": "up"
+                }
+            }
+        },
+        "notification.DTO": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "body": {
                     "type": "string"
                 },
+                "channel": {
+                    "type": "string",
+                    "enum": [
+                        "email",
+                        "webhook"
+                    ]
+                },
                 "created_at": {
                     "type": "string"
                 },
+                "digest": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "recipient": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "queued",
+                        "sending",
+                        "sent",
+                        "failed",
+                        "skipped"
+                    ]
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "topic": {
+                    "type": "string",
+                    "example": "waitlist.offered"
+                }
+            }
+        },
+        "notification.PreferenceDTO": {
+            "type": "object",
+            "properties": {
+                "digest": {
+                    "type": "boolean"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "webhook_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "notification.UpdatePreferenceRequest": {
+            "type": "object",
+            "properties": {
+                "digest": {
+                    "type": "boolean"
+                },
+                "email": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "alice@example.com"
+                },
+                "webhook_url": {
+                    "type": "string",
+                    "maxLength": 2048,
+                    "example": "https://hooks.example.com/devices"
+                }
+            }
+        },
+        "organization.CreateOrganizationRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
                 "name": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "organization.DTO": {
+            "type": "object",
+            "properties": {
+                "created_at": {
                     "type": "string"
                 },
-                "state": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
                     "type": "string"
                 }
             }
         },
-        "device.UpdateDeviceRequest": {
+        "policy.DTO": {
             "type": "object",
             "properties": {
-                "brand": {
+                "deny": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "message": {
                     "type": "string"
                 },
                 "name": {
                     "type": "string"
                 },
-                "state": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "policy.EvaluateRequest": {
+            "type": "object",
+            "required": [
+                "operation"
+            ],
+            "properties": {
+                "change": {
+                    "description": "Change is the update requested, or the fields of the device created.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/device.UpdateDeviceRequest"
+                        }
+                    ]
+                },
+                "device_id": {
+                    "description": "DeviceID is the device updated or deleted.",
+                    "type": "string"
+                },
+                "operation": {
                     "type": "string",
                     "enum": [
-                        "available",
-                        "in_use",
-                        "inactive"
+                        "create",
+                        "update",
+                        "delete"
                     ]
                 }
             }
         },
-        "err.Error": {
+        "policy.Evaluation": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "type": "boolean"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/policy.Result"
+                    }
+                }
+            }
+        },
+        "policy.Result": {
             "type": "object",
             "properties": {
+                "denied": {
+                    "type": "boolean"
+                },
                 "error": {
                     "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "policy": {
+                    "type": "string"
                 }
             }
         },
-        "err.Errors": {
+        "reservation.CreateReservationRequest": {
             "type": "object",
+            "required": [
+                "device_id",
+                "ends_at",
+                "holder",
+                "starts_at"
+            ],
             "properties": {
-                "errors": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "device_id": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string",
+                    "example": "2026-10-20T17:00:00Z"
+                },
+                "holder": {
+                    "description": "Holder defaults to the subject of the authenticated principal, and\nonly admins set another one.",
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "starts_at": {
+                    "type": "string",
+                    "example": "2026-10-20T09:00:00Z"
+                }
+            }
+        },
+        "reservation.DTO": {
+            "type": "object",
+            "properties": {
+                "cancelled_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "device_id": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string",
+                    "example": "2026-10-20T17:00:00Z"
+                },
+                "failure": {
+                    "type": "string",
+                    "example": "the device is in use by someone else"
+                },
+                "holder": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "starts_at": {
+                    "type": "string",
+                    "example": "2026-10-20T09:00:00Z"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "upcoming",
+                        "active",
+                        "ended",
+                        "cancelled",
+                        "failed"
+                    ]
+                },
+                "tenant_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "validator.FieldError": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "state must be one of the device states, see /states"
+                },
+                "param": {
+                    "description": "Param is the parameter of the rule, e.g. the maximum length of max.",
+                    "type": "string"
+                },
+                "pointer": {
+                    "description": "Pointer is the RFC 6901 JSON pointer to the field in the request body.",
+                    "type": "string",
+                    "example": "/state"
+                },
+                "rule": {
+                    "description": "Rule is the validation rule the field broke, e.g. required or\ndevice_state.",
+                    "type": "string",
+                    "example": "device_state"
+                }
+            }
+        },
+        "waitlist.CreateEntryRequest": {
+            "type": "object",
+            "required": [
+                "holder"
+            ],
+            "properties": {
+                "auto_assign": {
+                    "type": "boolean"
+                },
+                "brand": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Google"
+                },
+                "device_id": {
+                    "description": "DeviceID is the device to wait for. Without it, Brand and Name select\nthe pool of devices to wait for.",
+                    "type": "string"
+                },
+                "holder": {
+                    "description": "Holder defaults to the subject of the authenticated principal, and\nonly admins set another one.",
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Pixel"
+                }
+            }
+        },
+        "waitlist.DTO": {
+            "type": "object",
+            "properties": {
+                "auto_assign": {
+                    "type": "boolean"
+                },
+                "brand": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "device_id": {
+                    "type": "string"
+                },
+                "holder": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "offer_expires_at": {
+                    "type": "string",
+                    "example": "2026-10-20T09:15:00Z"
+                },
+                "offered_device_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "waiting",
+                        "offered",
+                        "assigned",
+                        "expired",
+                        "cancelled"
+                    ]
+                },
+                "tenant_id": {
+                    "type": "string"
                 }
             }
         }
@@ -0,0 +1,35 @@
+package approval
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound = errors.New("change request not found")
+	// ErrNotPending is returned when deciding on a change request that was
+	// already approved or rejected.
+	ErrNotPending = errors.New("change request is no longer pending")
+	// ErrSelfApproval is returned when the requester of a change tries to
+	// approve it.
+	ErrSelfApproval = errors.New("change requests cannot be approved by their requester")
+	// ErrInvalidPolicy is returned for policies that cannot be parsed.
+	ErrInvalidPolicy = errors.New("invalid approval policy")
+	// ErrApprovalRequired reports a change held back until approved, see
+	// PendingError.
+	ErrApprovalRequired = errors.New("device change requires approval")
+)
+
+// PendingError is returned for the changes of devices held back by a policy,
+// along with their pending change request. It matches ErrApprovalRequired.
+type PendingError struct {
+	ChangeRequest *ChangeRequest
+}
+
+func (e *PendingError) Error() string {
+	return fmt.Sprintf("%v: change request %s is pending", ErrApprovalRequired, e.ChangeRequest.ID)
+}
+
+func (e *PendingError) Is(target error) bool {
+	return target == ErrApprovalRequired
+}
@@ -0,0 +1,112 @@
+package approval
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// memoryRepository is a ChangeRequestRepository keeping change requests in
+// memory. Requests are copied in and out, so callers never share them with
+// the store.
+type memoryRepository struct {
+	mu       sync.RWMutex
+	requests map[uuid.UUID]*ChangeRequest
+}
+
+// NewMemoryRepository returns a thread-safe in-memory ChangeRequestRepository.
+func NewMemoryRepository() ChangeRequestRepository {
+	return &memoryRepository{
+		requests: make(map[uuid.UUID]*ChangeRequest),
+	}
+}
+
+func (r *memoryRepository) InsertChangeRequest(ctx context.Context, cr *ChangeRequest) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cr.TenantID = tenantID
+	stored := *cr
+	r.requests[stored.ID] = &stored
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateChangeRequest(ctx context.Context, cr *ChangeRequest, from string) (bool, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.requests[cr.ID]
+	if !ok || stored.TenantID != tenantID || stored.Status != from {
+		return false, nil
+	}
+
+	stored.Status = cr.Status
+	stored.DecidedBy = cr.DecidedBy
+	stored.Reason = cr.Reason
+	stored.Error = cr.Error
+	stored.DecidedAt = cr.DecidedAt
+
+	return true, nil
+}
+
+func (r *memoryRepository) ListChangeRequests(ctx context.Context, f Filter) (ChangeRequests, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	crs := make(ChangeRequests, 0)
+	for _, cr := range r.requests {
+		if cr.TenantID == tenantID &&
+			(f.DeviceID == uuid.Nil || cr.DeviceID == f.DeviceID) &&
+			(f.Status == "" || cr.Status == f.Status) {
+			found := *cr
+			crs = append(crs, &found)
+		}
+	}
+
+	slices.SortFunc(crs, func(a, b *ChangeRequest) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.ID[:], b.ID[:])
+	})
+
+	return crs, nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, ID uuid.UUID) (*ChangeRequest, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cr, ok := r.requests[ID]
+	if !ok || cr.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+
+	found := *cr
+	return &found, nil
+}
@@ -0,0 +1,147 @@
+package approval
+
+import (
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+
+	"github.com/google/uuid"
+)
+
+// Operations on devices that policies may require approval for.
+const (
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// Statuses of a change request.
+const (
+	// StatusPending requests wait for an approver.
+	StatusPending = "pending"
+	// StatusApproved requests were approved, and are being applied.
+	StatusApproved = "approved"
+	// StatusApplied requests were approved and their change applied.
+	StatusApplied = "applied"
+	// StatusFailed requests were approved but their change could not be
+	// applied, e.g. because the device changed since.
+	StatusFailed   = "failed"
+	StatusRejected = "rejected"
+)
+
+// ChangeRequest is a change to a device held back by a policy until an
+// approver approves or rejects it.
+type ChangeRequest struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	TenantID  uuid.UUID
+	DeviceID  uuid.UUID
+	Operation string
+	// Payload is the requested update, nil for deletions.
+	Payload *device.UpdateDeviceRequest `gorm:"serializer:json"`
+	// Policy is the policy that required approval.
+	Policy      string
+	RequestedBy string
+	// Requester is the principal the change was requested by, nil for
+	// anonymous requests. Approved changes are applied on its behalf.
+	Requester *auth.Principal `gorm:"serializer:json"`
+	Status    string
+	DecidedBy string
+	Reason    string
+	// Error is why an approved change could not be applied.
+	Error     string
+	CreatedAt time.Time
+	DecidedAt *time.Time
+}
+
+func (ChangeRequest) TableName() string {
+	return "change_requests"
+}
+
+type ChangeRequests []*ChangeRequest
+
+type DTO struct {
+	ID          uuid.UUID                   `json:"id"`
+	TenantID    uuid.UUID                   `json:"tenant_id"`
+	DeviceID    uuid.UUID                   `json:"device_id"`
+	Operation   string                      `json:"operation"`
+	Payload     *device.UpdateDeviceRequest `json:"payload,omitempty"`
+	Policy      string                      `json:"policy"`
+	RequestedBy string                      `json:"requested_by,omitempty"`
+	Status      string                      `json:"status"`
+	DecidedBy   string                      `json:"decided_by,omitempty"`
+	Reason      string                      `json:"reason,omitempty"`
+	Error       string                      `json:"error,omitempty"`
+	CreatedAt   string                      `json:"created_at"`
+	DecidedAt   *string                     `json:"decided_at,omitempty"`
+}
+
+// DecisionRequest approves or rejects a change request.
+type DecisionRequest struct {
+	Reason string `json:"reason" validate:"max=1024"`
+}
+
+// Filter selects change requests, an empty field matching every request.
+type Filter struct {
+	DeviceID uuid.UUID
+	Status   string
+}
+
+func newChangeRequest(deviceID uuid.UUID, operation string, payload *device.UpdateDeviceRequest, p Policy, requester *auth.Principal, now time.Time) *ChangeRequest {
+	cr := &ChangeRequest{
+		ID:        uuid.New(),
+		DeviceID:  deviceID,
+		Operation: operation,
+		Payload:   payload,
+		Policy:    p.String(),
+		Requester: requester,
+		Status:    StatusPending,
+		CreatedAt: now,
+	}
+
+	if requester != nil {
+		cr.RequestedBy = requester.Subject
+	}
+
+	return cr
+}
+
+// decide records the decision of approver on the request.
+func (cr *ChangeRequest) decide(status, approver, reason string, at time.Time) {
+	cr.Status = status
+	cr.DecidedBy = approver
+	cr.Reason = reason
+	cr.DecidedAt = &at
+}
+
+func (cr *ChangeRequest) ToDto() *DTO {
+	dto := &DTO{
+		ID:          cr.ID,
+		TenantID:    cr.TenantID,
+		DeviceID:    cr.DeviceID,
+		Operation:   cr.Operation,
+		Payload:     cr.Payload,
+		Policy:      cr.Policy,
+		RequestedBy: cr.RequestedBy,
+		Status:      cr.Status,
+		DecidedBy:   cr.DecidedBy,
+		Reason:      cr.Reason,
+		Error:       cr.Error,
+		CreatedAt:   cr.CreatedAt.Format(time.DateTime),
+	}
+
+	if cr.DecidedAt != nil {
+		decidedAt := cr.DecidedAt.Format(time.DateTime)
+		dto.DecidedAt = &decidedAt
+	}
+
+	return dto
+}
+
+func (crs ChangeRequests) ToDto() []*DTO {
+	dtos := make([]*DTO, len(crs))
+	for i, v := range crs {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
@@ -0,0 +1,105 @@
+package approval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hferr/device-manager/internal/api/device"
+)
+
+// AnyState matches every state in a policy.
+const AnyState = "*"
+
+// Policy requires approval for the device operations it matches.
+type Policy struct {
+	Operation string
+	// From and To are the states of the transitions matched by update
+	// policies, or From the state of the devices matched by delete policies.
+	// Update policies without states match every update, even those leaving
+	// the state as is.
+	From string
+	To   string
+}
+
+// ParsePolicies parses a comma-separated list of policies, each of them
+// either:
+//
+//   - delete, matching every deletion, or delete:<state>, matching the
+//     deletion of the devices in state;
+//   - update, matching every update, or update:<from>-><to>, matching the
+//     updates moving devices from state from to state to.
+//
+// States may be *, matching every state, e.g. update:*->inactive.
+func ParsePolicies(s string) ([]Policy, error) {
+	var policies []Policy
+	for _, text := range strings.Split(s, ",") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		p, err := parsePolicy(text)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+
+	return policies, nil
+}
+
+func parsePolicy(text string) (Policy, error) {
+	operation, states, hasStates := strings.Cut(text, ":")
+
+	p := Policy{Operation: operation}
+	switch {
+	case operation != OperationUpdate && operation != OperationDelete:
+		return Policy{}, fmt.Errorf("%w %q: the operation must be %s or %s", ErrInvalidPolicy, text, OperationUpdate, OperationDelete)
+	case !hasStates:
+		return p, nil
+	case operation == OperationDelete:
+		p.From = states
+	default:
+		var ok bool
+		if p.From, p.To, ok = strings.Cut(states, "->"); !ok {
+			return Policy{}, fmt.Errorf("%w %q: the transition must be <from>-><to>", ErrInvalidPolicy, text)
+		}
+	}
+
+	if p.From == "" || (operation == OperationUpdate && p.To == "") {
+		return Policy{}, fmt.Errorf("%w %q: the states must not be empty", ErrInvalidPolicy, text)
+	}
+
+	return p, nil
+}
+
+// String returns the policy as parsed by ParsePolicies.
+func (p Policy) String() string {
+	switch {
+	case p.From == "":
+		return p.Operation
+	case p.Operation == OperationDelete:
+		return p.Operation + ":" + p.From
+	default:
+		return p.Operation + ":" + p.From + "->" + p.To
+	}
+}
+
+// matches reports whether the policy requires approval for operation on d,
+// applying input for updates.
+func (p Policy) matches(operation string, d *device.Device, input *device.UpdateDeviceRequest) bool {
+	if p.Operation != operation {
+		return false
+	}
+
+	if operation == OperationDelete || p.From == "" {
+		return matchesState(p.From, d.State)
+	}
+
+	return input.State != nil && *input.State != d.State &&
+		matchesState(p.From, d.State) && matchesState(p.To, *input.State)
+}
+
+func matchesState(pattern, state string) bool {
+	return pattern == "" || pattern == AnyState || pattern == state
+}
@@ -0,0 +1,68 @@
+package approval_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/approval"
+)
+
+func TestParsePolicies(t *testing.T) {
+	var testCases = map[string]struct {
+		want    []approval.Policy
+		wantErr error
+		input   string
+	}{
+		"no policy": {},
+		"every operation": {
+			want:  []approval.Policy{{Operation: approval.OperationDelete}, {Operation: approval.OperationUpdate}},
+			input: "delete, update",
+		},
+		"states": {
+			want: []approval.Policy{
+				{Operation: approval.OperationDelete, From: "in_use"},
+				{Operation: approval.OperationUpdate, From: approval.AnyState, To: "inactive"},
+			},
+			input: "delete:in_use,update:*->inactive,",
+		},
+		"unknown operation": {
+			wantErr: approval.ErrInvalidPolicy,
+			input:   "create",
+		},
+		"update without transition": {
+			wantErr: approval.ErrInvalidPolicy,
+			input:   "update:inactive",
+		},
+		"empty state": {
+			wantErr: approval.ErrInvalidPolicy,
+			input:   "update:available->",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policies, err := approval.ParsePolicies(tc.input)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if !slices.Equal(policies, tc.want) {
+				t.Fatalf("expected policies %v, got: %v", tc.want, policies)
+			}
+
+			// assert policies are printed back as parsed
+
+			for _, p := range policies {
+				parsed, err := approval.ParsePolicies(p.String())
+				if err != nil || len(parsed) != 1 || parsed[0] != p {
+					t.Fatalf("expected %q to parse back to %+v, got: %v, %v", p, p, parsed, err)
+				}
+			}
+		})
+	}
+}
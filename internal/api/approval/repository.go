@@ -0,0 +1,102 @@
+package approval
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChangeRequestRepository interface {
+	InsertChangeRequest(ctx context.Context, cr *ChangeRequest) error
+	// UpdateChangeRequest updates the status, decision and error of a change
+	// request, provided it still has the status from. It reports whether it
+	// did, so that a request is decided on once.
+	UpdateChangeRequest(ctx context.Context, cr *ChangeRequest, from string) (bool, error)
+	// ListChangeRequests returns the change requests matching f, oldest
+	// first.
+	ListChangeRequests(ctx context.Context, f Filter) (ChangeRequests, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*ChangeRequest, error)
+}
+
+type changeRequestRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository expects db to translate driver errors, see database.Open.
+func NewRepository(db *gorm.DB) ChangeRequestRepository {
+	return &changeRequestRepository{
+		db: db,
+	}
+}
+
+func (r *changeRequestRepository) InsertChangeRequest(ctx context.Context, cr *ChangeRequest) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	cr.TenantID = tenantID
+	return r.db.WithContext(ctx).Create(cr).Error
+}
+
+func (r *changeRequestRepository) UpdateChangeRequest(ctx context.Context, cr *ChangeRequest, from string) (bool, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	res := r.db.WithContext(ctx).
+		Model(&ChangeRequest{}).
+		Select("status", "decided_by", "reason", "error", "decided_at").
+		Where("id = ? AND tenant_id = ? AND status = ?", cr.ID, tenantID, from).
+		Updates(cr)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func (r *changeRequestRepository) ListChangeRequests(ctx context.Context, f Filter) (ChangeRequests, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.db.WithContext(ctx).Where("tenant_id = ?", tenantID)
+	if f.DeviceID != uuid.Nil {
+		q = q.Where("device_id = ?", f.DeviceID)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+
+	crs := make(ChangeRequests, 0)
+	if err := q.Order("created_at, id").Find(&crs).Error; err != nil {
+		return nil, err
+	}
+
+	return crs, nil
+}
+
+func (r *changeRequestRepository) FindByID(ctx context.Context, ID uuid.UUID) (*ChangeRequest, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &ChangeRequest{}
+	err = r.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", ID, tenantID).First(cr).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
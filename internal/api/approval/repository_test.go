@@ -0,0 +1,159 @@
+package approval_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/approval"
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/test"
+
+	"github.com/google/uuid"
+)
+
+// repositories runs fn against the gorm repository of every driver and the
+// memory repository.
+func repositories(t *testing.T, fn func(t *testing.T, repo approval.ChangeRequestRepository)) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			fn(t, approval.NewRepository(db))
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		fn(t, approval.NewMemoryRepository())
+	})
+}
+
+func tenantCtx() context.Context {
+	return organization.WithTenant(context.Background(), organization.DefaultID)
+}
+
+// at returns the time m minutes after a fixed reference time.
+func at(m int) time.Time {
+	return time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC).Add(time.Duration(m) * time.Minute)
+}
+
+func newChangeRequest(deviceID uuid.UUID, payload *device.UpdateDeviceRequest, m int) *approval.ChangeRequest {
+	operation := approval.OperationDelete
+	if payload != nil {
+		operation = approval.OperationUpdate
+	}
+
+	return &approval.ChangeRequest{
+		ID:          uuid.New(),
+		DeviceID:    deviceID,
+		Operation:   operation,
+		Payload:     payload,
+		Policy:      operation,
+		RequestedBy: "alice",
+		Requester:   &auth.Principal{Subject: "alice", Role: auth.RoleUser},
+		Status:      approval.StatusPending,
+		CreatedAt:   at(m),
+	}
+}
+
+func TestRepositoryChangeRequests(t *testing.T) {
+	repositories(t, func(t *testing.T, repo approval.ChangeRequestRepository) {
+		ctx := tenantCtx()
+		deviceID := uuid.New()
+
+		inactive := device.StateInactive
+		update, deletion, other := newChangeRequest(deviceID, &device.UpdateDeviceRequest{State: &inactive}, 1), newChangeRequest(deviceID, nil, 2), newChangeRequest(uuid.New(), nil, 3)
+		for _, cr := range []*approval.ChangeRequest{update, deletion, other} {
+			if err := repo.InsertChangeRequest(ctx, cr); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		// assert the payload and the requester are stored along with the
+		// request
+
+		found, err := repo.FindByID(ctx, update.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if found.Payload == nil || found.Payload.State == nil || *found.Payload.State != inactive || found.Payload.Name != nil {
+			t.Fatalf("expected payload %+v, got: %+v", update.Payload, found.Payload)
+		}
+
+		if found.Requester == nil || *found.Requester != *update.Requester {
+			t.Fatalf("expected requester %+v, got: %+v", update.Requester, found.Requester)
+		}
+
+		found, err = repo.FindByID(ctx, deletion.ID)
+		if err != nil || found.Payload != nil {
+			t.Fatalf("expected deletion without payload, got: %+v, %v", found, err)
+		}
+
+		// assert a request is decided on once
+
+		deletion.Status, deletion.DecidedBy, deletion.Reason = approval.StatusRejected, "bob", "still needed"
+		decidedAt := at(4)
+		deletion.DecidedAt = &decidedAt
+		for i, want := range []bool{true, false} {
+			ok, err := repo.UpdateChangeRequest(ctx, deletion, approval.StatusPending)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if ok != want {
+				t.Fatalf("expected attempt %d to update: %t, got: %t", i, want, ok)
+			}
+		}
+
+		found, err = repo.FindByID(ctx, deletion.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if found.Status != approval.StatusRejected || found.DecidedBy != "bob" || found.Reason != "still needed" || found.DecidedAt == nil {
+			t.Fatalf("expected the rejection to be stored, got: %+v", found)
+		}
+
+		var testCases = map[string]struct {
+			want []*approval.ChangeRequest
+			f    approval.Filter
+		}{
+			"every request oldest first": {[]*approval.ChangeRequest{update, deletion, other}, approval.Filter{}},
+			"of a device":                {[]*approval.ChangeRequest{update, deletion}, approval.Filter{DeviceID: deviceID}},
+			"with a status":              {[]*approval.ChangeRequest{update, other}, approval.Filter{Status: approval.StatusPending}},
+			"of another tenant":          {nil, approval.Filter{}},
+		}
+
+		for name, tc := range testCases {
+			listCtx := ctx
+			if tc.want == nil {
+				listCtx = organization.WithTenant(context.Background(), uuid.New())
+			}
+
+			crs, err := repo.ListChangeRequests(listCtx, tc.f)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got: %v", name, err)
+			}
+
+			if len(crs) != len(tc.want) {
+				t.Fatalf("%s: expected %d change requests, got: %d", name, len(tc.want), len(crs))
+			}
+
+			for i, cr := range crs {
+				if cr.ID != tc.want[i].ID {
+					t.Fatalf("%s: expected change request %d to be %s, got: %s", name, i, tc.want[i].ID, cr.ID)
+				}
+			}
+		}
+
+		_, err = repo.FindByID(organization.WithTenant(context.Background(), uuid.New()), update.ID)
+		if !errors.Is(err, approval.ErrNotFound) {
+			t.Fatalf("expected error: %v, got: %v", approval.ErrNotFound, err)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+
+	"github.com/google/uuid"
+)
+
+type ApprovalService interface {
+	ListChangeRequests(ctx context.Context, f Filter) (ChangeRequests, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*ChangeRequest, error)
+	// Approve applies a pending change request on behalf of its requester. A
+	// change that cannot be applied marks the request failed, and its error
+	// is returned.
+	Approve(ctx context.Context, ID uuid.UUID, input DecisionRequest, approver string) (*ChangeRequest, error)
+	Reject(ctx context.Context, ID uuid.UUID, input DecisionRequest, approver string) (*ChangeRequest, error)
+}
+
+type ServiceOption func(*approvalService)
+
+// WithClock sets the clock telling the time, time.Now by default.
+func WithClock(now func() time.Time) ServiceOption {
+	return func(s *approvalService) {
+		s.now = now
+	}
+}
+
+type approvalService struct {
+	repo     ChangeRequestRepository
+	devices  device.DeviceService
+	policies []Policy
+	now      func() time.Time
+}
+
+// NewApprover returns the device.Approver holding back the changes matching
+// policies in pending change requests, reported by a PendingError. Without
+// policies, no change requires approval.
+func NewApprover(r ChangeRequestRepository, policies []Policy, opts ...ServiceOption) device.Approver {
+	return newService(r, nil, policies, opts)
+}
+
+// NewService returns an ApprovalService applying the change requests held
+// back by the approver of devices through devices, once approved.
+func NewService(r ChangeRequestRepository, devices device.DeviceService, opts ...ServiceOption) ApprovalService {
+	return newService(r, devices, nil, opts)
+}
+
+func newService(r ChangeRequestRepository, devices device.DeviceService, policies []Policy, opts []ServiceOption) *approvalService {
+	s := &approvalService{
+		repo:     r,
+		devices:  devices,
+		policies: policies,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// approvedCtxKey marks the contexts applying approved change requests, which
+// are not held back again.
+type approvedCtxKey struct{}
+
+// Hold creates the change request of m if a policy matches it, on behalf of
+// the principal of ctx.
+func (s *approvalService) Hold(ctx context.Context, m device.Mutation) error {
+	if approved, _ := ctx.Value(approvedCtxKey{}).(bool); approved {
+		return nil
+	}
+
+	if m.Operation != OperationUpdate && m.Operation != OperationDelete {
+		return nil
+	}
+
+	for _, p := range s.policies {
+		if !p.matches(m.Operation, m.Before, m.Change) {
+			continue
+		}
+
+		var requester *auth.Principal
+		if principal, ok := auth.FromContext(ctx); ok {
+			copied := *principal
+			requester = &copied
+		}
+
+		cr := newChangeRequest(m.Before.ID, m.Operation, m.Change, p, requester, s.now())
+		if err := s.repo.InsertChangeRequest(ctx, cr); err != nil {
+			return err
+		}
+
+		return &PendingError{ChangeRequest: cr}
+	}
+
+	return nil
+}
+
+func (s *approvalService) ListChangeRequests(ctx context.Context, f Filter) (ChangeRequests, error) {
+	crs, err := s.repo.ListChangeRequests(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return crs, nil
+}
+
+func (s *approvalService) FindByID(ctx context.Context, ID uuid.UUID) (*ChangeRequest, error) {
+	cr, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+func (s *approvalService) Approve(ctx context.Context, ID uuid.UUID, input DecisionRequest, approver string) (*ChangeRequest, error) {
+	cr, err := s.decide(ctx, ID, StatusApproved, input, approver)
+	if err != nil {
+		return nil, err
+	}
+
+	// the change is applied on behalf of its requester rather than of the
+	// approver, so that the policies see who asked for it
+	applyCtx := context.WithValue(auth.WithPrincipal(ctx, cr.Requester), approvedCtxKey{}, true)
+
+	var applyErr error
+	switch cr.Operation {
+	case OperationUpdate:
+		applyErr = s.devices.UpdateDevice(applyCtx, cr.DeviceID, *cr.Payload)
+	case OperationDelete:
+		applyErr = s.devices.DeleteDevice(applyCtx, cr.DeviceID)
+	default:
+		applyErr = fmt.Errorf("unknown operation %q", cr.Operation)
+	}
+
+	cr.Status = StatusApplied
+	if applyErr != nil {
+		cr.Status, cr.Error = StatusFailed, applyErr.Error()
+	}
+
+	if _, err := s.repo.UpdateChangeRequest(ctx, cr, StatusApproved); err != nil {
+		return nil, errors.Join(applyErr, err)
+	}
+
+	if applyErr != nil {
+		return nil, fmt.Errorf("failed to apply change request %s: %w", cr.ID, applyErr)
+	}
+
+	return cr, nil
+}
+
+func (s *approvalService) Reject(ctx context.Context, ID uuid.UUID, input DecisionRequest, approver string) (*ChangeRequest, error) {
+	return s.decide(ctx, ID, StatusRejected, input, approver)
+}
+
+// decide moves a pending change request to status, on behalf of approver.
+// Requests are decided on once, even by concurrent approvers.
+func (s *approvalService) decide(ctx context.Context, ID uuid.UUID, status string, input DecisionRequest, approver string) (*ChangeRequest, error) {
+	cr, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cr.Status != StatusPending {
+		return nil, ErrNotPending
+	}
+
+	// requesters may reject their own change, but not approve it
+	if status == StatusApproved && approver != "" && approver == cr.RequestedBy {
+		return nil, ErrSelfApproval
+	}
+
+	cr.decide(status, approver, input.Reason, s.now())
+	ok, err := s.repo.UpdateChangeRequest(ctx, cr, StatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrNotPending
+	}
+
+	return cr, nil
+}
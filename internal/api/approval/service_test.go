@@ -0,0 +1,363 @@
+package approval_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/approval"
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+)
+
+func policies(t *testing.T, s string) []approval.Policy {
+	t.Helper()
+
+	policies, err := approval.ParsePolicies(s)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	return policies
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// evaluator is a device.PolicyEvaluator recording the principals of the
+// changes it evaluates, and denying them with its err.
+type evaluator struct {
+	mu         sync.Mutex
+	err        error
+	principals []*auth.Principal
+}
+
+func (e *evaluator) Evaluate(ctx context.Context, m device.Mutation) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if m.Operation == device.OperationCreate {
+		return nil
+	}
+
+	p, _ := auth.FromContext(ctx)
+	e.principals = append(e.principals, p)
+
+	return e.err
+}
+
+func (e *evaluator) deny(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.err = err
+}
+
+func (e *evaluator) last() *auth.Principal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.principals) == 0 {
+		return nil
+	}
+
+	return e.principals[len(e.principals)-1]
+}
+
+// requesterCtx returns the context of the requests of alice.
+func requesterCtx() context.Context {
+	return auth.WithPrincipal(tenantCtx(), &auth.Principal{Subject: "alice", Role: auth.RoleUser})
+}
+
+func TestApproverHold(t *testing.T) {
+	errDenied := errors.New("denied")
+
+	var testCases = map[string]struct {
+		wantPolicy string
+		wantErr    error
+		policies   string
+		state      string
+		input      *device.UpdateDeviceRequest
+		policyErr  error
+	}{
+		"no policy": {
+			state: device.StateAvailable,
+		},
+		"deletion": {
+			wantPolicy: "delete",
+			wantErr:    approval.ErrApprovalRequired,
+			policies:   "delete",
+			state:      device.StateAvailable,
+		},
+		"deletion of a device in another state": {
+			policies: "delete:inactive",
+			state:    device.StateAvailable,
+		},
+		"deletion of a device in use": {
+			wantErr:  device.ErrDeviceInUse,
+			policies: "delete",
+			state:    device.StateInUse,
+		},
+		"deletion denied by a policy": {
+			wantErr:   errDenied,
+			policies:  "delete",
+			state:     device.StateAvailable,
+			policyErr: errDenied,
+		},
+		"transition": {
+			wantPolicy: "update:*->inactive",
+			wantErr:    approval.ErrApprovalRequired,
+			policies:   "delete,update:*->inactive",
+			state:      device.StateAvailable,
+			input:      &device.UpdateDeviceRequest{State: stringPtr(device.StateInactive)},
+		},
+		"transition denied by a policy": {
+			wantErr:   errDenied,
+			policies:  "update:*->inactive",
+			state:     device.StateAvailable,
+			input:     &device.UpdateDeviceRequest{State: stringPtr(device.StateInactive)},
+			policyErr: errDenied,
+		},
+		"update to the same state": {
+			policies: "update:*->inactive",
+			state:    device.StateInactive,
+			input:    &device.UpdateDeviceRequest{State: stringPtr(device.StateInactive)},
+		},
+		"update keeping the state": {
+			policies: "update:*->inactive",
+			state:    device.StateAvailable,
+			input:    &device.UpdateDeviceRequest{Name: stringPtr("Pixel")},
+		},
+		"update of the fields of a device in use": {
+			wantErr:  device.ErrDeviceInUse,
+			policies: "update",
+			state:    device.StateInUse,
+			input:    &device.UpdateDeviceRequest{Name: stringPtr("Pixel")},
+		},
+		"every update": {
+			wantPolicy: "update",
+			wantErr:    approval.ErrApprovalRequired,
+			policies:   "update:in_use->inactive,update",
+			state:      device.StateAvailable,
+			input:      &device.UpdateDeviceRequest{Name: stringPtr("Pixel")},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := requesterCtx()
+			repo := approval.NewMemoryRepository()
+			devices := device.NewService(device.NewMemoryRepository(),
+				device.WithPolicies(&evaluator{err: tc.policyErr}),
+				device.WithApprover(approval.NewApprover(repo, policies(t, tc.policies))),
+			)
+
+			d, err := devices.CreateDevice(ctx, device.CreateDeviceRequest{Name: "Galaxy", Brand: "Samsung", State: tc.state})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if tc.input != nil {
+				err = devices.UpdateDevice(ctx, d.ID, *tc.input)
+			} else {
+				err = devices.DeleteDevice(ctx, d.ID)
+			}
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			crs, err := repo.ListChangeRequests(ctx, approval.Filter{})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if (len(crs) == 1) != (tc.wantPolicy != "") || len(crs) > 1 {
+				t.Fatalf("expected a change request: %t, got: %+v", tc.wantPolicy != "", crs)
+			}
+
+			if tc.wantPolicy == "" {
+				return
+			}
+
+			// assert the pending request of alice is stored with the
+			// requested change, which is not applied
+
+			found := crs[0]
+			if found.Status != approval.StatusPending || found.Policy != tc.wantPolicy || found.RequestedBy != "alice" || found.DeviceID != d.ID {
+				t.Fatalf("expected a pending request of alice matching %q, got: %+v", tc.wantPolicy, found)
+			}
+
+			if found.Requester == nil || found.Requester.Role != auth.RoleUser {
+				t.Fatalf("expected the requester to be recorded, got: %+v", found.Requester)
+			}
+
+			if (found.Payload != nil) != (tc.input != nil) {
+				t.Fatalf("expected payload: %t, got: %+v", tc.input != nil, found.Payload)
+			}
+
+			stored, err := devices.FindByID(ctx, d.ID)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if *stored != *d {
+				t.Fatalf("expected the device to be left as is, got: %+v", stored)
+			}
+		})
+	}
+}
+
+func TestServiceDecide(t *testing.T) {
+	errDenied := errors.New("denied")
+
+	var testCases = map[string]struct {
+		wantStatus string
+		wantErr    error
+		approver   string
+		reject     bool
+		delete     bool
+		applyErr   error
+	}{
+		"approved update is applied": {
+			wantStatus: approval.StatusApplied,
+			approver:   "bob",
+		},
+		"approved deletion is applied": {
+			wantStatus: approval.StatusApplied,
+			approver:   "bob",
+			delete:     true,
+		},
+		"change that cannot be applied fails": {
+			wantStatus: approval.StatusFailed,
+			wantErr:    errDenied,
+			approver:   "bob",
+			applyErr:   errDenied,
+		},
+		"requester cannot approve": {
+			wantStatus: approval.StatusPending,
+			wantErr:    approval.ErrSelfApproval,
+			approver:   "alice",
+		},
+		"requester may reject": {
+			wantStatus: approval.StatusRejected,
+			approver:   "alice",
+			reject:     true,
+		},
+		"rejected change is not applied": {
+			wantStatus: approval.StatusRejected,
+			approver:   "bob",
+			reject:     true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := requesterCtx()
+			now := at(0)
+			clock := approval.WithClock(func() time.Time { return now })
+
+			repo := approval.NewMemoryRepository()
+			evaluations := &evaluator{}
+			devices := device.NewService(device.NewMemoryRepository(),
+				device.WithPolicies(evaluations),
+				device.WithApprover(approval.NewApprover(repo, policies(t, "delete,update:*->inactive"), clock)),
+			)
+			s := approval.NewService(repo, devices, clock)
+
+			d, err := devices.CreateDevice(ctx, device.CreateDeviceRequest{Name: "Galaxy", Brand: "Samsung", State: device.StateAvailable})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if tc.delete {
+				err = devices.DeleteDevice(ctx, d.ID)
+			} else {
+				err = devices.UpdateDevice(ctx, d.ID, device.UpdateDeviceRequest{State: stringPtr(device.StateInactive)})
+			}
+
+			var pending *approval.PendingError
+			if !errors.As(err, &pending) {
+				t.Fatalf("expected a pending change request, got: %v", err)
+			}
+			cr := pending.ChangeRequest
+
+			now = at(5)
+			evaluations.deny(tc.applyErr)
+			decide := s.Approve
+			if tc.reject {
+				decide = s.Reject
+			}
+
+			approverCtx := auth.WithPrincipal(tenantCtx(), &auth.Principal{Subject: tc.approver, Role: auth.RoleAdmin})
+			_, err = decide(approverCtx, cr.ID, approval.DecisionRequest{Reason: "ok"}, tc.approver)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			found, err := s.FindByID(ctx, cr.ID)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if found.Status != tc.wantStatus {
+				t.Fatalf("expected status %s, got: %s", tc.wantStatus, found.Status)
+			}
+
+			// assert the change is only applied once approved
+
+			stored, err := devices.FindByID(ctx, d.ID)
+			switch {
+			case tc.wantStatus == approval.StatusApplied && tc.delete:
+				if !errors.Is(err, device.ErrNotFound) {
+					t.Fatalf("expected error: %v, got: %v", device.ErrNotFound, err)
+				}
+			case tc.wantStatus == approval.StatusApplied:
+				if err != nil || stored.State != device.StateInactive {
+					t.Fatalf("expected the device to be inactive, got: %+v, %v", stored, err)
+				}
+			default:
+				if err != nil || stored.State != device.StateAvailable {
+					t.Fatalf("expected the device to be left as is, got: %+v, %v", stored, err)
+				}
+			}
+
+			if tc.wantStatus == approval.StatusApplied || tc.wantStatus == approval.StatusFailed {
+				// assert the policies evaluate the change as made by its
+				// requester rather than by its approver
+				if p := evaluations.last(); p == nil || p.Subject != "alice" || p.Role != auth.RoleUser {
+					t.Fatalf("expected the change to be evaluated on behalf of alice, got: %+v", p)
+				}
+			}
+
+			if tc.wantStatus == approval.StatusPending {
+				return
+			}
+
+			if found.DecidedBy != tc.approver || found.Reason != "ok" || found.DecidedAt == nil || !found.DecidedAt.Equal(at(5)) {
+				t.Fatalf("expected the decision of %s to be recorded, got: %+v", tc.approver, found)
+			}
+
+			if (found.Error != "") != (tc.applyErr != nil) {
+				t.Fatalf("expected error recorded: %t, got: %q", tc.applyErr != nil, found.Error)
+			}
+
+			// assert a request is decided on once
+
+			if _, err := s.Approve(approverCtx, cr.ID, approval.DecisionRequest{}, "carol"); !errors.Is(err, approval.ErrNotPending) {
+				t.Fatalf("expected error: %v, got: %v", approval.ErrNotPending, err)
+			}
+		})
+	}
+}
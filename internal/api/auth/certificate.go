@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// CertificateAuthenticator identifies the caller by the client certificate
+// verified during the TLS handshake:
+//
+//   - the subject is the certificate common name, or its first URI SAN, e.g.
+//     a SPIFFE ID, when it has no common name;
+//   - the role is admin when an organizational unit is "admin", user
+//     otherwise;
+//   - the tenant is the organization, when it is a UUID.
+//
+// Requests without a verified certificate are anonymous.
+type CertificateAuthenticator struct{}
+
+func (CertificateAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+
+	p := &Principal{
+		Subject: cert.Subject.CommonName,
+		Role:    RoleUser,
+	}
+	if p.Subject == "" && len(cert.URIs) > 0 {
+		p.Subject = cert.URIs[0].String()
+	}
+	if p.Subject == "" {
+		return nil, nil
+	}
+
+	if slices.Contains(cert.Subject.OrganizationalUnit, RoleAdmin) {
+		p.Role = RoleAdmin
+	}
+
+	for _, o := range cert.Subject.Organization {
+		if ID, err := uuid.Parse(o); err == nil {
+			p.TenantID = ID
+			break
+		}
+	}
+
+	return p, nil
+}
+
+// Chain resolves the principal with each authenticator in turn, returning the
+// first one found.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+
+	return nil, nil
+}
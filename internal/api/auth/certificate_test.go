@@ -0,0 +1,91 @@
+package auth_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+
+	"github.com/google/uuid"
+)
+
+func TestCertificateAuthenticator(t *testing.T) {
+	tenantID := uuid.New()
+	spiffeID, _ := url.Parse("spiffe://example.org/device-sync")
+
+	var testCases = map[string]struct {
+		cert *x509.Certificate
+		want *auth.Principal
+	}{
+		"no certificate": {},
+		"common name": {
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "client"}},
+			want: &auth.Principal{Subject: "client", Role: auth.RoleUser},
+		},
+		"uri san without common name": {
+			cert: &x509.Certificate{URIs: []*url.URL{spiffeID}},
+			want: &auth.Principal{Subject: spiffeID.String(), Role: auth.RoleUser},
+		},
+		"admin with tenant": {
+			cert: &x509.Certificate{Subject: pkix.Name{
+				CommonName:         "ops",
+				OrganizationalUnit: []string{"platform", auth.RoleAdmin},
+				Organization:       []string{"Acme", tenantID.String()},
+			}},
+			want: &auth.Principal{Subject: "ops", Role: auth.RoleAdmin, TenantID: tenantID},
+		},
+		"no identity": {
+			cert: &x509.Certificate{},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.cert != nil {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tc.cert}}}
+			}
+
+			got, err := auth.CertificateAuthenticator{}.Authenticate(r)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+				t.Fatalf("expected principal %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(auth.HeaderKeySubject, "proxied")
+
+	// assert the first principal found is returned
+	chain := auth.Chain{auth.CertificateAuthenticator{}, auth.HeaderAuthenticator{}}
+
+	p, err := chain.Authenticate(r)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if p == nil || p.Subject != "proxied" {
+		t.Fatalf("expected principal %q, got: %+v", "proxied", p)
+	}
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "client"}}}}}
+
+	if p, _ := chain.Authenticate(r); p == nil || p.Subject != "client" {
+		t.Fatalf("expected principal %q, got: %+v", "client", p)
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleAdmin string = "admin"
+	RoleUser  string = "user"
+	// RoleSystem is the role of the changes the API makes on its own, such as
+	// starting reservations, see AsSystem. Callers cannot take it.
+	RoleSystem string = "system"
+)
+
+const (
+	HeaderKeySubject  = "X-Auth-Subject"
+	HeaderKeyRole     = "X-Auth-Role"
+	HeaderKeyTenantID = "X-Auth-Tenant"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject  string
+	Role     string
+	TenantID uuid.UUID
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p != nil && p.Role == RoleAdmin
+}
+
+// Authenticator resolves the principal of a request. It returns a nil
+// principal, and no error, for anonymous requests.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// HeaderAuthenticator trusts the identity headers set by an authenticating
+// reverse proxy in front of the API. It must not be used when clients can
+// reach the API directly.
+type HeaderAuthenticator struct{}
+
+func (HeaderAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	subject := r.Header.Get(HeaderKeySubject)
+	if subject == "" {
+		return nil, nil
+	}
+
+	p := &Principal{
+		Subject: subject,
+		Role:    RoleUser,
+	}
+
+	if role := r.Header.Get(HeaderKeyRole); role != "" {
+		if role == RoleSystem {
+			return nil, fmt.Errorf("the %s role is reserved", RoleSystem)
+		}
+		p.Role = role
+	}
+
+	if tenant := r.Header.Get(HeaderKeyTenantID); tenant != "" {
+		tenantID, err := uuid.Parse(tenant)
+		if err != nil {
+			return nil, err
+		}
+		p.TenantID = tenantID
+	}
+
+	return p, nil
+}
+
+type principalCtxKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the given principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// AsSystem returns a copy of ctx carrying a principal of the RoleSystem role
+// named subject, for the changes the API makes on its own rather than on
+// behalf of the principal of ctx, e.g. the device moves of scheduled workers.
+func AsSystem(ctx context.Context, subject string) context.Context {
+	return WithPrincipal(ctx, &Principal{Subject: subject, Role: RoleSystem})
+}
+
+// FromContext returns the principal stored in ctx by WithPrincipal, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	return p, ok && p != nil
+}
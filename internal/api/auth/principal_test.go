@@ -0,0 +1,59 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+)
+
+func TestHeaderAuthenticator(t *testing.T) {
+	var testCases = map[string]struct {
+		want    *auth.Principal
+		wantErr bool
+		headers map[string]string
+	}{
+		"anonymous": {
+			headers: map[string]string{auth.HeaderKeyRole: auth.RoleAdmin},
+		},
+		"user by default": {
+			want:    &auth.Principal{Subject: "alice", Role: auth.RoleUser},
+			headers: map[string]string{auth.HeaderKeySubject: "alice"},
+		},
+		"admin": {
+			want:    &auth.Principal{Subject: "root", Role: auth.RoleAdmin},
+			headers: map[string]string{auth.HeaderKeySubject: "root", auth.HeaderKeyRole: auth.RoleAdmin},
+		},
+		"system role is reserved": {
+			wantErr: true,
+			headers: map[string]string{auth.HeaderKeySubject: "mallory", auth.HeaderKeyRole: auth.RoleSystem},
+		},
+		"invalid tenant": {
+			wantErr: true,
+			headers: map[string]string{auth.HeaderKeySubject: "alice", auth.HeaderKeyTenantID: "acme"},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			got, err := auth.HeaderAuthenticator{}.Authenticate(r)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %t, got: %v", tc.wantErr, err)
+			}
+
+			if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+				t.Fatalf("expected principal %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package device
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultCacheSize = 10000
+	defaultCacheTTL  = 30 * time.Second
+)
+
+// CacheObserver is notified of every FindByID lookup served by a
+// CachedRepository, to export hit and miss metrics.
+type CacheObserver interface {
+	ObserveCacheLookup(cache string, hit bool)
+}
+
+// CacheBroadcaster tells the other replicas a device changed, so they
+// invalidate their cached copy. Delivery is best effort, entries missed by a
+// replica still expire after the TTL.
+type CacheBroadcaster interface {
+	Broadcast(ctx context.Context, ID uuid.UUID)
+}
+
+type CacheOption func(*CachedRepository)
+
+// WithCacheSize bounds the number of cached devices, the least recently used
+// ones being evicted first.
+func WithCacheSize(size int) CacheOption {
+	return func(r *CachedRepository) {
+		r.size = size
+	}
+}
+
+// WithCacheTTL sets how long a device is served from the cache.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(r *CachedRepository) {
+		r.ttl = ttl
+	}
+}
+
+func WithCacheObserver(o CacheObserver) CacheOption {
+	return func(r *CachedRepository) {
+		r.observer = o
+	}
+}
+
+func WithCacheBroadcaster(b CacheBroadcaster) CacheOption {
+	return func(r *CachedRepository) {
+		r.broadcaster = b
+	}
+}
+
+// CachedRepository is a DeviceRepository serving FindByID from a bounded LRU
+// cache in front of another repository. Devices are invalidated when updated
+// or deleted through it, or through Invalidate, and concurrent misses for the
+// same device are coalesced into a single lookup.
+type CachedRepository struct {
+	next        DeviceRepository
+	size        int
+	ttl         time.Duration
+	observer    CacheObserver
+	broadcaster CacheBroadcaster
+	group       singleflight.Group
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	// lru holds the cache entries, most recently used first.
+	lru *list.List
+	// generation is bumped on every invalidation, so lookups started before it
+	// do not store a device that may be stale.
+	generation uint64
+}
+
+type cacheEntry struct {
+	device  Device
+	expires time.Time
+}
+
+func NewCachedRepository(r DeviceRepository, opts ...CacheOption) *CachedRepository {
+	c := &CachedRepository{
+		next:    r,
+		size:    defaultCacheSize,
+		ttl:     defaultCacheTTL,
+		entries: make(map[uuid.UUID]*list.Element),
+		lru:     list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (r *CachedRepository) InsertDevice(ctx context.Context, device *Device) error {
+	return r.next.InsertDevice(ctx, device)
+}
+
+func (r *CachedRepository) UpdateDevice(ctx context.Context, device *Device) error {
+	err := r.next.UpdateDevice(ctx, device)
+	r.invalidate(ctx, device.ID, err)
+
+	return err
+}
+
+func (r *CachedRepository) ListDevices(ctx context.Context) (Devices, error) {
+	return r.next.ListDevices(ctx)
+}
+
+func (r *CachedRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Device, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if d, ok := r.get(tenantID, ID); ok {
+		r.observe(true)
+		return d, nil
+	}
+	r.observe(false)
+
+	// the lookup is shared by every caller waiting on the device, so it must
+	// not be canceled by the first one giving up
+	ch := r.group.DoChan(tenantID.String()+"/"+ID.String(), func() (any, error) {
+		generation := r.currentGeneration()
+
+		d, err := r.next.FindByID(context.WithoutCancel(ctx), ID)
+		if err != nil {
+			return nil, err
+		}
+
+		r.store(d, generation)
+		return d, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		d := *res.Val.(*Device)
+		return &d, nil
+	}
+}
+
+func (r *CachedRepository) FindByState(ctx context.Context, state string) (Devices, error) {
+	return r.next.FindByState(ctx, state)
+}
+
+func (r *CachedRepository) FindByBrand(ctx context.Context, brand string) (Devices, error) {
+	return r.next.FindByBrand(ctx, brand)
+}
+
+func (r *CachedRepository) DeleteDevice(ctx context.Context, ID uuid.UUID) error {
+	err := r.next.DeleteDevice(ctx, ID)
+	r.invalidate(ctx, ID, err)
+
+	return err
+}
+
+// Invalidate removes the device from the cache, without notifying the other
+// replicas. It is meant for changes made by another replica.
+func (r *CachedRepository) Invalidate(ID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	if e, ok := r.entries[ID]; ok {
+		r.lru.Remove(e)
+		delete(r.entries, ID)
+	}
+}
+
+// Purge empties the cache, for instance after invalidations may have been
+// missed.
+func (r *CachedRepository) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	r.entries = make(map[uuid.UUID]*list.Element)
+	r.lru.Init()
+}
+
+// Len returns the number of cached devices, including expired ones not yet
+// evicted.
+func (r *CachedRepository) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lru.Len()
+}
+
+// invalidate drops the device after a write, whether it succeeded or not, and
+// tells the other replicas about successful ones.
+func (r *CachedRepository) invalidate(ctx context.Context, ID uuid.UUID, err error) {
+	r.Invalidate(ID)
+
+	if err == nil && r.broadcaster != nil {
+		r.broadcaster.Broadcast(ctx, ID)
+	}
+}
+
+// get returns a copy of the cached device, provided it belongs to tenantID and
+// has not expired.
+func (r *CachedRepository) get(tenantID, ID uuid.UUID) (*Device, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[ID]
+	if !ok {
+		return nil, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		r.lru.Remove(e)
+		delete(r.entries, ID)
+		return nil, false
+	}
+
+	// the device of another tenant is not found by the repository, which is
+	// left to report it
+	if entry.device.TenantID != tenantID {
+		return nil, false
+	}
+
+	r.lru.MoveToFront(e)
+	d := entry.device
+	return &d, true
+}
+
+// store caches a copy of d unless it was invalidated since generation.
+func (r *CachedRepository) store(d *Device, generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation != r.generation || r.size <= 0 {
+		return
+	}
+
+	entry := &cacheEntry{device: *d, expires: time.Now().Add(r.ttl)}
+	if e, ok := r.entries[d.ID]; ok {
+		e.Value = entry
+		r.lru.MoveToFront(e)
+		return
+	}
+
+	r.entries[d.ID] = r.lru.PushFront(entry)
+	for r.lru.Len() > r.size {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cacheEntry).device.ID)
+	}
+}
+
+func (r *CachedRepository) currentGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.generation
+}
+
+func (r *CachedRepository) observe(hit bool) {
+	if r.observer != nil {
+		r.observer.ObserveCacheLookup("devices", hit)
+	}
+}
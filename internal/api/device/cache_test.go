@@ -0,0 +1,340 @@
+package device_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/device/devicetest"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// countingRepository counts the FindByID calls reaching the wrapped repository.
+type countingRepository struct {
+	device.DeviceRepository
+	finds atomic.Int64
+	// release, when set, blocks FindByID until it is closed.
+	release chan struct{}
+}
+
+func (r *countingRepository) FindByID(ctx context.Context, ID uuid.UUID) (*device.Device, error) {
+	r.finds.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+
+	return r.DeviceRepository.FindByID(ctx, ID)
+}
+
+type lookupCounter struct {
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+func (c *lookupCounter) ObserveCacheLookup(_ string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+type broadcastRecorder struct {
+	mu  sync.Mutex
+	IDs []uuid.UUID
+}
+
+func (b *broadcastRecorder) Broadcast(_ context.Context, ID uuid.UUID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.IDs = append(b.IDs, ID)
+}
+
+func TestCachedRepository(t *testing.T) {
+	t.Parallel()
+
+	devicetest.TestRepository(t, func(t *testing.T) devicetest.Setup {
+		s := memoryFactory(t)
+		s.Repo = device.NewCachedRepository(s.Repo)
+		return s
+	})
+}
+
+func TestCachedRepositoryFindByID(t *testing.T) {
+	t.Parallel()
+
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	next := &countingRepository{DeviceRepository: device.NewMemoryRepository()}
+	observer := &lookupCounter{}
+	broadcaster := &broadcastRecorder{}
+	repo := device.NewCachedRepository(next,
+		device.WithCacheObserver(observer),
+		device.WithCacheBroadcaster(broadcaster),
+	)
+
+	d := device.NewDevice("test", "test", device.StateAvailable)
+	if err := repo.InsertDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert repeated lookups are served from the cache
+
+	for range 3 {
+		if _, err := repo.FindByID(ctx, d.ID); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	if n := next.finds.Load(); n != 1 {
+		t.Fatalf("expected %d lookup, got: %d", 1, n)
+	}
+
+	if observer.hits != 2 || observer.misses != 1 {
+		t.Fatalf("expected %d hits and %d miss, got: %d and %d", 2, 1, observer.hits, observer.misses)
+	}
+
+	// assert callers do not share the cached device
+
+	found, err := repo.FindByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	found.Name = "changed"
+
+	found, err = repo.FindByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if found.Name != "test" {
+		t.Fatalf("expected name %q, got: %q", "test", found.Name)
+	}
+
+	// assert the device of another tenant is not served from the cache
+
+	otherCtx := organization.WithTenant(context.Background(), uuid.New())
+	if _, err := repo.FindByID(otherCtx, d.ID); err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	// assert updates invalidate the device and are broadcast
+
+	d.Name = "updated"
+	if err := repo.UpdateDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	found, err = repo.FindByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if found.Name != "updated" {
+		t.Fatalf("expected name %q, got: %q", "updated", found.Name)
+	}
+
+	// assert deletes invalidate the device and are broadcast
+
+	if err := repo.DeleteDevice(ctx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctx, d.ID); err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	if len(broadcaster.IDs) != 2 || broadcaster.IDs[0] != d.ID || broadcaster.IDs[1] != d.ID {
+		t.Fatalf("expected 2 broadcasts of %s, got: %v", d.ID, broadcaster.IDs)
+	}
+
+	// assert remote invalidations drop the device
+
+	d = device.NewDevice("test", "test", device.StateAvailable)
+	if err := repo.InsertDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	repo.Invalidate(d.ID)
+	if repo.Len() != 0 {
+		t.Fatalf("expected an empty cache, got %d devices", repo.Len())
+	}
+}
+
+func TestCachedRepositoryEviction(t *testing.T) {
+	t.Parallel()
+
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	next := &countingRepository{DeviceRepository: device.NewMemoryRepository()}
+	repo := device.NewCachedRepository(next,
+		device.WithCacheSize(2),
+		device.WithCacheTTL(50*time.Millisecond),
+	)
+
+	var ds device.Devices
+	for range 3 {
+		d := device.NewDevice("test", "test", device.StateAvailable)
+		if err := repo.InsertDevice(ctx, d); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		ds = append(ds, d)
+	}
+
+	find := func(d *device.Device) {
+		t.Helper()
+
+		if _, err := repo.FindByID(ctx, d.ID); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	// assert the least recently used device is evicted
+
+	find(ds[0])
+	find(ds[1])
+	find(ds[0])
+	find(ds[2])
+
+	if repo.Len() != 2 {
+		t.Fatalf("expected %d cached devices, got: %d", 2, repo.Len())
+	}
+
+	next.finds.Store(0)
+	find(ds[0])
+	find(ds[1])
+
+	if n := next.finds.Load(); n != 1 {
+		t.Fatalf("expected %d lookup, got: %d", 1, n)
+	}
+
+	// assert expired devices are looked up again
+
+	time.Sleep(100 * time.Millisecond)
+
+	next.finds.Store(0)
+	find(ds[1])
+
+	if n := next.finds.Load(); n != 1 {
+		t.Fatalf("expected %d lookup, got: %d", 1, n)
+	}
+}
+
+func TestCachedRepositoryCoalescing(t *testing.T) {
+	t.Parallel()
+
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	next := &countingRepository{DeviceRepository: device.NewMemoryRepository()}
+	repo := device.NewCachedRepository(next)
+
+	d := device.NewDevice("test", "test", device.StateAvailable)
+	if err := repo.InsertDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert concurrent misses result in a single lookup
+
+	next.release = make(chan struct{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := repo.FindByID(ctx, d.ID)
+			errs <- err
+		}()
+	}
+
+	// give the callers time to join the pending lookup
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	if got := next.finds.Load(); got != 1 {
+		t.Fatalf("expected %d lookup, got: %d", 1, got)
+	}
+
+	// assert a caller giving up does not fail the others
+
+	repo.Invalidate(d.ID)
+	next.release = make(chan struct{})
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, err := repo.FindByID(canceledCtx, d.ID)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	close(next.release)
+	if _, err := repo.FindByID(ctx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestCachedRepositoryStaleLookup(t *testing.T) {
+	t.Parallel()
+
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	next := &countingRepository{DeviceRepository: device.NewMemoryRepository()}
+	repo := device.NewCachedRepository(next)
+
+	d := device.NewDevice("test", "test", device.StateAvailable)
+	if err := repo.InsertDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert a lookup overtaken by an update does not cache the old device
+
+	next.release = make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := repo.FindByID(ctx, d.ID)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	repo.Invalidate(d.ID)
+	close(next.release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if repo.Len() != 0 {
+		t.Fatalf("expected an empty cache, got %d devices", repo.Len())
+	}
+}
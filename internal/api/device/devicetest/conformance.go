@@ -0,0 +1,371 @@
+// Package devicetest provides a conformance suite for device.DeviceRepository
+// implementations.
+package devicetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// Setup is the repository under test, along with two distinct tenants it can
+// store devices for.
+type Setup struct {
+	Repo    device.DeviceRepository
+	TenantA uuid.UUID
+	TenantB uuid.UUID
+}
+
+// Factory returns a Setup whose repository holds no devices. It is called
+// once per subtest.
+type Factory func(t *testing.T) Setup
+
+// TestRepository verifies the repository returned by newRepo behaves like
+// every other device.DeviceRepository.
+func TestRepository(t *testing.T, newRepo Factory) {
+	for _, tc := range []struct {
+		name string
+		fn   func(*testing.T, Setup)
+	}{
+		{"insert", testInsert},
+		{"update", testUpdate},
+		{"find by ID", testFindByID},
+		{"list", testList},
+		{"find by state", testFindByState},
+		{"find by brand", testFindByBrand},
+		{"delete", testDelete},
+		{"tenant isolation", testTenantIsolation},
+		{"missing tenant", testMissingTenant},
+		{"concurrency", testConcurrency},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newRepo(t))
+		})
+	}
+}
+
+func testInsert(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	// assert valid device creation assigns the tenant
+
+	d := device.NewDevice("test", "test", device.StateAvailable)
+	if err := s.Repo.InsertDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if d.TenantID != s.TenantA {
+		t.Fatalf("expected tenant ID: %s, got: %s", s.TenantA, d.TenantID)
+	}
+
+	// assert invalid device creation (invalid state, duplicate ID)
+
+	if err := s.Repo.InsertDevice(ctx, device.NewDevice("test", "test", "invalid")); !errors.Is(err, device.ErrInvalidTransition) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrInvalidTransition, err)
+	}
+
+	if err := s.Repo.InsertDevice(ctx, d); !errors.Is(err, device.ErrDuplicate) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrDuplicate, err)
+	}
+}
+
+func testUpdate(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	d := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "test", device.StateAvailable))
+
+	// update the device and assert changes
+
+	d.Name, d.Brand, d.State = "updated", "updated", device.StateInUse
+	if err := s.Repo.UpdateDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	updated := mustFind(t, s.Repo, ctx, d.ID)
+	if updated.Name != "updated" || updated.Brand != "updated" || updated.State != device.StateInUse {
+		t.Fatalf("expected device to be updated, got: %+v", updated)
+	}
+
+	// assert invalid state is rejected and leaves the device unchanged
+
+	d.State = "invalid"
+	if err := s.Repo.UpdateDevice(ctx, d); !errors.Is(err, device.ErrInvalidTransition) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrInvalidTransition, err)
+	}
+
+	if got := mustFind(t, s.Repo, ctx, d.ID).State; got != device.StateInUse {
+		t.Fatalf("expected state: %s, got: %s", device.StateInUse, got)
+	}
+
+	// assert updating a missing device is a no-op
+
+	missing := device.NewDevice("test", "test", device.StateAvailable)
+	if err := s.Repo.UpdateDevice(ctx, missing); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := s.Repo.FindByID(ctx, missing.ID); !errors.Is(err, device.ErrNotFound) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrNotFound, err)
+	}
+}
+
+func testFindByID(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	d := mustInsert(t, s.Repo, ctx, device.NewDevice("name", "brand", device.StateInUse))
+
+	found := mustFind(t, s.Repo, ctx, d.ID)
+	if found.ID != d.ID || found.TenantID != s.TenantA || found.Name != "name" || found.Brand != "brand" || found.State != device.StateInUse {
+		t.Fatalf("expected device %+v, got: %+v", d, found)
+	}
+
+	if found.CreatedAt.IsZero() {
+		t.Fatal("expected created at to be set")
+	}
+
+	// assert the returned device is not shared with the repository
+
+	found.Name = "changed"
+	if got := mustFind(t, s.Repo, ctx, d.ID).Name; got != "name" {
+		t.Fatalf("expected name: %s, got: %s", "name", got)
+	}
+
+	// assert device not found case
+
+	if _, err := s.Repo.FindByID(ctx, uuid.New()); !errors.Is(err, device.ErrNotFound) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrNotFound, err)
+	}
+}
+
+func testList(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	// assert an empty repository lists no devices, not a nil list
+
+	ds, err := s.Repo.ListDevices(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if ds == nil || len(ds) != 0 {
+		t.Fatalf("expected an empty device list, got: %v", ds)
+	}
+
+	wantIDs := make(map[uuid.UUID]bool)
+	for range 4 {
+		d := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "test", device.StateInUse))
+		wantIDs[d.ID] = true
+	}
+
+	assertIDs(t, mustList(t)(s.Repo.ListDevices(ctx)), wantIDs)
+}
+
+func testFindByState(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	inUse := make(map[uuid.UUID]bool)
+	for range 3 {
+		d := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "test", device.StateInUse))
+		inUse[d.ID] = true
+	}
+
+	available := make(map[uuid.UUID]bool)
+	for range 2 {
+		d := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "test", device.StateAvailable))
+		available[d.ID] = true
+	}
+
+	assertIDs(t, mustList(t)(s.Repo.FindByState(ctx, device.StateInUse)), inUse)
+	assertIDs(t, mustList(t)(s.Repo.FindByState(ctx, device.StateAvailable)), available)
+	assertIDs(t, mustList(t)(s.Repo.FindByState(ctx, device.StateInactive)), map[uuid.UUID]bool{})
+}
+
+func testFindByBrand(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	pixel := make(map[uuid.UUID]bool)
+	for range 3 {
+		d := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "pixel", device.StateAvailable))
+		pixel[d.ID] = true
+	}
+	mustInsert(t, s.Repo, ctx, device.NewDevice("test", "iphone", device.StateAvailable))
+
+	assertIDs(t, mustList(t)(s.Repo.FindByBrand(ctx, "pixel")), pixel)
+	assertIDs(t, mustList(t)(s.Repo.FindByBrand(ctx, "unknown")), map[uuid.UUID]bool{})
+}
+
+func testDelete(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	d := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "test", device.StateAvailable))
+	kept := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "test", device.StateAvailable))
+
+	if err := s.Repo.DeleteDevice(ctx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := s.Repo.FindByID(ctx, d.ID); !errors.Is(err, device.ErrNotFound) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrNotFound, err)
+	}
+
+	assertIDs(t, mustList(t)(s.Repo.ListDevices(ctx)), map[uuid.UUID]bool{kept.ID: true})
+
+	// assert deleting a missing device is a no-op
+
+	if err := s.Repo.DeleteDevice(ctx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func testTenantIsolation(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+	otherCtx := organization.WithTenant(context.Background(), s.TenantB)
+
+	d := mustInsert(t, s.Repo, ctx, device.NewDevice("test", "test", device.StateAvailable))
+	other := mustInsert(t, s.Repo, otherCtx, device.NewDevice("other", "test", device.StateAvailable))
+
+	// assert the device is invisible to the other tenant
+
+	if _, err := s.Repo.FindByID(otherCtx, d.ID); !errors.Is(err, device.ErrNotFound) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrNotFound, err)
+	}
+
+	want := map[uuid.UUID]bool{other.ID: true}
+	assertIDs(t, mustList(t)(s.Repo.ListDevices(otherCtx)), want)
+	assertIDs(t, mustList(t)(s.Repo.FindByState(otherCtx, device.StateAvailable)), want)
+	assertIDs(t, mustList(t)(s.Repo.FindByBrand(otherCtx, "test")), want)
+
+	// assert the other tenant cannot update or delete the device
+
+	changed := *d
+	changed.Name = "changed"
+	if err := s.Repo.UpdateDevice(otherCtx, &changed); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if err := s.Repo.DeleteDevice(otherCtx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if got := mustFind(t, s.Repo, ctx, d.ID).Name; got != "test" {
+		t.Fatalf("expected name: %s, got: %s", "test", got)
+	}
+}
+
+func testMissingTenant(t *testing.T, s Setup) {
+	ctx := context.Background()
+
+	if err := s.Repo.InsertDevice(ctx, device.NewDevice("test", "test", device.StateAvailable)); !errors.Is(err, organization.ErrMissingTenant) {
+		t.Fatalf("expected error: %v, got: %v", organization.ErrMissingTenant, err)
+	}
+
+	if _, err := s.Repo.ListDevices(ctx); !errors.Is(err, organization.ErrMissingTenant) {
+		t.Fatalf("expected error: %v, got: %v", organization.ErrMissingTenant, err)
+	}
+
+	if _, err := s.Repo.FindByID(ctx, uuid.New()); !errors.Is(err, organization.ErrMissingTenant) {
+		t.Fatalf("expected error: %v, got: %v", organization.ErrMissingTenant, err)
+	}
+}
+
+func testConcurrency(t *testing.T, s Setup) {
+	ctx := organization.WithTenant(context.Background(), s.TenantA)
+
+	const workers = 20
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			d := device.NewDevice("test", "test", device.StateAvailable)
+			if err := s.Repo.InsertDevice(ctx, d); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+				return
+			}
+
+			d.State = device.StateInUse
+			if err := s.Repo.UpdateDevice(ctx, d); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+
+			if _, err := s.Repo.FindByID(ctx, d.ID); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+
+			if _, err := s.Repo.ListDevices(ctx); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if t.Failed() {
+		return
+	}
+
+	ds := mustList(t)(s.Repo.FindByState(ctx, device.StateInUse))
+	if len(ds) != workers {
+		t.Fatalf("expected %d devices, got: %d", workers, len(ds))
+	}
+}
+
+func mustInsert(t *testing.T, repo device.DeviceRepository, ctx context.Context, d *device.Device) *device.Device {
+	t.Helper()
+
+	if err := repo.InsertDevice(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	return d
+}
+
+func mustFind(t *testing.T, repo device.DeviceRepository, ctx context.Context, ID uuid.UUID) *device.Device {
+	t.Helper()
+
+	d, err := repo.FindByID(ctx, ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	return d
+}
+
+// mustList returns a function taking the results of a list method, so calls
+// read mustList(t)(repo.ListDevices(ctx)).
+func mustList(t *testing.T) func(device.Devices, error) device.Devices {
+	return func(ds device.Devices, err error) device.Devices {
+		t.Helper()
+
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if ds == nil {
+			t.Fatal("expected a device list, got nil")
+		}
+
+		return ds
+	}
+}
+
+func assertIDs(t *testing.T, ds device.Devices, want map[uuid.UUID]bool) {
+	t.Helper()
+
+	if len(ds) != len(want) {
+		t.Fatalf("expected %d devices, got: %d", len(want), len(ds))
+	}
+
+	for _, d := range ds {
+		if !want[d.ID] {
+			t.Fatalf("unexpected device %s", d.ID)
+		}
+	}
+}
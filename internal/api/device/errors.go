@@ -0,0 +1,45 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by DeviceRepository and DeviceService implementations,
+// whatever the storage behind them.
+var (
+	ErrNotFound = errors.New("device not found")
+	// ErrConflict reports an operation the current state of the device, or of
+	// the resources it refers to, does not allow.
+	ErrConflict = errors.New("device conflict")
+	// ErrInvalidTransition reports a device moved to a state that does not
+	// exist or cannot be reached.
+	ErrInvalidTransition = errors.New("invalid device state transition")
+	ErrDuplicate         = errors.New("device already exists")
+	// ErrVetoed reports a change vetoed by a hook, see VetoError.
+	ErrVetoed = errors.New("device change vetoed")
+
+	// ErrDeviceInUse reports a change the state of the device forbids, see
+	// devicestate.State.
+	ErrDeviceInUse = fmt.Errorf("%w: operation cannot be completed because the device is in use", ErrConflict)
+)
+
+// VetoError is returned for the changes vetoed by the Before function of a
+// hook, or by its timeout. It matches ErrVetoed, and unwraps to the error of
+// the hook.
+type VetoError struct {
+	Hook string
+	Err  error
+}
+
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("vetoed by hook %s: %v", e.Hook, e.Err)
+}
+
+func (e *VetoError) Unwrap() error {
+	return e.Err
+}
+
+func (e *VetoError) Is(target error) bool {
+	return target == ErrVetoed
+}
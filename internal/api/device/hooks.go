@@ -0,0 +1,304 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Operations of the changes hooks run around.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// Phases of a hook, reported to HookObserver.
+const (
+	PhaseBefore = "before"
+	PhaseAfter  = "after"
+)
+
+// Results of a hook run, reported to HookObserver.
+const (
+	HookResultOK      = "ok"
+	HookResultError   = "error"
+	HookResultTimeout = "timeout"
+	// HookResultAbandoned is reported a second time for the runs that timed
+	// out, with their full duration, once they return.
+	HookResultAbandoned = "abandoned"
+	// HookResultDropped is reported for the After functions not run because
+	// the HookQueue was full.
+	HookResultDropped = "dropped"
+)
+
+const (
+	// DefaultHookTimeout bounds the runs of the hooks without a Timeout.
+	DefaultHookTimeout = 5 * time.Second
+	// DefaultHookQueueSize is how many changes a HookQueue holds by default.
+	DefaultHookQueueSize = 1024
+)
+
+// Mutation is a change of a device, as seen by hooks. Hooks get copies of the
+// devices, so changing them has no effect.
+type Mutation struct {
+	Operation string
+	// Before is the device before the change, nil on creation.
+	Before *Device
+	// After is the device after the change, nil on deletion.
+	After *Device
+	// Change is the update requested, nil on creation and deletion.
+	Change *UpdateDeviceRequest
+}
+
+// Hook runs custom behaviour around the changes of devices made through the
+// DeviceService, such as enforcing naming conventions or syncing the devices
+// to another inventory. Either function may be nil.
+//
+// Functions must return once their context is done: a run is given up on
+// when its timeout is over, but the goroutine running it cannot be stopped,
+// and is left running until the function returns.
+type Hook struct {
+	// Name identifies the hook in errors, logs and metrics.
+	Name string
+	// Order sorts the hooks, lowest first. Hooks of the same order run in the
+	// order they were registered.
+	Order int
+	// Operations are the operations the hook runs on, all of them when empty.
+	Operations []string
+	// Timeout bounds each run of the hook, DefaultHookTimeout when zero.
+	Timeout time.Duration
+	// Before runs once the change is checked but before it is stored. An
+	// error, or running out of time, vetoes the change, which is reported as
+	// a VetoError and stops the hooks after it.
+	Before func(ctx context.Context, m Mutation) error
+	// After runs once the change is stored, even if the request that made it
+	// is cancelled, in the background when the service has a HookQueue.
+	// Errors are logged and do not fail the change.
+	After func(ctx context.Context, m Mutation) error
+}
+
+// HookObserver is notified of every hook run, to export per-hook metrics.
+type HookObserver interface {
+	ObserveHook(hook, operation, phase, result string, d time.Duration)
+}
+
+// WithHooks registers hooks run around the changes of devices, sorted by
+// their Order.
+func WithHooks(hooks ...Hook) ServiceOption {
+	return func(s *deviceService) {
+		s.hooks = append(s.hooks, hooks...)
+		slices.SortStableFunc(s.hooks, func(a, b Hook) int {
+			return a.Order - b.Order
+		})
+	}
+}
+
+func WithHookObserver(o HookObserver) ServiceOption {
+	return func(s *deviceService) {
+		s.hookObserver = o
+	}
+}
+
+// WithHookQueue runs the After functions of hooks through q, off the path of
+// the requests changing devices. They run before the change returns by
+// default.
+func WithHookQueue(q *HookQueue) ServiceOption {
+	return func(s *deviceService) {
+		s.hookQueue = q
+	}
+}
+
+// WithLogger sets the logger reporting the failures of the After functions of
+// hooks, which are discarded by default.
+func WithLogger(l *slog.Logger) ServiceOption {
+	return func(s *deviceService) {
+		s.logger = l
+	}
+}
+
+// runBefore runs the Before functions of the hooks of m.Operation, in order,
+// stopping at the first veto.
+func (s *deviceService) runBefore(ctx context.Context, m Mutation) error {
+	for _, h := range s.hooks {
+		if h.Before == nil || !h.runsOn(m.Operation) {
+			continue
+		}
+
+		if err := s.runHook(ctx, h, PhaseBefore, h.Before, m); err != nil {
+			return &VetoError{Hook: h.Name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// runAfter runs the After functions of the hooks of m.Operation, in order,
+// logging their failures. With a HookQueue they are queued to run in the
+// background, or dropped when it is full.
+func (s *deviceService) runAfter(ctx context.Context, m Mutation) {
+	// the change is stored, its hooks run even if the caller gives up
+	ctx = context.WithoutCancel(ctx)
+
+	if !slices.ContainsFunc(s.hooks, func(h Hook) bool { return h.After != nil && h.runsOn(m.Operation) }) {
+		return
+	}
+
+	if s.hookQueue == nil {
+		s.runAfterHooks(ctx, m)
+		return
+	}
+
+	m = m.copy()
+	if s.hookQueue.enqueue(func() { s.runAfterHooks(ctx, m) }) {
+		return
+	}
+
+	for _, h := range s.hooks {
+		if h.After == nil || !h.runsOn(m.Operation) {
+			continue
+		}
+
+		s.logger.ErrorContext(ctx, "device hook dropped, the hook queue is full",
+			slog.String("hook", h.Name),
+			slog.String("operation", m.Operation),
+		)
+		s.observeHook(h.Name, m.Operation, PhaseAfter, HookResultDropped, 0)
+	}
+}
+
+func (s *deviceService) runAfterHooks(ctx context.Context, m Mutation) {
+	for _, h := range s.hooks {
+		if h.After == nil || !h.runsOn(m.Operation) {
+			continue
+		}
+
+		if err := s.runHook(ctx, h, PhaseAfter, h.After, m); err != nil {
+			s.logger.ErrorContext(ctx, "device hook failed",
+				slog.String("hook", h.Name),
+				slog.String("operation", m.Operation),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// runHook runs fn, a function of h, with copies of the devices of m, giving
+// up once its timeout is over. A run given up on is reported again as
+// abandoned once fn returns.
+func (s *deviceService) runHook(ctx context.Context, h Hook, phase string, fn func(context.Context, Mutation) error, m Mutation) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx, m.copy())
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+
+		go func() {
+			<-done
+			s.observeHook(h.Name, m.Operation, phase, HookResultAbandoned, time.Since(start))
+		}()
+	}
+
+	result := HookResultOK
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result = HookResultTimeout
+	case err != nil:
+		result = HookResultError
+	}
+
+	s.observeHook(h.Name, m.Operation, phase, result, time.Since(start))
+
+	return err
+}
+
+func (s *deviceService) observeHook(hook, operation, phase, result string, d time.Duration) {
+	if s.hookObserver != nil {
+		s.hookObserver.ObserveHook(hook, operation, phase, result, d)
+	}
+}
+
+// HookQueue runs the After functions of hooks in the background, in the order
+// the changes were made, once Run is started. It holds a bounded number of
+// changes, so that slow hooks cannot pile up memory: the hooks of the changes
+// made while it is full are dropped.
+type HookQueue struct {
+	runs chan func()
+}
+
+// NewHookQueue returns a HookQueue holding up to size changes,
+// DefaultHookQueueSize when size is not positive.
+func NewHookQueue(size int) *HookQueue {
+	if size <= 0 {
+		size = DefaultHookQueueSize
+	}
+
+	return &HookQueue{runs: make(chan func(), size)}
+}
+
+// Run runs the queued hooks until ctx is done, then those still queued.
+func (q *HookQueue) Run(ctx context.Context) {
+	for {
+		select {
+		case run := <-q.runs:
+			run()
+		case <-ctx.Done():
+			for {
+				select {
+				case run := <-q.runs:
+					run()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (q *HookQueue) enqueue(run func()) bool {
+	select {
+	case q.runs <- run:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h Hook) runsOn(operation string) bool {
+	return len(h.Operations) == 0 || slices.Contains(h.Operations, operation)
+}
+
+func (m Mutation) copy() Mutation {
+	if m.Before != nil {
+		before := *m.Before
+		m.Before = &before
+	}
+
+	if m.After != nil {
+		after := *m.After
+		m.After = &after
+	}
+
+	if m.Change != nil {
+		change := *m.Change
+		m.Change = &change
+	}
+
+	return m
+}
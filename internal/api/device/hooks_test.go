@@ -0,0 +1,310 @@
+package device_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+)
+
+// hookObserver records the hook runs it observes.
+type hookObserver struct {
+	mu   sync.Mutex
+	runs []string
+}
+
+func (o *hookObserver) ObserveHook(hook, operation, phase, result string, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.runs = append(o.runs, fmt.Sprintf("%s %s %s %s", hook, operation, phase, result))
+}
+
+// observed returns the runs observed once there are n of them, failing t if
+// they are not observed within a second.
+func (o *hookObserver) observed(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		o.mu.Lock()
+		runs := append([]string(nil), o.runs...)
+		o.mu.Unlock()
+
+		if len(runs) >= n || time.Now().After(deadline) {
+			return runs
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// recordingHook returns a hook appending a line to calls for each of its runs.
+func recordingHook(name string, order int, calls *[]string, operations ...string) device.Hook {
+	record := func(phase string) func(ctx context.Context, m device.Mutation) error {
+		return func(_ context.Context, m device.Mutation) error {
+			before, after := "nil", "nil"
+			if m.Before != nil {
+				before = m.Before.Name
+			}
+			if m.After != nil {
+				after = m.After.Name
+			}
+
+			*calls = append(*calls, fmt.Sprintf("%s %s %s %s->%s", name, phase, m.Operation, before, after))
+			return nil
+		}
+	}
+
+	return device.Hook{
+		Name:       name,
+		Order:      order,
+		Operations: operations,
+		Before:     record(device.PhaseBefore),
+		After:      record(device.PhaseAfter),
+	}
+}
+
+func TestServiceHooks(t *testing.T) {
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	var calls []string
+	o := &hookObserver{}
+	s := device.NewService(device.NewMemoryRepository(),
+		device.WithHooks(
+			recordingHook("second", 10, &calls),
+			recordingHook("first", 0, &calls),
+			recordingHook("deletes", 0, &calls, device.OperationDelete),
+		),
+		device.WithHookObserver(o),
+	)
+
+	d, err := s.CreateDevice(ctx, device.CreateDeviceRequest{Name: "Pixel", Brand: "Google", State: device.StateAvailable})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	name := "Pixel 9"
+	if err := s.UpdateDevice(ctx, d.ID, device.UpdateDeviceRequest{Name: &name}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if err := s.DeleteDevice(ctx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert the hooks run in order, on their operations, with the devices
+	// before and after each change
+
+	want := []string{
+		"first before create nil->Pixel",
+		"second before create nil->Pixel",
+		"first after create nil->Pixel",
+		"second after create nil->Pixel",
+		"first before update Pixel->Pixel 9",
+		"second before update Pixel->Pixel 9",
+		"first after update Pixel->Pixel 9",
+		"second after update Pixel->Pixel 9",
+		"first before delete Pixel 9->nil",
+		"deletes before delete Pixel 9->nil",
+		"second before delete Pixel 9->nil",
+		"first after delete Pixel 9->nil",
+		"deletes after delete Pixel 9->nil",
+		"second after delete Pixel 9->nil",
+	}
+
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Fatalf("expected calls %q, got: %q", want, calls)
+	}
+
+	if runs := o.observed(t, len(want)); len(runs) != len(want) || runs[0] != "first create before ok" {
+		t.Fatalf("expected every run to be observed, got: %q", runs)
+	}
+}
+
+func TestServiceHookVeto(t *testing.T) {
+	errName := errors.New("names must start with a brand")
+
+	var testCases = map[string]struct {
+		wantErr  error
+		wantRuns []string
+		before   func(ctx context.Context, m device.Mutation) error
+	}{
+		"hook returns error": {
+			wantErr:  errName,
+			wantRuns: []string{"veto create before error"},
+			before: func(_ context.Context, _ device.Mutation) error {
+				return errName
+			},
+		},
+		"hook times out": {
+			wantErr:  context.DeadlineExceeded,
+			wantRuns: []string{"veto create before timeout", "veto create before abandoned"},
+			before: func(ctx context.Context, _ device.Mutation) error {
+				<-ctx.Done()
+				return nil
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+			repo := device.NewMemoryRepository()
+
+			var calls []string
+			o := &hookObserver{}
+			s := device.NewService(repo,
+				device.WithHooks(
+					device.Hook{Name: "veto", Timeout: 10 * time.Millisecond, Before: tc.before},
+					recordingHook("next", 1, &calls),
+				),
+				device.WithHookObserver(o),
+			)
+
+			_, err := s.CreateDevice(ctx, device.CreateDeviceRequest{Name: "Pixel", Brand: "Google", State: device.StateAvailable})
+
+			var veto *device.VetoError
+			if !errors.As(err, &veto) || veto.Hook != "veto" {
+				t.Fatalf("expected a veto of hook veto, got: %v", err)
+			}
+
+			if !errors.Is(err, device.ErrVetoed) || !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			// assert the change is neither stored nor passed on to the next
+			// hooks
+
+			ds, err := repo.ListDevices(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if len(ds) != 0 || len(calls) != 0 {
+				t.Fatalf("expected the change to be dropped, got: %d devices and calls %q", len(ds), calls)
+			}
+
+			if runs := o.observed(t, len(tc.wantRuns)); fmt.Sprint(runs) != fmt.Sprint(tc.wantRuns) {
+				t.Fatalf("expected runs %q, got: %q", tc.wantRuns, runs)
+			}
+		})
+	}
+}
+
+func TestServiceHookAfterError(t *testing.T) {
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	o := &hookObserver{}
+	s := device.NewService(device.NewMemoryRepository(),
+		device.WithHooks(device.Hook{
+			Name: "sync",
+			After: func(_ context.Context, _ device.Mutation) error {
+				return errors.New("inventory unreachable")
+			},
+		}),
+		device.WithHookObserver(o),
+	)
+
+	// assert the failures of hooks run after the change do not fail it
+
+	d, err := s.CreateDevice(ctx, device.CreateDeviceRequest{Name: "Pixel", Brand: "Google", State: device.StateAvailable})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := s.FindByID(ctx, d.ID); err != nil {
+		t.Fatalf("expected the device to be stored, got: %v", err)
+	}
+
+	if runs := o.observed(t, 1); len(runs) != 1 || runs[0] != "sync create after error" {
+		t.Fatalf("expected a failed run, got: %q", runs)
+	}
+}
+
+func TestServiceHookAbandoned(t *testing.T) {
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	release := make(chan struct{})
+	o := &hookObserver{}
+	s := device.NewService(device.NewMemoryRepository(),
+		device.WithHooks(device.Hook{
+			Name:    "stuck",
+			Timeout: 10 * time.Millisecond,
+			Before: func(_ context.Context, _ device.Mutation) error {
+				<-release
+				return nil
+			},
+		}),
+		device.WithHookObserver(o),
+	)
+
+	if _, err := s.CreateDevice(ctx, device.CreateDeviceRequest{Name: "Pixel", Brand: "Google", State: device.StateAvailable}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error: %v, got: %v", context.DeadlineExceeded, err)
+	}
+
+	// assert a run ignoring its context is reported as abandoned once it
+	// returns
+
+	if runs := o.observed(t, 1); fmt.Sprint(runs) != fmt.Sprint([]string{"stuck create before timeout"}) {
+		t.Fatalf("expected a timed out run, got: %q", runs)
+	}
+
+	close(release)
+
+	want := []string{"stuck create before timeout", "stuck create before abandoned"}
+	if runs := o.observed(t, len(want)); fmt.Sprint(runs) != fmt.Sprint(want) {
+		t.Fatalf("expected runs %q, got: %q", want, runs)
+	}
+}
+
+func TestServiceHookQueue(t *testing.T) {
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+	var calls []string
+	o := &hookObserver{}
+	q := device.NewHookQueue(1)
+	s := device.NewService(device.NewMemoryRepository(),
+		device.WithHooks(recordingHook("sync", 0, &calls, device.OperationCreate)),
+		device.WithHookQueue(q),
+		device.WithHookObserver(o),
+	)
+
+	for _, name := range []string{"Pixel", "Galaxy"} {
+		if _, err := s.CreateDevice(ctx, device.CreateDeviceRequest{Name: name, Brand: "Google", State: device.StateAvailable}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	// assert the After functions wait for the queue to run, and the hooks of
+	// the changes made while it is full are dropped
+
+	want := []string{"sync before create nil->Pixel", "sync before create nil->Galaxy"}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Fatalf("expected calls %q, got: %q", want, calls)
+	}
+
+	if runs := o.observed(t, 3); runs[len(runs)-1] != "sync create after dropped" {
+		t.Fatalf("expected a dropped run, got: %q", runs)
+	}
+
+	// assert the queued hooks still run once the queue is stopped
+
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	q.Run(stopped)
+
+	want = append(want, "sync after create nil->Pixel")
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Fatalf("expected calls %q, got: %q", want, calls)
+	}
+}
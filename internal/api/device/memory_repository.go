@@ -0,0 +1,215 @@
+package device
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// memoryRepository is a DeviceRepository keeping devices in memory, with the
+// same tenant scoping, not-found and state constraint semantics as the gorm
+// implementation. Devices are copied in and out, so callers never share them
+// with the store.
+type memoryRepository struct {
+	mu      sync.RWMutex
+	devices map[uuid.UUID]*Device
+	// order holds the device IDs in insertion order, so lists are stable.
+	order  []uuid.UUID
+	states StateFinder
+}
+
+type MemoryRepositoryOption func(*memoryRepository)
+
+// WithMemoryStates sets the states devices can be in, like the foreign key
+// from the devices table to the device_states table. Only the built-in states
+// exist by default.
+func WithMemoryStates(f StateFinder) MemoryRepositoryOption {
+	return func(r *memoryRepository) {
+		r.states = f
+	}
+}
+
+// NewMemoryRepository returns an empty, thread-safe in-memory DeviceRepository.
+func NewMemoryRepository(opts ...MemoryRepositoryOption) DeviceRepository {
+	r := &memoryRepository{
+		devices: make(map[uuid.UUID]*Device),
+		states:  devicestate.NewMemoryRepository(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (r *memoryRepository) InsertDevice(ctx context.Context, device *Device) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkState(ctx, device.State); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.devices[device.ID]; ok {
+		return fmt.Errorf("%w: duplicate device ID %s", ErrDuplicate, device.ID)
+	}
+
+	device.TenantID = tenantID
+	d := *device
+	r.devices[d.ID] = &d
+	r.order = append(r.order, d.ID)
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateDevice(ctx context.Context, device *Device) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkState(ctx, device.State); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// like the UPDATE it mirrors, updating a missing device is a no-op
+	d, ok := r.devices[device.ID]
+	if !ok || d.TenantID != tenantID {
+		return nil
+	}
+
+	d.Name = device.Name
+	d.Brand = device.Brand
+	d.State = device.State
+
+	return nil
+}
+
+func (r *memoryRepository) ListDevices(ctx context.Context) (Devices, error) {
+	return r.filter(ctx, func(*Device) bool { return true })
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Device, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.devices[ID]
+	if !ok || d.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+
+	found := *d
+	return &found, nil
+}
+
+func (r *memoryRepository) FindByState(ctx context.Context, state string) (Devices, error) {
+	return r.filter(ctx, func(d *Device) bool { return d.State == state })
+}
+
+func (r *memoryRepository) FindByBrand(ctx context.Context, brand string) (Devices, error) {
+	return r.filter(ctx, func(d *Device) bool { return d.Brand == brand })
+}
+
+func (r *memoryRepository) DeleteDevice(ctx context.Context, ID uuid.UUID) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.devices[ID]
+	if !ok || d.TenantID != tenantID {
+		return nil
+	}
+
+	delete(r.devices, ID)
+	r.order = slices.DeleteFunc(r.order, func(id uuid.UUID) bool { return id == ID })
+
+	return nil
+}
+
+// CountDevices reports the device inventory of every tenant, it is not scoped
+// to the tenant of ctx.
+func (r *memoryRepository) CountDevices(ctx context.Context) ([]DeviceCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[DeviceCount]int64)
+	for _, d := range r.devices {
+		counts[DeviceCount{TenantID: d.TenantID, State: d.State, Brand: d.Brand}]++
+	}
+
+	dcs := make([]DeviceCount, 0, len(counts))
+	for dc, n := range counts {
+		dc.Count = n
+		dcs = append(dcs, dc)
+	}
+
+	slices.SortFunc(dcs, func(a, b DeviceCount) int {
+		return cmp.Or(
+			cmp.Compare(a.TenantID.String(), b.TenantID.String()),
+			cmp.Compare(a.State, b.State),
+			cmp.Compare(a.Brand, b.Brand),
+		)
+	})
+
+	return dcs, nil
+}
+
+// filter returns copies of the devices of the tenant of ctx matching keep.
+func (r *memoryRepository) filter(ctx context.Context, keep func(*Device) bool) (Devices, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ds := make(Devices, 0)
+	for _, ID := range r.order {
+		d := r.devices[ID]
+		if d.TenantID != tenantID || !keep(d) {
+			continue
+		}
+
+		found := *d
+		ds = append(ds, &found)
+	}
+
+	return ds, nil
+}
+
+// checkState mirrors the foreign key from the devices to their state.
+func (r *memoryRepository) checkState(ctx context.Context, state string) error {
+	_, err := r.states.FindByName(ctx, state)
+	if errors.Is(err, devicestate.ErrNotFound) {
+		return fmt.Errorf("%w: unknown state %q", ErrInvalidTransition, state)
+	}
+
+	return err
+}
@@ -0,0 +1,31 @@
+package device_test
+
+import (
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/device/devicetest"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	t.Parallel()
+
+	devicetest.TestRepository(t, memoryFactory)
+}
+
+func TestMemoryRepositoryCountDevices(t *testing.T) {
+	t.Parallel()
+
+	testCountDevices(t, memoryFactory(t))
+}
+
+func memoryFactory(t *testing.T) devicetest.Setup {
+	return devicetest.Setup{
+		Repo:    device.NewMemoryRepository(),
+		TenantA: organization.DefaultID,
+		TenantB: uuid.New(),
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 type Device struct {
 	ID        uuid.UUID `gorm:"primarykey"`
+	TenantID  uuid.UUID
 	Name      string
 	Brand     string
 	State     string
@@ -17,23 +18,24 @@ type Device struct {
 type Devices []*Device
 
 type DTO struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Brand     string    `json:"brand"`
-	State     string    `json:"state"`
-	CreatedAt string    `json:"created_at"`
+	ID        uuid.UUID `json:"id" yaml:"id"`
+	TenantID  uuid.UUID `json:"tenant_id" yaml:"tenant_id"`
+	Name      string    `json:"name" yaml:"name"`
+	Brand     string    `json:"brand" yaml:"brand"`
+	State     string    `json:"state" yaml:"state"`
+	CreatedAt string    `json:"created_at" yaml:"created_at"`
 }
 
 type CreateDeviceRequest struct {
-	Name  string `json:"name" validate:"required,max=255"`
-	Brand string `json:"brand" validate:"required,max=255"`
-	State string `json:"state" validate:"required,oneof=available in_use inactive"`
+	Name  string `json:"name" yaml:"name" validate:"required,max=255"`
+	Brand string `json:"brand" yaml:"brand" validate:"required,max=255"`
+	State string `json:"state" yaml:"state" validate:"required,max=64,device_state"`
 }
 
 type UpdateDeviceRequest struct {
-	Name  *string `json:"name"`
-	Brand *string `json:"brand"`
-	State *string `json:"state" validate:"omitempty,oneof=available in_use inactive"`
+	Name  *string `json:"name" yaml:"name"`
+	Brand *string `json:"brand" yaml:"brand"`
+	State *string `json:"state" yaml:"state" validate:"omitempty,max=64,device_state"`
 }
 
 func (r *UpdateDeviceRequest) Apply(d *Device) {
@@ -63,6 +65,7 @@ func NewDevice(name, brand, state string) *Device {
 func (d *Device) ToDto() *DTO {
 	return &DTO{
 		ID:        d.ID,
+		TenantID:  d.TenantID,
 		Name:      d.Name,
 		Brand:     d.Brand,
 		State:     d.State,
@@ -1,83 +1,294 @@
 package device
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/organization"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type DeviceRepository interface {
-	InsertDevice(device *Device) error
-	UpdateDevice(device *Device) error
-	ListDevices() (Devices, error)
-	FindByID(ID uuid.UUID) (*Device, error)
-	FindByState(state string) (Devices, error)
-	FindByBrand(brand string) (Devices, error)
-	DeleteDevice(ID uuid.UUID) error
+	InsertDevice(ctx context.Context, device *Device) error
+	UpdateDevice(ctx context.Context, device *Device) error
+	ListDevices(ctx context.Context) (Devices, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Device, error)
+	FindByState(ctx context.Context, state string) (Devices, error)
+	FindByBrand(ctx context.Context, brand string) (Devices, error)
+	DeleteDevice(ctx context.Context, ID uuid.UUID) error
+}
+
+// DeviceCount is the number of devices of a tenant sharing a state and brand.
+type DeviceCount struct {
+	TenantID uuid.UUID
+	State    string
+	Brand    string
+	Count    int64
+}
+
+// DeviceCounter is implemented by repositories able to report the device
+// inventory across all tenants.
+type DeviceCounter interface {
+	CountDevices(ctx context.Context) ([]DeviceCount, error)
+}
+
+// DevicesInState reports, from the inventory of c, whether devices of any
+// tenant are in a state, see devicestate.WithUsage.
+func DevicesInState(c DeviceCounter) devicestate.UsageFunc {
+	return func(ctx context.Context, state string) (bool, error) {
+		counts, err := c.CountDevices(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		return slices.ContainsFunc(counts, func(dc DeviceCount) bool { return dc.State == state }), nil
+	}
+}
+
+type RepositoryOption func(*deviceRepository)
+
+// WithRowLevelSecurity makes the repository set the app.tenant_id setting on
+// every transaction, so the PostgreSQL row-level security policy on the devices
+// table enforces tenant isolation in addition to the query filters. Row-level
+// security must be enabled on the table, see SetupRowLevelSecurity.
+func WithRowLevelSecurity() RepositoryOption {
+	return func(r *deviceRepository) {
+		r.rowLevelSecurity = true
+	}
 }
 
 type deviceRepository struct {
-	db *gorm.DB
+	db               *gorm.DB
+	rowLevelSecurity bool
 }
 
-func NewRepository(db *gorm.DB) DeviceRepository {
-	return &deviceRepository{
+// NewRepository expects db to translate driver errors, see database.Open.
+// Errors are reported as the errors of this package, such as ErrNotFound.
+func NewRepository(db *gorm.DB, opts ...RepositoryOption) DeviceRepository {
+	r := &deviceRepository{
 		db: db,
 	}
-}
 
-func (r *deviceRepository) InsertDevice(device *Device) error {
-	if err := r.db.Create(device).Error; err != nil {
-		return err
+	for _, opt := range opts {
+		opt(r)
 	}
 
-	return nil
+	return r
 }
 
-func (r *deviceRepository) UpdateDevice(device *Device) error {
-	res := r.db.Model(&Device{}).
-		Select("name", "brand", "state").
-		Where("id = ?", device.ID).
-		Updates(device)
+func (r *deviceRepository) InsertDevice(ctx context.Context, device *Device) error {
+	err := r.withTenant(ctx, func(tx *gorm.DB, tenantID uuid.UUID) error {
+		device.TenantID = tenantID
+		return tx.Create(device).Error
+	})
 
-	return res.Error
+	return r.checkState(ctx, device.State, err)
 }
 
-func (r *deviceRepository) ListDevices() (Devices, error) {
+func (r *deviceRepository) UpdateDevice(ctx context.Context, device *Device) error {
+	err := r.withTenant(ctx, func(tx *gorm.DB, tenantID uuid.UUID) error {
+		return tx.Model(&Device{}).
+			Select("name", "brand", "state").
+			Where("id = ? AND tenant_id = ?", device.ID, tenantID).
+			Updates(device).Error
+	})
+
+	return r.checkState(ctx, device.State, err)
+}
+
+func (r *deviceRepository) ListDevices(ctx context.Context) (Devices, error) {
 	ds := make(Devices, 0)
-	if err := r.db.Find(&ds).Error; err != nil {
+	err := r.withTenant(ctx, func(tx *gorm.DB, tenantID uuid.UUID) error {
+		return tx.Where("tenant_id = ?", tenantID).Find(&ds).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return ds, nil
 }
 
-func (r *deviceRepository) FindByID(ID uuid.UUID) (*Device, error) {
+func (r *deviceRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Device, error) {
 	d := &Device{}
-	if err := r.db.Where("id = ?", ID).First(&d).Error; err != nil {
+	err := r.withTenant(ctx, func(tx *gorm.DB, tenantID uuid.UUID) error {
+		return tx.Where("id = ? AND tenant_id = ?", ID, tenantID).First(&d).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return d, nil
 }
 
-func (r *deviceRepository) FindByState(state string) (Devices, error) {
+func (r *deviceRepository) FindByState(ctx context.Context, state string) (Devices, error) {
 	ds := make(Devices, 0)
-	if err := r.db.Where("state = ?", state).Find(&ds).Error; err != nil {
+	err := r.withTenant(ctx, func(tx *gorm.DB, tenantID uuid.UUID) error {
+		return tx.Where("state = ? AND tenant_id = ?", state, tenantID).Find(&ds).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return ds, nil
 }
 
-func (r *deviceRepository) FindByBrand(brand string) (Devices, error) {
+func (r *deviceRepository) FindByBrand(ctx context.Context, brand string) (Devices, error) {
 	ds := make(Devices, 0)
-	if err := r.db.Where("brand = ?", brand).Find(&ds).Error; err != nil {
+	err := r.withTenant(ctx, func(tx *gorm.DB, tenantID uuid.UUID) error {
+		return tx.Where("brand = ? AND tenant_id = ?", brand, tenantID).Find(&ds).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return ds, nil
 }
 
-func (r *deviceRepository) DeleteDevice(ID uuid.UUID) error {
-	return r.db.Where("id = ?", ID).Delete(&Device{}).Error
+func (r *deviceRepository) DeleteDevice(ctx context.Context, ID uuid.UUID) error {
+	return r.withTenant(ctx, func(tx *gorm.DB, tenantID uuid.UUID) error {
+		return tx.Where("id = ? AND tenant_id = ?", ID, tenantID).Delete(&Device{}).Error
+	})
+}
+
+// SetupRowLevelSecurity enables, or disables, row-level security on the
+// devices table of a PostgreSQL database, as the repositories of db are given
+// WithRowLevelSecurity or not: the policies of the table hide every device
+// from the transactions that do not set app.tenant_id. The table is only
+// altered when its setting differs, which requires the role of db to own it.
+// It is left alone until the migrations create it.
+func SetupRowLevelSecurity(ctx context.Context, db *gorm.DB, enabled bool) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	var tables []struct {
+		Enabled bool
+		Forced  bool
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT relrowsecurity AS enabled, relforcerowsecurity AS forced
+		FROM pg_class
+		WHERE oid = to_regclass('devices')`).Scan(&tables).Error
+	if err != nil || len(tables) == 0 {
+		return err
+	}
+
+	if t := tables[0]; t.Enabled == enabled && t.Forced == enabled {
+		return nil
+	}
+
+	stmts := []string{"ALTER TABLE devices ENABLE ROW LEVEL SECURITY", "ALTER TABLE devices FORCE ROW LEVEL SECURITY"}
+	if !enabled {
+		stmts = []string{"ALTER TABLE devices NO FORCE ROW LEVEL SECURITY", "ALTER TABLE devices DISABLE ROW LEVEL SECURITY"}
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CountDevices reports the device inventory of every tenant, it is not scoped
+// to the tenant of ctx.
+func (r *deviceRepository) CountDevices(ctx context.Context) ([]DeviceCount, error) {
+	counts := make([]DeviceCount, 0)
+	count := func(tx *gorm.DB) error {
+		return tx.Model(&Device{}).
+			Select("tenant_id, state, brand, COUNT(*) AS count").
+			Group("tenant_id, state, brand").
+			Scan(&counts).Error
+	}
+
+	db := r.db.WithContext(ctx)
+	if !r.rowLevelSecurity {
+		if err := count(db); err != nil {
+			return nil, err
+		}
+
+		return counts, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// the policy on the devices table only lets the transactions that
+		// set app.all_tenants read the devices of every tenant
+		if err := tx.Exec("SELECT set_config('app.all_tenants', 'on', true)").Error; err != nil {
+			return err
+		}
+
+		return count(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// checkState reports the foreign key violation err of a device in an unknown
+// state as an invalid transition rather than a conflict. SQLite does not name
+// the violated foreign key, so the state is looked up, after the failed
+// statement so an aborted transaction does not get in the way.
+func (r *deviceRepository) checkState(ctx context.Context, state string, err error) error {
+	if !errors.Is(err, ErrConflict) {
+		return err
+	}
+
+	var n int64
+	if r.db.WithContext(ctx).Model(&devicestate.State{}).Where("name = ?", state).Count(&n).Error == nil && n == 0 {
+		return fmt.Errorf("%w: unknown state %q", ErrInvalidTransition, state)
+	}
+
+	return err
+}
+
+// withTenant runs fn with the tenant resolved from ctx. When row-level security
+// is enabled fn runs inside a transaction with app.tenant_id set locally.
+// Errors are translated with translateError.
+func (r *deviceRepository) withTenant(ctx context.Context, fn func(tx *gorm.DB, tenantID uuid.UUID) error) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	db := r.db.WithContext(ctx)
+	if !r.rowLevelSecurity {
+		return translateError(fn(db, tenantID))
+	}
+
+	return translateError(db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config('app.tenant_id', ?, true)", tenantID.String()).Error; err != nil {
+			return err
+		}
+
+		return fn(tx, tenantID)
+	}))
+}
+
+// translateError maps the gorm and driver errors of a query to the errors of
+// this package, returning other errors unchanged.
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return ErrConflict
+	case errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return ErrInvalidTransition
+	default:
+		return err
+	}
 }
@@ -1,248 +1,228 @@
 package device_test
 
 import (
+	"context"
+	"errors"
+	"regexp"
 	"testing"
 
 	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/device/devicetest"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/database"
 	"github.com/hferr/device-manager/test"
 
 	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func TestInsertDevice(t *testing.T) {
-	cleanup, db := test.SetupTestDBContainer(t)
-	defer cleanup()
-
-	repo := device.NewRepository(db)
-
-	// assert valid device creation
+func TestRepository(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
 
-	d := device.NewDevice("test", "test", "available")
-
-	if err := repo.InsertDevice(d); err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
-
-	// assert invalid device creation (invalid state)
-
-	invalidDevice := device.NewDevice("test", "test", "invalid")
-	if err := repo.InsertDevice(invalidDevice); err == nil {
-		t.Fatal("expected error, got none")
+			devicetest.TestRepository(t, gormFactory(db))
+		})
 	}
 }
 
-func TestListDevices(t *testing.T) {
+func TestRepositoryRowLevelSecurity(t *testing.T) {
 	cleanup, db := test.SetupTestDBContainer(t)
 	defer cleanup()
 
-	repo := device.NewRepository(db)
-
-	wantedDeviceListLen := 4
-	for range wantedDeviceListLen {
-		err := repo.InsertDevice(
-			device.NewDevice("test", "test", "in_use"),
-		)
-		if err != nil {
-			t.Fatalf("expected no error, got: %v", err)
-		}
-	}
-
-	ds, err := repo.ListDevices()
-	if err != nil {
+	if err := device.SetupRowLevelSecurity(context.Background(), db, true); err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	if wantedDeviceListLen != len(ds) {
-		t.Fatalf("wanted device list len to be %d, got %d", wantedDeviceListLen, len(ds))
-	}
-}
-
-func TestFindByID(t *testing.T) {
-	cleanup, db := test.SetupTestDBContainer(t)
-	defer cleanup()
-
-	repo := device.NewRepository(db)
-
-	// create and assert device when finding by ID
-
-	device := device.NewDevice("test", "test", "in_use")
-	if err := repo.InsertDevice(device); err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
+	appDB := rowLevelSecurityDB(t, db)
 
-	deviceFromDB, err := repo.FindByID(device.ID)
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
+	devicetest.TestRepository(t, gormFactoryOn(db, appDB, device.WithRowLevelSecurity()))
 
-	if device.ID != deviceFromDB.ID {
-		t.Fatalf("expected device ID: %s, got: %s", device.ID, deviceFromDB.ID)
-	}
+	t.Run("policy hides the devices of other tenants", func(t *testing.T) {
+		s := gormFactory(db)(t)
+		ctxA := organization.WithTenant(context.Background(), s.TenantA)
+		ctxB := organization.WithTenant(context.Background(), s.TenantB)
 
-	// assert device not found case
+		if err := s.Repo.InsertDevice(ctxA, device.NewDevice("test", "pixel", device.StateAvailable)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := s.Repo.InsertDevice(ctxB, device.NewDevice("test", "pixel", device.StateAvailable)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
 
-	_, err = repo.FindByID(uuid.New())
-	if err == nil {
-		t.Fatal("expected error, got none")
-	}
+		// assert queries without a tenant filter only see the tenant set
+		// the way WithRowLevelSecurity does, and nothing without one
+
+		for name, tenant := range map[string]string{"tenant": s.TenantA.String(), "no tenant": ""} {
+			var tenants []uuid.UUID
+			err := appDB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec("SELECT set_config('app.tenant_id', ?, true)", tenant).Error; err != nil {
+					return err
+				}
+
+				return tx.Raw("SELECT tenant_id FROM devices").Scan(&tenants).Error
+			})
+			if err != nil {
+				t.Fatalf("%s: expected no error, got: %v", name, err)
+			}
+
+			want := 1
+			if tenant == "" {
+				want = 0
+			}
+			if len(tenants) != want || (want == 1 && tenants[0] != s.TenantA) {
+				t.Fatalf("%s: expected %d devices of tenant %s, got: %v", name, want, s.TenantA, tenants)
+			}
+		}
+	})
 
-	if err != gorm.ErrRecordNotFound {
-		t.Fatalf("expected error: %v, got: %v", gorm.ErrRecordNotFound, err)
-	}
+	t.Run("inventory spans every tenant", func(t *testing.T) {
+		testCountDevices(t, gormFactoryOn(db, appDB, device.WithRowLevelSecurity())(t))
+	})
 }
 
-func TestDeleteDevice(t *testing.T) {
-	cleanup, db := test.SetupTestDBContainer(t)
-	defer cleanup()
-
-	repo := device.NewRepository(db)
-
-	d := device.NewDevice("test", "test", "available")
-	if err := repo.InsertDevice(d); err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
-
-	err := repo.DeleteDevice(d.ID)
-	if err != nil {
-		t.Fatal("expected error, got none")
+// rowLevelSecurityDB connects to the database of db, as superuser, with a
+// regular role that row-level security applies to.
+func rowLevelSecurityDB(t *testing.T, db *gorm.DB) *gorm.DB {
+	t.Helper()
+
+	const role = "device_manager_rls_test"
+
+	for _, stmt := range []string{
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = '` + role + `') THEN
+				CREATE ROLE ` + role + ` LOGIN PASSWORD '` + role + `';
+			END IF;
+		END $$`,
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON devices TO " + role,
+		"GRANT SELECT ON organizations, device_states TO " + role,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("failed to set up role %s: %v", role, err)
+		}
 	}
-}
-
-func TestFindByState(t *testing.T) {
-	cleanup, db := test.SetupTestDBContainer(t)
-	defer cleanup()
-
-	repo := device.NewRepository(db)
 
-	devicesInUse := []*device.Device{
-		device.NewDevice("test", "test", "in_use"),
-		device.NewDevice("test", "test", "in_use"),
-		device.NewDevice("test", "test", "in_use"),
+	d, ok := db.Dialector.(*postgres.Dialector)
+	if !ok {
+		t.Fatalf("expected a postgres database, got: %s", db.Dialector.Name())
 	}
 
-	devicesAvailable := []*device.Device{
-		device.NewDevice("test", "test", "available"),
-		device.NewDevice("test", "test", "available"),
+	dsn := regexp.MustCompile(`user=\S+ password=\S+`).ReplaceAllString(d.Config.DSN, "user="+role+" password="+role)
+	appDB, err := database.Open(postgres.Open(dsn), &gorm.Config{}, database.WithoutMigrations())
+	if err != nil {
+		t.Fatalf("failed to connect as %s: %v", role, err)
 	}
 
-	allDevices := append(devicesInUse, devicesAvailable...)
-
-	for _, d := range allDevices {
-		if err := repo.InsertDevice(d); err != nil {
-			t.Fatalf("expected no error, got: %v", err)
+	t.Cleanup(func() {
+		if dbHandle, err := appDB.DB(); err == nil {
+			dbHandle.Close()
 		}
-	}
+	})
 
-	// fetch by state 'in_use'
-
-	devicesInUseFromDB, err := repo.FindByState("in_use")
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
+	return appDB
+}
 
-	if len(devicesInUse) != len(devicesInUseFromDB) {
-		t.Fatalf("expected %d devices 'in_use', got: %d", len(devicesInUse), len(devicesInUseFromDB))
-	}
+func TestRepositoryUnknownTenant(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
 
-	// fetch by state 'available'
+			repo := device.NewRepository(db)
+			ctx := organization.WithTenant(context.Background(), uuid.New())
 
-	devicesAvailableFromDB, err := repo.FindByState("available")
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
+			// assert the foreign key violation is reported as a conflict
 
-	if len(devicesAvailable) != len(devicesAvailableFromDB) {
-		t.Fatalf("expected %d devices 'in_use', got: %d", len(devicesAvailable), len(devicesAvailableFromDB))
+			err := repo.InsertDevice(ctx, device.NewDevice("test", "test", device.StateAvailable))
+			if !errors.Is(err, device.ErrConflict) {
+				t.Fatalf("expected error: %v, got: %v", device.ErrConflict, err)
+			}
+		})
 	}
 }
 
-func TestFindByBrand(t *testing.T) {
-	cleanup, db := test.SetupTestDBContainer(t)
-	defer cleanup()
-
-	repo := device.NewRepository(db)
+func TestCountDevices(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
 
-	devicesWithBrand1 := []*device.Device{
-		device.NewDevice("test", "cool_brand", "in_use"),
-		device.NewDevice("test", "cool_brand", "in_use"),
-		device.NewDevice("test", "cool_brand", "in_use"),
-	}
-
-	devicesWithBrand2 := []*device.Device{
-		device.NewDevice("test", "nice_brand", "available"),
-		device.NewDevice("test", "nice_brand", "available"),
+			testCountDevices(t, gormFactory(db)(t))
+		})
 	}
+}
 
-	allDevices := append(devicesWithBrand1, devicesWithBrand2...)
+// gormFactory returns a factory emptying the devices table of db and creating
+// a second organization for every subtest.
+func gormFactory(db *gorm.DB, opts ...device.RepositoryOption) devicetest.Factory {
+	return gormFactoryOn(db, db, opts...)
+}
 
-	for _, d := range allDevices {
-		if err := repo.InsertDevice(d); err != nil {
-			t.Fatalf("expected no error, got: %v", err)
+// gormFactoryOn is gormFactory setting up the subtests through db, and
+// returning a repository of repoDB.
+func gormFactoryOn(db, repoDB *gorm.DB, opts ...device.RepositoryOption) devicetest.Factory {
+	return func(t *testing.T) devicetest.Setup {
+		if err := db.Exec("DELETE FROM devices").Error; err != nil {
+			t.Fatalf("failed to empty devices table: %v", err)
 		}
-	}
-
-	// fetch by brand 'cool_brand'
-
-	devicesWithBrand1FromDB, err := repo.FindByBrand("cool_brand")
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
-
-	if len(devicesWithBrand1) != len(devicesWithBrand1FromDB) {
-		t.Fatalf("expected %d devices, got: %d", len(devicesWithBrand1), len(devicesWithBrand1FromDB))
-	}
 
-	// fetch by brand 'nice_brand'
-
-	devicesWithBrand2FromDB, err := repo.FindByBrand("nice_brand")
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
+		other := organization.NewOrganization("other-" + uuid.NewString())
+		if err := organization.NewRepository(db).InsertOrganization(context.Background(), other); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
 
-	if len(devicesWithBrand2) != len(devicesWithBrand2FromDB) {
-		t.Fatalf("expected %d devices, got: %d", len(devicesWithBrand2), len(devicesWithBrand2FromDB))
+		return devicetest.Setup{
+			Repo:    device.NewRepository(repoDB, opts...),
+			TenantA: organization.DefaultID,
+			TenantB: other.ID,
+		}
 	}
 }
 
-func TestUpdateDevice(t *testing.T) {
-	cleanup, db := test.SetupTestDBContainer(t)
-	defer cleanup()
-
-	repo := device.NewRepository(db)
-
-	// create a device to update
-
-	d := device.NewDevice("test", "test", "available")
-	if err := repo.InsertDevice(d); err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
-
-	// update the device
-
-	wantName, wantBrand, wantState := "updated_test", "updated_test", "in_use"
-	d.Name, d.Brand, d.State = wantName, wantBrand, wantState
-
-	if err := repo.UpdateDevice(d); err != nil {
-		t.Fatalf("expected no error, got: %v", err)
+// testCountDevices asserts the inventory of a DeviceCounter spans every tenant.
+func testCountDevices(t *testing.T, s devicetest.Setup) {
+	counter, ok := s.Repo.(device.DeviceCounter)
+	if !ok {
+		t.Fatal("expected repository to implement device.DeviceCounter")
+	}
+
+	ctxA := organization.WithTenant(context.Background(), s.TenantA)
+	ctxB := organization.WithTenant(context.Background(), s.TenantB)
+
+	for _, in := range []struct {
+		ctx context.Context
+		d   *device.Device
+	}{
+		{ctxA, device.NewDevice("test", "pixel", device.StateAvailable)},
+		{ctxA, device.NewDevice("test", "pixel", device.StateAvailable)},
+		{ctxA, device.NewDevice("test", "pixel", device.StateInUse)},
+		{ctxB, device.NewDevice("test", "pixel", device.StateAvailable)},
+	} {
+		if err := s.Repo.InsertDevice(in.ctx, in.d); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
 	}
 
-	// fetch the updated device and assert changes
-
-	updatedDevice, err := repo.FindByID(d.ID)
+	counts, err := counter.CountDevices(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	if wantName != updatedDevice.Name {
-		t.Fatalf("expected name to be %s, got: %s", wantName, updatedDevice.Name)
+	want := map[device.DeviceCount]bool{
+		{TenantID: s.TenantA, State: device.StateAvailable, Brand: "pixel", Count: 2}: true,
+		{TenantID: s.TenantA, State: device.StateInUse, Brand: "pixel", Count: 1}:     true,
+		{TenantID: s.TenantB, State: device.StateAvailable, Brand: "pixel", Count: 1}: true,
 	}
 
-	if wantBrand != updatedDevice.Brand {
-		t.Fatalf("expected brand to be %s, got: %s", wantBrand, updatedDevice.Brand)
+	if len(counts) != len(want) {
+		t.Fatalf("expected %d counts, got: %+v", len(want), counts)
 	}
 
-	if wantState != updatedDevice.State {
-		t.Fatalf("expected state to be %s, got: %s", wantState, updatedDevice.State)
+	for _, c := range counts {
+		if !want[c] {
+			t.Fatalf("unexpected count %+v", c)
+		}
 	}
 }
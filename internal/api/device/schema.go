@@ -0,0 +1,145 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrSchemaDrift reports a devices table the Device model no longer
+	// matches, each mismatch wrapping it.
+	ErrSchemaDrift            = errors.New("schema drift")
+	ErrSchemaCheckUnsupported = errors.New("schema check requires the postgres driver")
+)
+
+// columnTypes are the PostgreSQL types the fields of the Device model can be
+// stored in, by field type.
+var columnTypes = map[reflect.Type][]string{
+	reflect.TypeFor[uuid.UUID](): {"uuid"},
+	reflect.TypeFor[string]():    {"character varying", "text", "character"},
+	reflect.TypeFor[time.Time](): {"timestamp without time zone", "timestamp with time zone"},
+}
+
+// Column describes a column of the devices table.
+type Column struct {
+	Name       string
+	Type       string
+	Nullable   bool
+	HasDefault bool
+}
+
+// Schema describes the devices table as the database defines it.
+type Schema struct {
+	Columns []Column
+}
+
+// InspectSchema reads the schema of the devices table from the database.
+func InspectSchema(ctx context.Context, db *gorm.DB) (*Schema, error) {
+	if db.Dialector.Name() != "postgres" {
+		return nil, ErrSchemaCheckUnsupported
+	}
+
+	model, err := parseModel()
+	if err != nil {
+		return nil, err
+	}
+
+	var s Schema
+
+	err = db.WithContext(ctx).Raw(`
+		SELECT column_name::text AS name,
+			data_type::text AS type,
+			is_nullable = 'YES' AS nullable,
+			column_default IS NOT NULL AS has_default
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ?
+		ORDER BY ordinal_position`, model.Table).Scan(&s.Columns).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// CheckSchema compares s with the Device model, reporting every mismatch.
+func CheckSchema(s *Schema) error {
+	model, err := parseModel()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	drift := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrSchemaDrift, fmt.Sprintf(format, args...)))
+	}
+
+	if len(s.Columns) == 0 {
+		drift("table %s does not exist", model.Table)
+		return errors.Join(errs...)
+	}
+
+	columns := make(map[string]Column, len(s.Columns))
+	for _, c := range s.Columns {
+		columns[c.Name] = c
+	}
+
+	for _, f := range model.Fields {
+		if f.DBName == "" {
+			continue
+		}
+
+		c, ok := columns[f.DBName]
+		if !ok {
+			drift("column %s of field %s does not exist", f.DBName, f.Name)
+			continue
+		}
+		delete(columns, f.DBName)
+
+		if types := columnTypes[f.FieldType]; !slices.Contains(types, c.Type) {
+			drift("column %s is of type %s, which field %s of type %s cannot hold", c.Name, c.Type, f.Name, f.FieldType)
+		}
+
+		// NULL cannot be scanned into a field which is not a pointer
+		if c.Nullable && f.FieldType.Kind() != reflect.Pointer {
+			drift("column %s is nullable, but field %s is not a pointer", c.Name, f.Name)
+		}
+	}
+
+	for _, c := range s.Columns {
+		if _, ok := columns[c.Name]; !ok {
+			continue
+		}
+
+		if !c.Nullable && !c.HasDefault {
+			drift("column %s is required, but has no field in the model", c.Name)
+		} else {
+			drift("column %s has no field in the model", c.Name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// VerifySchema inspects the devices table and compares it with the model, see
+// CheckSchema.
+func VerifySchema(ctx context.Context, db *gorm.DB) error {
+	s, err := InspectSchema(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return CheckSchema(s)
+}
+
+func parseModel() (*schema.Schema, error) {
+	return schema.Parse(&Device{}, &sync.Map{}, schema.NamingStrategy{})
+}
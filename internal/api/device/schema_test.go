@@ -0,0 +1,131 @@
+package device_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/test"
+)
+
+// matchingColumns are the columns of the devices table created by the
+// migrations.
+func matchingColumns() []device.Column {
+	return []device.Column{
+		{Name: "id", Type: "uuid"},
+		{Name: "name", Type: "character varying"},
+		{Name: "brand", Type: "character varying"},
+		{Name: "state", Type: "character varying"},
+		{Name: "created_at", Type: "timestamp without time zone"},
+		{Name: "tenant_id", Type: "uuid"},
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	t.Parallel()
+
+	var testCases = map[string]struct {
+		schema device.Schema
+		// wantDrift are the columns each mismatch is about
+		wantDrift []string
+	}{
+		"matching schema": {
+			schema: device.Schema{Columns: matchingColumns()},
+		},
+		"missing table": {
+			schema:    device.Schema{},
+			wantDrift: []string{"table devices"},
+		},
+		"missing column": {
+			schema:    device.Schema{Columns: matchingColumns()[:4]},
+			wantDrift: []string{"column tenant_id", "column created_at"},
+		},
+		"unmapped columns": {
+			schema: device.Schema{
+				Columns: append(matchingColumns(),
+					device.Column{Name: "serial", Type: "text"},
+					device.Column{Name: "notes", Type: "text", Nullable: true},
+				),
+			},
+			wantDrift: []string{"column serial is required", "column notes"},
+		},
+		"changed types": {
+			schema: device.Schema{
+				Columns: []device.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "character varying"},
+					{Name: "brand", Type: "character varying"},
+					{Name: "state", Type: "boolean"},
+					{Name: "created_at", Type: "date"},
+					{Name: "tenant_id", Type: "uuid"},
+				},
+			},
+			wantDrift: []string{"column id", "column state", "column created_at"},
+		},
+		"nullable column": {
+			schema: device.Schema{
+				Columns: append(matchingColumns()[:5], device.Column{Name: "tenant_id", Type: "uuid", Nullable: true}),
+			},
+			wantDrift: []string{"column tenant_id"},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := device.CheckSchema(&tc.schema)
+			if len(tc.wantDrift) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, device.ErrSchemaDrift) {
+				t.Fatalf("expected error %v, got: %v", device.ErrSchemaDrift, err)
+			}
+
+			// assert every mismatch is reported, one per line
+
+			problems := strings.Split(err.Error(), "\n")
+			if len(problems) != len(tc.wantDrift) {
+				t.Fatalf("expected %d mismatches, got: %q", len(tc.wantDrift), problems)
+			}
+
+			for i, want := range tc.wantDrift {
+				if !strings.Contains(problems[i], want) {
+					t.Fatalf("expected mismatch about %q, got: %q", want, problems[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVerifySchema(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			err := device.VerifySchema(context.Background(), db)
+
+			if driver == "sqlite" {
+				if !errors.Is(err, device.ErrSchemaCheckUnsupported) {
+					t.Fatalf("expected error %v, got: %v", device.ErrSchemaCheckUnsupported, err)
+				}
+				return
+			}
+
+			// assert the migrations match the model
+
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		})
+	}
+}
@@ -1,72 +1,158 @@
 package device
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/utils/logger"
 
 	"github.com/google/uuid"
 )
 
+// The built-in states, more can be added through the devicestate service.
 const (
-	StateAvailable string = "available"
-	StateInUse     string = "in_use"
-	StateInactive  string = "inactive"
-)
-
-var (
-	ErrDeviceInUse = errors.New("operation cannot be completed because the device is in use")
+	StateAvailable string = devicestate.Available
+	StateInUse     string = devicestate.InUse
+	StateInactive  string = devicestate.Inactive
 )
 
 type DeviceService interface {
-	CreateDevice(input CreateDeviceRequest) (*Device, error)
-	UpdateDevice(ID uuid.UUID, input UpdateDeviceRequest) error
-	ListDevices() (Devices, error)
-	FindByID(ID uuid.UUID) (*Device, error)
-	FindByState(state string) (Devices, error)
-	FindByBrand(brand string) (Devices, error)
-	DeleteDevice(ID uuid.UUID) error
+	CreateDevice(ctx context.Context, input CreateDeviceRequest) (*Device, error)
+	UpdateDevice(ctx context.Context, ID uuid.UUID, input UpdateDeviceRequest) error
+	ListDevices(ctx context.Context) (Devices, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Device, error)
+	FindByState(ctx context.Context, state string) (Devices, error)
+	FindByBrand(ctx context.Context, brand string) (Devices, error)
+	DeleteDevice(ctx context.Context, ID uuid.UUID) error
+}
+
+// StateFinder looks up the states devices can be in, along with their rules.
+type StateFinder interface {
+	FindByName(ctx context.Context, name string) (*devicestate.State, error)
+}
+
+// PolicyEvaluator decides whether a change of a device is allowed, returning
+// an error when it is not.
+type PolicyEvaluator interface {
+	Evaluate(ctx context.Context, m Mutation) error
+}
+
+// Approver holds back the changes of devices that require approval.
+type Approver interface {
+	// Hold returns a non-nil error for the changes it holds back, which are
+	// then not stored.
+	Hold(ctx context.Context, m Mutation) error
+}
+
+type ServiceOption func(*deviceService)
+
+// WithStates sets where the states of the devices and their rules are read
+// from, only the built-in states exist by default.
+func WithStates(f StateFinder) ServiceOption {
+	return func(s *deviceService) {
+		s.states = f
+	}
+}
+
+// WithPolicies sets the policies every change of a device is checked against
+// before the hooks run, all changes are allowed by default.
+func WithPolicies(p PolicyEvaluator) ServiceOption {
+	return func(s *deviceService) {
+		s.policies = p
+	}
+}
+
+// WithApprover sets the approver of the updates and deletions of devices,
+// once they passed the rules of their states, the policies and the hooks. No
+// change requires approval by default.
+func WithApprover(a Approver) ServiceOption {
+	return func(s *deviceService) {
+		s.approver = a
+	}
 }
 
 type deviceService struct {
-	repo DeviceRepository
+	repo         DeviceRepository
+	states       StateFinder
+	policies     PolicyEvaluator
+	approver     Approver
+	hooks        []Hook
+	hookObserver HookObserver
+	hookQueue    *HookQueue
+	logger       *slog.Logger
 }
 
-func NewService(r DeviceRepository) DeviceService {
-	return &deviceService{
-		repo: r,
+func NewService(r DeviceRepository, opts ...ServiceOption) DeviceService {
+	s := &deviceService{
+		repo:   r,
+		states: devicestate.NewMemoryRepository(),
+		logger: logger.Discard(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
-func (s *deviceService) CreateDevice(input CreateDeviceRequest) (*Device, error) {
+func (s *deviceService) CreateDevice(ctx context.Context, input CreateDeviceRequest) (*Device, error) {
+	if _, err := s.findState(ctx, input.State); err != nil {
+		return nil, err
+	}
+
 	d := NewDevice(input.Name, input.Brand, input.State)
 
-	if err := s.repo.InsertDevice(d); err != nil {
+	m := Mutation{Operation: OperationCreate, After: d}
+	if err := s.checkMutation(ctx, m); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.InsertDevice(ctx, d); err != nil {
 		return d, err
 	}
 
+	s.runAfter(ctx, m)
+
 	return d, nil
 }
 
-func (s *deviceService) UpdateDevice(ID uuid.UUID, input UpdateDeviceRequest) error {
-	d, err := s.FindByID(ID)
+func (s *deviceService) UpdateDevice(ctx context.Context, ID uuid.UUID, input UpdateDeviceRequest) error {
+	d, err := s.FindByID(ctx, ID)
 	if err != nil {
 		return err
 	}
 
-	if !canUpdateDevice(d, input) {
-		return ErrDeviceInUse
+	if err := s.checkUpdate(ctx, d, input); err != nil {
+		return err
 	}
 
+	before := *d
 	input.Apply(d)
 
-	if err := s.repo.UpdateDevice(d); err != nil {
+	m := Mutation{Operation: OperationUpdate, Before: &before, After: d, Change: &input}
+	if err := s.checkMutation(ctx, m); err != nil {
+		return err
+	}
+
+	if err := s.hold(ctx, m); err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateDevice(ctx, d); err != nil {
 		return err
 	}
 
+	s.runAfter(ctx, m)
+
 	return nil
 }
 
-func (s *deviceService) ListDevices() (Devices, error) {
-	ds, err := s.repo.ListDevices()
+func (s *deviceService) ListDevices(ctx context.Context) (Devices, error) {
+	ds, err := s.repo.ListDevices(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -74,8 +160,8 @@ func (s *deviceService) ListDevices() (Devices, error) {
 	return ds, nil
 }
 
-func (s *deviceService) FindByID(ID uuid.UUID) (*Device, error) {
-	d, err := s.repo.FindByID(ID)
+func (s *deviceService) FindByID(ctx context.Context, ID uuid.UUID) (*Device, error) {
+	d, err := s.repo.FindByID(ctx, ID)
 	if err != nil {
 		return nil, err
 	}
@@ -83,8 +169,8 @@ func (s *deviceService) FindByID(ID uuid.UUID) (*Device, error) {
 	return d, nil
 }
 
-func (s *deviceService) FindByState(state string) (Devices, error) {
-	ds, err := s.repo.FindByState(state)
+func (s *deviceService) FindByState(ctx context.Context, state string) (Devices, error) {
+	ds, err := s.repo.FindByState(ctx, state)
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +178,8 @@ func (s *deviceService) FindByState(state string) (Devices, error) {
 	return ds, nil
 }
 
-func (s *deviceService) FindByBrand(brand string) (Devices, error) {
-	ds, err := s.repo.FindByBrand(brand)
+func (s *deviceService) FindByBrand(ctx context.Context, brand string) (Devices, error) {
+	ds, err := s.repo.FindByBrand(ctx, brand)
 	if err != nil {
 		return nil, err
 	}
@@ -101,29 +187,97 @@ func (s *deviceService) FindByBrand(brand string) (Devices, error) {
 	return ds, nil
 }
 
-func (s *deviceService) DeleteDevice(ID uuid.UUID) error {
-	d, err := s.FindByID(ID)
+func (s *deviceService) DeleteDevice(ctx context.Context, ID uuid.UUID) error {
+	d, err := s.FindByID(ctx, ID)
 	if err != nil {
 		return err
 	}
 
-	if isDeviceInUse(d) {
+	state, err := s.findState(ctx, d.State)
+	if err != nil {
+		return err
+	}
+
+	if state.BlocksDelete {
 		return ErrDeviceInUse
 	}
 
-	return s.repo.DeleteDevice(ID)
+	m := Mutation{Operation: OperationDelete, Before: d}
+	if err := s.checkMutation(ctx, m); err != nil {
+		return err
+	}
+
+	if err := s.hold(ctx, m); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteDevice(ctx, ID); err != nil {
+		return err
+	}
+
+	s.runAfter(ctx, m)
+
+	return nil
+}
+
+// checkMutation checks m against the policies, then runs the Before functions
+// of the hooks.
+func (s *deviceService) checkMutation(ctx context.Context, m Mutation) error {
+	if s.policies != nil {
+		if err := s.policies.Evaluate(ctx, m.copy()); err != nil {
+			return err
+		}
+	}
+
+	return s.runBefore(ctx, m)
 }
 
-func isDeviceInUse(d *Device) bool {
-	return d.State == StateInUse
+// hold lets the approver hold back m, if any.
+func (s *deviceService) hold(ctx context.Context, m Mutation) error {
+	if s.approver == nil {
+		return nil
+	}
+
+	return s.approver.Hold(ctx, m.copy())
 }
 
-func canUpdateDevice(d *Device, input UpdateDeviceRequest) bool {
-	// device name or brand cannot be updated if device state is 'in-use'
-	if (d.State == StateInUse) &&
-		(input.Name != nil || input.Brand != nil) {
-		return false
+// checkUpdate applies the rules of the current state of d, and of the state
+// it moves to, to input.
+func (s *deviceService) checkUpdate(ctx context.Context, d *Device, input UpdateDeviceRequest) error {
+	current, err := s.findState(ctx, d.State)
+	if err != nil {
+		return err
+	}
+
+	if current.LocksFields && (input.Name != nil || input.Brand != nil) {
+		return ErrDeviceInUse
+	}
+
+	if input.State == nil || *input.State == d.State {
+		return nil
+	}
+
+	if current.IsTerminal {
+		return fmt.Errorf("%w: devices cannot leave the terminal state %q", ErrInvalidTransition, d.State)
+	}
+
+	if _, err := s.findState(ctx, *input.State); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// findState returns the state named name, reporting unknown states as invalid
+// transitions.
+func (s *deviceService) findState(ctx context.Context, name string) (*devicestate.State, error) {
+	state, err := s.states.FindByName(ctx, name)
+	if errors.Is(err, devicestate.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown state %q", ErrInvalidTransition, name)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return true
+	return state, nil
 }
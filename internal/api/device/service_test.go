@@ -1,10 +1,13 @@
 package device_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
 	"github.com/hferr/device-manager/test"
 	"github.com/hferr/device-manager/test/mock"
 
@@ -54,7 +57,7 @@ func TestServiceCreateDevice(t *testing.T) {
 
 			s := device.NewService(&tc.repo)
 
-			_, err := s.CreateDevice(tc.input)
+			_, err := s.CreateDevice(context.Background(), tc.input)
 			if err != nil && !tc.wantErr {
 				t.Fatalf("expected no error, got %v", err)
 			}
@@ -115,7 +118,7 @@ func TestServiceDeleteDevice(t *testing.T) {
 
 			s := device.NewService(&tc.repo)
 
-			err := s.DeleteDevice(tc.inputID)
+			err := s.DeleteDevice(context.Background(), tc.inputID)
 			if err != nil && !tc.wantErr {
 				t.Fatalf("expected no error, got %v", err)
 			}
@@ -163,7 +166,7 @@ func TestServiceListDevices(t *testing.T) {
 
 			s := device.NewService(&tc.repo)
 
-			ds, err := s.ListDevices()
+			ds, err := s.ListDevices(context.Background())
 			if err != nil && !tc.wantErr {
 				t.Fatalf("expected no error, got %v", err)
 			}
@@ -217,7 +220,7 @@ func TestServiceFindByID(t *testing.T) {
 
 			s := device.NewService(&tc.repo)
 
-			got, err := s.FindByID(tc.inputID)
+			got, err := s.FindByID(context.Background(), tc.inputID)
 			if err != nil && !tc.wantErr {
 				t.Fatalf("expected no error, got %v", err)
 			}
@@ -272,7 +275,7 @@ func TestServiceFindByState(t *testing.T) {
 
 			s := device.NewService(&tc.repo)
 
-			got, err := s.FindByState(tc.state)
+			got, err := s.FindByState(context.Background(), tc.state)
 			if err != nil && !tc.wantErr {
 				t.Fatalf("expected no error, got %v", err)
 			}
@@ -329,7 +332,7 @@ func TestServiceFindByBrand(t *testing.T) {
 
 			s := device.NewService(&tc.repo)
 
-			got, err := s.FindByBrand(tc.brand)
+			got, err := s.FindByBrand(context.Background(), tc.brand)
 			if err != nil && !tc.wantErr {
 				t.Fatalf("expected no error, got %v", err)
 			}
@@ -422,7 +425,7 @@ func TestServiceUpdateDevice(t *testing.T) {
 
 			s := device.NewService(&tc.repo)
 
-			err := s.UpdateDevice(tc.inputID, tc.input)
+			err := s.UpdateDevice(context.Background(), tc.inputID, tc.input)
 			if err != nil && !tc.wantErr {
 				t.Fatalf("expected no error, got %v", err)
 			}
@@ -433,3 +436,89 @@ func TestServiceUpdateDevice(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceCustomStates(t *testing.T) {
+	states := devicestate.NewMemoryRepository()
+	for _, input := range []devicestate.CreateStateRequest{
+		{Name: "in_repair", LocksFields: true},
+		{Name: "on_loan", BlocksDelete: true},
+		{Name: "retired", IsTerminal: true},
+	} {
+		if err := states.InsertState(context.Background(), devicestate.NewState(input)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	var testCases = map[string]struct {
+		wantErr error
+		state   string
+		update  *device.UpdateDeviceRequest
+	}{
+		"fields of a device in a state locking them cannot be updated": {
+			wantErr: device.ErrDeviceInUse,
+			state:   "in_repair",
+			update:  &device.UpdateDeviceRequest{Name: test.Ptr("updated-name")},
+		},
+		"device in a state locking its fields can change state": {
+			state:  "in_repair",
+			update: &device.UpdateDeviceRequest{State: test.Ptr(device.StateAvailable)},
+		},
+		"device cannot leave a terminal state": {
+			wantErr: device.ErrInvalidTransition,
+			state:   "retired",
+			update:  &device.UpdateDeviceRequest{State: test.Ptr(device.StateAvailable)},
+		},
+		"device cannot move to an unknown state": {
+			wantErr: device.ErrInvalidTransition,
+			state:   device.StateAvailable,
+			update:  &device.UpdateDeviceRequest{State: test.Ptr("lost")},
+		},
+		"device in a state blocking deletes cannot be deleted": {
+			wantErr: device.ErrDeviceInUse,
+			state:   "on_loan",
+		},
+		"device in a custom state can be deleted": {
+			state: "retired",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := mock.DeviceRepository{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return &device.Device{ID: ID, State: tc.state}, nil
+				},
+				UpdateDeviceFunc: func(d *device.Device) error {
+					return nil
+				},
+				DeleteDeviceFunc: func(ID uuid.UUID) error {
+					return nil
+				},
+			}
+
+			s := device.NewService(&repo, device.WithStates(states))
+
+			var err error
+			if tc.update != nil {
+				err = s.UpdateDevice(context.Background(), uuid.New(), *tc.update)
+			} else {
+				err = s.DeleteDevice(context.Background(), uuid.New())
+			}
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	// assert devices cannot be created in an unknown state
+
+	s := device.NewService(&mock.DeviceRepository{}, device.WithStates(states))
+	if _, err := s.CreateDevice(context.Background(), device.CreateDeviceRequest{Name: "test", Brand: "test", State: "lost"}); !errors.Is(err, device.ErrInvalidTransition) {
+		t.Fatalf("expected error: %v, got: %v", device.ErrInvalidTransition, err)
+	}
+}
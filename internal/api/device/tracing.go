@@ -0,0 +1,154 @@
+package device
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/hferr/device-manager/internal/api/device"
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func idAttr(ID uuid.UUID) attribute.KeyValue {
+	return attribute.String("device.id", ID.String())
+}
+
+type tracedService struct {
+	next   DeviceService
+	tracer trace.Tracer
+}
+
+// NewTracedService wraps s so every call is recorded as a span.
+func NewTracedService(s DeviceService, tp trace.TracerProvider) DeviceService {
+	return &tracedService{
+		next:   s,
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+func (s *tracedService) CreateDevice(ctx context.Context, input CreateDeviceRequest) (d *Device, err error) {
+	ctx, span := s.tracer.Start(ctx, "DeviceService.CreateDevice")
+	defer func() { endSpan(span, err) }()
+
+	d, err = s.next.CreateDevice(ctx, input)
+	if d != nil {
+		span.SetAttributes(idAttr(d.ID))
+	}
+
+	return d, err
+}
+
+func (s *tracedService) UpdateDevice(ctx context.Context, ID uuid.UUID, input UpdateDeviceRequest) (err error) {
+	ctx, span := s.tracer.Start(ctx, "DeviceService.UpdateDevice", trace.WithAttributes(idAttr(ID)))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.UpdateDevice(ctx, ID, input)
+}
+
+func (s *tracedService) ListDevices(ctx context.Context) (ds Devices, err error) {
+	ctx, span := s.tracer.Start(ctx, "DeviceService.ListDevices")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.ListDevices(ctx)
+}
+
+func (s *tracedService) FindByID(ctx context.Context, ID uuid.UUID) (d *Device, err error) {
+	ctx, span := s.tracer.Start(ctx, "DeviceService.FindByID", trace.WithAttributes(idAttr(ID)))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.FindByID(ctx, ID)
+}
+
+func (s *tracedService) FindByState(ctx context.Context, state string) (ds Devices, err error) {
+	ctx, span := s.tracer.Start(ctx, "DeviceService.FindByState", trace.WithAttributes(attribute.String("device.state", state)))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.FindByState(ctx, state)
+}
+
+func (s *tracedService) FindByBrand(ctx context.Context, brand string) (ds Devices, err error) {
+	ctx, span := s.tracer.Start(ctx, "DeviceService.FindByBrand", trace.WithAttributes(attribute.String("device.brand", brand)))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.FindByBrand(ctx, brand)
+}
+
+func (s *tracedService) DeleteDevice(ctx context.Context, ID uuid.UUID) (err error) {
+	ctx, span := s.tracer.Start(ctx, "DeviceService.DeleteDevice", trace.WithAttributes(idAttr(ID)))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.DeleteDevice(ctx, ID)
+}
+
+type tracedRepository struct {
+	next   DeviceRepository
+	tracer trace.Tracer
+}
+
+// NewTracedRepository wraps r so every call is recorded as a span.
+func NewTracedRepository(r DeviceRepository, tp trace.TracerProvider) DeviceRepository {
+	return &tracedRepository{
+		next:   r,
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+func (r *tracedRepository) InsertDevice(ctx context.Context, device *Device) (err error) {
+	ctx, span := r.tracer.Start(ctx, "DeviceRepository.InsertDevice", trace.WithAttributes(idAttr(device.ID)))
+	defer func() { endSpan(span, err) }()
+
+	return r.next.InsertDevice(ctx, device)
+}
+
+func (r *tracedRepository) UpdateDevice(ctx context.Context, device *Device) (err error) {
+	ctx, span := r.tracer.Start(ctx, "DeviceRepository.UpdateDevice", trace.WithAttributes(idAttr(device.ID)))
+	defer func() { endSpan(span, err) }()
+
+	return r.next.UpdateDevice(ctx, device)
+}
+
+func (r *tracedRepository) ListDevices(ctx context.Context) (ds Devices, err error) {
+	ctx, span := r.tracer.Start(ctx, "DeviceRepository.ListDevices")
+	defer func() { endSpan(span, err) }()
+
+	return r.next.ListDevices(ctx)
+}
+
+func (r *tracedRepository) FindByID(ctx context.Context, ID uuid.UUID) (d *Device, err error) {
+	ctx, span := r.tracer.Start(ctx, "DeviceRepository.FindByID", trace.WithAttributes(idAttr(ID)))
+	defer func() { endSpan(span, err) }()
+
+	return r.next.FindByID(ctx, ID)
+}
+
+func (r *tracedRepository) FindByState(ctx context.Context, state string) (ds Devices, err error) {
+	ctx, span := r.tracer.Start(ctx, "DeviceRepository.FindByState", trace.WithAttributes(attribute.String("device.state", state)))
+	defer func() { endSpan(span, err) }()
+
+	return r.next.FindByState(ctx, state)
+}
+
+func (r *tracedRepository) FindByBrand(ctx context.Context, brand string) (ds Devices, err error) {
+	ctx, span := r.tracer.Start(ctx, "DeviceRepository.FindByBrand", trace.WithAttributes(attribute.String("device.brand", brand)))
+	defer func() { endSpan(span, err) }()
+
+	return r.next.FindByBrand(ctx, brand)
+}
+
+func (r *tracedRepository) DeleteDevice(ctx context.Context, ID uuid.UUID) (err error) {
+	ctx, span := r.tracer.Start(ctx, "DeviceRepository.DeleteDevice", trace.WithAttributes(idAttr(ID)))
+	defer func() { endSpan(span, err) }()
+
+	return r.next.DeleteDevice(ctx, ID)
+}
@@ -0,0 +1,68 @@
+package device_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/test/mock"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedService(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	repo := device.NewTracedRepository(&mock.DeviceRepository{
+		FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+			return &device.Device{ID: ID, State: device.StateAvailable}, nil
+		},
+		UpdateDeviceFunc: func(d *device.Device) error {
+			return fmt.Errorf("boom")
+		},
+	}, tp)
+	s := device.NewTracedService(device.NewService(repo), tp)
+
+	if err := s.UpdateDevice(context.Background(), uuid.New(), device.UpdateDeviceRequest{}); err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	spans := sr.Ended()
+
+	// spans end innermost first
+	wantNames := []string{
+		"DeviceRepository.FindByID",
+		"DeviceRepository.UpdateDevice",
+		"DeviceService.UpdateDevice",
+	}
+
+	if len(spans) != len(wantNames) {
+		t.Fatalf("expected %d spans, got: %d", len(wantNames), len(spans))
+	}
+
+	root := spans[len(spans)-1]
+	for i, span := range spans {
+		if span.Name() != wantNames[i] {
+			t.Fatalf("expected span %q, got: %q", wantNames[i], span.Name())
+		}
+
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Fatalf("expected span %q to belong to the service trace", span.Name())
+		}
+	}
+
+	// assert the failing calls are marked as errors
+
+	if spans[1].Status().Code != codes.Error || root.Status().Code != codes.Error {
+		t.Fatal("expected failing spans to have an error status")
+	}
+
+	if spans[0].Status().Code == codes.Error {
+		t.Fatal("expected successful span not to have an error status")
+	}
+}
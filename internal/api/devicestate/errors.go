@@ -0,0 +1,15 @@
+package devicestate
+
+import (
+	"errors"
+)
+
+// Errors returned by StateRepository and StateService implementations,
+// whatever the storage behind them.
+var (
+	ErrNotFound  = errors.New("device state not found")
+	ErrDuplicate = errors.New("device state already exists")
+	// ErrStateInUse reports the deletion of a state devices are still in.
+	ErrStateInUse   = errors.New("operation cannot be completed because devices are still in the state")
+	ErrBuiltinState = errors.New("built-in device states cannot be deleted")
+)
@@ -0,0 +1,101 @@
+package devicestate
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// memoryRepository is a StateRepository keeping states in memory, seeded with
+// the built-in states like the migrations seed the database. States are copied
+// in and out, so callers never share them with the store.
+type memoryRepository struct {
+	mu     sync.RWMutex
+	states map[string]*State
+}
+
+// NewMemoryRepository returns a thread-safe in-memory StateRepository holding
+// the built-in states.
+func NewMemoryRepository() StateRepository {
+	r := &memoryRepository{
+		states: make(map[string]*State),
+	}
+
+	for _, s := range Builtin() {
+		r.states[s.Name] = s
+	}
+
+	return r
+}
+
+func (r *memoryRepository) InsertState(_ context.Context, state *State) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.states[state.Name]; ok {
+		return fmt.Errorf("%w: duplicate state %q", ErrDuplicate, state.Name)
+	}
+
+	s := *state
+	r.states[s.Name] = &s
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateState(_ context.Context, state *State) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// like the UPDATE it mirrors, updating a missing state is a no-op
+	s, ok := r.states[state.Name]
+	if !ok {
+		return nil
+	}
+
+	s.Description = state.Description
+	s.IsAssignable = state.IsAssignable
+	s.IsTerminal = state.IsTerminal
+	s.LocksFields = state.LocksFields
+	s.BlocksDelete = state.BlocksDelete
+
+	return nil
+}
+
+func (r *memoryRepository) ListStates(_ context.Context) (States, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ss := make(States, 0, len(r.states))
+	for _, s := range r.states {
+		found := *s
+		ss = append(ss, &found)
+	}
+
+	slices.SortFunc(ss, func(a, b *State) int { return cmp.Compare(a.Name, b.Name) })
+
+	return ss, nil
+}
+
+func (r *memoryRepository) FindByName(_ context.Context, name string) (*State, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.states[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	found := *s
+	return &found, nil
+}
+
+func (r *memoryRepository) DeleteState(_ context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.states, name)
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package devicestate
+
+import (
+	"time"
+)
+
+// Names of the built-in states, seeded by the migrations.
+const (
+	Available = "available"
+	InUse     = "in_use"
+	Inactive  = "inactive"
+)
+
+// State is a state devices can be in, along with the rules applying to the
+// devices in it.
+type State struct {
+	Name        string `gorm:"primarykey"`
+	Description string
+	// IsAssignable marks the states of the devices which can be handed out.
+	IsAssignable bool
+	// IsTerminal marks the states devices cannot leave.
+	IsTerminal bool
+	// LocksFields forbids changing the name and brand of the devices.
+	LocksFields bool
+	// BlocksDelete forbids deleting the devices.
+	BlocksDelete bool
+	CreatedAt    time.Time
+}
+
+func (State) TableName() string {
+	return "device_states"
+}
+
+type States []*State
+
+type DTO struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	IsAssignable bool   `json:"is_assignable"`
+	IsTerminal   bool   `json:"is_terminal"`
+	LocksFields  bool   `json:"locks_fields"`
+	BlocksDelete bool   `json:"blocks_delete"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type CreateStateRequest struct {
+	Name         string `json:"name" validate:"required,max=64,snake_case"`
+	Description  string `json:"description" validate:"max=255"`
+	IsAssignable bool   `json:"is_assignable"`
+	IsTerminal   bool   `json:"is_terminal"`
+	LocksFields  bool   `json:"locks_fields"`
+	BlocksDelete bool   `json:"blocks_delete"`
+}
+
+type UpdateStateRequest struct {
+	Description  *string `json:"description" validate:"omitempty,max=255"`
+	IsAssignable *bool   `json:"is_assignable"`
+	IsTerminal   *bool   `json:"is_terminal"`
+	LocksFields  *bool   `json:"locks_fields"`
+	BlocksDelete *bool   `json:"blocks_delete"`
+}
+
+func (r *UpdateStateRequest) Apply(s *State) {
+	if r.Description != nil {
+		s.Description = *r.Description
+	}
+
+	if r.IsAssignable != nil {
+		s.IsAssignable = *r.IsAssignable
+	}
+
+	if r.IsTerminal != nil {
+		s.IsTerminal = *r.IsTerminal
+	}
+
+	if r.LocksFields != nil {
+		s.LocksFields = *r.LocksFields
+	}
+
+	if r.BlocksDelete != nil {
+		s.BlocksDelete = *r.BlocksDelete
+	}
+}
+
+func NewState(input CreateStateRequest) *State {
+	return &State{
+		Name:         input.Name,
+		Description:  input.Description,
+		IsAssignable: input.IsAssignable,
+		IsTerminal:   input.IsTerminal,
+		LocksFields:  input.LocksFields,
+		BlocksDelete: input.BlocksDelete,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// Builtin returns the states seeded by the migrations, which the API relies on
+// and which cannot be deleted.
+func Builtin() States {
+	return States{
+		NewState(CreateStateRequest{Name: Available, Description: "ready to be assigned", IsAssignable: true}),
+		NewState(CreateStateRequest{Name: InUse, Description: "assigned, its name and brand cannot change and it cannot be deleted", LocksFields: true, BlocksDelete: true}),
+		NewState(CreateStateRequest{Name: Inactive, Description: "out of service"}),
+	}
+}
+
+// IsBuiltin reports whether name is one of the built-in states.
+func IsBuiltin(name string) bool {
+	switch name {
+	case Available, InUse, Inactive:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *State) ToDto() *DTO {
+	return &DTO{
+		Name:         s.Name,
+		Description:  s.Description,
+		IsAssignable: s.IsAssignable,
+		IsTerminal:   s.IsTerminal,
+		LocksFields:  s.LocksFields,
+		BlocksDelete: s.BlocksDelete,
+		CreatedAt:    s.CreatedAt.Format(time.DateTime),
+	}
+}
+
+func (ss States) ToDto() []*DTO {
+	dtos := make([]*DTO, len(ss))
+	for i, v := range ss {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
@@ -0,0 +1,81 @@
+package devicestate
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type StateRepository interface {
+	InsertState(ctx context.Context, state *State) error
+	UpdateState(ctx context.Context, state *State) error
+	ListStates(ctx context.Context) (States, error)
+	FindByName(ctx context.Context, name string) (*State, error)
+	DeleteState(ctx context.Context, name string) error
+}
+
+type stateRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository expects db to translate driver errors, see database.Open.
+// Errors are reported as the errors of this package, such as ErrNotFound.
+func NewRepository(db *gorm.DB) StateRepository {
+	return &stateRepository{
+		db: db,
+	}
+}
+
+func (r *stateRepository) InsertState(ctx context.Context, state *State) error {
+	return translateError(r.db.WithContext(ctx).Create(state).Error)
+}
+
+func (r *stateRepository) UpdateState(ctx context.Context, state *State) error {
+	err := r.db.WithContext(ctx).
+		Model(&State{}).
+		Select("description", "is_assignable", "is_terminal", "locks_fields", "blocks_delete").
+		Where("name = ?", state.Name).
+		Updates(state).Error
+
+	return translateError(err)
+}
+
+func (r *stateRepository) ListStates(ctx context.Context) (States, error) {
+	ss := make(States, 0)
+	if err := r.db.WithContext(ctx).Order("name").Find(&ss).Error; err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
+
+func (r *stateRepository) FindByName(ctx context.Context, name string) (*State, error) {
+	s := &State{}
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&s).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return s, nil
+}
+
+func (r *stateRepository) DeleteState(ctx context.Context, name string) error {
+	return translateError(r.db.WithContext(ctx).Where("name = ?", name).Delete(&State{}).Error)
+}
+
+// translateError maps the gorm errors of a query to the errors of this
+// package, returning other errors unchanged.
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return ErrStateInUse
+	default:
+		return err
+	}
+}
@@ -0,0 +1,139 @@
+package devicestate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/test"
+)
+
+// repositories runs fn against the gorm repository of every driver and the
+// memory repository.
+func repositories(t *testing.T, fn func(t *testing.T, repo devicestate.StateRepository)) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			fn(t, devicestate.NewRepository(db))
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		fn(t, devicestate.NewMemoryRepository())
+	})
+}
+
+func TestRepositoryBuiltinStates(t *testing.T) {
+	repositories(t, func(t *testing.T, repo devicestate.StateRepository) {
+		ss, err := repo.ListStates(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		// assert the built-in states are seeded with the same rules everywhere
+
+		builtin := devicestate.Builtin()
+		if len(ss) != len(builtin) {
+			t.Fatalf("expected %d states, got: %d", len(builtin), len(ss))
+		}
+
+		for _, want := range builtin {
+			got, err := repo.FindByName(context.Background(), want.Name)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			want.CreatedAt = got.CreatedAt
+			if *got != *want {
+				t.Fatalf("expected state %+v, got: %+v", want, got)
+			}
+		}
+	})
+}
+
+func TestRepositoryStates(t *testing.T) {
+	repositories(t, func(t *testing.T, repo devicestate.StateRepository) {
+		ctx := context.Background()
+
+		s := devicestate.NewState(devicestate.CreateStateRequest{Name: "in_repair", LocksFields: true})
+		if err := repo.InsertState(ctx, s); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		// assert state names are unique
+
+		if err := repo.InsertState(ctx, devicestate.NewState(devicestate.CreateStateRequest{Name: "in_repair"})); !errors.Is(err, devicestate.ErrDuplicate) {
+			t.Fatalf("expected error: %v, got: %v", devicestate.ErrDuplicate, err)
+		}
+
+		// assert updates replace every rule
+
+		s.Description, s.LocksFields, s.BlocksDelete = "at the repair shop", false, true
+		if err := repo.UpdateState(ctx, s); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		found, err := repo.FindByName(ctx, "in_repair")
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if found.Description != "at the repair shop" || found.LocksFields || !found.BlocksDelete {
+			t.Fatalf("expected updated state, got: %+v", found)
+		}
+
+		ss, err := repo.ListStates(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		names := make([]string, len(ss))
+		for i, s := range ss {
+			names[i] = s.Name
+		}
+
+		if want := "[available in_repair in_use inactive]"; fmt.Sprint(names) != want {
+			t.Fatalf("expected states sorted by name %s, got: %v", want, names)
+		}
+
+		if err := repo.DeleteState(ctx, "in_repair"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if _, err := repo.FindByName(ctx, "in_repair"); !errors.Is(err, devicestate.ErrNotFound) {
+			t.Fatalf("expected error: %v, got: %v", devicestate.ErrNotFound, err)
+		}
+	})
+}
+
+func TestRepositoryDeleteStateInUse(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			repo := devicestate.NewRepository(db)
+			ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+
+			if err := repo.InsertState(ctx, devicestate.NewState(devicestate.CreateStateRequest{Name: "retired"})); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if err := device.NewRepository(db).InsertDevice(ctx, device.NewDevice("test", "test", "retired")); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// assert the foreign key keeps the states devices are in
+
+			if err := repo.DeleteState(ctx, "retired"); !errors.Is(err, devicestate.ErrStateInUse) {
+				t.Fatalf("expected error: %v, got: %v", devicestate.ErrStateInUse, err)
+			}
+		})
+	}
+}
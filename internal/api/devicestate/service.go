@@ -0,0 +1,109 @@
+package devicestate
+
+import (
+	"context"
+)
+
+type StateService interface {
+	CreateState(ctx context.Context, input CreateStateRequest) (*State, error)
+	UpdateState(ctx context.Context, name string, input UpdateStateRequest) (*State, error)
+	ListStates(ctx context.Context) (States, error)
+	FindByName(ctx context.Context, name string) (*State, error)
+	DeleteState(ctx context.Context, name string) error
+}
+
+// UsageFunc reports whether devices are in the given state.
+type UsageFunc func(ctx context.Context, state string) (bool, error)
+
+type ServiceOption func(*stateService)
+
+// WithUsage makes the service refuse to delete states devices are still in,
+// for storages without a foreign key from the devices to their state.
+func WithUsage(f UsageFunc) ServiceOption {
+	return func(s *stateService) {
+		s.inUse = f
+	}
+}
+
+type stateService struct {
+	repo  StateRepository
+	inUse UsageFunc
+}
+
+func NewService(r StateRepository, opts ...ServiceOption) StateService {
+	s := &stateService{
+		repo: r,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *stateService) CreateState(ctx context.Context, input CreateStateRequest) (*State, error) {
+	st := NewState(input)
+
+	if err := s.repo.InsertState(ctx, st); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+func (s *stateService) UpdateState(ctx context.Context, name string, input UpdateStateRequest) (*State, error) {
+	st, err := s.FindByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	input.Apply(st)
+
+	if err := s.repo.UpdateState(ctx, st); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+func (s *stateService) ListStates(ctx context.Context) (States, error) {
+	ss, err := s.repo.ListStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
+
+func (s *stateService) FindByName(ctx context.Context, name string) (*State, error) {
+	st, err := s.repo.FindByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+func (s *stateService) DeleteState(ctx context.Context, name string) error {
+	if IsBuiltin(name) {
+		return ErrBuiltinState
+	}
+
+	if _, err := s.FindByName(ctx, name); err != nil {
+		return err
+	}
+
+	if s.inUse != nil {
+		inUse, err := s.inUse(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		if inUse {
+			return ErrStateInUse
+		}
+	}
+
+	return s.repo.DeleteState(ctx, name)
+}
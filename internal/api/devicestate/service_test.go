@@ -0,0 +1,161 @@
+package devicestate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+)
+
+func TestServiceCreateState(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr bool
+		repo    mock.StateRepository
+	}{
+		"successfully calls repo to insert state": {
+			wantErr: false,
+			repo: mock.StateRepository{
+				InsertStateFunc: func(s *devicestate.State) error {
+					return nil
+				},
+			},
+		},
+		"repo returns error": {
+			wantErr: true,
+			repo: mock.StateRepository{
+				InsertStateFunc: func(s *devicestate.State) error {
+					return devicestate.ErrDuplicate
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := devicestate.NewService(&tc.repo)
+
+			_, err := s.CreateState(context.Background(), devicestate.CreateStateRequest{Name: "in_repair"})
+			if err != nil && !tc.wantErr {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err == nil && tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestServiceUpdateState(t *testing.T) {
+	var updated *devicestate.State
+
+	repo := mock.StateRepository{
+		FindByNameFunc: func(name string) (*devicestate.State, error) {
+			return &devicestate.State{Name: name, LocksFields: true}, nil
+		},
+		UpdateStateFunc: func(s *devicestate.State) error {
+			updated = s
+			return nil
+		},
+	}
+
+	s := devicestate.NewService(&repo)
+
+	if _, err := s.UpdateState(context.Background(), "in_repair", devicestate.UpdateStateRequest{BlocksDelete: test.Ptr(true)}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// assert the omitted rules are kept
+
+	if !updated.LocksFields || !updated.BlocksDelete {
+		t.Fatalf("expected state locking fields and blocking deletes, got: %+v", updated)
+	}
+}
+
+func TestServiceDeleteState(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr   error
+		repo      mock.StateRepository
+		usage     devicestate.UsageFunc
+		inputName string
+	}{
+		"successfully deletes state": {
+			repo: mock.StateRepository{
+				FindByNameFunc: func(name string) (*devicestate.State, error) {
+					return &devicestate.State{Name: name}, nil
+				},
+				DeleteStateFunc: func(name string) error {
+					return nil
+				},
+			},
+			inputName: "in_repair",
+		},
+		"built-in states cannot be deleted": {
+			wantErr:   devicestate.ErrBuiltinState,
+			repo:      mock.StateRepository{},
+			inputName: devicestate.Inactive,
+		},
+		"unknown state": {
+			wantErr: devicestate.ErrNotFound,
+			repo: mock.StateRepository{
+				FindByNameFunc: func(name string) (*devicestate.State, error) {
+					return nil, devicestate.ErrNotFound
+				},
+			},
+			inputName: "in_repair",
+		},
+		"devices still in the state": {
+			wantErr: devicestate.ErrStateInUse,
+			repo: mock.StateRepository{
+				FindByNameFunc: func(name string) (*devicestate.State, error) {
+					return &devicestate.State{Name: name}, nil
+				},
+			},
+			usage: func(ctx context.Context, state string) (bool, error) {
+				return true, nil
+			},
+			inputName: "in_repair",
+		},
+		"usage check returns error": {
+			wantErr: errBoom,
+			repo: mock.StateRepository{
+				FindByNameFunc: func(name string) (*devicestate.State, error) {
+					return &devicestate.State{Name: name}, nil
+				},
+			},
+			usage: func(ctx context.Context, state string) (bool, error) {
+				return false, errBoom
+			},
+			inputName: "in_repair",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []devicestate.ServiceOption
+			if tc.usage != nil {
+				opts = append(opts, devicestate.WithUsage(tc.usage))
+			}
+
+			s := devicestate.NewService(&tc.repo, opts...)
+
+			if err := s.DeleteState(context.Background(), tc.inputName); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+var errBoom = fmt.Errorf("boom")
@@ -1,43 +1,137 @@
 package err
 
-import "net/http"
+import (
+	"net/http"
 
-var (
-	// device error responses
-	DeviceInUseErrResp         = []byte(`{"error": "operation cannot be completed because the device is in use"}`)
-	DeviceServiceFailedErrResp = []byte(`{"error": "device operation failed"}`)
-	DeviceNotFoundErrResp      = []byte(`{"error": "device not found"}`)
-
-	// handler error responses
-	JSONEncodeErrResp = []byte(`{"error": "error encoding json"}`)
-	JSONDecodeErrResp = []byte(`{"error": "error decoding json"}`)
-	InvalidIDErrResp  = []byte(`{"error": "invalid id param in url"}`)
+	"github.com/hferr/device-manager/utils/validator"
 )
 
-type Error struct {
-	Error string `json:"error"`
-}
+// ContentTypeProblemJSON is the media type of RFC 7807 problem details.
+const ContentTypeProblemJSON = "application/problem+json"
 
-type Errors struct {
-	Errors []string `json:"errors"`
-}
+// typeBaseURI prefixes the type of every problem. Type URIs are relative
+// references, resolved against the URL of the API.
+const typeBaseURI = "/problems/"
 
-func ServerError(w http.ResponseWriter, error []byte) {
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Write(error)
+// ProblemType is a kind of error reported to clients, identified by its type
+// URI. Its title and status are the same for every occurrence.
+type ProblemType struct {
+	Type   string
+	Title  string
+	Status int
+	// Detail is the explanation given for an occurrence unless a more
+	// specific one is known.
+	Detail string
 }
 
-func BadRequest(w http.ResponseWriter, error []byte) {
-	w.WriteHeader(http.StatusBadRequest)
-	w.Write(error)
+func newProblemType(slug, title string, status int, detail string) ProblemType {
+	return ProblemType{
+		Type:   typeBaseURI + slug,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
 }
 
-func NotFound(w http.ResponseWriter, error []byte) {
-	w.WriteHeader(http.StatusNotFound)
-	w.Write(error)
+var (
+	// device problems
+	DeviceInUse         = newProblemType("device-in-use", "Device in use", http.StatusUnprocessableEntity, "operation cannot be completed because the device is in use")
+	DeviceServiceFailed = newProblemType("device-service-failed", "Device operation failed", http.StatusInternalServerError, "device operation failed")
+	DeviceNotFound      = newProblemType("device-not-found", "Device not found", http.StatusNotFound, "device not found")
+	DeviceChangeVetoed  = newProblemType("device-change-vetoed", "Device change vetoed", http.StatusUnprocessableEntity, "the change was vetoed by a hook")
+	DeviceDuplicate     = newProblemType("device-duplicate", "Device already exists", http.StatusConflict, "a device with the same id already exists")
+	DeviceConflict      = newProblemType("device-conflict", "Device conflict", http.StatusConflict, "operation conflicts with the current state of the device")
+	InvalidTransition   = newProblemType("invalid-transition", "Invalid state transition", http.StatusUnprocessableEntity, "the device cannot be moved to the requested state")
+
+	// device state problems
+	StateServiceFailed = newProblemType("state-service-failed", "Device state operation failed", http.StatusInternalServerError, "device state operation failed")
+	StateNotFound      = newProblemType("state-not-found", "Device state not found", http.StatusNotFound, "device state not found")
+	StateDuplicate     = newProblemType("state-duplicate", "Device state already exists", http.StatusConflict, "a device state with the same name already exists")
+	StateInUse         = newProblemType("state-in-use", "Device state in use", http.StatusUnprocessableEntity, "operation cannot be completed because devices are still in the state")
+	BuiltinState       = newProblemType("builtin-state", "Built-in device state", http.StatusUnprocessableEntity, "built-in device states cannot be deleted")
+
+	// reservation problems
+	ReservationServiceFailed = newProblemType("reservation-service-failed", "Reservation operation failed", http.StatusInternalServerError, "reservation operation failed")
+	ReservationNotFound      = newProblemType("reservation-not-found", "Reservation not found", http.StatusNotFound, "reservation not found")
+	ReservationOverlap       = newProblemType("reservation-overlap", "Reservation overlap", http.StatusConflict, "the device is already reserved for part of the period")
+	InvalidPeriod            = newProblemType("invalid-period", "Invalid period", http.StatusUnprocessableEntity, "the period must end after it starts, and not be over")
+	ReservationEnded         = newProblemType("reservation-ended", "Reservation ended", http.StatusUnprocessableEntity, "the reservation has already ended")
+
+	// waitlist problems
+	WaitlistServiceFailed  = newProblemType("waitlist-service-failed", "Waitlist operation failed", http.StatusInternalServerError, "waitlist operation failed")
+	WaitlistEntryNotFound  = newProblemType("waitlist-entry-not-found", "Waitlist entry not found", http.StatusNotFound, "waitlist entry not found")
+	InvalidWaitlistTarget  = newProblemType("invalid-waitlist-target", "Invalid waitlist target", http.StatusUnprocessableEntity, "wait for either a device, by device_id, or a pool of devices, by brand and name")
+	WaitlistNotOffered     = newProblemType("waitlist-not-offered", "No pending offer", http.StatusConflict, "no device is offered to the entry, or the offer expired")
+	WaitlistOfferWithdrawn = newProblemType("waitlist-offer-withdrawn", "Offer withdrawn", http.StatusConflict, "the offered device is no longer available, the entry is back to waiting")
+	WaitlistNotPending     = newProblemType("waitlist-not-pending", "Waitlist entry not pending", http.StatusUnprocessableEntity, "the entry is no longer on the waitlist")
+
+	// notification problems
+	NotificationServiceFailed = newProblemType("notification-service-failed", "Notification operation failed", http.StatusInternalServerError, "notification operation failed")
+	WebhookForbidden          = newProblemType("webhook-forbidden", "Webhook forbidden", http.StatusUnprocessableEntity, "webhooks must be http or https URLs of a public host")
+
+	// approval problems
+	ApprovalServiceFailed   = newProblemType("approval-service-failed", "Approval operation failed", http.StatusInternalServerError, "approval operation failed")
+	ChangeRequestNotFound   = newProblemType("change-request-not-found", "Change request not found", http.StatusNotFound, "change request not found")
+	ChangeRequestNotPending = newProblemType("change-request-not-pending", "Change request not pending", http.StatusConflict, "the change request was already approved or rejected")
+	SelfApproval            = newProblemType("self-approval", "Self approval", http.StatusForbidden, "change requests must be approved by someone other than their requester")
+	ApprovalRequired        = newProblemType("approval-required", "Approval required", http.StatusConflict, "the device change is held back until approved")
+
+	// policy problems
+	PolicyServiceFailed = newProblemType("policy-service-failed", "Policy operation failed", http.StatusInternalServerError, "policy operation failed")
+	PolicyDenied        = newProblemType("policy-denied", "Denied by policy", http.StatusForbidden, "the change was denied by a policy")
+
+	// organization problems
+	OrganizationServiceFailed = newProblemType("organization-service-failed", "Organization operation failed", http.StatusInternalServerError, "organization operation failed")
+	OrganizationNotFound      = newProblemType("organization-not-found", "Organization not found", http.StatusNotFound, "organization not found")
+	OrganizationHasDevices    = newProblemType("organization-has-devices", "Organization has devices", http.StatusUnprocessableEntity, "operation cannot be completed because the organization still owns devices")
+	DefaultOrganization       = newProblemType("default-organization", "Default organization", http.StatusUnprocessableEntity, "the default organization cannot be deleted")
+
+	// tenancy and authentication problems
+	MissingTenant   = newProblemType("missing-tenant", "Missing tenant", http.StatusBadRequest, "no tenant could be resolved for the request")
+	InvalidTenant   = newProblemType("invalid-tenant", "Invalid tenant", http.StatusBadRequest, "invalid tenant id")
+	TenantNotFound  = newProblemType("tenant-not-found", "Tenant not found", http.StatusNotFound, "tenant not found")
+	TenantForbidden = newProblemType("tenant-forbidden", "Tenant forbidden", http.StatusForbidden, "access to the requested tenant is forbidden")
+	Unauthorized    = newProblemType("unauthorized", "Unauthorized", http.StatusUnauthorized, "invalid credentials")
+	Forbidden       = newProblemType("forbidden", "Forbidden", http.StatusForbidden, "insufficient permissions")
+
+	// idempotency problems
+	IdempotencyServiceFailed     = newProblemType("idempotency-service-failed", "Idempotency key lookup failed", http.StatusInternalServerError, "idempotency key lookup failed")
+	InvalidIdempotencyKey        = newProblemType("invalid-idempotency-key", "Invalid idempotency key", http.StatusBadRequest, "idempotency key must be at most 255 characters")
+	IdempotencyKeyReused         = newProblemType("idempotency-key-reused", "Idempotency key reused", http.StatusUnprocessableEntity, "idempotency key was already used for a different request")
+	IdempotentBodyTooLarge       = newProblemType("idempotent-body-too-large", "Request body too large", http.StatusRequestEntityTooLarge, "the body of a request made with an idempotency key exceeds the size limit")
+	IdempotencyRequestInProgress = newProblemType("idempotency-request-in-progress", "Request in progress", http.StatusConflict, "a request with the same idempotency key is still being processed")
+
+	// handler problems
+	EncodeFailed         = newProblemType("encode-failed", "Response encoding failed", http.StatusInternalServerError, "error encoding the response body")
+	MalformedBody        = newProblemType("malformed-body", "Malformed request body", http.StatusBadRequest, "error decoding the request body")
+	InvalidID            = newProblemType("invalid-id", "Invalid ID", http.StatusBadRequest, "invalid id param in url")
+	InvalidQuery         = newProblemType("invalid-query", "Invalid query parameter", http.StatusBadRequest, "invalid or missing query parameter, ids must be UUIDs and times RFC 3339 timestamps")
+	ValidationFailed     = newProblemType("validation-failed", "Validation failed", http.StatusUnprocessableEntity, "the request body is invalid")
+	RouteNotFound        = newProblemType("route-not-found", "Not found", http.StatusNotFound, "no resource matches the request path")
+	MethodNotAllowed     = newProblemType("method-not-allowed", "Method not allowed", http.StatusMethodNotAllowed, "the resource does not support the request method")
+	NotAcceptable        = newProblemType("not-acceptable", "Not acceptable", http.StatusNotAcceptable, "none of the accepted media types is supported, use application/json, application/x-ndjson, text/csv, application/yaml or application/msgpack")
+	UnsupportedMediaType = newProblemType("unsupported-media-type", "Unsupported media type", http.StatusUnsupportedMediaType, "the request body media type is not supported, use application/json, application/x-ndjson, text/csv, application/yaml or application/msgpack")
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type   string `json:"type" example:"/problems/device-not-found"`
+	Title  string `json:"title" example:"Device not found"`
+	Status int    `json:"status" example:"404"`
+	Detail string `json:"detail,omitempty" example:"device not found"`
+	// Instance is the path of the request the problem occurred on.
+	Instance  string `json:"instance,omitempty" example:"/devices/8f7e3a52-5d1c-4b8a-9f0e-6b1f2a3c4d5e"`
+	RequestID string `json:"request_id,omitempty" example:"3b1f0c2e-7a4d-4e8b-9c6f-1d2e3f4a5b6c"`
+	// Errors lists the invalid fields of a request failing validation.
+	Errors []validator.FieldError `json:"errors,omitempty"`
 }
 
-func UnprocessableEntity(w http.ResponseWriter, reps []byte) {
-	w.WriteHeader(http.StatusUnprocessableEntity)
-	w.Write(reps)
+// New returns an occurrence of the problem type with its default detail.
+func (t ProblemType) New() Problem {
+	return Problem{
+		Type:   t.Type,
+		Title:  t.Title,
+		Status: t.Status,
+		Detail: t.Detail,
+	}
 }
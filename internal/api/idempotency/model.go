@@ -0,0 +1,39 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Record is the stored outcome of a request made with an Idempotency-Key.
+// A zero StatusCode marks a request that is still being processed.
+type Record struct {
+	Scope        string `gorm:"primarykey"`
+	Key          string `gorm:"primarykey"`
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+func (r *Record) InProgress() bool {
+	return r.StatusCode == 0
+}
+
+// Fingerprint identifies a request by its method, path, the media type of its
+// response and its body so reuse of a key for a different request, or asking
+// for the stored response in another format, can be detected.
+func Fingerprint(method, path, mediaType string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + " " + mediaType + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,68 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDuplicateKey = errors.New("idempotency key already exists")
+)
+
+type IdempotencyRepository interface {
+	InsertRecord(ctx context.Context, rec *Record) error
+	UpdateRecord(ctx context.Context, rec *Record) error
+	FindByKey(ctx context.Context, scope, key string) (*Record, error)
+	DeleteRecord(ctx context.Context, scope, key string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository relies on gorm.ErrDuplicatedKey, so db must be opened with
+// TranslateError as database.Open does.
+func NewRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{
+		db: db,
+	}
+}
+
+func (r *idempotencyRepository) InsertRecord(ctx context.Context, rec *Record) error {
+	err := r.db.WithContext(ctx).Create(rec).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateKey
+	}
+
+	return err
+}
+
+func (r *idempotencyRepository) UpdateRecord(ctx context.Context, rec *Record) error {
+	return r.db.WithContext(ctx).Model(&Record{}).
+		Select("status_code", "content_type", "response_body").
+		Where("scope = ? AND key = ?", rec.Scope, rec.Key).
+		Updates(rec).Error
+}
+
+func (r *idempotencyRepository) FindByKey(ctx context.Context, scope, key string) (*Record, error) {
+	rec := &Record{}
+	if err := r.db.WithContext(ctx).Where("scope = ? AND key = ?", scope, key).First(&rec).Error; err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+func (r *idempotencyRepository) DeleteRecord(ctx context.Context, scope, key string) error {
+	return r.db.WithContext(ctx).Where("scope = ? AND key = ?", scope, key).Delete(&Record{}).Error
+}
+
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Record{})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,90 @@
+package idempotency_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/idempotency"
+	"github.com/hferr/device-manager/test"
+)
+
+func TestInsertRecord(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			repo := idempotency.NewRepository(db)
+			ctx := context.Background()
+
+			rec := &idempotency.Record{
+				Scope:       "scope",
+				Key:         "key",
+				RequestHash: idempotency.Fingerprint("POST", "/devices", "application/json", nil),
+				CreatedAt:   time.Now(),
+				ExpiresAt:   time.Now().Add(time.Hour),
+			}
+
+			if err := repo.InsertRecord(ctx, rec); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// assert the same key cannot be reserved twice
+
+			if err := repo.InsertRecord(ctx, rec); err != idempotency.ErrDuplicateKey {
+				t.Fatalf("expected error: %v, got: %v", idempotency.ErrDuplicateKey, err)
+			}
+
+			// assert the response is stored
+
+			rec.StatusCode, rec.ContentType, rec.ResponseBody = 201, "application/json", []byte(`{}`)
+			if err := repo.UpdateRecord(ctx, rec); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			got, err := repo.FindByKey(ctx, "scope", "key")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if got.StatusCode != 201 || string(got.ResponseBody) != `{}` {
+				t.Fatalf("expected stored response, got: %d %s", got.StatusCode, got.ResponseBody)
+			}
+		})
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			repo := idempotency.NewRepository(db)
+			ctx := context.Background()
+
+			for i, expiresAt := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour)} {
+				rec := &idempotency.Record{
+					Scope:       "scope",
+					Key:         string(rune('a' + i)),
+					RequestHash: idempotency.Fingerprint("POST", "/devices", "application/json", nil),
+					CreatedAt:   time.Now(),
+					ExpiresAt:   expiresAt,
+				}
+				if err := repo.InsertRecord(ctx, rec); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+			}
+
+			n, err := repo.DeleteExpired(ctx, time.Now())
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if n != 1 {
+				t.Fatalf("expected %d expired records deleted, got: %d", 1, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reserveAttempts bounds how often Reserve retries when a competing record
+// expires or disappears between the insert and the lookup.
+const reserveAttempts = 3
+
+var (
+	ErrKeyReused         = errors.New("idempotency key was already used for a different request")
+	ErrRequestInProgress = errors.New("a request with the same idempotency key is still being processed")
+)
+
+type IdempotencyService interface {
+	// Reserve claims key within scope for a request with the given fingerprint.
+	// If the key was already used for the same request the stored record is
+	// returned with replay set, and the request must not be executed again.
+	Reserve(ctx context.Context, scope, key, fingerprint string) (rec *Record, replay bool, err error)
+	// Complete stores the response of a request reserved with Reserve.
+	Complete(ctx context.Context, rec *Record) error
+	// Release forgets a reserved key so the request can be retried.
+	Release(ctx context.Context, rec *Record) error
+	// PurgeExpired deletes the records older than the retention window.
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+type idempotencyService struct {
+	repo IdempotencyRepository
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+func NewService(r IdempotencyRepository, ttl time.Duration) IdempotencyService {
+	return &idempotencyService{
+		repo: r,
+		ttl:  ttl,
+		now:  time.Now,
+	}
+}
+
+func (s *idempotencyService) Reserve(ctx context.Context, scope, key, fingerprint string) (*Record, bool, error) {
+	for range reserveAttempts {
+		now := s.now()
+		rec := &Record{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: fingerprint,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(s.ttl),
+		}
+
+		err := s.repo.InsertRecord(ctx, rec)
+		if err == nil {
+			return rec, false, nil
+		}
+		if !errors.Is(err, ErrDuplicateKey) {
+			return nil, false, err
+		}
+
+		existing, err := s.repo.FindByKey(ctx, scope, key)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, false, err
+		}
+
+		if !existing.ExpiresAt.After(now) {
+			if err := s.repo.DeleteRecord(ctx, scope, key); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		if existing.RequestHash != fingerprint {
+			return nil, false, ErrKeyReused
+		}
+
+		if existing.InProgress() {
+			return nil, false, ErrRequestInProgress
+		}
+
+		return existing, true, nil
+	}
+
+	return nil, false, ErrRequestInProgress
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, rec *Record) error {
+	return s.repo.UpdateRecord(ctx, rec)
+}
+
+func (s *idempotencyService) Release(ctx context.Context, rec *Record) error {
+	return s.repo.DeleteRecord(ctx, rec.Scope, rec.Key)
+}
+
+func (s *idempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpired(ctx, s.now())
+}
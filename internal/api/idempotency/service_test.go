@@ -0,0 +1,147 @@
+package idempotency_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/idempotency"
+	"github.com/hferr/device-manager/test/mock"
+
+	"gorm.io/gorm"
+)
+
+func TestServiceReserve(t *testing.T) {
+	fingerprint := idempotency.Fingerprint("POST", "/devices", "application/json", []byte(`{}`))
+
+	var testCases = map[string]struct {
+		wantErr    error
+		wantReplay bool
+		repo       mock.IdempotencyRepository
+	}{
+		"reserves unused key": {
+			repo: mock.IdempotencyRepository{
+				InsertRecordFunc: func(rec *idempotency.Record) error {
+					return nil
+				},
+			},
+		},
+		"replays completed request": {
+			wantReplay: true,
+			repo: mock.IdempotencyRepository{
+				InsertRecordFunc: func(rec *idempotency.Record) error {
+					return idempotency.ErrDuplicateKey
+				},
+				FindByKeyFunc: func(scope, key string) (*idempotency.Record, error) {
+					return &idempotency.Record{
+						RequestHash: fingerprint,
+						StatusCode:  201,
+						ExpiresAt:   time.Now().Add(time.Hour),
+					}, nil
+				},
+			},
+		},
+		"key reused with a different request": {
+			wantErr: idempotency.ErrKeyReused,
+			repo: mock.IdempotencyRepository{
+				InsertRecordFunc: func(rec *idempotency.Record) error {
+					return idempotency.ErrDuplicateKey
+				},
+				FindByKeyFunc: func(scope, key string) (*idempotency.Record, error) {
+					return &idempotency.Record{
+						RequestHash: "other",
+						StatusCode:  201,
+						ExpiresAt:   time.Now().Add(time.Hour),
+					}, nil
+				},
+			},
+		},
+		"request still in progress": {
+			wantErr: idempotency.ErrRequestInProgress,
+			repo: mock.IdempotencyRepository{
+				InsertRecordFunc: func(rec *idempotency.Record) error {
+					return idempotency.ErrDuplicateKey
+				},
+				FindByKeyFunc: func(scope, key string) (*idempotency.Record, error) {
+					return &idempotency.Record{
+						RequestHash: fingerprint,
+						ExpiresAt:   time.Now().Add(time.Hour),
+					}, nil
+				},
+			},
+		},
+		"expired key is reserved again": {
+			repo: func() mock.IdempotencyRepository {
+				inserted := false
+				return mock.IdempotencyRepository{
+					InsertRecordFunc: func(rec *idempotency.Record) error {
+						if inserted {
+							return nil
+						}
+						inserted = true
+						return idempotency.ErrDuplicateKey
+					},
+					FindByKeyFunc: func(scope, key string) (*idempotency.Record, error) {
+						return &idempotency.Record{
+							RequestHash: "other",
+							StatusCode:  201,
+							ExpiresAt:   time.Now().Add(-time.Hour),
+						}, nil
+					},
+					DeleteRecordFunc: func(scope, key string) error {
+						return nil
+					},
+				}
+			}(),
+		},
+		"concurrently released key is reserved again": {
+			repo: func() mock.IdempotencyRepository {
+				inserted := false
+				return mock.IdempotencyRepository{
+					InsertRecordFunc: func(rec *idempotency.Record) error {
+						if inserted {
+							return nil
+						}
+						inserted = true
+						return idempotency.ErrDuplicateKey
+					},
+					FindByKeyFunc: func(scope, key string) (*idempotency.Record, error) {
+						return nil, gorm.ErrRecordNotFound
+					},
+				}
+			}(),
+		},
+		"repo returns error": {
+			wantErr: fmt.Errorf("boom"),
+			repo: mock.IdempotencyRepository{
+				InsertRecordFunc: func(rec *idempotency.Record) error {
+					return fmt.Errorf("boom")
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := idempotency.NewService(&tc.repo, time.Hour)
+
+			_, replay, err := s.Reserve(context.Background(), "scope", "key", fingerprint)
+			if tc.wantErr == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if tc.wantErr != nil && (err == nil || err.Error() != tc.wantErr.Error()) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+
+			if tc.wantReplay != replay {
+				t.Fatalf("expected replay to be %t, got %t", tc.wantReplay, replay)
+			}
+		})
+	}
+}
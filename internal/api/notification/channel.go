@@ -0,0 +1,19 @@
+package notification
+
+import "context"
+
+// Message is a notification, or a digest of notifications, ready to send.
+type Message struct {
+	// Topic is the topic of the notification, or "digest".
+	Topic     string
+	Recipient string
+	// To is the email address or the webhook URL of the recipient.
+	To      string
+	Subject string
+	Body    string
+}
+
+// Channel sends messages, such as emails or webhooks.
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/hferr/device-manager/internal/api/device"
+
+	"github.com/google/uuid"
+)
+
+// TopicDeviceDeleted is the topic of the notifications telling the watchers
+// of a device that it was deleted.
+const TopicDeviceDeleted = "device.deleted"
+
+// WatchersFunc returns the recipients to notify of the changes of the device
+// with the given ID, such as its holders.
+type WatchersFunc func(ctx context.Context, deviceID uuid.UUID) ([]string, error)
+
+type notifyingDeviceService struct {
+	device.DeviceService
+	notifier Notifier
+	watchers WatchersFunc
+	logger   *slog.Logger
+}
+
+// NewDeviceService returns a DeviceService notifying the watchers of the
+// devices deleted through next. Watchers are looked up before the deletion,
+// which may take their reservations and waitlist entries along. Failures to
+// notify are logged to l, and do not fail the deletion.
+func NewDeviceService(next device.DeviceService, n Notifier, watchers WatchersFunc, l *slog.Logger) device.DeviceService {
+	return &notifyingDeviceService{
+		DeviceService: next,
+		notifier:      n,
+		watchers:      watchers,
+		logger:        l,
+	}
+}
+
+func (s *notifyingDeviceService) DeleteDevice(ctx context.Context, ID uuid.UUID) error {
+	d, err := s.DeviceService.FindByID(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := s.watchers(ctx, ID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to look up the watchers of a device", slog.String("device_id", ID.String()), slog.Any("error", err))
+	}
+
+	if err := s.DeviceService.DeleteDevice(ctx, ID); err != nil {
+		return err
+	}
+
+	for _, recipient := range recipients {
+		err := s.notifier.Notify(ctx, Event{
+			Topic:     TopicDeviceDeleted,
+			Recipient: recipient,
+			Data: map[string]any{
+				"device_id": d.ID.String(),
+				"name":      d.Name,
+				"brand":     d.Brand,
+			},
+		})
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to notify of a deleted device", slog.String("recipient", recipient), slog.Any("error", err))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package notification
+
+import "errors"
+
+var (
+	// ErrInvalidTemplate is returned for templates not defining both a
+	// subject and a body.
+	ErrInvalidTemplate = errors.New("notification template must define a subject and a body")
+	// ErrWebhookStatus is returned for webhooks answering with a status other
+	// than 2xx.
+	ErrWebhookStatus = errors.New("webhook answered with an unexpected status")
+	// ErrWebhookForbidden is returned for webhook URLs that are not http or
+	// https URLs of a public host, such as a loopback or private address.
+	ErrWebhookForbidden = errors.New("webhook URL must be an http or https URL of a public host")
+)
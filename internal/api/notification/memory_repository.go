@@ -0,0 +1,155 @@
+package notification
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// preferenceKey identifies the preference of a recipient of a tenant.
+type preferenceKey struct {
+	tenantID  uuid.UUID
+	recipient string
+}
+
+// memoryRepository is a NotificationRepository keeping notifications and
+// preferences in memory. They are copied in and out, so callers never share
+// them with the store.
+type memoryRepository struct {
+	mu            sync.RWMutex
+	notifications map[uuid.UUID]*Notification
+	preferences   map[preferenceKey]*Preference
+}
+
+// NewMemoryRepository returns a thread-safe in-memory NotificationRepository.
+func NewMemoryRepository() NotificationRepository {
+	return &memoryRepository{
+		notifications: make(map[uuid.UUID]*Notification),
+		preferences:   make(map[preferenceKey]*Preference),
+	}
+}
+
+func (r *memoryRepository) InsertNotification(ctx context.Context, n *Notification) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n.TenantID = tenantID
+	stored := *n
+	r.notifications[stored.ID] = &stored
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateNotification(ctx context.Context, n *Notification, from string) (bool, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.notifications[n.ID]
+	if !ok || stored.TenantID != tenantID || stored.Status != from {
+		return false, nil
+	}
+
+	stored.Status = n.Status
+	stored.Error = n.Error
+	stored.SentAt = n.SentAt
+
+	return true, nil
+}
+
+func (r *memoryRepository) ListNotifications(ctx context.Context, f Filter) (Notifications, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := r.list(func(n *Notification) bool {
+		return n.TenantID == tenantID &&
+			(f.Recipient == "" || n.Recipient == f.Recipient) &&
+			(f.Topic == "" || n.Topic == f.Topic) &&
+			(f.Status == "" || n.Status == f.Status)
+	})
+
+	slices.Reverse(ns)
+	if f.Limit > 0 && len(ns) > f.Limit {
+		ns = ns[:f.Limit]
+	}
+
+	return ns, nil
+}
+
+func (r *memoryRepository) ListQueued(_ context.Context) (Notifications, error) {
+	return r.list(func(n *Notification) bool {
+		return n.Status == StatusQueued
+	}), nil
+}
+
+func (r *memoryRepository) FindPreference(ctx context.Context, recipient string) (*Preference, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.preferences[preferenceKey{tenantID, recipient}]
+	if !ok {
+		return nil, nil
+	}
+
+	found := *p
+	return &found, nil
+}
+
+func (r *memoryRepository) SavePreference(ctx context.Context, p *Preference) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p.TenantID = tenantID
+	stored := *p
+	r.preferences[preferenceKey{tenantID, p.Recipient}] = &stored
+
+	return nil
+}
+
+// list returns copies of the notifications matching keep, oldest first.
+func (r *memoryRepository) list(keep func(n *Notification) bool) Notifications {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ns := make(Notifications, 0)
+	for _, n := range r.notifications {
+		if keep(n) {
+			found := *n
+			ns = append(ns, &found)
+		}
+	}
+
+	slices.SortFunc(ns, func(a, b *Notification) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.ID[:], b.ID[:])
+	})
+
+	return ns
+}
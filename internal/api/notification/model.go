@@ -0,0 +1,170 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Channels notifications are sent through.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Statuses of a notification.
+const (
+	// StatusQueued notifications wait for the next delivery, or for their
+	// digest.
+	StatusQueued = "queued"
+	// StatusSending notifications are being sent by a replica.
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+	// StatusSkipped notifications could not be sent, the recipient having no
+	// channel set, or only channels that are not configured.
+	StatusSkipped = "skipped"
+)
+
+// Event is something to tell a recipient about, rendered with the templates
+// of its topic.
+type Event struct {
+	Topic     string
+	Recipient string
+	// Data is passed to the templates of the topic.
+	Data map[string]any
+}
+
+// Notification is the log of an event sent, or to send, to a recipient
+// through a channel.
+type Notification struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	TenantID  uuid.UUID
+	Recipient string
+	Topic     string
+	Channel   string
+	// Address is the email address or the webhook URL the notification is
+	// sent to.
+	Address string
+	Subject string
+	Body    string
+	// Digest notifications are batched with the other notifications of the
+	// recipient, rather than sent on their own.
+	Digest    bool
+	Status    string
+	Error     string
+	CreatedAt time.Time
+	SentAt    *time.Time
+}
+
+type Notifications []*Notification
+
+type DTO struct {
+	ID        uuid.UUID `json:"id"`
+	Recipient string    `json:"recipient"`
+	Topic     string    `json:"topic" example:"waitlist.offered"`
+	Channel   string    `json:"channel,omitempty" enums:"email,webhook"`
+	Address   string    `json:"address,omitempty"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Digest    bool      `json:"digest"`
+	Status    string    `json:"status" enums:"queued,sending,sent,failed,skipped"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt string    `json:"created_at"`
+	SentAt    *string   `json:"sent_at,omitempty"`
+}
+
+// Filter selects the notifications to list. Zero fields match every
+// notification, but for Limit.
+type Filter struct {
+	Recipient string
+	Topic     string
+	Status    string
+	// Limit caps the number of notifications listed, newest first.
+	Limit int
+}
+
+// Preference is how a recipient wants to be notified. A channel is enabled
+// by setting its address.
+type Preference struct {
+	TenantID   uuid.UUID `gorm:"primarykey"`
+	Recipient  string    `gorm:"primarykey"`
+	Email      string
+	WebhookURL string
+	// Digest batches the notifications of the recipient into digests.
+	Digest    bool
+	UpdatedAt time.Time
+}
+
+func (Preference) TableName() string {
+	return "notification_preferences"
+}
+
+type PreferenceDTO struct {
+	Recipient  string `json:"recipient"`
+	Email      string `json:"email,omitempty"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+	Digest     bool   `json:"digest"`
+}
+
+// UpdatePreferenceRequest replaces the preference of a recipient. Empty
+// addresses disable their channel.
+type UpdatePreferenceRequest struct {
+	Email      string `json:"email" validate:"omitempty,email,max=255" example:"alice@example.com"`
+	WebhookURL string `json:"webhook_url" validate:"omitempty,http_url,max=2048" example:"https://hooks.example.com/devices"`
+	Digest     bool   `json:"digest"`
+}
+
+// channels returns the addresses of the channels enabled by p, by channel.
+func (p *Preference) channels() map[string]string {
+	channels := make(map[string]string, 2)
+	if p.Email != "" {
+		channels[ChannelEmail] = p.Email
+	}
+	if p.WebhookURL != "" {
+		channels[ChannelWebhook] = p.WebhookURL
+	}
+
+	return channels
+}
+
+func (p *Preference) ToDto() *PreferenceDTO {
+	return &PreferenceDTO{
+		Recipient:  p.Recipient,
+		Email:      p.Email,
+		WebhookURL: p.WebhookURL,
+		Digest:     p.Digest,
+	}
+}
+
+func (n *Notification) ToDto() *DTO {
+	dto := &DTO{
+		ID:        n.ID,
+		Recipient: n.Recipient,
+		Topic:     n.Topic,
+		Channel:   n.Channel,
+		Address:   n.Address,
+		Subject:   n.Subject,
+		Body:      n.Body,
+		Digest:    n.Digest,
+		Status:    n.Status,
+		Error:     n.Error,
+		CreatedAt: n.CreatedAt.Format(time.DateTime),
+	}
+
+	if n.SentAt != nil {
+		sentAt := n.SentAt.Format(time.DateTime)
+		dto.SentAt = &sentAt
+	}
+
+	return dto
+}
+
+func (ns Notifications) ToDto() []*DTO {
+	dtos := make([]*DTO, len(ns))
+	for i, v := range ns {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
@@ -0,0 +1,142 @@
+package notification
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository interface {
+	InsertNotification(ctx context.Context, n *Notification) error
+	// UpdateNotification updates the status, error and sending time of a
+	// notification still in the status from, reporting whether it was. A
+	// notification is thus only claimed by one of the replicas delivering it.
+	UpdateNotification(ctx context.Context, n *Notification, from string) (bool, error)
+	// ListNotifications returns the notifications matching f, newest first.
+	ListNotifications(ctx context.Context, f Filter) (Notifications, error)
+	// ListQueued returns the queued notifications of every tenant, oldest
+	// first. It is not scoped to the tenant of ctx.
+	ListQueued(ctx context.Context) (Notifications, error)
+	// FindPreference returns nil, without error, for recipients without
+	// preference.
+	FindPreference(ctx context.Context, recipient string) (*Preference, error)
+	// SavePreference inserts or replaces the preference of a recipient.
+	SavePreference(ctx context.Context, p *Preference) error
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository expects db to translate driver errors, see database.Open.
+func NewRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{
+		db: db,
+	}
+}
+
+func (r *notificationRepository) InsertNotification(ctx context.Context, n *Notification) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	n.TenantID = tenantID
+	return r.db.WithContext(ctx).Create(n).Error
+}
+
+func (r *notificationRepository) UpdateNotification(ctx context.Context, n *Notification, from string) (bool, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	res := r.db.WithContext(ctx).
+		Model(&Notification{}).
+		Select("status", "error", "sent_at").
+		Where("id = ? AND tenant_id = ? AND status = ?", n.ID, tenantID, from).
+		Updates(n)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func (r *notificationRepository) ListNotifications(ctx context.Context, f Filter) (Notifications, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.db.WithContext(ctx).Where("tenant_id = ?", tenantID)
+	if f.Recipient != "" {
+		q = q.Where("recipient = ?", f.Recipient)
+	}
+	if f.Topic != "" {
+		q = q.Where("topic = ?", f.Topic)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+
+	ns := make(Notifications, 0)
+	if err := q.Order("created_at DESC, id DESC").Find(&ns).Error; err != nil {
+		return nil, err
+	}
+
+	return ns, nil
+}
+
+func (r *notificationRepository) ListQueued(ctx context.Context) (Notifications, error) {
+	ns := make(Notifications, 0)
+	err := r.db.WithContext(ctx).
+		Where("status = ?", StatusQueued).
+		Order("created_at, id").
+		Find(&ns).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return ns, nil
+}
+
+func (r *notificationRepository) FindPreference(ctx context.Context, recipient string) (*Preference, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Preference{}
+	err = r.db.WithContext(ctx).Where("tenant_id = ? AND recipient = ?", tenantID, recipient).First(p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (r *notificationRepository) SavePreference(ctx context.Context, p *Preference) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.TenantID = tenantID
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "recipient"}},
+			DoUpdates: clause.AssignmentColumns([]string{"email", "webhook_url", "digest", "updated_at"}),
+		}).
+		Create(p).Error
+}
@@ -0,0 +1,170 @@
+package notification_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/test"
+
+	"github.com/google/uuid"
+)
+
+// repositories runs fn against the gorm repository of every driver and the
+// memory repository.
+func repositories(t *testing.T, fn func(t *testing.T, repo notification.NotificationRepository)) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			fn(t, notification.NewRepository(db))
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		fn(t, notification.NewMemoryRepository())
+	})
+}
+
+func tenantCtx() context.Context {
+	return organization.WithTenant(context.Background(), organization.DefaultID)
+}
+
+// at returns the time m minutes after a fixed reference time.
+func at(m int) time.Time {
+	return time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC).Add(time.Duration(m) * time.Minute)
+}
+
+func newNotification(recipient, topic string, m int) *notification.Notification {
+	return &notification.Notification{
+		ID:        uuid.New(),
+		Recipient: recipient,
+		Topic:     topic,
+		Channel:   notification.ChannelEmail,
+		Address:   recipient + "@example.com",
+		Subject:   "subject",
+		Body:      "body",
+		Status:    notification.StatusQueued,
+		CreatedAt: at(m),
+	}
+}
+
+func TestRepositoryNotifications(t *testing.T) {
+	repositories(t, func(t *testing.T, repo notification.NotificationRepository) {
+		ctx := tenantCtx()
+
+		first, second, other := newNotification("alice", "waitlist.offered", 1), newNotification("alice", "device.deleted", 2), newNotification("bob", "waitlist.offered", 3)
+		for _, n := range []*notification.Notification{first, second, other} {
+			if err := repo.InsertNotification(ctx, n); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		// assert a notification is claimed once
+
+		first.Status = notification.StatusSending
+		for i, want := range []bool{true, false} {
+			claimed, err := repo.UpdateNotification(ctx, first, notification.StatusQueued)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if claimed != want {
+				t.Fatalf("expected attempt %d to claim: %t, got: %t", i, want, claimed)
+			}
+		}
+
+		sentAt := at(4)
+		first.Status, first.SentAt = notification.StatusSent, &sentAt
+		if _, err := repo.UpdateNotification(ctx, first, notification.StatusSending); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		var testCases = map[string]struct {
+			want []*notification.Notification
+			f    notification.Filter
+		}{
+			"every notification newest first": {[]*notification.Notification{other, second, first}, notification.Filter{}},
+			"of a recipient":                  {[]*notification.Notification{second, first}, notification.Filter{Recipient: "alice"}},
+			"of a topic":                      {[]*notification.Notification{other, first}, notification.Filter{Topic: "waitlist.offered"}},
+			"with a status":                   {[]*notification.Notification{first}, notification.Filter{Status: notification.StatusSent}},
+			"up to a limit":                   {[]*notification.Notification{other}, notification.Filter{Limit: 1}},
+			"of another tenant":               {nil, notification.Filter{}},
+		}
+
+		for name, tc := range testCases {
+			listCtx := ctx
+			if tc.want == nil {
+				listCtx = organization.WithTenant(context.Background(), uuid.New())
+			}
+
+			ns, err := repo.ListNotifications(listCtx, tc.f)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got: %v", name, err)
+			}
+
+			if len(ns) != len(tc.want) {
+				t.Fatalf("%s: expected %d notifications, got: %d", name, len(tc.want), len(ns))
+			}
+
+			for i, n := range ns {
+				if n.ID != tc.want[i].ID {
+					t.Fatalf("%s: expected notification %d to be %s, got: %s", name, i, tc.want[i].ID, n.ID)
+				}
+			}
+		}
+
+		// assert only the queued notifications are listed for delivery,
+		// oldest first and whatever their tenant
+
+		ns, err := repo.ListQueued(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(ns) != 2 || ns[0].ID != second.ID || ns[1].ID != other.ID {
+			t.Fatalf("expected notifications %s and %s to be queued, got: %d notifications", second.ID, other.ID, len(ns))
+		}
+	})
+}
+
+func TestRepositoryPreferences(t *testing.T) {
+	repositories(t, func(t *testing.T, repo notification.NotificationRepository) {
+		ctx := tenantCtx()
+
+		p, err := repo.FindPreference(ctx, "alice")
+		if err != nil || p != nil {
+			t.Fatalf("expected no preference, got: %+v, %v", p, err)
+		}
+
+		// assert saving a preference again replaces it
+
+		for _, want := range []*notification.Preference{
+			{Recipient: "alice", Email: "alice@example.com", Digest: true, UpdatedAt: at(0)},
+			{Recipient: "alice", WebhookURL: "https://hooks.example.com", UpdatedAt: at(1)},
+		} {
+			if err := repo.SavePreference(ctx, want); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			p, err := repo.FindPreference(ctx, "alice")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if p.Email != want.Email || p.WebhookURL != want.WebhookURL || p.Digest != want.Digest || p.TenantID != organization.DefaultID {
+				t.Fatalf("expected preference %+v, got: %+v", want, p)
+			}
+		}
+
+		// assert preferences are scoped to their tenant
+
+		p, err = repo.FindPreference(organization.WithTenant(context.Background(), uuid.New()), "alice")
+		if err != nil || p != nil {
+			t.Fatalf("expected no preference, got: %+v, %v", p, err)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// DefaultDigestInterval is how long the notifications of recipients asking
+// for digests are batched, unless set with WithDigestInterval.
+const DefaultDigestInterval = time.Hour
+
+// Notifier notifies recipients of events.
+type Notifier interface {
+	// Notify queues the notifications of ev, one for each channel the
+	// recipient enabled, to be sent by the next delivery.
+	Notify(ctx context.Context, ev Event) error
+}
+
+type NotificationService interface {
+	Notifier
+	ListNotifications(ctx context.Context, f Filter) (Notifications, error)
+	// FindPreference returns the preference of a recipient, one without
+	// channel for recipients who did not set theirs.
+	FindPreference(ctx context.Context, recipient string) (*Preference, error)
+	// UpdatePreference replaces the preference of a recipient, reporting
+	// webhook URLs of hosts that are not public as ErrWebhookForbidden.
+	UpdatePreference(ctx context.Context, recipient string, input UpdatePreferenceRequest) (*Preference, error)
+	// Deliver sends the queued notifications of every tenant, and the digests
+	// whose oldest notification was queued a digest interval ago. It returns
+	// the number of messages sent.
+	Deliver(ctx context.Context) (int, error)
+}
+
+type ServiceOption func(*notificationService)
+
+// WithChannel sends the notifications of the channel name through c. The
+// notifications of channels without sender are skipped.
+func WithChannel(name string, c Channel) ServiceOption {
+	return func(s *notificationService) {
+		s.channels[name] = c
+	}
+}
+
+// WithTemplates sets the templates rendering the notifications, the
+// built-in ones by default.
+func WithTemplates(t *Templates) ServiceOption {
+	return func(s *notificationService) {
+		s.templates = t
+	}
+}
+
+// WithDigestInterval sets how long the notifications of recipients asking
+// for digests are batched, DefaultDigestInterval by default.
+func WithDigestInterval(d time.Duration) ServiceOption {
+	return func(s *notificationService) {
+		s.digestInterval = d
+	}
+}
+
+// WithClock sets the clock telling the time, time.Now by default.
+func WithClock(now func() time.Time) ServiceOption {
+	return func(s *notificationService) {
+		s.now = now
+	}
+}
+
+type notificationService struct {
+	repo           NotificationRepository
+	channels       map[string]Channel
+	templates      *Templates
+	digestInterval time.Duration
+	now            func() time.Time
+}
+
+func NewService(r NotificationRepository, opts ...ServiceOption) NotificationService {
+	s := &notificationService{
+		repo:           r,
+		channels:       make(map[string]Channel),
+		templates:      DefaultTemplates(),
+		digestInterval: DefaultDigestInterval,
+		now:            time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *notificationService) Notify(ctx context.Context, ev Event) error {
+	now := s.now()
+
+	subject, body, err := s.templates.Render(ev, now)
+	if err != nil {
+		return fmt.Errorf("failed to render %s notification: %w", ev.Topic, err)
+	}
+
+	p, err := s.FindPreference(ctx, ev.Recipient)
+	if err != nil {
+		return err
+	}
+
+	newNotification := func(channel, address string) *Notification {
+		return &Notification{
+			ID:        uuid.New(),
+			Recipient: ev.Recipient,
+			Topic:     ev.Topic,
+			Channel:   channel,
+			Address:   address,
+			Subject:   subject,
+			Body:      body,
+			Digest:    p.Digest,
+			Status:    StatusQueued,
+			CreatedAt: now,
+		}
+	}
+
+	// the notifications that cannot be sent are logged all the same, so that
+	// the log tells why a recipient was not notified
+	var ns Notifications
+	for _, channel := range []string{ChannelEmail, ChannelWebhook} {
+		address, ok := p.channels()[channel]
+		if !ok {
+			continue
+		}
+
+		n := newNotification(channel, address)
+		if _, ok := s.channels[channel]; !ok {
+			n.Status, n.Error = StatusSkipped, fmt.Sprintf("the %s channel is not configured", channel)
+		}
+		ns = append(ns, n)
+	}
+
+	if len(ns) == 0 {
+		n := newNotification("", "")
+		n.Status, n.Error = StatusSkipped, "the recipient has no channel set"
+		ns = append(ns, n)
+	}
+
+	for _, n := range ns {
+		if err := s.repo.InsertNotification(ctx, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *notificationService) ListNotifications(ctx context.Context, f Filter) (Notifications, error) {
+	ns, err := s.repo.ListNotifications(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return ns, nil
+}
+
+func (s *notificationService) FindPreference(ctx context.Context, recipient string) (*Preference, error) {
+	p, err := s.repo.FindPreference(ctx, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	if p == nil {
+		return &Preference{Recipient: recipient}, nil
+	}
+
+	return p, nil
+}
+
+func (s *notificationService) UpdatePreference(ctx context.Context, recipient string, input UpdatePreferenceRequest) (*Preference, error) {
+	if input.WebhookURL != "" {
+		if err := checkWebhookURL(input.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &Preference{
+		Recipient:  recipient,
+		Email:      input.Email,
+		WebhookURL: input.WebhookURL,
+		Digest:     input.Digest,
+		UpdatedAt:  s.now(),
+	}
+
+	if err := s.repo.SavePreference(ctx, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (s *notificationService) Deliver(ctx context.Context) (int, error) {
+	now := s.now()
+
+	ns, err := s.repo.ListQueued(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// digests gather the notifications of a recipient to the same address,
+	// in the order they were queued
+	type digestKey struct {
+		tenantID  uuid.UUID
+		recipient string
+		channel   string
+		address   string
+	}
+	var (
+		digests = make(map[digestKey]Notifications)
+		order   []digestKey
+		sent    int
+		errs    []error
+	)
+	for _, n := range ns {
+		if !n.Digest {
+			if err := s.send(ctx, Notifications{n}, Message{Topic: n.Topic, Subject: n.Subject, Body: n.Body}); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			sent++
+			continue
+		}
+
+		key := digestKey{n.TenantID, n.Recipient, n.Channel, n.Address}
+		if _, ok := digests[key]; !ok {
+			order = append(order, key)
+		}
+		digests[key] = append(digests[key], n)
+	}
+
+	for _, key := range order {
+		batch := digests[key]
+		if now.Sub(batch[0].CreatedAt) < s.digestInterval {
+			continue
+		}
+
+		subject, body, err := s.templates.RenderDigest(key.recipient, batch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to render digest of %s: %w", key.recipient, err))
+			continue
+		}
+
+		if err := s.send(ctx, batch, Message{Topic: digestTemplate, Subject: subject, Body: body}); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// send claims ns, notifications of the same recipient to the same address,
+// then sends them as msg, logging the outcome on each of them. Notifications
+// claimed by another replica are left to it. The error reports failures to
+// send or log.
+func (s *notificationService) send(ctx context.Context, ns Notifications, msg Message) error {
+	first := ns[0]
+	tenantCtx := organization.WithTenant(ctx, first.TenantID)
+
+	claimed := make(Notifications, 0, len(ns))
+	for _, n := range ns {
+		n.Status = StatusSending
+		ok, err := s.repo.UpdateNotification(tenantCtx, n, StatusQueued)
+		if err != nil {
+			return fmt.Errorf("notification %s: %w", n.ID, err)
+		}
+		if ok {
+			claimed = append(claimed, n)
+		}
+	}
+
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	msg.Recipient, msg.To = first.Recipient, first.Address
+
+	var sendErr error
+	if channel, ok := s.channels[first.Channel]; ok {
+		sendErr = channel.Send(ctx, msg)
+	} else {
+		sendErr = fmt.Errorf("the %s channel is not configured", first.Channel)
+	}
+
+	now := s.now()
+	var errs []error
+	for _, n := range claimed {
+		n.Status, n.SentAt = StatusSent, &now
+		if sendErr != nil {
+			n.Status, n.Error, n.SentAt = StatusFailed, sendErr.Error(), nil
+		}
+
+		if _, err := s.repo.UpdateNotification(tenantCtx, n, StatusSending); err != nil {
+			errs = append(errs, fmt.Errorf("notification %s: %w", n.ID, err))
+		}
+	}
+
+	if sendErr != nil {
+		errs = append(errs, fmt.Errorf("failed to send %s notification to %s: %w", first.Channel, first.Recipient, sendErr))
+	}
+
+	return errors.Join(errs...)
+}
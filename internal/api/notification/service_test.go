@@ -0,0 +1,359 @@
+package notification_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/test/mock"
+
+	"github.com/google/uuid"
+)
+
+// fakeChannel records the messages it sends, or fails with err.
+type fakeChannel struct {
+	mu   sync.Mutex
+	sent []notification.Message
+	err  error
+}
+
+func (c *fakeChannel) Send(_ context.Context, msg notification.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, msg)
+
+	return nil
+}
+
+func offeredEvent(recipient string) notification.Event {
+	return notification.Event{
+		Topic:     "waitlist.offered",
+		Recipient: recipient,
+		Data:      map[string]any{"device_id": "d1", "entry_id": "e1", "offer_expires_at": "10:15"},
+	}
+}
+
+func TestServiceNotify(t *testing.T) {
+	var testCases = map[string]struct {
+		pref       *notification.UpdatePreferenceRequest
+		opts       []notification.ServiceOption
+		want       map[string]string
+		wantDigest bool
+	}{
+		"email and webhook": {
+			pref: &notification.UpdatePreferenceRequest{Email: "alice@example.com", WebhookURL: "https://hooks.example.com"},
+			opts: []notification.ServiceOption{
+				notification.WithChannel(notification.ChannelEmail, &fakeChannel{}),
+				notification.WithChannel(notification.ChannelWebhook, &fakeChannel{}),
+			},
+			want: map[string]string{notification.ChannelEmail: notification.StatusQueued, notification.ChannelWebhook: notification.StatusQueued},
+		},
+		"digest": {
+			pref:       &notification.UpdatePreferenceRequest{Email: "alice@example.com", Digest: true},
+			opts:       []notification.ServiceOption{notification.WithChannel(notification.ChannelEmail, &fakeChannel{})},
+			want:       map[string]string{notification.ChannelEmail: notification.StatusQueued},
+			wantDigest: true,
+		},
+		"channel not configured": {
+			pref: &notification.UpdatePreferenceRequest{WebhookURL: "https://hooks.example.com"},
+			opts: []notification.ServiceOption{notification.WithChannel(notification.ChannelEmail, &fakeChannel{})},
+			want: map[string]string{notification.ChannelWebhook: notification.StatusSkipped},
+		},
+		"no preference": {
+			want: map[string]string{"": notification.StatusSkipped},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := tenantCtx()
+			s := notification.NewService(notification.NewMemoryRepository(), tc.opts...)
+
+			if tc.pref != nil {
+				if _, err := s.UpdatePreference(ctx, "alice", *tc.pref); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+			}
+
+			if err := s.Notify(ctx, offeredEvent("alice")); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			ns, err := s.ListNotifications(ctx, notification.Filter{})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if len(ns) != len(tc.want) {
+				t.Fatalf("expected %d notifications, got: %d", len(tc.want), len(ns))
+			}
+
+			for _, n := range ns {
+				if want, ok := tc.want[n.Channel]; !ok || n.Status != want {
+					t.Fatalf("expected %q notification to be %s, got: %s", n.Channel, want, n.Status)
+				}
+
+				if n.Digest != tc.wantDigest {
+					t.Fatalf("expected digest: %t, got: %t", tc.wantDigest, n.Digest)
+				}
+
+				if n.Subject != "A device is available for you" || !strings.Contains(n.Body, "/waitlist/e1/claim") {
+					t.Fatalf("expected the rendered offer, got: %q, %q", n.Subject, n.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceDeliver(t *testing.T) {
+	ctx := tenantCtx()
+	now := at(0)
+	email, webhook := &fakeChannel{}, &fakeChannel{}
+	s := notification.NewService(notification.NewMemoryRepository(),
+		notification.WithChannel(notification.ChannelEmail, email),
+		notification.WithChannel(notification.ChannelWebhook, webhook),
+		notification.WithDigestInterval(time.Hour),
+		notification.WithClock(func() time.Time { return now }),
+	)
+
+	for recipient, pref := range map[string]notification.UpdatePreferenceRequest{
+		"alice": {Email: "alice@example.com"},
+		"bob":   {WebhookURL: "https://hooks.example.com", Digest: true},
+	} {
+		if _, err := s.UpdatePreference(ctx, recipient, pref); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	for _, recipient := range []string{"alice", "bob", "bob"} {
+		if err := s.Notify(ctx, offeredEvent(recipient)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	// assert immediate notifications are sent right away, and digests are
+	// held back until their interval passed
+
+	for _, tc := range []struct {
+		after   time.Duration
+		want    int
+		emails  int
+		digests int
+	}{
+		{0, 1, 1, 0},
+		{30 * time.Minute, 0, 1, 0},
+		{time.Hour, 1, 1, 1},
+		{2 * time.Hour, 0, 1, 1},
+	} {
+		now = at(0).Add(tc.after)
+
+		sent, err := s.Deliver(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if sent != tc.want || len(email.sent) != tc.emails || len(webhook.sent) != tc.digests {
+			t.Fatalf("after %s: expected %d messages sent, %d emails and %d digests, got: %d, %d and %d",
+				tc.after, tc.want, tc.emails, tc.digests, sent, len(email.sent), len(webhook.sent))
+		}
+	}
+
+	if msg := email.sent[0]; msg.To != "alice@example.com" || msg.Topic != "waitlist.offered" {
+		t.Fatalf("expected the offer to be emailed to alice, got: %+v", msg)
+	}
+
+	if msg := webhook.sent[0]; msg.Subject != "2 device manager notifications" || msg.To != "https://hooks.example.com" {
+		t.Fatalf("expected a digest of 2 notifications to bob, got: %+v", msg)
+	}
+
+	ns, err := s.ListNotifications(ctx, notification.Filter{Status: notification.StatusSent})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(ns) != 3 {
+		t.Fatalf("expected 3 notifications sent, got: %d", len(ns))
+	}
+}
+
+func TestServiceDeliverFailure(t *testing.T) {
+	ctx := tenantCtx()
+	errSend := errors.New("connection refused")
+	s := notification.NewService(notification.NewMemoryRepository(),
+		notification.WithChannel(notification.ChannelEmail, &fakeChannel{err: errSend}),
+	)
+
+	if _, err := s.UpdatePreference(ctx, "alice", notification.UpdatePreferenceRequest{Email: "alice@example.com"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if err := s.Notify(ctx, offeredEvent("alice")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	sent, err := s.Deliver(context.Background())
+	if !errors.Is(err, errSend) || sent != 0 {
+		t.Fatalf("expected error: %v and nothing sent, got: %v, %d", errSend, err, sent)
+	}
+
+	// assert the failure is logged and not retried
+
+	ns, err := s.ListNotifications(ctx, notification.Filter{Status: notification.StatusFailed})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(ns) != 1 || ns[0].Error != errSend.Error() {
+		t.Fatalf("expected a notification failed with %q, got: %d notifications", errSend, len(ns))
+	}
+
+	if sent, err := s.Deliver(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("expected nothing left to deliver, got: %d, %v", sent, err)
+	}
+}
+
+func TestServiceUpdatePreferenceWebhookURL(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr error
+		url     string
+	}{
+		"public host":            {nil, "https://hooks.example.com/devices"},
+		"public address":         {nil, "http://203.0.113.7:8080/hooks"},
+		"localhost":              {notification.ErrWebhookForbidden, "http://localhost:8080/hooks"},
+		"loopback address":       {notification.ErrWebhookForbidden, "http://127.0.0.1/hooks"},
+		"IPv6 loopback address":  {notification.ErrWebhookForbidden, "http://[::1]/hooks"},
+		"link-local address":     {notification.ErrWebhookForbidden, "http://169.254.169.254/latest/meta-data"},
+		"private address":        {notification.ErrWebhookForbidden, "http://10.1.2.3/hooks"},
+		"mapped private address": {notification.ErrWebhookForbidden, "http://[::ffff:192.168.0.1]/hooks"},
+		"unspecified address":    {notification.ErrWebhookForbidden, "http://0.0.0.0/hooks"},
+		"other scheme":           {notification.ErrWebhookForbidden, "ftp://hooks.example.com"},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := notification.NewService(notification.NewMemoryRepository())
+
+			_, err := s.UpdatePreference(tenantCtx(), "alice", notification.UpdatePreferenceRequest{WebhookURL: tc.url})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	var testCases = map[string]struct {
+		text    string
+		wantErr error
+	}{
+		"override": {
+			text: `{{define "subject"}}Offer for {{.Recipient}}{{end}}{{define "body"}}Claim {{.Data.device_id}}{{end}}`,
+		},
+		"without body": {
+			text:    `{{define "subject"}}Offer{{end}}`,
+			wantErr: notification.ErrInvalidTemplate,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "waitlist.offered.tmpl"), []byte(tc.text), 0o600); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			tmpl, err := notification.LoadTemplates(dir)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if tc.wantErr != nil {
+				return
+			}
+
+			subject, body, err := tmpl.Render(offeredEvent("alice"), at(0))
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if subject != "Offer for alice" || body != "Claim d1" {
+				t.Fatalf("expected the overridden template, got: %q, %q", subject, body)
+			}
+
+			// assert the other topics keep their built-in template
+
+			subject, _, err = tmpl.Render(notification.Event{Topic: "unknown"}, at(0))
+			if err != nil || subject != "unknown" {
+				t.Fatalf("expected the default template, got: %q, %v", subject, err)
+			}
+		})
+	}
+}
+
+func TestDeviceServiceDeleteDevice(t *testing.T) {
+	ctx := tenantCtx()
+	d := &device.Device{ID: uuid.New(), Name: "iPhone", Brand: "Apple"}
+
+	var deleted bool
+	devices := &mock.DeviceService{
+		FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+			return d, nil
+		},
+		DeleteDeviceFunc: func(ID uuid.UUID) error {
+			deleted = true
+			return nil
+		},
+	}
+
+	n := notification.NewService(notification.NewMemoryRepository())
+	watchers := func(_ context.Context, ID uuid.UUID) ([]string, error) {
+		return []string{"alice", "bob"}, nil
+	}
+	s := notification.NewDeviceService(devices, n, watchers, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := s.DeleteDevice(ctx, d.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !deleted {
+		t.Fatal("expected the device to be deleted")
+	}
+
+	ns, err := n.ListNotifications(ctx, notification.Filter{Topic: notification.TopicDeviceDeleted})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(ns) != 2 {
+		t.Fatalf("expected 2 notifications, got: %d", len(ns))
+	}
+
+	for _, n := range ns {
+		if n.Subject != "Device iPhone was deleted" {
+			t.Fatalf("expected the deletion subject, got: %q", n.Subject)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package notification_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/test"
+)
+
+func TestSMTPSender(t *testing.T) {
+	server := test.NewSMTPServer(t)
+	s := notification.NewSMTPSender(server.Host, server.Port, "devices@example.com")
+
+	err := s.Send(context.Background(), notification.Message{
+		Topic:     "waitlist.offered",
+		Recipient: "alice",
+		To:        "alice@example.com",
+		Subject:   "A device is available for you",
+		Body:      "Device 42 is available.\n.Claim it soon.",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	msgs := server.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got: %d", len(msgs))
+	}
+
+	// assert the envelope and the message are those of the notification
+
+	msg := msgs[0]
+	if msg.From != "devices@example.com" || len(msg.To) != 1 || msg.To[0] != "alice@example.com" {
+		t.Fatalf("expected a message from devices@example.com to alice@example.com, got: %+v", msg)
+	}
+
+	for _, want := range []string{
+		"From: devices@example.com\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: A device is available for you\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nDevice 42 is available.\r\n.Claim it soon.\r\n",
+	} {
+		if !strings.Contains(msg.Data, want) {
+			t.Fatalf("expected message to contain %q, got: %q", want, msg.Data)
+		}
+	}
+}
+
+func TestSMTPSenderUnreachable(t *testing.T) {
+	server := test.NewSMTPServer(t)
+	s := notification.NewSMTPSender(server.Host, 1, "devices@example.com")
+
+	if err := s.Send(context.Background(), notification.Message{To: "alice@example.com"}); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
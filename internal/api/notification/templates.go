@@ -0,0 +1,150 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// templateExt is the extension of the template files, named after their
+	// topic, such as waitlist.offered.tmpl.
+	templateExt = ".tmpl"
+	// digestTemplate is the name of the template of digests.
+	digestTemplate = "digest"
+	// defaultTemplate is the name of the template of the topics without one.
+	defaultTemplate = "default"
+)
+
+// defaultTemplates are the templates of the known topics, of the topics
+// without template and of digests. Each defines a subject and a body.
+var defaultTemplates = map[string]string{
+	"waitlist.offered": `{{define "subject"}}A device is available for you{{end}}
+{{define "body"}}Device {{.Data.device_id}} is available and offered to you until {{.Data.offer_expires_at}}.
+Claim it with POST /waitlist/{{.Data.entry_id}}/claim before the offer goes to the next waiter.{{end}}`,
+	"waitlist.assigned": `{{define "subject"}}A device was assigned to you{{end}}
+{{define "body"}}Device {{.Data.device_id}} was assigned to you from the waitlist, and is now in use.{{end}}`,
+	"waitlist.offer_expired": `{{define "subject"}}Your device offer expired{{end}}
+{{define "body"}}The offer of device {{.Data.device_id}} expired before it was claimed, and went to the next waiter.{{end}}`,
+	"reservation.overdue": `{{define "subject"}}Your reservation is overdue{{end}}
+{{define "body"}}Your reservation of device {{.Data.device_id}} ended at {{.Data.ends_at}}, but the device is still in use.
+Please return it.{{end}}`,
+	"reservation.failed": `{{define "subject"}}Your reservation could not start{{end}}
+{{define "body"}}Device {{.Data.device_id}} could not be handed over to you when your reservation started: {{.Data.failure}}.{{end}}`,
+	TopicDeviceDeleted: `{{define "subject"}}Device {{.Data.name}} was deleted{{end}}
+{{define "body"}}Device {{.Data.name}} ({{.Data.brand}}, {{.Data.device_id}}) was deleted, along with its reservations and waitlist.{{end}}`,
+	defaultTemplate: `{{define "subject"}}{{.Topic}}{{end}}
+{{define "body"}}{{range $k, $v := .Data}}{{$k}}: {{$v}}
+{{end}}{{end}}`,
+	digestTemplate: `{{define "subject"}}{{len .Notifications}} device manager notifications{{end}}
+{{define "body"}}{{range .Notifications}}- {{.Subject}}
+{{.Body}}
+
+{{end}}{{end}}`,
+}
+
+// Templates render the subject and body of notifications, by topic.
+type Templates struct {
+	byName map[string]*template.Template
+}
+
+// templateData is passed to the templates of topics.
+type templateData struct {
+	Topic     string
+	Recipient string
+	Data      map[string]any
+	At        time.Time
+}
+
+// digestData is passed to the template of digests.
+type digestData struct {
+	Recipient     string
+	Notifications Notifications
+}
+
+// DefaultTemplates returns the built-in templates.
+func DefaultTemplates() *Templates {
+	t := &Templates{byName: make(map[string]*template.Template, len(defaultTemplates))}
+	for name, text := range defaultTemplates {
+		t.byName[name] = template.Must(parseTemplate(name, text))
+	}
+
+	return t
+}
+
+// LoadTemplates returns the built-in templates, overridden or extended by
+// the files of dir named after a topic, such as waitlist.offered.tmpl, or
+// digest.tmpl and default.tmpl. Each file defines a "subject" and a "body"
+// template, rendered with the Topic, Recipient, Data and At of the event, or
+// the Recipient and Notifications of the digest.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := DefaultTemplates()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), templateExt)
+		if t.byName[name], err = parseTemplate(name, string(b)); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	return t, nil
+}
+
+// Render returns the subject and body of ev, with the default template when
+// its topic has none.
+func (t *Templates) Render(ev Event, at time.Time) (subject, body string, err error) {
+	tmpl, ok := t.byName[ev.Topic]
+	if !ok {
+		tmpl = t.byName[defaultTemplate]
+	}
+
+	return execute(tmpl, templateData{Topic: ev.Topic, Recipient: ev.Recipient, Data: ev.Data, At: at})
+}
+
+// RenderDigest returns the subject and body of the digest of ns, sent to
+// recipient.
+func (t *Templates) RenderDigest(recipient string, ns Notifications) (subject, body string, err error) {
+	return execute(t.byName[digestTemplate], digestData{Recipient: recipient, Notifications: ns})
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if tmpl.Lookup("subject") == nil || tmpl.Lookup("body") == nil {
+		return nil, ErrInvalidTemplate
+	}
+
+	return tmpl, nil
+}
+
+func execute(tmpl *template.Template, data any) (subject, body string, err error) {
+	var b bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&b, "subject", data); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(b.String())
+
+	b.Reset()
+	if err := tmpl.ExecuteTemplate(&b, "body", data); err != nil {
+		return "", "", err
+	}
+
+	return subject, strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,183 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HeaderKeySignature carries the hex HMAC-SHA256 of the body of webhooks,
+// keyed with the webhook secret, when one is set.
+const HeaderKeySignature = "X-Signature-SHA256"
+
+type WebhookOption func(*webhookSender)
+
+// WithWebhookSecret signs the webhooks with secret, see HeaderKeySignature.
+func WithWebhookSecret(secret string) WebhookOption {
+	return func(s *webhookSender) {
+		s.secret = []byte(secret)
+	}
+}
+
+// WithWebhookClient sets the client sending the webhooks. The default one
+// times out after 10s and only connects to public addresses, see
+// WithWebhookTimeout.
+func WithWebhookClient(c *http.Client) WebhookOption {
+	return func(s *webhookSender) {
+		s.client = c
+	}
+}
+
+// WithWebhookTimeout sets how long the default client waits for webhooks.
+func WithWebhookTimeout(d time.Duration) WebhookOption {
+	return func(s *webhookSender) {
+		s.client.Timeout = d
+	}
+}
+
+type webhookSender struct {
+	client *http.Client
+	secret []byte
+	now    func() time.Time
+}
+
+// webhookPayload is the JSON body of webhooks.
+type webhookPayload struct {
+	Topic     string `json:"topic"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	SentAt    string `json:"sent_at"`
+}
+
+// NewWebhookSender returns the Channel posting messages as JSON to the URL
+// of their recipient. Answers other than 2xx are reported as
+// ErrWebhookStatus, and URLs resolving to addresses that are not public as
+// ErrWebhookForbidden.
+func NewWebhookSender(opts ...WebhookOption) Channel {
+	// recipients choose the URLs, so the connections are checked once the
+	// hosts are resolved, redirects included, rather than their names
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip, err := netip.ParseAddr(host); err != nil || !isPublic(ip) {
+				return fmt.Errorf("%w: %s", ErrWebhookForbidden, host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	s := &webhookSender{
+		client: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *webhookSender) Send(ctx context.Context, msg Message) error {
+	b, err := json.Marshal(webhookPayload{
+		Topic:     msg.Topic,
+		Recipient: msg.Recipient,
+		Subject:   msg.Subject,
+		Body:      msg.Body,
+		SentAt:    s.now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.To, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(s.secret) > 0 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(b)
+		req.Header.Set(HeaderKeySignature, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s", ErrWebhookStatus, resp.Status)
+	}
+
+	return nil
+}
+
+// checkWebhookURL reports, as ErrWebhookForbidden, the webhook URLs which are
+// not http or https URLs, or whose host is a loopback, link-local, private or
+// unspecified address. Host names are checked once resolved, when the webhooks
+// are sent.
+func checkWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrWebhookForbidden
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrWebhookForbidden, host)
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil && !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookForbidden, host)
+	}
+
+	return nil
+}
+
+// nonPublicPrefixes are the IPv4 ranges that are not public, beyond the
+// private ones: "this network" and the shared address space of carriers.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// isPublic reports whether ip is neither a loopback, link-local, private,
+// multicast nor unspecified address.
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+
+	return !slices.ContainsFunc(nonPublicPrefixes, func(p netip.Prefix) bool { return p.Contains(ip) })
+}
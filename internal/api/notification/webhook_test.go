@@ -0,0 +1,115 @@
+package notification_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/notification"
+)
+
+func TestWebhookSender(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr       error
+		wantSignature bool
+		status        int
+		opts          []notification.WebhookOption
+	}{
+		"successfully posts message": {
+			status: http.StatusNoContent,
+		},
+		"successfully posts signed message": {
+			wantSignature: true,
+			status:        http.StatusOK,
+			opts:          []notification.WebhookOption{notification.WithWebhookSecret("topsecret")},
+		},
+		"webhook answering with an error": {
+			wantErr: notification.ErrWebhookStatus,
+			status:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				payload   map[string]string
+				signature string
+				body      []byte
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				_ = json.Unmarshal(body, &payload)
+				signature = r.Header.Get(notification.HeaderKeySignature)
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			// the test server listens on a loopback address, which the
+			// default client refuses
+			opts := append([]notification.WebhookOption{notification.WithWebhookClient(server.Client())}, tc.opts...)
+
+			s := notification.NewWebhookSender(opts...)
+			err := s.Send(context.Background(), notification.Message{
+				Topic:     "waitlist.offered",
+				Recipient: "alice",
+				To:        server.URL,
+				Subject:   "A device is available for you",
+				Body:      "Device 42 is available.",
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if payload["topic"] != "waitlist.offered" || payload["recipient"] != "alice" || payload["subject"] != "A device is available for you" {
+				t.Fatalf("expected the payload of the message, got: %+v", payload)
+			}
+
+			// assert the signature is the HMAC of the body, keyed with the secret
+
+			if !tc.wantSignature {
+				if signature != "" {
+					t.Fatalf("expected no signature, got: %s", signature)
+				}
+				return
+			}
+
+			mac := hmac.New(sha256.New, []byte("topsecret"))
+			mac.Write(body)
+			if want := hex.EncodeToString(mac.Sum(nil)); signature != want {
+				t.Fatalf("expected signature %s, got: %s", want, signature)
+			}
+		})
+	}
+}
+
+func TestWebhookSenderPrivateHost(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// assert the default client does not connect to loopback addresses
+
+	s := notification.NewWebhookSender()
+	err := s.Send(context.Background(), notification.Message{Topic: "waitlist.offered", Recipient: "alice", To: server.URL})
+	if !errors.Is(err, notification.ErrWebhookForbidden) {
+		t.Fatalf("expected error: %v, got: %v", notification.ErrWebhookForbidden, err)
+	}
+
+	if called {
+		t.Fatal("expected the webhook not to be called")
+	}
+}
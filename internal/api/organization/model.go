@@ -0,0 +1,50 @@
+package organization
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Organization struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	Name      string
+	CreatedAt time.Time
+}
+
+type Organizations []*Organization
+
+type DTO struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+func NewOrganization(name string) *Organization {
+	return &Organization{
+		ID:        uuid.New(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (o *Organization) ToDto() *DTO {
+	return &DTO{
+		ID:        o.ID,
+		Name:      o.Name,
+		CreatedAt: o.CreatedAt.Format(time.DateTime),
+	}
+}
+
+func (os Organizations) ToDto() []*DTO {
+	dtos := make([]*DTO, len(os))
+	for i, v := range os {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
@@ -0,0 +1,65 @@
+package organization
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository interface {
+	InsertOrganization(ctx context.Context, org *Organization) error
+	ListOrganizations(ctx context.Context) (Organizations, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Organization, error)
+	DeleteOrganization(ctx context.Context, ID uuid.UUID) error
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository expects db to translate driver errors, see database.Open.
+func NewRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{
+		db: db,
+	}
+}
+
+func (r *organizationRepository) InsertOrganization(ctx context.Context, org *Organization) error {
+	if err := r.db.WithContext(ctx).Create(org).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) ListOrganizations(ctx context.Context) (Organizations, error) {
+	os := make(Organizations, 0)
+	if err := r.db.WithContext(ctx).Order("name").Find(&os).Error; err != nil {
+		return nil, err
+	}
+
+	return os, nil
+}
+
+func (r *organizationRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	o := &Organization{}
+	if err := r.db.WithContext(ctx).Where("id = ?", ID).First(&o).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (r *organizationRepository) DeleteOrganization(ctx context.Context, ID uuid.UUID) error {
+	err := r.db.WithContext(ctx).Where("id = ?", ID).Delete(&Organization{}).Error
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return ErrOrganizationHasDevices
+	}
+
+	return err
+}
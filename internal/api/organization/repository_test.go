@@ -0,0 +1,125 @@
+package organization_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/test"
+
+	"github.com/google/uuid"
+)
+
+func TestInsertOrganization(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			repo := organization.NewRepository(db)
+			ctx := context.Background()
+
+			o := organization.NewOrganization("test")
+			if err := repo.InsertOrganization(ctx, o); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// assert organization names are unique
+
+			if err := repo.InsertOrganization(ctx, organization.NewOrganization("test")); err == nil {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestListOrganizations(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			repo := organization.NewRepository(db)
+			ctx := context.Background()
+
+			if err := repo.InsertOrganization(ctx, organization.NewOrganization("test")); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			os, err := repo.ListOrganizations(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// the default organization is seeded by the migrations
+			if len(os) != 2 {
+				t.Fatalf("wanted organization list len to be %d, got %d", 2, len(os))
+			}
+		})
+	}
+}
+
+func TestFindOrganizationByID(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			repo := organization.NewRepository(db)
+			ctx := context.Background()
+
+			o, err := repo.FindByID(ctx, organization.DefaultID)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if o.ID != organization.DefaultID {
+				t.Fatalf("expected organization ID: %s, got: %s", organization.DefaultID, o.ID)
+			}
+
+			// assert organization not found case
+
+			if _, err := repo.FindByID(ctx, uuid.New()); err != organization.ErrNotFound {
+				t.Fatalf("expected error: %v, got: %v", organization.ErrNotFound, err)
+			}
+		})
+	}
+}
+
+func TestDeleteOrganization(t *testing.T) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			repo := organization.NewRepository(db)
+			ctx := context.Background()
+
+			o := organization.NewOrganization("test")
+			if err := repo.InsertOrganization(ctx, o); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// assert organizations owning devices cannot be deleted
+
+			deviceRepo := device.NewRepository(db)
+			d := device.NewDevice("test", "test", "available")
+			if err := deviceRepo.InsertDevice(organization.WithTenant(ctx, o.ID), d); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if err := repo.DeleteOrganization(ctx, o.ID); err != organization.ErrOrganizationHasDevices {
+				t.Fatalf("expected error: %v, got: %v", organization.ErrOrganizationHasDevices, err)
+			}
+
+			if err := deviceRepo.DeleteDevice(organization.WithTenant(ctx, o.ID), d.ID); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if err := repo.DeleteOrganization(ctx, o.ID); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package organization
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound               = errors.New("organization not found")
+	ErrOrganizationHasDevices = errors.New("operation cannot be completed because the organization still owns devices")
+	ErrDefaultOrganization    = errors.New("the default organization cannot be deleted")
+)
+
+type OrganizationService interface {
+	CreateOrganization(ctx context.Context, input CreateOrganizationRequest) (*Organization, error)
+	ListOrganizations(ctx context.Context) (Organizations, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Organization, error)
+	DeleteOrganization(ctx context.Context, ID uuid.UUID) error
+}
+
+type organizationService struct {
+	repo OrganizationRepository
+}
+
+func NewService(r OrganizationRepository) OrganizationService {
+	return &organizationService{
+		repo: r,
+	}
+}
+
+func (s *organizationService) CreateOrganization(ctx context.Context, input CreateOrganizationRequest) (*Organization, error) {
+	o := NewOrganization(input.Name)
+
+	if err := s.repo.InsertOrganization(ctx, o); err != nil {
+		return o, err
+	}
+
+	return o, nil
+}
+
+func (s *organizationService) ListOrganizations(ctx context.Context) (Organizations, error) {
+	os, err := s.repo.ListOrganizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return os, nil
+}
+
+func (s *organizationService) FindByID(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	o, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (s *organizationService) DeleteOrganization(ctx context.Context, ID uuid.UUID) error {
+	if ID == DefaultID {
+		return ErrDefaultOrganization
+	}
+
+	if _, err := s.FindByID(ctx, ID); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteOrganization(ctx, ID)
+}
@@ -0,0 +1,121 @@
+package organization_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/test/mock"
+
+	"github.com/google/uuid"
+)
+
+func TestServiceCreateOrganization(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr bool
+		repo    mock.OrganizationRepository
+	}{
+		"successfully calls repo to insert organization": {
+			wantErr: false,
+			repo: mock.OrganizationRepository{
+				InsertOrganizationFunc: func(o *organization.Organization) error {
+					return nil
+				},
+			},
+		},
+		"repo returns error": {
+			wantErr: true,
+			repo: mock.OrganizationRepository{
+				InsertOrganizationFunc: func(o *organization.Organization) error {
+					return fmt.Errorf("boom")
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := organization.NewService(&tc.repo)
+
+			_, err := s.CreateOrganization(context.Background(), organization.CreateOrganizationRequest{Name: "test"})
+			if err != nil && !tc.wantErr {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err == nil && tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestServiceDeleteOrganization(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr bool
+		repo    mock.OrganizationRepository
+		inputID uuid.UUID
+	}{
+		"successfully deletes organization": {
+			wantErr: false,
+			repo: mock.OrganizationRepository{
+				FindByIDFunc: func(ID uuid.UUID) (*organization.Organization, error) {
+					return &organization.Organization{ID: ID}, nil
+				},
+				DeleteOrganizationFunc: func(ID uuid.UUID) error {
+					return nil
+				},
+			},
+			inputID: uuid.New(),
+		},
+		"default organization cannot be deleted": {
+			wantErr: true,
+			repo:    mock.OrganizationRepository{},
+			inputID: organization.DefaultID,
+		},
+		"repo returns error on find": {
+			wantErr: true,
+			repo: mock.OrganizationRepository{
+				FindByIDFunc: func(ID uuid.UUID) (*organization.Organization, error) {
+					return nil, fmt.Errorf("boom")
+				},
+			},
+			inputID: uuid.New(),
+		},
+		"repo returns error on delete": {
+			wantErr: true,
+			repo: mock.OrganizationRepository{
+				FindByIDFunc: func(ID uuid.UUID) (*organization.Organization, error) {
+					return &organization.Organization{ID: ID}, nil
+				},
+				DeleteOrganizationFunc: func(ID uuid.UUID) error {
+					return organization.ErrOrganizationHasDevices
+				},
+			},
+			inputID: uuid.New(),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := organization.NewService(&tc.repo)
+
+			err := s.DeleteOrganization(context.Background(), tc.inputID)
+			if err != nil && !tc.wantErr {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err == nil && tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
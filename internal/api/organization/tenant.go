@@ -0,0 +1,33 @@
+package organization
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// DefaultID is the organization seeded by the migrations, owning every device
+// created before multi-tenancy was introduced.
+var DefaultID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+var (
+	ErrMissingTenant = errors.New("no tenant in context")
+)
+
+type tenantCtxKey struct{}
+
+// WithTenant returns a copy of ctx scoped to the given tenant (organization) ID.
+func WithTenant(ctx context.Context, tenantID uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ID stored in ctx by WithTenant.
+func TenantFromContext(ctx context.Context) (uuid.UUID, error) {
+	tenantID, ok := ctx.Value(tenantCtxKey{}).(uuid.UUID)
+	if !ok || tenantID == uuid.Nil {
+		return uuid.Nil, ErrMissingTenant
+	}
+
+	return tenantID, nil
+}
@@ -0,0 +1,252 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// costLimit bounds the work evaluating a policy takes, so that expressions
+// iterating over large values cannot stall the changes of devices.
+const costLimit = 100000
+
+var operations = []string{device.OperationCreate, device.OperationUpdate, device.OperationDelete}
+
+// Engine evaluates policies before the changes of devices, implementing
+// device.PolicyEvaluator. The zero Engine has no policies and allows all
+// changes.
+type Engine struct {
+	policies []compiled
+}
+
+type compiled struct {
+	Policy
+	program cel.Program
+}
+
+// NewEngine compiles policies, whose Deny expressions can use:
+//
+//   - operation, the operation of the change: create, update or delete.
+//   - old, the device before the change, null on creation.
+//   - new, the device after the change, null on deletion.
+//   - change, the fields set by the change, among name, brand and state.
+//   - principal, the subject, role and tenant_id of the caller, empty for
+//     anonymous requests, and tenant_id empty for callers of no tenant. The
+//     changes the API makes on its own, such as starting reservations and
+//     assigning devices to waitlists, have the system role, see
+//     auth.AsSystem. Approved change requests are applied on behalf of their
+//     requester.
+//
+// Devices have an id, tenant_id, name, brand, state and created_at. The tenant
+// of the devices created is only set once they are stored, so new.tenant_id
+// is the nil UUID on creation.
+func NewEngine(ps Policies) (*Engine, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("operation", cel.StringType),
+		cel.Variable("old", cel.DynType),
+		cel.Variable("new", cel.DynType),
+		cel.Variable("change", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("principal", cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engine{}
+	for _, p := range ps {
+		if p.Name == "" {
+			return nil, fmt.Errorf("%w: a name is required", ErrInvalidPolicy)
+		}
+
+		if slices.ContainsFunc(e.policies, func(c compiled) bool { return c.Name == p.Name }) {
+			return nil, fmt.Errorf("%w %s: the name is already taken", ErrInvalidPolicy, p.Name)
+		}
+
+		for _, op := range p.Operations {
+			if !slices.Contains(operations, op) {
+				return nil, fmt.Errorf("%w %s: unknown operation %q", ErrInvalidPolicy, p.Name, op)
+			}
+		}
+
+		ast, iss := env.Compile(p.Deny)
+		if iss.Err() != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidPolicy, p.Name, iss.Err())
+		}
+
+		if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+			return nil, fmt.Errorf("%w %s: the expression is a %s, not a bool", ErrInvalidPolicy, p.Name, t)
+		}
+
+		prg, err := env.Program(ast,
+			cel.CostLimit(costLimit),
+			cel.InterruptCheckFrequency(100),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidPolicy, p.Name, err)
+		}
+
+		e.policies = append(e.policies, compiled{Policy: p, program: prg})
+	}
+
+	return e, nil
+}
+
+// Load compiles the policies of the YAML file at path, laid out as a File.
+func Load(path string) (*Engine, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	e, err := NewEngine(f.Policies)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return e, nil
+}
+
+// Policies returns the policies of e, in the order they are evaluated.
+func (e *Engine) Policies() Policies {
+	ps := make(Policies, len(e.policies))
+	for i, c := range e.policies {
+		ps[i] = c.Policy
+	}
+
+	return ps
+}
+
+// Evaluate returns a DeniedError for the first policy denying m, or failing
+// to be evaluated, with the principal of ctx.
+func (e *Engine) Evaluate(ctx context.Context, m device.Mutation) error {
+	vars := variables(ctx, m)
+	for _, c := range e.policies {
+		if !c.appliesTo(m.Operation) {
+			continue
+		}
+
+		if r := c.evaluate(ctx, vars); r.Denied {
+			return r.err()
+		}
+	}
+
+	return nil
+}
+
+// Explain returns the results of all the policies applying to m, with the
+// principal of ctx.
+func (e *Engine) Explain(ctx context.Context, m device.Mutation) []Result {
+	vars := variables(ctx, m)
+	rs := []Result{}
+	for _, c := range e.policies {
+		if c.appliesTo(m.Operation) {
+			rs = append(rs, c.evaluate(ctx, vars))
+		}
+	}
+
+	return rs
+}
+
+func (c compiled) appliesTo(operation string) bool {
+	return len(c.Operations) == 0 || slices.Contains(c.Operations, operation)
+}
+
+// evaluate runs the Deny expression of c, failing closed: a policy that
+// cannot be evaluated denies the change.
+func (c compiled) evaluate(ctx context.Context, vars map[string]any) Result {
+	r := Result{Policy: c.Name}
+
+	v, _, err := c.program.ContextEval(ctx, vars)
+	if err != nil {
+		r.Denied, r.Error = true, err.Error()
+		return r
+	}
+
+	denied, ok := v.Value().(bool)
+	if !ok {
+		r.Denied, r.Error = true, fmt.Sprintf("the expression returned a %s, not a bool", v.Type().TypeName())
+		return r
+	}
+
+	if denied {
+		r.Denied, r.Message = true, c.message()
+	}
+
+	return r
+}
+
+func (r Result) err() error {
+	err := &DeniedError{Policy: r.Policy, Message: r.Message}
+	if r.Error != "" {
+		err.Err = errors.New(r.Error)
+	}
+
+	return err
+}
+
+// variables returns the values of the variables of the expressions for m.
+func variables(ctx context.Context, m device.Mutation) map[string]any {
+	change := map[string]any{}
+	switch {
+	case m.Change != nil:
+		if m.Change.Name != nil {
+			change["name"] = *m.Change.Name
+		}
+		if m.Change.Brand != nil {
+			change["brand"] = *m.Change.Brand
+		}
+		if m.Change.State != nil {
+			change["state"] = *m.Change.State
+		}
+	case m.Operation == device.OperationCreate && m.After != nil:
+		change["name"] = m.After.Name
+		change["brand"] = m.After.Brand
+		change["state"] = m.After.State
+	}
+
+	principal := map[string]string{"subject": "", "role": "", "tenant_id": ""}
+	if p, ok := auth.FromContext(ctx); ok && p != nil {
+		principal["subject"] = p.Subject
+		principal["role"] = p.Role
+		if p.TenantID != uuid.Nil {
+			principal["tenant_id"] = p.TenantID.String()
+		}
+	}
+
+	return map[string]any{
+		"operation": m.Operation,
+		"old":       deviceValue(m.Before),
+		"new":       deviceValue(m.After),
+		"change":    change,
+		"principal": principal,
+	}
+}
+
+func deviceValue(d *device.Device) any {
+	if d == nil {
+		return nil
+	}
+
+	return map[string]any{
+		"id":         d.ID.String(),
+		"tenant_id":  d.TenantID.String(),
+		"name":       d.Name,
+		"brand":      d.Brand,
+		"state":      d.State,
+		"created_at": d.CreatedAt,
+	}
+}
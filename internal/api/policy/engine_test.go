@@ -0,0 +1,273 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/policy"
+
+	"github.com/google/uuid"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func engine(t *testing.T, ps ...policy.Policy) *policy.Engine {
+	t.Helper()
+
+	e, err := policy.NewEngine(ps)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	return e
+}
+
+func TestNewEngine(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr  error
+		policies policy.Policies
+	}{
+		"no policy": {},
+		"valid policies": {
+			policies: policy.Policies{
+				{Name: "locked", Operations: []string{"update"}, Deny: "old.state == 'in_use' && principal.role != 'admin'"},
+				{Name: "no-renames", Deny: "'name' in change && operation == 'update'"},
+			},
+		},
+		"missing name": {
+			wantErr:  policy.ErrInvalidPolicy,
+			policies: policy.Policies{{Deny: "false"}},
+		},
+		"duplicate name": {
+			wantErr:  policy.ErrInvalidPolicy,
+			policies: policy.Policies{{Name: "a", Deny: "false"}, {Name: "a", Deny: "true"}},
+		},
+		"unknown operation": {
+			wantErr:  policy.ErrInvalidPolicy,
+			policies: policy.Policies{{Name: "a", Operations: []string{"rename"}, Deny: "false"}},
+		},
+		"syntax error": {
+			wantErr:  policy.ErrInvalidPolicy,
+			policies: policy.Policies{{Name: "a", Deny: "old.state =="}},
+		},
+		"unknown variable": {
+			wantErr:  policy.ErrInvalidPolicy,
+			policies: policy.Policies{{Name: "a", Deny: "device.state == 'in_use'"}},
+		},
+		"not a bool": {
+			wantErr:  policy.ErrInvalidPolicy,
+			policies: policy.Policies{{Name: "a", Deny: "principal.role"}},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := policy.NewEngine(tc.policies)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	inUse := &device.Device{Name: "Pixel", Brand: "Google", State: device.StateInUse}
+	available := &device.Device{Name: "Pixel", Brand: "Google", State: device.StateAvailable}
+	tenanted := &device.Device{TenantID: uuid.New(), Name: "Pixel", Brand: "Google", State: device.StateAvailable}
+
+	e := engine(t,
+		policy.Policy{
+			Name:       "in-use-locked",
+			Operations: []string{device.OperationUpdate, device.OperationDelete},
+			Deny:       "old.state == 'in_use' && !(principal.role in ['admin', 'system'])",
+			Message:    "only admins change devices in use",
+		},
+		policy.Policy{
+			Name:       "no-rebranding",
+			Operations: []string{device.OperationUpdate},
+			Deny:       "has(change.brand) && change.brand != old.brand",
+		},
+		policy.Policy{
+			Name:       "create-available",
+			Operations: []string{device.OperationCreate},
+			Deny:       "old != null || new.state != 'available'",
+		},
+		policy.Policy{
+			Name:       "own-tenant",
+			Operations: []string{device.OperationDelete},
+			Deny:       "principal.tenant_id != '' && principal.tenant_id != old.tenant_id",
+		},
+	)
+
+	var testCases = map[string]struct {
+		wantPolicy string
+		wantErr    bool
+		principal  *auth.Principal
+		mutation   device.Mutation
+	}{
+		"update allowed": {
+			principal: &auth.Principal{Subject: "alice", Role: auth.RoleUser},
+			mutation:  device.Mutation{Operation: device.OperationUpdate, Before: available, After: available, Change: &device.UpdateDeviceRequest{Name: stringPtr("Pixel 9")}},
+		},
+		"update of a device in use denied": {
+			wantPolicy: "in-use-locked",
+			principal:  &auth.Principal{Subject: "alice", Role: auth.RoleUser},
+			mutation:   device.Mutation{Operation: device.OperationUpdate, Before: inUse, After: inUse, Change: &device.UpdateDeviceRequest{}},
+		},
+		"anonymous deletion of a device in use denied": {
+			wantPolicy: "in-use-locked",
+			mutation:   device.Mutation{Operation: device.OperationDelete, Before: inUse},
+		},
+		"deletion of a device in use by an admin allowed": {
+			principal: &auth.Principal{Subject: "root", Role: auth.RoleAdmin},
+			mutation:  device.Mutation{Operation: device.OperationDelete, Before: inUse},
+		},
+		"deletion by a caller of no tenant allowed": {
+			principal: &auth.Principal{Subject: "alice", Role: auth.RoleUser},
+			mutation:  device.Mutation{Operation: device.OperationDelete, Before: tenanted},
+		},
+		"deletion of a device of another tenant denied": {
+			wantPolicy: "own-tenant",
+			principal:  &auth.Principal{Subject: "alice", Role: auth.RoleUser, TenantID: uuid.New()},
+			mutation:   device.Mutation{Operation: device.OperationDelete, Before: tenanted},
+		},
+		"update of a device in use by the system allowed": {
+			principal: &auth.Principal{Subject: "approval", Role: auth.RoleSystem},
+			mutation:  device.Mutation{Operation: device.OperationUpdate, Before: inUse, After: inUse, Change: &device.UpdateDeviceRequest{}},
+		},
+		"change of brand denied": {
+			wantPolicy: "no-rebranding",
+			principal:  &auth.Principal{Subject: "root", Role: auth.RoleAdmin},
+			mutation:   device.Mutation{Operation: device.OperationUpdate, Before: available, After: available, Change: &device.UpdateDeviceRequest{Brand: stringPtr("Apple")}},
+		},
+		"creation allowed": {
+			mutation: device.Mutation{Operation: device.OperationCreate, After: available},
+		},
+		"creation denied": {
+			wantPolicy: "create-available",
+			mutation:   device.Mutation{Operation: device.OperationCreate, After: inUse},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tc.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tc.principal)
+			}
+
+			err := e.Evaluate(ctx, tc.mutation)
+
+			if tc.wantPolicy == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+
+			var denied *policy.DeniedError
+			if !errors.As(err, &denied) || !errors.Is(err, policy.ErrDenied) {
+				t.Fatalf("expected a denial, got: %v", err)
+			}
+
+			if denied.Policy != tc.wantPolicy {
+				t.Fatalf("expected denial by %s, got: %s", tc.wantPolicy, denied.Policy)
+			}
+		})
+	}
+}
+
+func TestEngineEvaluateFailsClosed(t *testing.T) {
+	// old is null on creation, so the policy cannot be evaluated
+	e := engine(t, policy.Policy{Name: "locked", Deny: "old.state == 'in_use'"})
+
+	err := e.Evaluate(context.Background(), device.Mutation{
+		Operation: device.OperationCreate,
+		After:     &device.Device{State: device.StateAvailable},
+	})
+
+	var denied *policy.DeniedError
+	if !errors.As(err, &denied) || denied.Err == nil {
+		t.Fatalf("expected a denial with an evaluation error, got: %v", err)
+	}
+}
+
+func TestEngineExplain(t *testing.T) {
+	e := engine(t,
+		policy.Policy{Name: "never", Deny: "false"},
+		policy.Policy{Name: "always", Deny: "true", Message: "no changes today"},
+		policy.Policy{Name: "deletions", Operations: []string{device.OperationDelete}, Deny: "true"},
+	)
+
+	rs := e.Explain(context.Background(), device.Mutation{
+		Operation: device.OperationCreate,
+		After:     &device.Device{State: device.StateAvailable},
+	})
+
+	// assert every policy applying is reported, the denials with a message
+	want := []policy.Result{
+		{Policy: "never"},
+		{Policy: "always", Denied: true, Message: "no changes today"},
+	}
+	if len(rs) != len(want) {
+		t.Fatalf("expected results %+v, got: %+v", want, rs)
+	}
+	for i := range want {
+		if rs[i] != want[i] {
+			t.Fatalf("expected results %+v, got: %+v", want, rs)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "policies.yaml")
+	if err := os.WriteFile(valid, []byte(`
+policies:
+  - name: in-use-locked
+    operations: [update, delete]
+    deny: old.state == 'in_use' && principal.role != 'admin'
+    message: only admins change devices in use
+`), 0o600); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("policies:\n  - name: a\n    deny: old.\n"), 0o600); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	e, err := policy.Load(valid)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if ps := e.Policies(); len(ps) != 1 || ps[0].Name != "in-use-locked" || len(ps[0].Operations) != 2 {
+		t.Fatalf("expected the in-use-locked policy, got: %+v", ps)
+	}
+
+	if _, err := policy.Load(invalid); !errors.Is(err, policy.ErrInvalidPolicy) {
+		t.Fatalf("expected error %v, got: %v", policy.ErrInvalidPolicy, err)
+	}
+
+	if _, err := policy.Load(filepath.Join(dir, "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected error %v, got: %v", os.ErrNotExist, err)
+	}
+}
@@ -0,0 +1,38 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDenied is returned for the changes of devices denied by a policy.
+	ErrDenied = errors.New("device change denied by policy")
+	// ErrInvalidPolicy is returned for policies that cannot be compiled.
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// DeniedError is the error of a change denied by a policy, either because its
+// expression holds or because it could not be evaluated.
+type DeniedError struct {
+	Policy  string
+	Message string
+	// Err is the error evaluating the policy, if any.
+	Err error
+}
+
+func (e *DeniedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("denied by policy %s, which could not be evaluated: %v", e.Policy, e.Err)
+	}
+
+	return fmt.Sprintf("denied by policy %s: %s", e.Policy, e.Message)
+}
+
+func (e *DeniedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DeniedError) Is(target error) bool {
+	return target == ErrDenied
+}
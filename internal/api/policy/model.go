@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"github.com/hferr/device-manager/internal/api/device"
+
+	"github.com/google/uuid"
+)
+
+// Policy denies the changes of devices its Deny expression holds for.
+type Policy struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Operations are the operations the policy applies to, create, update
+	// or delete, all of them when empty.
+	Operations []string `yaml:"operations"`
+	// Deny is a CEL expression over the change, see NewEngine for the
+	// variables it can use.
+	Deny string `yaml:"deny"`
+	// Message explains the denials to the callers, the name of the policy
+	// by default.
+	Message string `yaml:"message"`
+}
+
+type Policies []Policy
+
+// File is the layout of policy files.
+type File struct {
+	Policies Policies `yaml:"policies"`
+}
+
+type DTO struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Operations  []string `json:"operations"`
+	Deny        string   `json:"deny"`
+	Message     string   `json:"message"`
+}
+
+type EvaluateRequest struct {
+	Operation string `json:"operation" validate:"required,oneof=create update delete"`
+	// DeviceID is the device updated or deleted.
+	DeviceID *uuid.UUID `json:"device_id" validate:"required_unless=Operation create"`
+	// Change is the update requested, or the fields of the device created.
+	Change device.UpdateDeviceRequest `json:"change"`
+}
+
+// Result is the outcome of a policy applying to a change.
+type Result struct {
+	Policy  string `json:"policy"`
+	Denied  bool   `json:"denied"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Evaluation is the outcome of the policies applying to a change.
+type Evaluation struct {
+	Allowed bool     `json:"allowed"`
+	Results []Result `json:"results"`
+}
+
+func (p Policy) ToDto() *DTO {
+	operations := p.Operations
+	if operations == nil {
+		operations = []string{}
+	}
+
+	return &DTO{
+		Name:        p.Name,
+		Description: p.Description,
+		Operations:  operations,
+		Deny:        p.Deny,
+		Message:     p.message(),
+	}
+}
+
+func (ps Policies) ToDto() []*DTO {
+	dtos := make([]*DTO, len(ps))
+	for i, v := range ps {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
+
+func (p Policy) message() string {
+	if p.Message != "" {
+		return p.Message
+	}
+
+	return p.Name
+}
@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/device"
+)
+
+type PolicyService interface {
+	ListPolicies(ctx context.Context) (Policies, error)
+	// Evaluate dry-runs the policies applying to the change of input on
+	// behalf of the principal of ctx, without making it. Policies are
+	// evaluated on their own, the rules of the device states are not.
+	Evaluate(ctx context.Context, input EvaluateRequest) (*Evaluation, error)
+}
+
+type policyService struct {
+	engine  *Engine
+	devices device.DeviceService
+}
+
+// NewService returns a PolicyService evaluating the policies of e, against
+// the devices found through devices.
+func NewService(e *Engine, devices device.DeviceService) PolicyService {
+	return &policyService{
+		engine:  e,
+		devices: devices,
+	}
+}
+
+func (s *policyService) ListPolicies(ctx context.Context) (Policies, error) {
+	return s.engine.Policies(), nil
+}
+
+func (s *policyService) Evaluate(ctx context.Context, input EvaluateRequest) (*Evaluation, error) {
+	m := device.Mutation{Operation: input.Operation}
+
+	if input.Operation == device.OperationCreate {
+		m.After = device.NewDevice(deref(input.Change.Name), deref(input.Change.Brand), deref(input.Change.State))
+	} else {
+		d, err := s.devices.FindByID(ctx, *input.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+		m.Before = d
+
+		if input.Operation == device.OperationUpdate {
+			after := *d
+			input.Change.Apply(&after)
+			m.After, m.Change = &after, &input.Change
+		}
+	}
+
+	ev := &Evaluation{Allowed: true, Results: s.engine.Explain(ctx, m)}
+	for _, r := range ev.Results {
+		if r.Denied {
+			ev.Allowed = false
+		}
+	}
+
+	return ev, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
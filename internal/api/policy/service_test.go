@@ -0,0 +1,149 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/api/policy"
+	"github.com/hferr/device-manager/test/mock"
+
+	"github.com/google/uuid"
+)
+
+func lockedEngine(t *testing.T) *policy.Engine {
+	t.Helper()
+
+	return engine(t, policy.Policy{
+		Name:       "in-use-locked",
+		Operations: []string{device.OperationUpdate, device.OperationDelete},
+		Deny:       "old.state == 'in_use' && principal.role != 'admin'",
+	})
+}
+
+func TestServiceEvaluate(t *testing.T) {
+	inUse := &device.Device{ID: uuid.New(), Name: "Pixel", Brand: "Google", State: device.StateInUse}
+
+	var testCases = map[string]struct {
+		wantAllowed bool
+		wantResults int
+		wantErr     error
+		role        string
+		input       policy.EvaluateRequest
+		findErr     error
+	}{
+		"update denied": {
+			wantResults: 1,
+			role:        auth.RoleUser,
+			input:       policy.EvaluateRequest{Operation: device.OperationUpdate, DeviceID: &inUse.ID, Change: device.UpdateDeviceRequest{Name: stringPtr("Pixel 9")}},
+		},
+		"update by an admin allowed": {
+			wantAllowed: true,
+			wantResults: 1,
+			role:        auth.RoleAdmin,
+			input:       policy.EvaluateRequest{Operation: device.OperationUpdate, DeviceID: &inUse.ID},
+		},
+		"creation without policies allowed": {
+			wantAllowed: true,
+			role:        auth.RoleUser,
+			input:       policy.EvaluateRequest{Operation: device.OperationCreate, Change: device.UpdateDeviceRequest{Name: stringPtr("Pixel")}},
+		},
+		"device not found": {
+			wantErr: device.ErrNotFound,
+			input:   policy.EvaluateRequest{Operation: device.OperationDelete, DeviceID: &inUse.ID},
+			findErr: device.ErrNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					if tc.findErr != nil {
+						return nil, tc.findErr
+					}
+					d := *inUse
+					return &d, nil
+				},
+			}
+
+			s := policy.NewService(lockedEngine(t), &ds)
+			ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice", Role: tc.role})
+
+			ev, err := s.Evaluate(ctx, tc.input)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tc.wantErr, err)
+			}
+
+			if tc.wantErr != nil {
+				return
+			}
+
+			if ev.Allowed != tc.wantAllowed || len(ev.Results) != tc.wantResults {
+				t.Fatalf("expected allowed %t with %d results, got: %+v", tc.wantAllowed, tc.wantResults, ev)
+			}
+		})
+	}
+}
+
+func TestDeviceServicePolicies(t *testing.T) {
+	ctx := organization.WithTenant(context.Background(), organization.DefaultID)
+	user := auth.WithPrincipal(ctx, &auth.Principal{Subject: "alice", Role: auth.RoleUser})
+	admin := auth.WithPrincipal(ctx, &auth.Principal{Subject: "root", Role: auth.RoleAdmin})
+
+	var vetoed bool
+	s := device.NewService(device.NewMemoryRepository(),
+		device.WithPolicies(lockedEngine(t)),
+		device.WithHooks(device.Hook{
+			Name: "veto",
+			Before: func(context.Context, device.Mutation) error {
+				vetoed = true
+				return errors.New("vetoed")
+			},
+			Operations: []string{device.OperationUpdate},
+		}),
+	)
+
+	d, err := s.CreateDevice(user, device.CreateDeviceRequest{Name: "Pixel", Brand: "Google", State: device.StateInUse})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert the policy denies the change before the hooks run
+
+	err = s.UpdateDevice(user, d.ID, device.UpdateDeviceRequest{State: stringPtr(device.StateAvailable)})
+	if !errors.Is(err, policy.ErrDenied) {
+		t.Fatalf("expected error %v, got: %v", policy.ErrDenied, err)
+	}
+
+	if vetoed {
+		t.Fatalf("expected the hooks not to run")
+	}
+
+	// assert admins are allowed, up to the hooks
+
+	if err := s.UpdateDevice(admin, d.ID, device.UpdateDeviceRequest{State: stringPtr(device.StateAvailable)}); !errors.Is(err, device.ErrVetoed) {
+		t.Fatalf("expected error %v, got: %v", device.ErrVetoed, err)
+	}
+
+	if !vetoed {
+		t.Fatalf("expected the hooks to run")
+	}
+
+	got, err := s.FindByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if got.State != device.StateInUse {
+		t.Fatalf("expected state %s, got: %s", device.StateInUse, got.State)
+	}
+}
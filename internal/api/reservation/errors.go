@@ -0,0 +1,15 @@
+package reservation
+
+import "errors"
+
+var (
+	ErrNotFound = errors.New("reservation not found")
+	// ErrOverlap is returned when a device is reserved for a period
+	// overlapping one of its other reservations.
+	ErrOverlap = errors.New("reservation overlaps another reservation of the device")
+	// ErrInvalidPeriod is returned for periods not ending after they start,
+	// or already over.
+	ErrInvalidPeriod = errors.New("invalid reservation period")
+	// ErrEnded is returned when cancelling a reservation that is over.
+	ErrEnded = errors.New("reservation has ended")
+)
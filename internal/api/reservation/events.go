@@ -0,0 +1,37 @@
+package reservation
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// EventOverdue is published when a reservation ended while its device is
+	// still in use.
+	EventOverdue = "reservation.overdue"
+	// EventFailed is published when a reservation started while its device
+	// could not be handed over, see Reservation.Failure.
+	EventFailed = "reservation.failed"
+)
+
+// Event tells the holder of a reservation what happened to it.
+type Event struct {
+	Type        string
+	Reservation *Reservation
+	At          time.Time
+}
+
+// EventSink receives the events of the reservations, to notify the holders.
+type EventSink interface {
+	Publish(ctx context.Context, ev Event)
+}
+
+// EventSinkFunc adapts a function to an EventSink.
+type EventSinkFunc func(ctx context.Context, ev Event)
+
+func (f EventSinkFunc) Publish(ctx context.Context, ev Event) {
+	f(ctx, ev)
+}
+
+// discard is the EventSink dropping every event.
+var discard = EventSinkFunc(func(context.Context, Event) {})
@@ -0,0 +1,157 @@
+package reservation
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// memoryRepository is a ReservationRepository keeping reservations in
+// memory. Like the exclusion constraint of the database, it rejects
+// overlapping reservations of a device. Reservations are copied in and out,
+// so callers never share them with the store.
+type memoryRepository struct {
+	mu           sync.RWMutex
+	reservations map[uuid.UUID]*Reservation
+}
+
+// NewMemoryRepository returns a thread-safe in-memory ReservationRepository.
+func NewMemoryRepository() ReservationRepository {
+	return &memoryRepository{
+		reservations: make(map[uuid.UUID]*Reservation),
+	}
+}
+
+func (r *memoryRepository) InsertReservation(ctx context.Context, res *Reservation) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !res.EndsAt.After(res.StartsAt) {
+		return ErrInvalidPeriod
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if res.CancelledAt == nil {
+		for _, other := range r.reservations {
+			if other.DeviceID == res.DeviceID && other.Overlaps(res.StartsAt, res.EndsAt) {
+				return ErrOverlap
+			}
+		}
+	}
+
+	res.TenantID = tenantID
+	stored := *res
+	r.reservations[stored.ID] = &stored
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateReservation(ctx context.Context, res *Reservation) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// like the UPDATE it mirrors, updating a missing reservation is a no-op
+	stored, ok := r.reservations[res.ID]
+	if !ok || stored.TenantID != tenantID {
+		return nil
+	}
+
+	stored.StartedAt = res.StartedAt
+	stored.FailedAt = res.FailedAt
+	stored.Failure = res.Failure
+	stored.CancelledAt = res.CancelledAt
+	stored.OverdueCheckedAt = res.OverdueCheckedAt
+
+	return nil
+}
+
+func (r *memoryRepository) ListReservations(ctx context.Context, f Filter) (Reservations, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.list(func(res *Reservation) bool {
+		return res.TenantID == tenantID &&
+			res.CancelledAt == nil &&
+			res.FailedAt == nil &&
+			(f.DeviceID == uuid.Nil || res.DeviceID == f.DeviceID) &&
+			(f.Holder == "" || res.Holder == f.Holder) &&
+			(f.From.IsZero() || res.EndsAt.After(f.From)) &&
+			(f.To.IsZero() || res.StartsAt.Before(f.To))
+	}), nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Reservation, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res, ok := r.reservations[ID]
+	if !ok || res.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+
+	found := *res
+	return &found, nil
+}
+
+func (r *memoryRepository) ListDue(_ context.Context, now time.Time) (Reservations, error) {
+	return r.list(func(res *Reservation) bool {
+		return !res.StartsAt.After(now) &&
+			res.EndsAt.After(now) &&
+			res.StartedAt == nil &&
+			res.FailedAt == nil &&
+			res.CancelledAt == nil
+	}), nil
+}
+
+func (r *memoryRepository) ListEnded(_ context.Context, now time.Time) (Reservations, error) {
+	return r.list(func(res *Reservation) bool {
+		return !res.EndsAt.After(now) &&
+			res.StartedAt != nil &&
+			res.CancelledAt == nil &&
+			res.OverdueCheckedAt == nil
+	}), nil
+}
+
+// list returns copies of the reservations matching keep, ordered by start.
+func (r *memoryRepository) list(keep func(res *Reservation) bool) Reservations {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rs := make(Reservations, 0)
+	for _, res := range r.reservations {
+		if keep(res) {
+			found := *res
+			rs = append(rs, &found)
+		}
+	}
+
+	slices.SortFunc(rs, func(a, b *Reservation) int {
+		if c := a.StartsAt.Compare(b.StartsAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.ID[:], b.ID[:])
+	})
+
+	return rs
+}
@@ -0,0 +1,136 @@
+package reservation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a reservation, derived from its times.
+const (
+	StatusUpcoming  = "upcoming"
+	StatusActive    = "active"
+	StatusEnded     = "ended"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+// Reservation holds a device for a holder from StartsAt until EndsAt. Times
+// are kept in UTC.
+type Reservation struct {
+	ID       uuid.UUID `gorm:"primarykey"`
+	TenantID uuid.UUID
+	DeviceID uuid.UUID
+	Holder   string
+	StartsAt time.Time
+	EndsAt   time.Time
+	// StartedAt is when the device was moved to in_use, once the reservation
+	// started.
+	StartedAt   *time.Time
+	CancelledAt *time.Time
+	// FailedAt is when the device could not be handed over, once the
+	// reservation started, for the reason given by Failure.
+	FailedAt *time.Time
+	Failure  string
+	// OverdueCheckedAt is when the device was checked for being returned,
+	// once the reservation ended.
+	OverdueCheckedAt *time.Time
+	CreatedAt        time.Time
+}
+
+type Reservations []*Reservation
+
+type DTO struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenant_id"`
+	DeviceID    uuid.UUID `json:"device_id"`
+	Holder      string    `json:"holder"`
+	StartsAt    string    `json:"starts_at" example:"2026-10-20T09:00:00Z"`
+	EndsAt      string    `json:"ends_at" example:"2026-10-20T17:00:00Z"`
+	Status      string    `json:"status" enums:"upcoming,active,ended,cancelled,failed"`
+	Failure     string    `json:"failure,omitempty" example:"the device is in use by someone else"`
+	CancelledAt *string   `json:"cancelled_at,omitempty"`
+	CreatedAt   string    `json:"created_at"`
+}
+
+type CreateReservationRequest struct {
+	DeviceID uuid.UUID `json:"device_id" validate:"required"`
+	// Holder defaults to the subject of the authenticated principal, and
+	// only admins set another one.
+	Holder   string    `json:"holder" validate:"required,max=255"`
+	StartsAt time.Time `json:"starts_at" validate:"required" example:"2026-10-20T09:00:00Z"`
+	EndsAt   time.Time `json:"ends_at" validate:"required" example:"2026-10-20T17:00:00Z"`
+}
+
+// Filter selects the reservations to list. Zero fields match every
+// reservation, and cancelled and failed reservations are never listed.
+type Filter struct {
+	DeviceID uuid.UUID
+	Holder   string
+	// From and To select the reservations overlapping the period.
+	From time.Time
+	To   time.Time
+}
+
+func NewReservation(input CreateReservationRequest) *Reservation {
+	return &Reservation{
+		ID:        uuid.New(),
+		DeviceID:  input.DeviceID,
+		Holder:    input.Holder,
+		StartsAt:  input.StartsAt.UTC(),
+		EndsAt:    input.EndsAt.UTC(),
+		CreatedAt: time.Now(),
+	}
+}
+
+// Overlaps reports whether the reservation holds its device at any time
+// between from and to. Cancelled and failed reservations overlap nothing.
+func (r *Reservation) Overlaps(from, to time.Time) bool {
+	return r.CancelledAt == nil && r.FailedAt == nil && r.StartsAt.Before(to) && from.Before(r.EndsAt)
+}
+
+// Status returns the status of the reservation at the given time.
+func (r *Reservation) Status(now time.Time) string {
+	switch {
+	case r.CancelledAt != nil:
+		return StatusCancelled
+	case r.FailedAt != nil:
+		return StatusFailed
+	case now.Before(r.StartsAt):
+		return StatusUpcoming
+	case now.Before(r.EndsAt):
+		return StatusActive
+	default:
+		return StatusEnded
+	}
+}
+
+func (r *Reservation) ToDto() *DTO {
+	dto := &DTO{
+		ID:        r.ID,
+		TenantID:  r.TenantID,
+		DeviceID:  r.DeviceID,
+		Holder:    r.Holder,
+		StartsAt:  r.StartsAt.UTC().Format(time.RFC3339),
+		EndsAt:    r.EndsAt.UTC().Format(time.RFC3339),
+		Status:    r.Status(time.Now()),
+		Failure:   r.Failure,
+		CreatedAt: r.CreatedAt.Format(time.DateTime),
+	}
+
+	if r.CancelledAt != nil {
+		cancelledAt := r.CancelledAt.UTC().Format(time.RFC3339)
+		dto.CancelledAt = &cancelledAt
+	}
+
+	return dto
+}
+
+func (rs Reservations) ToDto() []*DTO {
+	dtos := make([]*DTO, len(rs))
+	for i, v := range rs {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
@@ -0,0 +1,175 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// pgExclusionViolation is reported for rows breaking an exclusion constraint,
+// which the PostgreSQL dialector does not translate.
+const pgExclusionViolation = "23P01"
+
+type ReservationRepository interface {
+	InsertReservation(ctx context.Context, r *Reservation) error
+	UpdateReservation(ctx context.Context, r *Reservation) error
+	ListReservations(ctx context.Context, f Filter) (Reservations, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Reservation, error)
+	// ListDue returns the reservations of every tenant that started by now
+	// and are neither over, cancelled, failed nor started yet. It is not
+	// scoped to the tenant of ctx.
+	ListDue(ctx context.Context, now time.Time) (Reservations, error)
+	// ListEnded returns the started reservations of every tenant that ended
+	// by now, and were not checked for being overdue yet. It is not scoped to
+	// the tenant of ctx.
+	ListEnded(ctx context.Context, now time.Time) (Reservations, error)
+}
+
+type reservationRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository expects db to translate driver errors, see database.Open.
+// Errors are reported as the errors of this package, such as ErrOverlap.
+func NewRepository(db *gorm.DB) ReservationRepository {
+	return &reservationRepository{
+		db: db,
+	}
+}
+
+func (r *reservationRepository) InsertReservation(ctx context.Context, res *Reservation) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	res.TenantID = tenantID
+	return translateError(r.db.WithContext(ctx).Create(res).Error)
+}
+
+func (r *reservationRepository) UpdateReservation(ctx context.Context, res *Reservation) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.WithContext(ctx).
+		Model(&Reservation{}).
+		Select("started_at", "failed_at", "failure", "cancelled_at", "overdue_checked_at").
+		Where("id = ? AND tenant_id = ?", res.ID, tenantID).
+		Updates(res).Error
+
+	return translateError(err)
+}
+
+func (r *reservationRepository) ListReservations(ctx context.Context, f Filter) (Reservations, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.db.WithContext(ctx).Where("tenant_id = ? AND cancelled_at IS NULL AND failed_at IS NULL", tenantID)
+	if f.DeviceID != uuid.Nil {
+		q = q.Where("device_id = ?", f.DeviceID)
+	}
+	if f.Holder != "" {
+		q = q.Where("holder = ?", f.Holder)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("ends_at > ?", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		q = q.Where("starts_at < ?", f.To.UTC())
+	}
+
+	rs := make(Reservations, 0)
+	if err := q.Order("starts_at, id").Find(&rs).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return rs, nil
+}
+
+func (r *reservationRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Reservation, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Reservation{}
+	if err := r.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", ID, tenantID).First(res).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return res, nil
+}
+
+func (r *reservationRepository) ListDue(ctx context.Context, now time.Time) (Reservations, error) {
+	rs := make(Reservations, 0)
+	err := r.db.WithContext(ctx).
+		Where("starts_at <= ? AND ends_at > ? AND started_at IS NULL AND failed_at IS NULL AND cancelled_at IS NULL", now.UTC(), now.UTC()).
+		Order("starts_at, id").
+		Find(&rs).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return rs, nil
+}
+
+func (r *reservationRepository) ListEnded(ctx context.Context, now time.Time) (Reservations, error) {
+	rs := make(Reservations, 0)
+	err := r.db.WithContext(ctx).
+		Where("ends_at <= ? AND started_at IS NOT NULL AND cancelled_at IS NULL AND overdue_checked_at IS NULL", now.UTC()).
+		Order("ends_at, id").
+		Find(&rs).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return rs, nil
+}
+
+// translateError maps the gorm and driver errors of a query to the errors of
+// this package, returning other errors unchanged.
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return device.ErrNotFound
+	case errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return ErrInvalidPeriod
+	case isOverlap(err):
+		return ErrOverlap
+	default:
+		return err
+	}
+}
+
+// isOverlap reports whether err is the database rejecting overlapping
+// reservations, through the exclusion constraint of PostgreSQL or the
+// trigger of SQLite.
+func isOverlap(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgExclusionViolation
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintTrigger
+	}
+
+	return false
+}
@@ -0,0 +1,276 @@
+package reservation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/api/reservation"
+	"github.com/hferr/device-manager/test"
+
+	"github.com/google/uuid"
+)
+
+// repositories runs fn against the gorm repository of every driver and the
+// memory repository, along with a function inserting a device to reserve.
+func repositories(t *testing.T, fn func(t *testing.T, repo reservation.ReservationRepository, newDevice func() uuid.UUID)) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			devices := device.NewRepository(db)
+			fn(t, reservation.NewRepository(db), func() uuid.UUID {
+				d := device.NewDevice("test", "test", device.StateAvailable)
+				if err := devices.InsertDevice(tenantCtx(), d); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return d.ID
+			})
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		fn(t, reservation.NewMemoryRepository(), uuid.New)
+	})
+}
+
+func tenantCtx() context.Context {
+	return organization.WithTenant(context.Background(), organization.DefaultID)
+}
+
+// at returns the time h hours after a fixed reference time.
+func at(h int) time.Time {
+	return time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC).Add(time.Duration(h) * time.Hour)
+}
+
+func newReservation(deviceID uuid.UUID, from, to int) *reservation.Reservation {
+	return reservation.NewReservation(reservation.CreateReservationRequest{
+		DeviceID: deviceID,
+		Holder:   "alice",
+		StartsAt: at(from),
+		EndsAt:   at(to),
+	})
+}
+
+func TestRepositoryOverlap(t *testing.T) {
+	repositories(t, func(t *testing.T, repo reservation.ReservationRepository, newDevice func() uuid.UUID) {
+		ctx := tenantCtx()
+		deviceID, otherID := newDevice(), newDevice()
+
+		first := newReservation(deviceID, 9, 12)
+		if err := repo.InsertReservation(ctx, first); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		var testCases = map[string]struct {
+			wantErr error
+			r       *reservation.Reservation
+		}{
+			"overlapping the start":         {reservation.ErrOverlap, newReservation(deviceID, 8, 10)},
+			"overlapping the end":           {reservation.ErrOverlap, newReservation(deviceID, 11, 13)},
+			"within the period":             {reservation.ErrOverlap, newReservation(deviceID, 10, 11)},
+			"ending when the period starts": {nil, newReservation(deviceID, 7, 9)},
+			"starting when the period ends": {nil, newReservation(deviceID, 12, 14)},
+			"of another device":             {nil, newReservation(otherID, 9, 12)},
+		}
+
+		for name, tc := range testCases {
+			if err := repo.InsertReservation(ctx, tc.r); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("%s: expected error: %v, got: %v", name, tc.wantErr, err)
+			}
+		}
+
+		// assert cancelled reservations free their period
+
+		now := time.Now()
+		first.CancelledAt = &now
+		if err := repo.UpdateReservation(ctx, first); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := repo.InsertReservation(ctx, newReservation(deviceID, 10, 11)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		// assert failed reservations free their period, and are no longer
+		// listed
+
+		failed := newReservation(otherID, 13, 16)
+		if err := repo.InsertReservation(ctx, failed); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		failed.FailedAt, failed.Failure = &now, "the device is inactive"
+		if err := repo.UpdateReservation(ctx, failed); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := repo.InsertReservation(ctx, newReservation(otherID, 14, 15)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		rs, err := repo.ListReservations(ctx, reservation.Filter{DeviceID: otherID, From: at(13), To: at(16)})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(rs) != 1 || rs[0].ID == failed.ID {
+			t.Fatalf("expected only the reservation replacing the failed one, got: %d reservations", len(rs))
+		}
+	})
+}
+
+func TestRepositoryListReservations(t *testing.T) {
+	repositories(t, func(t *testing.T, repo reservation.ReservationRepository, newDevice func() uuid.UUID) {
+		ctx := tenantCtx()
+		deviceID, otherID := newDevice(), newDevice()
+
+		late, early, other := newReservation(deviceID, 14, 16), newReservation(deviceID, 9, 12), newReservation(otherID, 10, 11)
+		other.Holder = "bob"
+		for _, r := range []*reservation.Reservation{late, early, other} {
+			if err := repo.InsertReservation(ctx, r); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		var testCases = map[string]struct {
+			want []*reservation.Reservation
+			f    reservation.Filter
+		}{
+			"every reservation ordered by start": {[]*reservation.Reservation{early, other, late}, reservation.Filter{}},
+			"of a device":                        {[]*reservation.Reservation{early, late}, reservation.Filter{DeviceID: deviceID}},
+			"of a holder":                        {[]*reservation.Reservation{other}, reservation.Filter{Holder: "bob"}},
+			"overlapping a period":               {[]*reservation.Reservation{early, other}, reservation.Filter{From: at(10), To: at(14)}},
+			"of another tenant":                  {nil, reservation.Filter{}},
+		}
+
+		for name, tc := range testCases {
+			listCtx := ctx
+			if tc.want == nil {
+				listCtx = organization.WithTenant(context.Background(), uuid.New())
+			}
+
+			rs, err := repo.ListReservations(listCtx, tc.f)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got: %v", name, err)
+			}
+
+			if len(rs) != len(tc.want) {
+				t.Fatalf("%s: expected %d reservations, got: %d", name, len(tc.want), len(rs))
+			}
+
+			for i, r := range rs {
+				if r.ID != tc.want[i].ID {
+					t.Fatalf("%s: expected reservation %d to be %s, got: %s", name, i, tc.want[i].ID, r.ID)
+				}
+			}
+		}
+
+		// assert times are read back in UTC, as written
+
+		found, err := repo.FindByID(ctx, early.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if !found.StartsAt.Equal(at(9)) || !found.EndsAt.Equal(at(12)) || found.TenantID != organization.DefaultID {
+			t.Fatalf("expected reservation %+v, got: %+v", early, found)
+		}
+
+		if _, err := repo.FindByID(ctx, uuid.New()); !errors.Is(err, reservation.ErrNotFound) {
+			t.Fatalf("expected error: %v, got: %v", reservation.ErrNotFound, err)
+		}
+	})
+}
+
+func TestRepositoryListDue(t *testing.T) {
+	repositories(t, func(t *testing.T, repo reservation.ReservationRepository, newDevice func() uuid.UUID) {
+		ctx := tenantCtx()
+		deviceID, otherID := newDevice(), newDevice()
+
+		ended, started, due, upcoming := newReservation(deviceID, 1, 2), newReservation(deviceID, 3, 5), newReservation(deviceID, 5, 12), newReservation(deviceID, 12, 14)
+		failed := newReservation(otherID, 4, 8)
+		for _, r := range []*reservation.Reservation{ended, started, due, upcoming, failed} {
+			if err := repo.InsertReservation(ctx, r); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		startedAt := at(3)
+		started.StartedAt = &startedAt
+		if err := repo.UpdateReservation(ctx, started); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		failedAt := at(4)
+		failed.FailedAt, failed.Failure = &failedAt, "the device is inactive"
+		if err := repo.UpdateReservation(ctx, failed); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		// assert the failure is stored
+
+		found, err := repo.FindByID(ctx, failed.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if found.FailedAt == nil || found.Failure != failed.Failure {
+			t.Fatalf("expected reservation failed with %q, got: %v %q", failed.Failure, found.FailedAt, found.Failure)
+		}
+
+		// assert only the reservations that started, and are neither over,
+		// failed nor started already, are due, whatever their tenant
+
+		rs, err := repo.ListDue(context.Background(), at(5))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(rs) != 1 || rs[0].ID != due.ID {
+			t.Fatalf("expected reservation %s to be due, got: %d reservations", due.ID, len(rs))
+		}
+	})
+}
+
+func TestRepositoryListEnded(t *testing.T) {
+	repositories(t, func(t *testing.T, repo reservation.ReservationRepository, newDevice func() uuid.UUID) {
+		ctx := tenantCtx()
+		deviceID := newDevice()
+
+		unstarted, checked, ended, ongoing := newReservation(deviceID, 1, 2), newReservation(deviceID, 2, 3), newReservation(deviceID, 3, 5), newReservation(deviceID, 5, 12)
+		for _, r := range []*reservation.Reservation{unstarted, checked, ended, ongoing} {
+			if err := repo.InsertReservation(ctx, r); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		for _, r := range []*reservation.Reservation{checked, ended, ongoing} {
+			startedAt := r.StartsAt
+			r.StartedAt = &startedAt
+			if r == checked {
+				r.OverdueCheckedAt = &r.EndsAt
+			}
+
+			if err := repo.UpdateReservation(ctx, r); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		// assert only the reservations that started, ended and were not
+		// checked yet are listed, whatever their tenant
+
+		rs, err := repo.ListEnded(context.Background(), at(5))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(rs) != 1 || rs[0].ID != ended.ID {
+			t.Fatalf("expected reservation %s to have ended, got: %d reservations", ended.ID, len(rs))
+		}
+	})
+}
@@ -0,0 +1,385 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+type ReservationService interface {
+	CreateReservation(ctx context.Context, input CreateReservationRequest) (*Reservation, error)
+	ListReservations(ctx context.Context, f Filter) (Reservations, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Reservation, error)
+	CancelReservation(ctx context.Context, ID uuid.UUID) (*Reservation, error)
+	// AvailableDevices returns the devices of the tenant of ctx in assignable
+	// states, which reservations can be handed, without reservations
+	// overlapping the period from from to to.
+	AvailableDevices(ctx context.Context, from, to time.Time) (device.Devices, error)
+	// StartDue moves the devices of the reservations of every tenant that
+	// started by now to in_use, returning the reservations started. A
+	// reservation whose device is gone, in a state that is not assignable, or
+	// in use without another reservation of its holder holding it, is marked
+	// failed instead, publishing an EventFailed. Other errors are reported in
+	// the returned error, and the reservations retried on the next call.
+	StartDue(ctx context.Context) (Reservations, error)
+	// ReportOverdue checks, once, whether the devices of the reservations of
+	// every tenant that ended by now were returned, publishing an
+	// EventOverdue for those still in use that no other reservation holds. It
+	// returns the reservations reported overdue.
+	ReportOverdue(ctx context.Context) (Reservations, error)
+}
+
+// StateLister lists the device states, telling which are assignable.
+type StateLister interface {
+	ListStates(ctx context.Context) (devicestate.States, error)
+}
+
+type ServiceOption func(*reservationService)
+
+// WithClock sets the clock telling the time, time.Now by default.
+func WithClock(now func() time.Time) ServiceOption {
+	return func(s *reservationService) {
+		s.now = now
+	}
+}
+
+// WithEvents sets the sink receiving the events of the reservations, which
+// are dropped by default.
+func WithEvents(sink EventSink) ServiceOption {
+	return func(s *reservationService) {
+		s.events = sink
+	}
+}
+
+type reservationService struct {
+	repo    ReservationRepository
+	devices device.DeviceService
+	states  StateLister
+	events  EventSink
+	now     func() time.Time
+}
+
+// NewService returns a ReservationService looking devices up, and moving them
+// to in_use, through devices. Only the devices in the states states reports
+// as assignable are handed over when reservations start.
+func NewService(r ReservationRepository, devices device.DeviceService, states StateLister, opts ...ServiceOption) ReservationService {
+	s := &reservationService{
+		repo:    r,
+		devices: devices,
+		states:  states,
+		events:  discard,
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *reservationService) CreateReservation(ctx context.Context, input CreateReservationRequest) (*Reservation, error) {
+	if err := checkPeriod(input.StartsAt, input.EndsAt); err != nil {
+		return nil, err
+	}
+
+	if !input.EndsAt.After(s.now()) {
+		return nil, fmt.Errorf("%w: the period is over", ErrInvalidPeriod)
+	}
+
+	// the device must exist in the tenant of the request
+	if _, err := s.devices.FindByID(ctx, input.DeviceID); err != nil {
+		return nil, err
+	}
+
+	r := NewReservation(input)
+	if err := s.repo.InsertReservation(ctx, r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (s *reservationService) ListReservations(ctx context.Context, f Filter) (Reservations, error) {
+	if !f.From.IsZero() && !f.To.IsZero() {
+		if err := checkPeriod(f.From, f.To); err != nil {
+			return nil, err
+		}
+	}
+
+	rs, err := s.repo.ListReservations(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+func (s *reservationService) FindByID(ctx context.Context, ID uuid.UUID) (*Reservation, error) {
+	r, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (s *reservationService) CancelReservation(ctx context.Context, ID uuid.UUID) (*Reservation, error) {
+	r, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	switch r.Status(now) {
+	case StatusCancelled:
+		return r, nil
+	case StatusEnded:
+		return nil, ErrEnded
+	}
+
+	r.CancelledAt = &now
+	if err := s.repo.UpdateReservation(ctx, r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (s *reservationService) AvailableDevices(ctx context.Context, from, to time.Time) (device.Devices, error) {
+	if err := checkPeriod(from, to); err != nil {
+		return nil, err
+	}
+
+	ds, err := s.devices.ListDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assignable, err := s.assignableStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := s.repo.ListReservations(ctx, Filter{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[uuid.UUID]bool, len(rs))
+	for _, r := range rs {
+		reserved[r.DeviceID] = true
+	}
+
+	available := make(device.Devices, 0, len(ds))
+	for _, d := range ds {
+		if assignable[d.State] && !reserved[d.ID] {
+			available = append(available, d)
+		}
+	}
+
+	return available, nil
+}
+
+func (s *reservationService) StartDue(ctx context.Context) (Reservations, error) {
+	now := s.now()
+
+	rs, err := s.repo.ListDue(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	started := make(Reservations, 0, len(rs))
+	var errs []error
+	for _, r := range rs {
+		tenantCtx := organization.WithTenant(ctx, r.TenantID)
+
+		failure, err := s.start(tenantCtx, r, now)
+		if err != nil {
+			// retried on the next attempt, as the error may be transient
+			errs = append(errs, fmt.Errorf("reservation %s: failed to hand over device %s: %w", r.ID, r.DeviceID, err))
+			continue
+		}
+
+		if failure != "" {
+			r.FailedAt, r.Failure = &now, failure
+		} else {
+			r.StartedAt = &now
+		}
+
+		if err := s.repo.UpdateReservation(tenantCtx, r); err != nil {
+			errs = append(errs, fmt.Errorf("reservation %s: %w", r.ID, err))
+			continue
+		}
+
+		if failure != "" {
+			s.events.Publish(tenantCtx, Event{Type: EventFailed, Reservation: r, At: now})
+			continue
+		}
+
+		started = append(started, r)
+	}
+
+	return started, errors.Join(errs...)
+}
+
+// start hands the device of r over to its holder, moving it to in_use unless
+// another started reservation of the holder already holds it. It returns why
+// the device cannot be handed over, or the error that kept it from trying.
+func (s *reservationService) start(ctx context.Context, r *Reservation, now time.Time) (string, error) {
+	d, err := s.devices.FindByID(ctx, r.DeviceID)
+	if errors.Is(err, device.ErrNotFound) {
+		return "the device no longer exists", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if d.State == device.StateInUse {
+		held, err := s.heldByHolder(ctx, r, now)
+		if err != nil {
+			return "", err
+		}
+		if !held {
+			return "the device is in use by someone else", nil
+		}
+
+		return "", nil
+	}
+
+	assignable, err := s.assignableStates(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !assignable[d.State] {
+		return fmt.Sprintf("the device is %s", d.State), nil
+	}
+
+	// the device is moved by the API, rather than by the principal of ctx
+	state := device.StateInUse
+	err = s.devices.UpdateDevice(auth.AsSystem(ctx, "reservation"), r.DeviceID, device.UpdateDeviceRequest{State: &state})
+	switch {
+	case errors.Is(err, device.ErrNotFound),
+		errors.Is(err, device.ErrInvalidTransition),
+		errors.Is(err, device.ErrConflict),
+		errors.Is(err, device.ErrVetoed):
+		return err.Error(), nil
+	case err != nil:
+		return "", err
+	}
+
+	return "", nil
+}
+
+// heldByHolder reports whether another started reservation of the holder of
+// r held its device until r started, or still does, such as the reservation
+// it follows.
+func (s *reservationService) heldByHolder(ctx context.Context, r *Reservation, now time.Time) (bool, error) {
+	rs, err := s.repo.ListReservations(ctx, Filter{DeviceID: r.DeviceID, Holder: r.Holder, From: r.StartsAt.Add(-time.Nanosecond), To: now})
+	if err != nil {
+		return false, err
+	}
+
+	for _, other := range rs {
+		if other.ID != r.ID && other.StartedAt != nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *reservationService) assignableStates(ctx context.Context) (map[string]bool, error) {
+	ss, err := s.states.ListStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assignable := make(map[string]bool, len(ss))
+	for _, st := range ss {
+		assignable[st.Name] = st.IsAssignable
+	}
+
+	return assignable, nil
+}
+
+func (s *reservationService) ReportOverdue(ctx context.Context) (Reservations, error) {
+	now := s.now()
+
+	rs, err := s.repo.ListEnded(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	overdue := make(Reservations, 0, len(rs))
+	var errs []error
+	for _, r := range rs {
+		tenantCtx := organization.WithTenant(ctx, r.TenantID)
+
+		isOverdue, err := s.isOverdue(tenantCtx, r, now)
+		if err != nil {
+			// checked again on the next attempt
+			errs = append(errs, fmt.Errorf("reservation %s: %w", r.ID, err))
+			continue
+		}
+
+		r.OverdueCheckedAt = &now
+		if err := s.repo.UpdateReservation(tenantCtx, r); err != nil {
+			errs = append(errs, fmt.Errorf("reservation %s: %w", r.ID, err))
+			continue
+		}
+
+		if isOverdue {
+			s.events.Publish(tenantCtx, Event{Type: EventOverdue, Reservation: r, At: now})
+			overdue = append(overdue, r)
+		}
+	}
+
+	return overdue, errors.Join(errs...)
+}
+
+// isOverdue reports whether the device of r, which ended, is still in use
+// without being held by another reservation.
+func (s *reservationService) isOverdue(ctx context.Context, r *Reservation, now time.Time) (bool, error) {
+	d, err := s.devices.FindByID(ctx, r.DeviceID)
+	if errors.Is(err, device.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if d.State != device.StateInUse {
+		return false, nil
+	}
+
+	rs, err := s.repo.ListReservations(ctx, Filter{DeviceID: r.DeviceID, From: now, To: now.Add(time.Nanosecond)})
+	if err != nil {
+		return false, err
+	}
+
+	for _, other := range rs {
+		if other.ID != r.ID && other.StartedAt != nil {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// checkPeriod reports an ErrInvalidPeriod unless to is after from.
+func checkPeriod(from, to time.Time) error {
+	if !to.After(from) {
+		return fmt.Errorf("%w: the end must be after the start", ErrInvalidPeriod)
+	}
+
+	return nil
+}
@@ -0,0 +1,476 @@
+package reservation_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/policy"
+	"github.com/hferr/device-manager/internal/api/reservation"
+	"github.com/hferr/device-manager/test/mock"
+
+	"github.com/google/uuid"
+)
+
+// clock returns the reference time h hours in, see at.
+func clock(h int) reservation.ServiceOption {
+	return reservation.WithClock(func() time.Time { return at(h) })
+}
+
+// states returns the built-in device states.
+func states() *mock.StateService {
+	return &mock.StateService{
+		ListStatesFunc: func() (devicestate.States, error) {
+			return devicestate.Builtin(), nil
+		},
+	}
+}
+
+func TestServiceCreateReservation(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr error
+		repo    mock.ReservationRepository
+		devices mock.DeviceService
+		input   reservation.CreateReservationRequest
+	}{
+		"successfully calls repo to insert reservation": {
+			repo: mock.ReservationRepository{
+				InsertReservationFunc: func(r *reservation.Reservation) error {
+					return nil
+				},
+			},
+			devices: mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return &device.Device{ID: ID}, nil
+				},
+			},
+			input: reservation.CreateReservationRequest{StartsAt: at(9), EndsAt: at(12)},
+		},
+		"period ending before it starts": {
+			wantErr: reservation.ErrInvalidPeriod,
+			input:   reservation.CreateReservationRequest{StartsAt: at(12), EndsAt: at(9)},
+		},
+		"period already over": {
+			wantErr: reservation.ErrInvalidPeriod,
+			input:   reservation.CreateReservationRequest{StartsAt: at(-3), EndsAt: at(-1)},
+		},
+		"device not found": {
+			wantErr: device.ErrNotFound,
+			devices: mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return nil, device.ErrNotFound
+				},
+			},
+			input: reservation.CreateReservationRequest{StartsAt: at(9), EndsAt: at(12)},
+		},
+		"repo returns error": {
+			wantErr: reservation.ErrOverlap,
+			repo: mock.ReservationRepository{
+				InsertReservationFunc: func(r *reservation.Reservation) error {
+					return reservation.ErrOverlap
+				},
+			},
+			devices: mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return &device.Device{ID: ID}, nil
+				},
+			},
+			input: reservation.CreateReservationRequest{StartsAt: at(9), EndsAt: at(12)},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := reservation.NewService(&tc.repo, &tc.devices, states(), clock(0))
+
+			tc.input.DeviceID, tc.input.Holder = uuid.New(), "alice"
+			if _, err := s.CreateReservation(tenantCtx(), tc.input); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestServiceCancelReservation(t *testing.T) {
+	cancelled := at(0)
+
+	var testCases = map[string]struct {
+		wantErr     error
+		wantUpdated bool
+		found       *reservation.Reservation
+	}{
+		"successfully cancels upcoming reservation": {
+			wantUpdated: true,
+			found:       newReservation(uuid.New(), 9, 12),
+		},
+		"successfully cancels active reservation": {
+			wantUpdated: true,
+			found:       newReservation(uuid.New(), 5, 12),
+		},
+		"cancelled reservation is left as is": {
+			found: &reservation.Reservation{StartsAt: at(9), EndsAt: at(12), CancelledAt: &cancelled},
+		},
+		"ended reservation cannot be cancelled": {
+			wantErr: reservation.ErrEnded,
+			found:   newReservation(uuid.New(), 1, 2),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var updated bool
+			repo := mock.ReservationRepository{
+				FindByIDFunc: func(ID uuid.UUID) (*reservation.Reservation, error) {
+					return tc.found, nil
+				},
+				UpdateReservationFunc: func(r *reservation.Reservation) error {
+					updated = true
+					return nil
+				},
+			}
+
+			s := reservation.NewService(&repo, &mock.DeviceService{}, states(), clock(6))
+
+			r, err := s.CancelReservation(tenantCtx(), uuid.New())
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if updated != tc.wantUpdated {
+				t.Fatalf("expected reservation updated: %t, got: %t", tc.wantUpdated, updated)
+			}
+
+			if err == nil && r.Status(at(6)) != reservation.StatusCancelled {
+				t.Fatalf("expected cancelled reservation, got status: %s", r.Status(at(6)))
+			}
+		})
+	}
+}
+
+func TestServiceAvailableDevices(t *testing.T) {
+	free, reserved := device.NewDevice("free", "test", device.StateAvailable), device.NewDevice("reserved", "test", device.StateAvailable)
+	inactive := device.NewDevice("inactive", "test", devicestate.Inactive)
+
+	var filter reservation.Filter
+	repo := mock.ReservationRepository{
+		ListReservationsFunc: func(f reservation.Filter) (reservation.Reservations, error) {
+			filter = f
+			return reservation.Reservations{newReservation(reserved.ID, 9, 12)}, nil
+		},
+	}
+	devices := mock.DeviceService{
+		ListDevicesFunc: func() (device.Devices, error) {
+			return device.Devices{free, reserved, inactive}, nil
+		},
+	}
+
+	s := reservation.NewService(&repo, &devices, states())
+
+	ds, err := s.AvailableDevices(tenantCtx(), at(10), at(11))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert the devices with reservations overlapping the period, or which
+	// cannot be handed over, are left out
+
+	if !filter.From.Equal(at(10)) || !filter.To.Equal(at(11)) {
+		t.Fatalf("expected reservations overlapping the period, got filter: %+v", filter)
+	}
+
+	if len(ds) != 1 || ds[0].ID != free.ID {
+		t.Fatalf("expected only device %s to be available, got: %d devices", free.ID, len(ds))
+	}
+
+	if _, err := s.AvailableDevices(tenantCtx(), at(11), at(10)); !errors.Is(err, reservation.ErrInvalidPeriod) {
+		t.Fatalf("expected error: %v, got: %v", reservation.ErrInvalidPeriod, err)
+	}
+}
+
+func TestServiceStartDue(t *testing.T) {
+	var testCases = map[string]struct {
+		wantStarted bool
+		wantFailure bool
+		wantMoved   bool
+		wantErr     bool
+		state       string
+		findErr     error
+		updateErr   error
+		previous    func(deviceID uuid.UUID) reservation.Reservations
+	}{
+		"successfully moves device to in_use": {
+			wantStarted: true,
+			wantMoved:   true,
+			state:       device.StateAvailable,
+		},
+		"device held by the previous reservation of the holder": {
+			wantStarted: true,
+			state:       device.StateInUse,
+			previous: func(deviceID uuid.UUID) reservation.Reservations {
+				previous := newReservation(deviceID, 1, 5)
+				startedAt := at(1)
+				previous.StartedAt = &startedAt
+				return reservation.Reservations{previous}
+			},
+		},
+		"device in use by someone else fails": {
+			wantFailure: true,
+			state:       device.StateInUse,
+		},
+		"device not assignable fails": {
+			wantFailure: true,
+			state:       devicestate.Inactive,
+		},
+		"device deleted fails": {
+			wantFailure: true,
+			findErr:     device.ErrNotFound,
+		},
+		"device that cannot be moved fails": {
+			wantFailure: true,
+			wantMoved:   true,
+			state:       device.StateAvailable,
+			updateErr:   device.ErrInvalidTransition,
+		},
+		"device failing to move is retried": {
+			wantMoved: true,
+			wantErr:   true,
+			state:     device.StateAvailable,
+			updateErr: fmt.Errorf("boom"),
+		},
+		"device failing to be found is retried": {
+			wantErr: true,
+			findErr: fmt.Errorf("boom"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			due := newReservation(uuid.New(), 5, 12)
+
+			var updated *reservation.Reservation
+			repo := mock.ReservationRepository{
+				ListDueFunc: func(now time.Time) (reservation.Reservations, error) {
+					return reservation.Reservations{due}, nil
+				},
+				ListReservationsFunc: func(f reservation.Filter) (reservation.Reservations, error) {
+					if tc.previous == nil || f.Holder != due.Holder {
+						return nil, nil
+					}
+					return tc.previous(f.DeviceID), nil
+				},
+				UpdateReservationFunc: func(r *reservation.Reservation) error {
+					updated = r
+					return nil
+				},
+			}
+
+			var moved bool
+			devices := mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					if tc.findErr != nil {
+						return nil, tc.findErr
+					}
+					return &device.Device{ID: ID, State: tc.state}, nil
+				},
+				UpdateDeviceFunc: func(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+					moved = *input.State == device.StateInUse
+					return tc.updateErr
+				},
+			}
+
+			var events []reservation.Event
+			sink := reservation.EventSinkFunc(func(_ context.Context, ev reservation.Event) {
+				events = append(events, ev)
+			})
+
+			s := reservation.NewService(&repo, &devices, states(), clock(6), reservation.WithEvents(sink))
+
+			rs, err := s.StartDue(context.Background())
+			if err != nil && !tc.wantErr {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err == nil && tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+
+			if moved != tc.wantMoved {
+				t.Fatalf("expected device moved to %s: %t, got: %t", device.StateInUse, tc.wantMoved, moved)
+			}
+
+			// assert the reservation is started or failed, or left for the next
+			// attempt
+
+			started := updated != nil && updated.StartedAt != nil
+			if started != tc.wantStarted || (len(rs) == 1) != tc.wantStarted {
+				t.Fatalf("expected reservation started: %t, got: %t", tc.wantStarted, started)
+			}
+
+			failed := updated != nil && updated.FailedAt != nil && updated.Failure != ""
+			if failed != tc.wantFailure {
+				t.Fatalf("expected reservation failed: %t, got: %t", tc.wantFailure, failed)
+			}
+
+			if (len(events) == 1) != tc.wantFailure || (tc.wantFailure && events[0].Type != reservation.EventFailed) {
+				t.Fatalf("expected %s event: %t, got: %v", reservation.EventFailed, tc.wantFailure, events)
+			}
+		})
+	}
+}
+
+func TestServiceReportOverdue(t *testing.T) {
+	var testCases = map[string]struct {
+		wantOverdue bool
+		state       string
+		findErr     error
+		others      func(deviceID uuid.UUID) reservation.Reservations
+	}{
+		"device still in use is overdue": {
+			wantOverdue: true,
+			state:       device.StateInUse,
+		},
+		"device returned is not overdue": {
+			state: device.StateAvailable,
+		},
+		"device deleted is not overdue": {
+			findErr: device.ErrNotFound,
+		},
+		"device held by the next reservation is not overdue": {
+			state: device.StateInUse,
+			others: func(deviceID uuid.UUID) reservation.Reservations {
+				next := newReservation(deviceID, 5, 8)
+				startedAt := at(5)
+				next.StartedAt = &startedAt
+				return reservation.Reservations{next}
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ended := newReservation(uuid.New(), 1, 5)
+
+			var checked bool
+			repo := mock.ReservationRepository{
+				ListEndedFunc: func(now time.Time) (reservation.Reservations, error) {
+					return reservation.Reservations{ended}, nil
+				},
+				ListReservationsFunc: func(f reservation.Filter) (reservation.Reservations, error) {
+					if tc.others == nil {
+						return nil, nil
+					}
+					return tc.others(f.DeviceID), nil
+				},
+				UpdateReservationFunc: func(r *reservation.Reservation) error {
+					checked = r.OverdueCheckedAt != nil
+					return nil
+				},
+			}
+
+			devices := mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					if tc.findErr != nil {
+						return nil, tc.findErr
+					}
+					return &device.Device{ID: ID, State: tc.state}, nil
+				},
+			}
+
+			var events []reservation.Event
+			sink := reservation.EventSinkFunc(func(_ context.Context, ev reservation.Event) {
+				events = append(events, ev)
+			})
+
+			s := reservation.NewService(&repo, &devices, states(), clock(6), reservation.WithEvents(sink))
+
+			rs, err := s.ReportOverdue(context.Background())
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// assert the reservation is checked once, whether overdue or not
+
+			if !checked {
+				t.Fatal("expected reservation to be checked")
+			}
+
+			if (len(rs) == 1) != tc.wantOverdue || (len(events) == 1) != tc.wantOverdue {
+				t.Fatalf("expected reservation overdue: %t, got: %d reservations and %d events", tc.wantOverdue, len(rs), len(events))
+			}
+
+			if tc.wantOverdue && events[0].Type != reservation.EventOverdue {
+				t.Fatalf("expected event %s, got: %s", reservation.EventOverdue, events[0].Type)
+			}
+		})
+	}
+}
+
+func TestServiceStartDuePolicies(t *testing.T) {
+	engine, err := policy.Load("../../../policies.example.yaml")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	ctx := tenantCtx()
+	devices := device.NewService(device.NewMemoryRepository(), device.WithPolicies(engine))
+
+	d, err := devices.CreateDevice(ctx, device.CreateDeviceRequest{Name: "Pixel", Brand: "Google", State: device.StateAvailable})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	repo := reservation.NewMemoryRepository()
+	if err := repo.InsertReservation(ctx, newReservation(d.ID, 5, 12)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert the example policies, denying users to put devices in use,
+	// let reservations start on behalf of a user
+
+	user := auth.WithPrincipal(ctx, &auth.Principal{Subject: "alice", Role: auth.RoleUser})
+	state := device.StateInUse
+	if err := devices.UpdateDevice(user, d.ID, device.UpdateDeviceRequest{State: &state}); !errors.Is(err, policy.ErrDenied) {
+		t.Fatalf("expected error %v, got: %v", policy.ErrDenied, err)
+	}
+
+	s := reservation.NewService(repo, devices, states(), clock(6))
+
+	rs, err := s.StartDue(user)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(rs) != 1 || rs[0].StartedAt == nil {
+		t.Fatalf("expected the reservation to start, got: %+v", rs)
+	}
+
+	got, err := devices.FindByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if got.State != device.StateInUse {
+		t.Fatalf("expected device state %s, got: %s", device.StateInUse, got.State)
+	}
+}
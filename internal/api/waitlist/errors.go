@@ -0,0 +1,22 @@
+package waitlist
+
+import "errors"
+
+var (
+	ErrNotFound = errors.New("waitlist entry not found")
+	// ErrInvalidTarget is returned for entries naming both a device and a
+	// pool, or neither.
+	ErrInvalidTarget = errors.New("a waitlist entry waits for either a device or a pool of devices")
+	// ErrNotOffered is returned when claiming the offer of an entry that has
+	// none, e.g. because it expired.
+	ErrNotOffered = errors.New("waitlist entry has no pending offer")
+	// ErrOfferWithdrawn is returned when claiming a device that stopped being
+	// available since it was offered. The entry goes back to waiting.
+	ErrOfferWithdrawn = errors.New("offered device is no longer available")
+	// ErrNotPending is returned when leaving a waitlist the entry is no longer
+	// on.
+	ErrNotPending = errors.New("waitlist entry is no longer pending")
+	// ErrDeviceOffered is returned when offering a device that already has a
+	// pending offer.
+	ErrDeviceOffered = errors.New("device already has a pending offer")
+)
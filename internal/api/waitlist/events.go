@@ -0,0 +1,41 @@
+package waitlist
+
+import (
+	"context"
+	"time"
+)
+
+// Types of the events published as entries move through the waitlist.
+const (
+	// EventOffered is published when a device is offered to a waiter.
+	EventOffered = "waitlist.offered"
+	// EventAssigned is published when a waiter gets its device, claimed or
+	// assigned automatically.
+	EventAssigned = "waitlist.assigned"
+	// EventOfferExpired is published when a waiter does not claim its offer
+	// in time.
+	EventOfferExpired = "waitlist.offer_expired"
+)
+
+// Event tells the holder of an entry what happened to it.
+type Event struct {
+	Type  string
+	Entry *Entry
+	At    time.Time
+}
+
+// EventSink receives the events of the waitlist, to notify the holders.
+// Publishing must not block the dispatch for long.
+type EventSink interface {
+	Publish(ctx context.Context, ev Event)
+}
+
+// EventSinkFunc adapts a function to an EventSink.
+type EventSinkFunc func(ctx context.Context, ev Event)
+
+func (f EventSinkFunc) Publish(ctx context.Context, ev Event) {
+	f(ctx, ev)
+}
+
+// discard is the EventSink dropping every event.
+var discard = EventSinkFunc(func(context.Context, Event) {})
@@ -0,0 +1,134 @@
+package waitlist
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// memoryRepository is a WaitlistRepository keeping entries in memory. Like
+// the unique index of the database, it rejects offering a device that
+// already has a pending offer. Entries are copied in and out, so callers
+// never share them with the store.
+type memoryRepository struct {
+	mu      sync.RWMutex
+	entries map[uuid.UUID]*Entry
+}
+
+// NewMemoryRepository returns a thread-safe in-memory WaitlistRepository.
+func NewMemoryRepository() WaitlistRepository {
+	return &memoryRepository{
+		entries: make(map[uuid.UUID]*Entry),
+	}
+}
+
+func (r *memoryRepository) InsertEntry(ctx context.Context, e *Entry) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.TenantID = tenantID
+	stored := *e
+	r.entries[stored.ID] = &stored
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateEntry(ctx context.Context, e *Entry) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// like the UPDATE it mirrors, updating a missing entry is a no-op
+	stored, ok := r.entries[e.ID]
+	if !ok || stored.TenantID != tenantID {
+		return nil
+	}
+
+	if e.Status == StatusOffered && e.OfferedDeviceID != nil {
+		for _, other := range r.entries {
+			if other.ID != e.ID && other.Status == StatusOffered &&
+				other.OfferedDeviceID != nil && *other.OfferedDeviceID == *e.OfferedDeviceID {
+				return ErrDeviceOffered
+			}
+		}
+	}
+
+	stored.Status = e.Status
+	stored.OfferedDeviceID = e.OfferedDeviceID
+	stored.OfferExpiresAt = e.OfferExpiresAt
+	stored.UpdatedAt = e.UpdatedAt
+
+	return nil
+}
+
+func (r *memoryRepository) ListEntries(ctx context.Context, f Filter) (Entries, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.list(func(e *Entry) bool {
+		return e.TenantID == tenantID &&
+			(f.DeviceID == uuid.Nil || (e.DeviceID != nil && *e.DeviceID == f.DeviceID)) &&
+			(f.Holder == "" || e.Holder == f.Holder) &&
+			(f.Status == "" || e.Status == f.Status)
+	}), nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Entry, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.entries[ID]
+	if !ok || e.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+
+	found := *e
+	return &found, nil
+}
+
+func (r *memoryRepository) ListPending(_ context.Context) (Entries, error) {
+	return r.list((*Entry).IsPending), nil
+}
+
+// list returns copies of the entries matching keep, first come first.
+func (r *memoryRepository) list(keep func(e *Entry) bool) Entries {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	es := make(Entries, 0)
+	for _, e := range r.entries {
+		if keep(e) {
+			found := *e
+			es = append(es, &found)
+		}
+	}
+
+	slices.SortFunc(es, func(a, b *Entry) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.ID[:], b.ID[:])
+	})
+
+	return es
+}
@@ -0,0 +1,158 @@
+package waitlist
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a waitlist entry.
+const (
+	// StatusWaiting entries wait for a device to become available.
+	StatusWaiting = "waiting"
+	// StatusOffered entries were offered a device, to claim before the offer
+	// expires.
+	StatusOffered = "offered"
+	// StatusAssigned entries got their device, either claimed or assigned
+	// automatically.
+	StatusAssigned = "assigned"
+	// StatusExpired entries did not claim their offer in time.
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+)
+
+// Entry is a holder waiting for a device, either a given one or any device of
+// a pool, e.g. any device of a brand. Entries are served in the order they
+// were created.
+type Entry struct {
+	ID       uuid.UUID `gorm:"primarykey"`
+	TenantID uuid.UUID
+	Holder   string
+	// DeviceID is the device waited for, or nil when waiting for a pool.
+	DeviceID *uuid.UUID
+	// Brand and Name select the devices of the pool waited for, an empty
+	// field matching every device.
+	Brand string
+	Name  string
+	// AutoAssign assigns the device to the holder as soon as it is available,
+	// instead of offering it.
+	AutoAssign      bool
+	Status          string
+	OfferedDeviceID *uuid.UUID
+	OfferExpiresAt  *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (Entry) TableName() string {
+	return "waitlist_entries"
+}
+
+type Entries []*Entry
+
+type DTO struct {
+	ID              uuid.UUID  `json:"id"`
+	TenantID        uuid.UUID  `json:"tenant_id"`
+	Holder          string     `json:"holder"`
+	DeviceID        *uuid.UUID `json:"device_id,omitempty"`
+	Brand           string     `json:"brand,omitempty"`
+	Name            string     `json:"name,omitempty"`
+	AutoAssign      bool       `json:"auto_assign"`
+	Status          string     `json:"status" enums:"waiting,offered,assigned,expired,cancelled"`
+	OfferedDeviceID *uuid.UUID `json:"offered_device_id,omitempty"`
+	OfferExpiresAt  *string    `json:"offer_expires_at,omitempty" example:"2026-10-20T09:15:00Z"`
+	CreatedAt       string     `json:"created_at"`
+}
+
+type CreateEntryRequest struct {
+	// DeviceID is the device to wait for. Without it, Brand and Name select
+	// the pool of devices to wait for.
+	DeviceID *uuid.UUID `json:"device_id"`
+	Brand    string     `json:"brand" validate:"max=255" example:"Google"`
+	Name     string     `json:"name" validate:"max=255" example:"Pixel"`
+	// Holder defaults to the subject of the authenticated principal, and
+	// only admins set another one.
+	Holder     string `json:"holder" validate:"required,max=255"`
+	AutoAssign bool   `json:"auto_assign"`
+}
+
+// Filter selects the entries to list. Zero fields match every entry.
+type Filter struct {
+	DeviceID uuid.UUID
+	Holder   string
+	Status   string
+}
+
+func NewEntry(input CreateEntryRequest) *Entry {
+	now := time.Now()
+
+	return &Entry{
+		ID:         uuid.New(),
+		Holder:     input.Holder,
+		DeviceID:   input.DeviceID,
+		Brand:      input.Brand,
+		Name:       input.Name,
+		AutoAssign: input.AutoAssign,
+		Status:     StatusWaiting,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// IsPending reports whether the entry still waits for its device.
+func (e *Entry) IsPending() bool {
+	return e.Status == StatusWaiting || e.Status == StatusOffered
+}
+
+// Matches reports whether the entry waits for the device with the given ID,
+// brand and name.
+func (e *Entry) Matches(ID uuid.UUID, brand, name string) bool {
+	if e.DeviceID != nil {
+		return *e.DeviceID == ID
+	}
+
+	return (e.Brand == "" || e.Brand == brand) && (e.Name == "" || e.Name == name)
+}
+
+// offer offers the device with the given ID to the entry, to claim within
+// ttl.
+func (e *Entry) offer(deviceID uuid.UUID, now time.Time, ttl time.Duration) {
+	expiresAt := now.Add(ttl).UTC()
+	e.Status, e.OfferedDeviceID, e.OfferExpiresAt, e.UpdatedAt = StatusOffered, &deviceID, &expiresAt, now
+}
+
+// wait puts the entry back to waiting, dropping its offer.
+func (e *Entry) wait(now time.Time) {
+	e.Status, e.OfferedDeviceID, e.OfferExpiresAt, e.UpdatedAt = StatusWaiting, nil, nil, now
+}
+
+func (e *Entry) ToDto() *DTO {
+	dto := &DTO{
+		ID:              e.ID,
+		TenantID:        e.TenantID,
+		Holder:          e.Holder,
+		DeviceID:        e.DeviceID,
+		Brand:           e.Brand,
+		Name:            e.Name,
+		AutoAssign:      e.AutoAssign,
+		Status:          e.Status,
+		OfferedDeviceID: e.OfferedDeviceID,
+		CreatedAt:       e.CreatedAt.Format(time.DateTime),
+	}
+
+	if e.OfferExpiresAt != nil {
+		expiresAt := e.OfferExpiresAt.UTC().Format(time.RFC3339)
+		dto.OfferExpiresAt = &expiresAt
+	}
+
+	return dto
+}
+
+func (es Entries) ToDto() []*DTO {
+	dtos := make([]*DTO, len(es))
+	for i, v := range es {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
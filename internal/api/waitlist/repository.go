@@ -0,0 +1,133 @@
+package waitlist
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WaitlistRepository interface {
+	InsertEntry(ctx context.Context, e *Entry) error
+	// UpdateEntry updates the status and offer of an entry, reporting an
+	// ErrDeviceOffered when offering a device that already has a pending
+	// offer.
+	UpdateEntry(ctx context.Context, e *Entry) error
+	// ListEntries returns the entries matching f, first come first.
+	ListEntries(ctx context.Context, f Filter) (Entries, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Entry, error)
+	// ListPending returns the waiting and offered entries of every tenant,
+	// first come first. It is not scoped to the tenant of ctx.
+	ListPending(ctx context.Context) (Entries, error)
+}
+
+type waitlistRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository expects db to translate driver errors, see database.Open.
+// Errors are reported as the errors of this package, such as
+// ErrDeviceOffered.
+func NewRepository(db *gorm.DB) WaitlistRepository {
+	return &waitlistRepository{
+		db: db,
+	}
+}
+
+func (r *waitlistRepository) InsertEntry(ctx context.Context, e *Entry) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	e.TenantID = tenantID
+	return translateError(r.db.WithContext(ctx).Create(e).Error)
+}
+
+func (r *waitlistRepository) UpdateEntry(ctx context.Context, e *Entry) error {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.WithContext(ctx).
+		Model(&Entry{}).
+		Select("status", "offered_device_id", "offer_expires_at", "updated_at").
+		Where("id = ? AND tenant_id = ?", e.ID, tenantID).
+		Updates(e).Error
+
+	return translateError(err)
+}
+
+func (r *waitlistRepository) ListEntries(ctx context.Context, f Filter) (Entries, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.db.WithContext(ctx).Where("tenant_id = ?", tenantID)
+	if f.DeviceID != uuid.Nil {
+		q = q.Where("device_id = ?", f.DeviceID)
+	}
+	if f.Holder != "" {
+		q = q.Where("holder = ?", f.Holder)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+
+	es := make(Entries, 0)
+	if err := q.Order("created_at, id").Find(&es).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return es, nil
+}
+
+func (r *waitlistRepository) FindByID(ctx context.Context, ID uuid.UUID) (*Entry, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{}
+	if err := r.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", ID, tenantID).First(e).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return e, nil
+}
+
+func (r *waitlistRepository) ListPending(ctx context.Context) (Entries, error) {
+	es := make(Entries, 0)
+	err := r.db.WithContext(ctx).
+		Where("status IN ?", []string{StatusWaiting, StatusOffered}).
+		Order("created_at, id").
+		Find(&es).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return es, nil
+}
+
+// translateError maps the gorm errors of a query to the errors of this
+// package, returning other errors unchanged.
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return device.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDeviceOffered
+	default:
+		return err
+	}
+}
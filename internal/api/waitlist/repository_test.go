@@ -0,0 +1,179 @@
+package waitlist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+	"github.com/hferr/device-manager/test"
+
+	"github.com/google/uuid"
+)
+
+// repositories runs fn against the gorm repository of every driver and the
+// memory repository, along with a function inserting a device to wait for.
+func repositories(t *testing.T, fn func(t *testing.T, repo waitlist.WaitlistRepository, newDevice func() uuid.UUID)) {
+	for _, driver := range test.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			defer cleanup()
+
+			devices := device.NewRepository(db)
+			fn(t, waitlist.NewRepository(db), func() uuid.UUID {
+				d := device.NewDevice("test", "test", device.StateInUse)
+				if err := devices.InsertDevice(tenantCtx(), d); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return d.ID
+			})
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		fn(t, waitlist.NewMemoryRepository(), uuid.New)
+	})
+}
+
+func tenantCtx() context.Context {
+	return organization.WithTenant(context.Background(), organization.DefaultID)
+}
+
+// at returns the time m minutes after a fixed reference time.
+func at(m int) time.Time {
+	return time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC).Add(time.Duration(m) * time.Minute)
+}
+
+// newEntry returns an entry of holder for the device with the given ID, or
+// for the pool of Pixel devices with uuid.Nil, joined m minutes in.
+func newEntry(holder string, deviceID uuid.UUID, m int) *waitlist.Entry {
+	input := waitlist.CreateEntryRequest{Holder: holder, Name: "Pixel"}
+	if deviceID != uuid.Nil {
+		input = waitlist.CreateEntryRequest{Holder: holder, DeviceID: &deviceID}
+	}
+
+	e := waitlist.NewEntry(input)
+	e.CreatedAt, e.UpdatedAt = at(m), at(m)
+	return e
+}
+
+func TestRepositoryListEntries(t *testing.T) {
+	repositories(t, func(t *testing.T, repo waitlist.WaitlistRepository, newDevice func() uuid.UUID) {
+		ctx := tenantCtx()
+		deviceID := newDevice()
+
+		second, first, pool := newEntry("bob", deviceID, 2), newEntry("alice", deviceID, 1), newEntry("alice", uuid.Nil, 3)
+		for _, e := range []*waitlist.Entry{second, first, pool} {
+			if err := repo.InsertEntry(ctx, e); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		pool.Status, pool.UpdatedAt = waitlist.StatusCancelled, at(4)
+		if err := repo.UpdateEntry(ctx, pool); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		var testCases = map[string]struct {
+			want []*waitlist.Entry
+			f    waitlist.Filter
+		}{
+			"every entry first come first": {[]*waitlist.Entry{first, second, pool}, waitlist.Filter{}},
+			"of a device":                  {[]*waitlist.Entry{first, second}, waitlist.Filter{DeviceID: deviceID}},
+			"of a holder":                  {[]*waitlist.Entry{first, pool}, waitlist.Filter{Holder: "alice"}},
+			"with a status":                {[]*waitlist.Entry{pool}, waitlist.Filter{Status: waitlist.StatusCancelled}},
+			"of another tenant":            {nil, waitlist.Filter{}},
+		}
+
+		for name, tc := range testCases {
+			listCtx := ctx
+			if tc.want == nil {
+				listCtx = organization.WithTenant(context.Background(), uuid.New())
+			}
+
+			es, err := repo.ListEntries(listCtx, tc.f)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got: %v", name, err)
+			}
+
+			if len(es) != len(tc.want) {
+				t.Fatalf("%s: expected %d entries, got: %d", name, len(tc.want), len(es))
+			}
+
+			for i, e := range es {
+				if e.ID != tc.want[i].ID {
+					t.Fatalf("%s: expected entry %d to be %s, got: %s", name, i, tc.want[i].ID, e.ID)
+				}
+			}
+		}
+
+		// assert only the waiting and offered entries are pending, whatever
+		// their tenant
+
+		es, err := repo.ListPending(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(es) != 2 || es[0].ID != first.ID || es[1].ID != second.ID {
+			t.Fatalf("expected entries %s and %s to be pending, got: %d entries", first.ID, second.ID, len(es))
+		}
+
+		if _, err := repo.FindByID(ctx, uuid.New()); !errors.Is(err, waitlist.ErrNotFound) {
+			t.Fatalf("expected error: %v, got: %v", waitlist.ErrNotFound, err)
+		}
+	})
+}
+
+func TestRepositorySingleOffer(t *testing.T) {
+	repositories(t, func(t *testing.T, repo waitlist.WaitlistRepository, newDevice func() uuid.UUID) {
+		ctx := tenantCtx()
+		deviceID := newDevice()
+
+		first, second := newEntry("alice", deviceID, 1), newEntry("bob", deviceID, 2)
+		for _, e := range []*waitlist.Entry{first, second} {
+			if err := repo.InsertEntry(ctx, e); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+
+		offer := func(e *waitlist.Entry) error {
+			expiresAt := at(20)
+			e.Status, e.OfferedDeviceID, e.OfferExpiresAt = waitlist.StatusOffered, &deviceID, &expiresAt
+			return repo.UpdateEntry(ctx, e)
+		}
+
+		if err := offer(first); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		found, err := repo.FindByID(ctx, first.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if found.Status != waitlist.StatusOffered || *found.OfferedDeviceID != deviceID || !found.OfferExpiresAt.Equal(at(20)) {
+			t.Fatalf("expected entry offered device %s, got: %+v", deviceID, found)
+		}
+
+		// assert a device is offered to a single waiter at a time
+
+		if err := offer(second); !errors.Is(err, waitlist.ErrDeviceOffered) {
+			t.Fatalf("expected error: %v, got: %v", waitlist.ErrDeviceOffered, err)
+		}
+
+		// assert the device can be offered again once the offer expired
+
+		first.Status = waitlist.StatusExpired
+		if err := repo.UpdateEntry(ctx, first); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := offer(second); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	})
+}
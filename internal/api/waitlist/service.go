@@ -0,0 +1,364 @@
+package waitlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// DefaultOfferTTL is how long waiters have to claim the devices offered to
+// them, unless set with WithOfferTTL.
+const DefaultOfferTTL = 15 * time.Minute
+
+type WaitlistService interface {
+	// JoinWaitlist puts a holder at the end of the waitlist of a device, or
+	// of a pool of devices.
+	JoinWaitlist(ctx context.Context, input CreateEntryRequest) (*Entry, error)
+	ListEntries(ctx context.Context, f Filter) (Entries, error)
+	FindByID(ctx context.Context, ID uuid.UUID) (*Entry, error)
+	// ClaimOffer assigns the device offered to an entry, moving it to in_use.
+	// A device that stopped being available puts the entry back to waiting,
+	// with its place in the queue, and reports an ErrOfferWithdrawn.
+	ClaimOffer(ctx context.Context, ID uuid.UUID) (*Entry, error)
+	// LeaveWaitlist cancels a pending entry, passing its offer, if any, on to
+	// the next waiter.
+	LeaveWaitlist(ctx context.Context, ID uuid.UUID) (*Entry, error)
+	// Dispatch expires the offers of every tenant that were not claimed in
+	// time, then offers the devices in assignable states, and free of
+	// reservations until the offers would expire, to the waiters, or assigns
+	// them to those asking for it, first come first served. It returns the
+	// events published.
+	Dispatch(ctx context.Context) ([]Event, error)
+}
+
+// StateLister lists the device states, telling which are assignable.
+type StateLister interface {
+	ListStates(ctx context.Context) (devicestate.States, error)
+}
+
+// Calendar lists the devices without reservations overlapping a period, such
+// as reservation.ReservationService.
+type Calendar interface {
+	AvailableDevices(ctx context.Context, from, to time.Time) (device.Devices, error)
+}
+
+type ServiceOption func(*waitlistService)
+
+// WithOfferTTL sets how long waiters have to claim the devices offered to
+// them, DefaultOfferTTL by default.
+func WithOfferTTL(ttl time.Duration) ServiceOption {
+	return func(s *waitlistService) {
+		s.offerTTL = ttl
+	}
+}
+
+// WithEvents sets the sink receiving the events of the waitlist, which are
+// dropped by default.
+func WithEvents(sink EventSink) ServiceOption {
+	return func(s *waitlistService) {
+		s.events = sink
+	}
+}
+
+// WithReservations sets the calendar keeping the reserved devices away from
+// the waiters. Reservations are ignored by default.
+func WithReservations(c Calendar) ServiceOption {
+	return func(s *waitlistService) {
+		s.reservations = c
+	}
+}
+
+// WithClock sets the clock telling the time, time.Now by default.
+func WithClock(now func() time.Time) ServiceOption {
+	return func(s *waitlistService) {
+		s.now = now
+	}
+}
+
+type waitlistService struct {
+	repo         WaitlistRepository
+	devices      device.DeviceService
+	states       StateLister
+	reservations Calendar
+	offerTTL     time.Duration
+	events       EventSink
+	now          func() time.Time
+}
+
+// NewService returns a WaitlistService looking devices up, and assigning
+// them, through devices. The devices in the states states reports as
+// assignable are handed out to the waiters.
+func NewService(r WaitlistRepository, devices device.DeviceService, states StateLister, opts ...ServiceOption) WaitlistService {
+	s := &waitlistService{
+		repo:     r,
+		devices:  devices,
+		states:   states,
+		offerTTL: DefaultOfferTTL,
+		events:   discard,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *waitlistService) JoinWaitlist(ctx context.Context, input CreateEntryRequest) (*Entry, error) {
+	pool := input.Brand != "" || input.Name != ""
+	if (input.DeviceID != nil) == pool {
+		return nil, ErrInvalidTarget
+	}
+
+	// the device must exist in the tenant of the request
+	if input.DeviceID != nil {
+		if _, err := s.devices.FindByID(ctx, *input.DeviceID); err != nil {
+			return nil, err
+		}
+	}
+
+	e := NewEntry(input)
+	if err := s.repo.InsertEntry(ctx, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *waitlistService) ListEntries(ctx context.Context, f Filter) (Entries, error) {
+	es, err := s.repo.ListEntries(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return es, nil
+}
+
+func (s *waitlistService) FindByID(ctx context.Context, ID uuid.UUID) (*Entry, error) {
+	e, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *waitlistService) ClaimOffer(ctx context.Context, ID uuid.UUID) (*Entry, error) {
+	e, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if e.Status != StatusOffered || !e.OfferExpiresAt.After(now) {
+		return nil, ErrNotOffered
+	}
+
+	assignable, err := s.assignableStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := s.devices.FindByID(ctx, *e.OfferedDeviceID)
+	if err != nil && !errors.Is(err, device.ErrNotFound) {
+		return nil, err
+	}
+
+	if d == nil || !assignable[d.State] {
+		e.wait(now)
+		if err := s.repo.UpdateEntry(ctx, e); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrOfferWithdrawn
+	}
+
+	if err := s.assign(ctx, e, now); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *waitlistService) LeaveWaitlist(ctx context.Context, ID uuid.UUID) (*Entry, error) {
+	e, err := s.repo.FindByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case e.Status == StatusCancelled:
+		return e, nil
+	case !e.IsPending():
+		return nil, ErrNotPending
+	}
+
+	e.Status, e.OfferedDeviceID, e.OfferExpiresAt, e.UpdatedAt = StatusCancelled, nil, nil, s.now()
+	if err := s.repo.UpdateEntry(ctx, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *waitlistService) Dispatch(ctx context.Context) ([]Event, error) {
+	now := s.now()
+
+	es, err := s.repo.ListPending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		published []Event
+		errs      []error
+		// the devices of each tenant that can be handed out in this pass,
+		// looked up on first use
+		free = make(map[uuid.UUID]device.Devices)
+	)
+	publish := func(ctx context.Context, typ string, e *Entry) {
+		ev := Event{Type: typ, Entry: e, At: now}
+		s.events.Publish(ctx, ev)
+		published = append(published, ev)
+	}
+
+	// expire the offers first, so that their devices can go to the next
+	// waiters, and keep the devices with pending offers away from them
+	offered := make(map[uuid.UUID]bool)
+	waiting := make(Entries, 0, len(es))
+	for _, e := range es {
+		tenantCtx := organization.WithTenant(ctx, e.TenantID)
+
+		switch {
+		case e.Status == StatusWaiting:
+			waiting = append(waiting, e)
+		case e.OfferExpiresAt.After(now):
+			offered[*e.OfferedDeviceID] = true
+		default:
+			e.Status, e.UpdatedAt = StatusExpired, now
+			if err := s.repo.UpdateEntry(tenantCtx, e); err != nil {
+				errs = append(errs, fmt.Errorf("waitlist entry %s: %w", e.ID, err))
+				offered[*e.OfferedDeviceID] = true
+				continue
+			}
+
+			publish(tenantCtx, EventOfferExpired, e)
+		}
+	}
+
+	for _, e := range waiting {
+		tenantCtx := organization.WithTenant(ctx, e.TenantID)
+
+		ds, ok := free[e.TenantID]
+		if !ok {
+			if ds, err = s.freeDevices(tenantCtx, now); err != nil {
+				errs = append(errs, fmt.Errorf("tenant %s: %w", e.TenantID, err))
+			}
+			free[e.TenantID] = ds
+		}
+
+		i := slices.IndexFunc(ds, func(d *device.Device) bool {
+			return !offered[d.ID] && e.Matches(d.ID, d.Brand, d.Name)
+		})
+		if i < 0 {
+			continue
+		}
+
+		d := ds[i]
+		offered[d.ID] = true
+
+		e.offer(d.ID, now, s.offerTTL)
+		if err := s.repo.UpdateEntry(tenantCtx, e); err != nil {
+			// another replica offered the device first
+			if !errors.Is(err, ErrDeviceOffered) {
+				errs = append(errs, fmt.Errorf("waitlist entry %s: %w", e.ID, err))
+			}
+			continue
+		}
+
+		if !e.AutoAssign {
+			publish(tenantCtx, EventOffered, e)
+			continue
+		}
+
+		if err := s.assign(tenantCtx, e, now); err != nil {
+			errs = append(errs, fmt.Errorf("waitlist entry %s: failed to assign device %s: %w", e.ID, d.ID, err))
+
+			// the device could not be assigned, so the entry keeps its place
+			e.wait(now)
+			if err := s.repo.UpdateEntry(tenantCtx, e); err != nil {
+				errs = append(errs, fmt.Errorf("waitlist entry %s: %w", e.ID, err))
+			}
+			continue
+		}
+
+		publish(tenantCtx, EventAssigned, e)
+	}
+
+	return published, errors.Join(errs...)
+}
+
+// assign moves the device offered to e to in_use, then marks e assigned. The
+// device is moved by the API, whoever claims the offer.
+func (s *waitlistService) assign(ctx context.Context, e *Entry, now time.Time) error {
+	state := device.StateInUse
+	if err := s.devices.UpdateDevice(auth.AsSystem(ctx, "waitlist"), *e.OfferedDeviceID, device.UpdateDeviceRequest{State: &state}); err != nil {
+		return err
+	}
+
+	e.Status, e.OfferExpiresAt, e.UpdatedAt = StatusAssigned, nil, now
+	return s.repo.UpdateEntry(ctx, e)
+}
+
+// freeDevices returns the devices of the tenant of ctx in assignable states,
+// without reservations until the offers made now would expire, in the order
+// they were listed.
+func (s *waitlistService) freeDevices(ctx context.Context, now time.Time) (device.Devices, error) {
+	assignable, err := s.assignableStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ds device.Devices
+	if s.reservations != nil {
+		ds, err = s.reservations.AvailableDevices(ctx, now, now.Add(s.offerTTL))
+	} else {
+		ds, err = s.devices.ListDevices(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	free := make(device.Devices, 0, len(ds))
+	for _, d := range ds {
+		if assignable[d.State] {
+			free = append(free, d)
+		}
+	}
+
+	return free, nil
+}
+
+func (s *waitlistService) assignableStates(ctx context.Context) (map[string]bool, error) {
+	ss, err := s.states.ListStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assignable := make(map[string]bool, len(ss))
+	for _, st := range ss {
+		assignable[st.Name] = st.IsAssignable
+	}
+
+	return assignable, nil
+}
@@ -0,0 +1,457 @@
+package waitlist_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+	"github.com/hferr/device-manager/test/mock"
+
+	"github.com/google/uuid"
+)
+
+// clock returns the reference time m minutes in, see at.
+func clock(m int) waitlist.ServiceOption {
+	return waitlist.WithClock(func() time.Time { return at(m) })
+}
+
+// states returns the built-in device states.
+func states() *mock.StateService {
+	return &mock.StateService{
+		ListStatesFunc: func() (devicestate.States, error) {
+			return devicestate.Builtin(), nil
+		},
+	}
+}
+
+func TestServiceJoinWaitlist(t *testing.T) {
+	deviceID := uuid.New()
+
+	var testCases = map[string]struct {
+		wantErr error
+		devices mock.DeviceService
+		input   waitlist.CreateEntryRequest
+	}{
+		"successfully joins waitlist of device": {
+			devices: mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return &device.Device{ID: ID}, nil
+				},
+			},
+			input: waitlist.CreateEntryRequest{DeviceID: &deviceID},
+		},
+		"successfully joins waitlist of pool": {
+			input: waitlist.CreateEntryRequest{Brand: "Google", Name: "Pixel"},
+		},
+		"neither device nor pool": {
+			wantErr: waitlist.ErrInvalidTarget,
+		},
+		"both device and pool": {
+			wantErr: waitlist.ErrInvalidTarget,
+			input:   waitlist.CreateEntryRequest{DeviceID: &deviceID, Brand: "Google"},
+		},
+		"device not found": {
+			wantErr: device.ErrNotFound,
+			devices: mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return nil, device.ErrNotFound
+				},
+			},
+			input: waitlist.CreateEntryRequest{DeviceID: &deviceID},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := mock.WaitlistRepository{
+				InsertEntryFunc: func(e *waitlist.Entry) error {
+					return nil
+				},
+			}
+
+			s := waitlist.NewService(&repo, &tc.devices, states())
+
+			tc.input.Holder = "alice"
+			e, err := s.JoinWaitlist(tenantCtx(), tc.input)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if err == nil && e.Status != waitlist.StatusWaiting {
+				t.Fatalf("expected entry %s, got: %s", waitlist.StatusWaiting, e.Status)
+			}
+		})
+	}
+}
+
+func TestServiceClaimOffer(t *testing.T) {
+	offered := func(expiresAt int) *waitlist.Entry {
+		e := newEntry("alice", uuid.Nil, 0)
+		deviceID, expires := uuid.New(), at(expiresAt)
+		e.Status, e.OfferedDeviceID, e.OfferExpiresAt = waitlist.StatusOffered, &deviceID, &expires
+		return e
+	}
+
+	var testCases = map[string]struct {
+		wantErr    error
+		wantStatus string
+		found      *waitlist.Entry
+		state      string
+		updateErr  error
+	}{
+		"successfully claims offered device": {
+			wantStatus: waitlist.StatusAssigned,
+			found:      offered(15),
+			state:      device.StateAvailable,
+		},
+		"expired offer": {
+			wantErr: waitlist.ErrNotOffered,
+			found:   offered(5),
+		},
+		"entry without offer": {
+			wantErr: waitlist.ErrNotOffered,
+			found:   newEntry("alice", uuid.Nil, 0),
+		},
+		"device no longer available": {
+			wantErr:    waitlist.ErrOfferWithdrawn,
+			wantStatus: waitlist.StatusWaiting,
+			found:      offered(15),
+			state:      device.StateInUse,
+		},
+		"device failing to move": {
+			wantErr:    device.ErrInvalidTransition,
+			wantStatus: waitlist.StatusOffered,
+			found:      offered(15),
+			state:      device.StateAvailable,
+			updateErr:  device.ErrInvalidTransition,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			status := tc.found.Status
+			repo := mock.WaitlistRepository{
+				FindByIDFunc: func(ID uuid.UUID) (*waitlist.Entry, error) {
+					return tc.found, nil
+				},
+				UpdateEntryFunc: func(e *waitlist.Entry) error {
+					status = e.Status
+					return nil
+				},
+			}
+			devices := mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return &device.Device{ID: ID, State: tc.state}, nil
+				},
+				UpdateDeviceFunc: func(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+					return tc.updateErr
+				},
+			}
+
+			s := waitlist.NewService(&repo, &devices, states(), clock(10))
+
+			if _, err := s.ClaimOffer(tenantCtx(), tc.found.ID); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if tc.wantStatus != "" && status != tc.wantStatus {
+				t.Fatalf("expected entry %s, got: %s", tc.wantStatus, status)
+			}
+		})
+	}
+}
+
+func TestServiceLeaveWaitlist(t *testing.T) {
+	var testCases = map[string]struct {
+		wantErr     error
+		wantUpdated bool
+		status      string
+	}{
+		"successfully leaves while waiting":     {wantUpdated: true, status: waitlist.StatusWaiting},
+		"successfully leaves while offered":     {wantUpdated: true, status: waitlist.StatusOffered},
+		"cancelled entry is left as is":         {status: waitlist.StatusCancelled},
+		"assigned entry cannot leave":           {wantErr: waitlist.ErrNotPending, status: waitlist.StatusAssigned},
+		"entry with expired offer cannot leave": {wantErr: waitlist.ErrNotPending, status: waitlist.StatusExpired},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var updated *waitlist.Entry
+			repo := mock.WaitlistRepository{
+				FindByIDFunc: func(ID uuid.UUID) (*waitlist.Entry, error) {
+					e := newEntry("alice", uuid.Nil, 0)
+					e.Status = tc.status
+					return e, nil
+				},
+				UpdateEntryFunc: func(e *waitlist.Entry) error {
+					updated = e
+					return nil
+				},
+			}
+
+			s := waitlist.NewService(&repo, &mock.DeviceService{}, states())
+
+			if _, err := s.LeaveWaitlist(tenantCtx(), uuid.New()); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if (updated != nil) != tc.wantUpdated {
+				t.Fatalf("expected entry updated: %t, got: %t", tc.wantUpdated, updated != nil)
+			}
+
+			if updated != nil && (updated.Status != waitlist.StatusCancelled || updated.OfferedDeviceID != nil) {
+				t.Fatalf("expected entry cancelled without offer, got: %+v", updated)
+			}
+		})
+	}
+}
+
+func TestServiceDispatch(t *testing.T) {
+	ctx := tenantCtx()
+
+	pixel, other := device.NewDevice("Pixel", "Google", device.StateInUse), device.NewDevice("Galaxy", "Samsung", device.StateInUse)
+	deviceRepo := device.NewMemoryRepository()
+	for _, d := range []*device.Device{pixel, other} {
+		if err := deviceRepo.InsertDevice(ctx, d); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	devices := device.NewService(deviceRepo)
+
+	var published []waitlist.Event
+	repo := waitlist.NewMemoryRepository()
+	now := at(0)
+	s := waitlist.NewService(repo, devices, states(),
+		waitlist.WithOfferTTL(15*time.Minute),
+		waitlist.WithClock(func() time.Time { return now }),
+		waitlist.WithEvents(waitlist.EventSinkFunc(func(_ context.Context, ev waitlist.Event) {
+			published = append(published, ev)
+		})),
+	)
+
+	join := func(input waitlist.CreateEntryRequest) *waitlist.Entry {
+		e, err := s.JoinWaitlist(ctx, input)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		// keep the order of the entries whatever the resolution of the clock
+		time.Sleep(time.Millisecond)
+		return e
+	}
+
+	alice := join(waitlist.CreateEntryRequest{Holder: "alice", Name: "Pixel"})
+	bob := join(waitlist.CreateEntryRequest{Holder: "bob", DeviceID: &pixel.ID})
+	carol := join(waitlist.CreateEntryRequest{Holder: "carol", Brand: "Google", AutoAssign: true})
+
+	dispatch := func(wantTypes ...string) {
+		t.Helper()
+
+		published = nil
+		evs, err := s.Dispatch(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(evs) != len(wantTypes) || len(published) != len(wantTypes) {
+			t.Fatalf("expected %d events, got: %d", len(wantTypes), len(evs))
+		}
+
+		for i, ev := range evs {
+			if ev.Type != wantTypes[i] {
+				t.Fatalf("expected event %d to be %s, got: %s", i, wantTypes[i], ev.Type)
+			}
+		}
+	}
+	status := func(e *waitlist.Entry) string {
+		found, err := s.FindByID(ctx, e.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		return found.Status
+	}
+	release := func(d *device.Device) {
+		state := device.StateAvailable
+		if err := devices.UpdateDevice(ctx, d.ID, device.UpdateDeviceRequest{State: &state}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	// assert nothing is handed out while the devices are in use
+
+	dispatch()
+
+	// assert the device returning to available is offered to the first
+	// waiter only
+
+	release(pixel)
+	dispatch(waitlist.EventOffered)
+
+	if status(alice) != waitlist.StatusOffered || status(bob) != waitlist.StatusWaiting {
+		t.Fatalf("expected the device offered to alice only, got: %s and %s", status(alice), status(bob))
+	}
+
+	// assert the unclaimed offer expires and goes to the next waiter
+
+	now = at(20)
+	dispatch(waitlist.EventOfferExpired, waitlist.EventOffered)
+
+	if status(alice) != waitlist.StatusExpired || status(bob) != waitlist.StatusOffered {
+		t.Fatalf("expected the offer of alice to expire and go to bob, got: %s and %s", status(alice), status(bob))
+	}
+
+	if _, err := s.ClaimOffer(ctx, alice.ID); !errors.Is(err, waitlist.ErrNotOffered) {
+		t.Fatalf("expected error: %v, got: %v", waitlist.ErrNotOffered, err)
+	}
+
+	// assert the offer is claimed by moving the device to in_use
+
+	if _, err := s.ClaimOffer(ctx, bob.ID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if d, _ := devices.FindByID(ctx, pixel.ID); d.State != device.StateInUse || status(bob) != waitlist.StatusAssigned {
+		t.Fatalf("expected device %s assigned to bob, got: %s and %s", pixel.ID, d.State, status(bob))
+	}
+
+	// assert waiters asking for it are assigned the device right away, and
+	// devices out of their pool are left alone
+
+	release(other)
+	dispatch()
+
+	release(pixel)
+	dispatch(waitlist.EventAssigned)
+
+	if d, _ := devices.FindByID(ctx, pixel.ID); d.State != device.StateInUse || status(carol) != waitlist.StatusAssigned {
+		t.Fatalf("expected device %s assigned to carol, got: %s and %s", pixel.ID, d.State, status(carol))
+	}
+}
+
+func TestServiceDispatchReservations(t *testing.T) {
+	reserved, free := device.NewDevice("Pixel", "Google", device.StateAvailable), device.NewDevice("Pixel", "Google", device.StateAvailable)
+	e := newEntry("alice", uuid.Nil, 0)
+
+	var offered *uuid.UUID
+	repo := mock.WaitlistRepository{
+		ListPendingFunc: func() (waitlist.Entries, error) {
+			return waitlist.Entries{e}, nil
+		},
+		UpdateEntryFunc: func(e *waitlist.Entry) error {
+			offered = e.OfferedDeviceID
+			return nil
+		},
+	}
+
+	var from, to time.Time
+	reservations := mock.ReservationService{
+		AvailableDevicesFunc: func(f, t time.Time) (device.Devices, error) {
+			from, to = f, t
+			return device.Devices{free}, nil
+		},
+	}
+
+	s := waitlist.NewService(&repo, &mock.DeviceService{}, states(), clock(0),
+		waitlist.WithOfferTTL(15*time.Minute),
+		waitlist.WithReservations(&reservations),
+	)
+
+	if _, err := s.Dispatch(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert devices reserved before the offer would expire are not offered
+
+	if !from.Equal(at(0)) || !to.Equal(at(0).Add(15*time.Minute)) {
+		t.Fatalf("expected devices free from %s to %s, got: %s to %s", at(0), at(0).Add(15*time.Minute), from, to)
+	}
+
+	if offered == nil || *offered != free.ID {
+		t.Fatalf("expected device %s offered instead of %s, got: %v", free.ID, reserved.ID, offered)
+	}
+}
+
+func TestServiceDispatchErrors(t *testing.T) {
+	var testCases = map[string]struct {
+		wantStatus string
+		updateErr  error
+		autoAssign bool
+	}{
+		"device offered by another replica is skipped": {
+			wantStatus: waitlist.StatusOffered,
+			updateErr:  waitlist.ErrDeviceOffered,
+		},
+		"entry failing to update is reported": {
+			wantStatus: waitlist.StatusOffered,
+			updateErr:  fmt.Errorf("boom"),
+		},
+		"device failing to be assigned keeps the waiter in place": {
+			wantStatus: waitlist.StatusWaiting,
+			autoAssign: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := device.NewDevice("Pixel", "Google", device.StateAvailable)
+			e := newEntry("alice", d.ID, 0)
+			e.AutoAssign = tc.autoAssign
+
+			var status string
+			repo := mock.WaitlistRepository{
+				ListPendingFunc: func() (waitlist.Entries, error) {
+					return waitlist.Entries{e}, nil
+				},
+				UpdateEntryFunc: func(e *waitlist.Entry) error {
+					status = e.Status
+					return tc.updateErr
+				},
+			}
+			devices := mock.DeviceService{
+				ListDevicesFunc: func() (device.Devices, error) {
+					return device.Devices{d}, nil
+				},
+				UpdateDeviceFunc: func(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+					return device.ErrInvalidTransition
+				},
+			}
+
+			s := waitlist.NewService(&repo, &devices, states(), clock(0))
+
+			evs, err := s.Dispatch(context.Background())
+			if len(evs) != 0 {
+				t.Fatalf("expected no events, got: %d", len(evs))
+			}
+
+			// offers lost to another replica are not errors
+			if wantErr := !errors.Is(tc.updateErr, waitlist.ErrDeviceOffered); (err != nil) != wantErr {
+				t.Fatalf("expected error: %t, got: %v", wantErr, err)
+			}
+
+			if status != tc.wantStatus {
+				t.Fatalf("expected entry %s, got: %s", tc.wantStatus, status)
+			}
+		})
+	}
+}
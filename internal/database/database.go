@@ -0,0 +1,98 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/migrations"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const fmtPostgresDSN = "host=%s user=%s password=%s dbname=%s port=%d sslmode=disable"
+
+// sqliteDSNParams enables foreign keys, which SQLite ignores by default, and
+// makes writers wait for the database lock instead of failing.
+const sqliteDSNParams = "?_foreign_keys=on&_busy_timeout=5000"
+
+var ErrUnknownDriver = errors.New("unknown database driver")
+
+// Dialector returns the gorm dialector for the configured driver.
+func Dialector(cfg *config.ConfDB) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case config.DBDriverPostgres:
+		return postgres.Open(fmt.Sprintf(
+			fmtPostgresDSN,
+			cfg.Host,
+			cfg.Username,
+			cfg.Password,
+			cfg.DBName,
+			cfg.Port,
+		)), nil
+	case config.DBDriverSQLite:
+		return SQLite(cfg.Path), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver)
+	}
+}
+
+// SQLite returns the dialector of the SQLite database stored at path.
+func SQLite(path string) gorm.Dialector {
+	return sqlite.Open("file:" + path + sqliteDSNParams)
+}
+
+type openOptions struct {
+	migrate bool
+}
+
+type OpenOption func(*openOptions)
+
+// WithoutMigrations leaves the schema as it is, for the migrations to be
+// applied separately.
+func WithoutMigrations() OpenOption {
+	return func(o *openOptions) {
+		o.migrate = false
+	}
+}
+
+// Open connects to the database and, unless WithoutMigrations is given,
+// applies the pending migrations of its dialect. Driver errors are translated
+// to gorm errors, such as gorm.ErrDuplicatedKey, so repositories do not depend
+// on a driver.
+func Open(d gorm.Dialector, cfg *gorm.Config, opts ...OpenOption) (*gorm.DB, error) {
+	o := openOptions{migrate: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg.TranslateError = true
+
+	db, err := gorm.Open(d, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dbHandle, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, serializing the connections avoids
+	// lock errors
+	if d.Name() == migrations.DialectSQLite {
+		dbHandle.SetMaxOpenConns(1)
+	}
+
+	if !o.migrate {
+		return db, nil
+	}
+
+	if err := migrations.MaybeApplyMigrations(dbHandle, d.Name()); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
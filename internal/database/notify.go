@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+var ErrListenUnsupported = errors.New("LISTEN requires the postgres driver")
+
+// Notify sends payload to the listeners of the PostgreSQL channel.
+func Notify(ctx context.Context, db *sql.DB, channel, payload string) error {
+	_, err := db.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+// Listen holds a connection of db listening on the PostgreSQL channel, calling
+// fn with the payload of every notification, until ctx is done or the
+// connection fails. onListen is called once the connection listens, since
+// notifications sent before are lost.
+func Listen(ctx context.Context, db *sql.DB, channel string, onListen func(), fn func(payload string)) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return ErrListenUnsupported
+		}
+		pc := c.Conn()
+
+		if _, err := pc.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		// the connection goes back to the pool, it must not keep listening
+		defer func() {
+			_, _ = pc.Exec(context.WithoutCancel(ctx), "UNLISTEN *")
+		}()
+
+		onListen()
+
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+
+			fn(n.Payload)
+		}
+	})
+}
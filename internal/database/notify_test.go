@@ -0,0 +1,83 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/database"
+	"github.com/hferr/device-manager/test"
+)
+
+func TestListen(t *testing.T) {
+	cleanup, db := test.SetupTestDBContainer(t)
+	t.Cleanup(cleanup)
+
+	dbHandle, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listening := make(chan struct{})
+	payloads := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- database.Listen(ctx, dbHandle, "test_channel", func() {
+			close(listening)
+		}, func(payload string) {
+			payloads <- payload
+		})
+	}()
+
+	select {
+	case <-listening:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the connection to listen")
+	}
+
+	// assert notifications are delivered
+
+	if err := database.Notify(ctx, dbHandle, "test_channel", "hello"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	select {
+	case p := <-payloads:
+		if p != "hello" {
+			t.Fatalf("expected payload %q, got: %q", "hello", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a notification")
+	}
+
+	// assert listening stops with the context
+
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected error, got none")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected listening to stop")
+	}
+}
+
+func TestListenUnsupported(t *testing.T) {
+	cleanup, db := test.SetupTestSQLiteDB(t)
+	t.Cleanup(cleanup)
+
+	dbHandle, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = database.Listen(context.Background(), dbHandle, "test_channel", func() {}, func(string) {})
+	if !errors.Is(err, database.ErrListenUnsupported) {
+		t.Fatalf("expected %v, got: %v", database.ErrListenUnsupported, err)
+	}
+}
@@ -0,0 +1,90 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/health"
+)
+
+func TestChecker(t *testing.T) {
+	t.Parallel()
+
+	var testCases = map[string]struct {
+		checks     map[string]health.CheckFunc
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		"no checks": {
+			wantStatus: health.StatusUp,
+			wantChecks: map[string]string{},
+		},
+		"all checks pass": {
+			checks: map[string]health.CheckFunc{
+				"database": func(ctx context.Context) error { return nil },
+				"workers":  func(ctx context.Context) error { return nil },
+			},
+			wantStatus: health.StatusUp,
+			wantChecks: map[string]string{"database": health.StatusUp, "workers": health.StatusUp},
+		},
+		"failing check": {
+			checks: map[string]health.CheckFunc{
+				"database": func(ctx context.Context) error { return errors.New("connection refused") },
+				"workers":  func(ctx context.Context) error { return nil },
+			},
+			wantStatus: health.StatusDown,
+			wantChecks: map[string]string{"database": health.StatusDown, "workers": health.StatusUp},
+		},
+		"check ignoring the timeout": {
+			checks: map[string]health.CheckFunc{
+				"database": func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				},
+			},
+			wantStatus: health.StatusDown,
+			wantChecks: map[string]string{"database": health.StatusDown},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := health.NewChecker(50 * time.Millisecond)
+			for name, fn := range tc.checks {
+				c.Add(name, fn)
+			}
+
+			start := time.Now()
+			report := c.Check(context.Background())
+
+			if time.Since(start) > 500*time.Millisecond {
+				t.Fatal("expected checks to be bounded by the timeout")
+			}
+
+			if report.Status != tc.wantStatus {
+				t.Fatalf("expected status %q, got: %q", tc.wantStatus, report.Status)
+			}
+
+			if len(report.Checks) != len(tc.wantChecks) {
+				t.Fatalf("expected %d checks, got: %d", len(tc.wantChecks), len(report.Checks))
+			}
+
+			for name, want := range tc.wantChecks {
+				res := report.Checks[name]
+				if res.Status != want {
+					t.Fatalf("expected check %q to be %q, got: %q", name, want, res.Status)
+				}
+
+				if want == health.StatusDown && res.Error == "" {
+					t.Fatalf("expected check %q to report its error", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hferr/device-manager/utils/logger"
+)
+
+var (
+	ErrServerFailed    = errors.New("server failed")
+	ErrShutdownTimeout = errors.New("shutdown did not complete within the grace period")
+	ErrShuttingDown    = errors.New("server is shutting down")
+	ErrNotStarted      = errors.New("workers are not started")
+	ErrWorkerStopped   = errors.New("worker stopped")
+)
+
+// Worker is a background task that runs until its context is cancelled.
+type Worker interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// WorkerFunc adapts a function to a named Worker.
+func WorkerFunc(name string, fn func(ctx context.Context) error) Worker {
+	return worker{name: name, fn: fn}
+}
+
+type worker struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (w worker) Name() string                  { return w.name }
+func (w worker) Run(ctx context.Context) error { return w.fn(ctx) }
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type runningWorker struct {
+	Worker
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// App runs an http.Server along with its background workers and tears them
+// down in order when the context given to Run is done:
+//
+//  1. ShuttingDown starts reporting true, so readiness probes fail;
+//  2. after the shutdown delay the server stops accepting connections and
+//     in-flight requests are drained;
+//  3. workers are stopped in the reverse order they were added;
+//  4. the shutdown hooks run in the reverse order they were added.
+//
+// Draining the requests, and stopping the workers and running the hooks, are
+// each bounded by the shutdown timeout.
+type App struct {
+	server          *http.Server
+	listener        net.Listener
+	logger          *slog.Logger
+	shutdownTimeout time.Duration
+	shutdownDelay   time.Duration
+
+	workers      []Worker
+	hooks        []hook
+	shuttingDown atomic.Bool
+
+	mu      sync.Mutex
+	started bool
+	// stopped holds why the workers that exited before shutdown stopped.
+	stopped map[string]error
+}
+
+type Option func(*App)
+
+// WithLogger sets the logger for lifecycle events, which are discarded by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(a *App) {
+		a.logger = l
+	}
+}
+
+// WithShutdownTimeout bounds how long the shutdown may take, 30s by default.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(a *App) {
+		a.shutdownTimeout = d
+	}
+}
+
+// WithShutdownDelay keeps serving requests for d after shutdown begins, giving
+// load balancers time to notice the failing readiness probe.
+func WithShutdownDelay(d time.Duration) Option {
+	return func(a *App) {
+		a.shutdownDelay = d
+	}
+}
+
+// WithListener serves on ln instead of listening on the server address.
+func WithListener(ln net.Listener) Option {
+	return func(a *App) {
+		a.listener = ln
+	}
+}
+
+func New(s *http.Server, opts ...Option) *App {
+	a := &App{
+		server:          s,
+		logger:          logger.Discard(),
+		shutdownTimeout: 30 * time.Second,
+		stopped:         make(map[string]error),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// AddWorker registers a worker to be started by Run.
+func (a *App) AddWorker(w Worker) {
+	a.workers = append(a.workers, w)
+}
+
+// OnShutdown registers a function to be called once the server is drained and
+// the workers are stopped, e.g. to close a database pool.
+func (a *App) OnShutdown(name string, fn func(ctx context.Context) error) {
+	a.hooks = append(a.hooks, hook{name: name, fn: fn})
+}
+
+// ShuttingDown reports whether shutdown has begun.
+func (a *App) ShuttingDown() bool {
+	return a.shuttingDown.Load()
+}
+
+// CheckShutdown returns ErrShuttingDown once shutdown has begun.
+func (a *App) CheckShutdown(ctx context.Context) error {
+	if a.ShuttingDown() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+// CheckWorkers returns an error naming the workers that stopped before
+// shutdown began.
+func (a *App) CheckWorkers(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.started {
+		return ErrNotStarted
+	}
+
+	var errs []error
+	for _, w := range a.workers {
+		if err, ok := a.stopped[w.Name()]; ok {
+			errs = append(errs, fmt.Errorf("%s: %w", w.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run serves requests, over TLS when the server has a TLS config, and runs
+// the workers until ctx is done or the server fails, then shuts everything
+// down. It returns the server error, if any, wrapped in ErrServerFailed and
+// joined with the errors of the shutdown.
+func (a *App) Run(ctx context.Context) error {
+	ln := a.listener
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", a.server.Addr); err != nil {
+			return errors.Join(fmt.Errorf("%w: %w", ErrServerFailed, err), a.shutdown(nil))
+		}
+	}
+
+	workers := a.startWorkers()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		tls := a.server.TLSConfig != nil
+		a.logger.Info("server starting", slog.String("addr", ln.Addr().String()), slog.Bool("tls", tls))
+
+		// the certificates are provided by the server's TLS config
+		if tls {
+			serveErr <- a.server.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- a.server.Serve(ln)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		a.logger.Info("shutdown signal received")
+	case err = <-serveErr:
+		a.logger.Error("server failed", slog.Any("error", err))
+		err = fmt.Errorf("%w: %w", ErrServerFailed, err)
+	}
+
+	return errors.Join(err, a.shutdown(workers))
+}
+
+func (a *App) startWorkers() []runningWorker {
+	workers := make([]runningWorker, 0, len(a.workers))
+
+	a.mu.Lock()
+	a.started = true
+	a.mu.Unlock()
+
+	for _, w := range a.workers {
+		ctx, cancel := context.WithCancel(context.Background())
+		rw := runningWorker{Worker: w, cancel: cancel, done: make(chan struct{})}
+
+		go func() {
+			defer close(rw.done)
+
+			err := rw.Run(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				a.logger.Error("worker stopped", slog.String("worker", rw.Name()), slog.Any("error", err))
+			}
+
+			if ctx.Err() == nil {
+				a.workerStopped(rw.Name(), err)
+			}
+		}()
+
+		workers = append(workers, rw)
+	}
+
+	return workers
+}
+
+func (a *App) workerStopped(name string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err == nil {
+		err = ErrWorkerStopped
+	} else {
+		err = fmt.Errorf("%w: %w", ErrWorkerStopped, err)
+	}
+	a.stopped[name] = err
+}
+
+func (a *App) shutdown(workers []runningWorker) error {
+	a.shuttingDown.Store(true)
+
+	if a.shutdownDelay > 0 {
+		a.logger.Info("waiting before draining requests", slog.Duration("delay", a.shutdownDelay))
+		time.Sleep(a.shutdownDelay)
+	}
+
+	var errs []error
+
+	a.logger.Info("draining requests")
+	if err := a.drain(); err != nil {
+		errs = append(errs, fmt.Errorf("drain requests: %w", timeoutErr(err)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	for i := len(workers) - 1; i >= 0; i-- {
+		w := workers[i]
+		w.cancel()
+
+		select {
+		case <-w.done:
+			a.logger.Info("worker stopped", slog.String("worker", w.Name()))
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("stop worker %s: %w", w.Name(), ErrShutdownTimeout))
+		}
+	}
+
+	for i := len(a.hooks) - 1; i >= 0; i-- {
+		h := a.hooks[i]
+		if err := h.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, timeoutErr(err)))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		a.logger.Error("shutdown failed", slog.Any("error", err))
+		return err
+	}
+
+	a.logger.Info("shutdown complete")
+	return nil
+}
+
+// drain stops accepting connections and waits for the in-flight requests,
+// closing the remaining connections once the shutdown timeout is over.
+func (a *App) drain() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.server.Close()
+		return err
+	}
+
+	return nil
+}
+
+// timeoutErr reports running out of the grace period as ErrShutdownTimeout.
+func timeoutErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrShutdownTimeout
+	}
+	return err
+}
@@ -0,0 +1,271 @@
+package lifecycle_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/lifecycle"
+	"github.com/hferr/device-manager/test"
+)
+
+func TestAppRun(t *testing.T) {
+	t.Parallel()
+
+	var testCases = map[string]struct {
+		handlerDelay    time.Duration
+		shutdownTimeout time.Duration
+		wantErr         error
+	}{
+		"drains in-flight requests": {
+			handlerDelay:    50 * time.Millisecond,
+			shutdownTimeout: time.Second,
+		},
+		"grace period exceeded": {
+			handlerDelay:    time.Second,
+			shutdownTimeout: 50 * time.Millisecond,
+			wantErr:         lifecycle.ErrShutdownTimeout,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			started := make(chan struct{})
+			s := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(started)
+					time.Sleep(tc.handlerDelay)
+					w.WriteHeader(http.StatusOK)
+				}),
+			}
+
+			app := lifecycle.New(s, lifecycle.WithListener(ln), lifecycle.WithShutdownTimeout(tc.shutdownTimeout))
+
+			var (
+				mu    sync.Mutex
+				order []string
+			)
+			record := func(name string) {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, name)
+			}
+
+			for _, name := range []string{"first worker", "second worker"} {
+				app.AddWorker(lifecycle.WorkerFunc(name, func(ctx context.Context) error {
+					<-ctx.Done()
+					record(name)
+					return ctx.Err()
+				}))
+			}
+			for _, name := range []string{"first hook", "second hook"} {
+				app.OnShutdown(name, func(ctx context.Context) error {
+					record(name)
+					return nil
+				})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- app.Run(ctx)
+			}()
+
+			respErr := make(chan error, 1)
+			go func() {
+				resp, err := http.Get("http://" + ln.Addr().String())
+				if err == nil {
+					resp.Body.Close()
+				}
+				respErr <- err
+			}()
+
+			<-started
+			if app.ShuttingDown() {
+				t.Fatal("expected app not to be shutting down")
+			}
+			cancel()
+
+			err = <-done
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tc.wantErr, err)
+			}
+
+			if err := <-respErr; tc.wantErr == nil && err != nil {
+				t.Fatalf("expected in-flight request to complete, got: %v", err)
+			}
+
+			if !app.ShuttingDown() {
+				t.Fatal("expected app to be shutting down")
+			}
+
+			// assert workers and hooks were stopped in reverse order
+
+			wantOrder := []string{"second worker", "first worker", "second hook", "first hook"}
+			if !slices.Equal(order, wantOrder) {
+				t.Fatalf("expected shutdown order %v, got: %v", wantOrder, order)
+			}
+		})
+	}
+}
+
+func TestAppRunTLS(t *testing.T) {
+	t.Parallel()
+
+	ca := test.NewCA(t)
+	certPEM, keyPEM := ca.Issue(t, pkix.Name{CommonName: "server"})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &http.Server{
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	}
+
+	app := lifecycle.New(s, lifecycle.WithListener(ln))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run(ctx)
+	}()
+
+	// assert the server is served over TLS
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.CertPEM)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.TLS == nil {
+		t.Fatal("expected a TLS connection")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestAppRunServerFailure(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	app := lifecycle.New(&http.Server{}, lifecycle.WithListener(ln))
+
+	stopped := make(chan struct{})
+	app.AddWorker(lifecycle.WorkerFunc("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	}))
+
+	if err := app.Run(context.Background()); !errors.Is(err, lifecycle.ErrServerFailed) {
+		t.Fatalf("expected error %v, got: %v", lifecycle.ErrServerFailed, err)
+	}
+
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("expected worker to be stopped")
+	}
+}
+
+func TestAppChecks(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := lifecycle.New(&http.Server{}, lifecycle.WithListener(ln))
+
+	app.AddWorker(lifecycle.WorkerFunc("healthy", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	failed := make(chan struct{})
+	app.AddWorker(lifecycle.WorkerFunc("failing", func(ctx context.Context) error {
+		defer close(failed)
+		return errors.New("boom")
+	}))
+
+	if err := app.CheckWorkers(context.Background()); !errors.Is(err, lifecycle.ErrNotStarted) {
+		t.Fatalf("expected error %v, got: %v", lifecycle.ErrNotStarted, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run(ctx)
+	}()
+
+	<-failed
+
+	// the failing worker is recorded right after it returns
+	var checkErr error
+	for range 100 {
+		if checkErr = app.CheckWorkers(context.Background()); checkErr != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if !errors.Is(checkErr, lifecycle.ErrWorkerStopped) || !strings.Contains(checkErr.Error(), "failing") {
+		t.Fatalf("expected failing worker to be reported, got: %v", checkErr)
+	}
+
+	if strings.Contains(checkErr.Error(), "healthy") {
+		t.Fatalf("expected healthy worker not to be reported, got: %v", checkErr)
+	}
+
+	if err := app.CheckShutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cancel()
+	<-done
+
+	if err := app.CheckShutdown(context.Background()); !errors.Is(err, lifecycle.ErrShuttingDown) {
+		t.Fatalf("expected error %v, got: %v", lifecycle.ErrShuttingDown, err)
+	}
+}
@@ -0,0 +1,11 @@
+package metrics
+
+// ObserveCacheLookup records a lookup of the named cache as a hit or a miss.
+func (m *Metrics) ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	m.cacheLookups.WithLabelValues(cache, result).Inc()
+}
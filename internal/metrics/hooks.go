@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+)
+
+// ObserveHook records a run of a device hook, see device.HookObserver.
+func (m *Metrics) ObserveHook(hook, operation, phase, result string, d time.Duration) {
+	m.hookRuns.WithLabelValues(hook, operation, phase, result).Inc()
+
+	// timed out runs are observed once abandoned, with their full duration,
+	// and dropped ones never ran
+	if result != device.HookResultTimeout && result != device.HookResultDropped {
+		m.hookDuration.WithLabelValues(hook, phase).Observe(d.Seconds())
+	}
+}
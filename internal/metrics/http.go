@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// ObserveHTTPRequest records a served request. route must be a route pattern,
+// not the raw path, to keep the label cardinality bounded.
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpRequestDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+)
+
+// RefreshInventory replaces the device inventory gauges with the current counts.
+func (m *Metrics) RefreshInventory(ctx context.Context, c device.DeviceCounter) error {
+	counts, err := c.CountDevices(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.devices.Reset()
+	for _, dc := range counts {
+		m.devices.WithLabelValues(dc.TenantID.String(), dc.State, dc.Brand).Set(float64(dc.Count))
+	}
+
+	return nil
+}
+
+// RunInventoryRefresh refreshes the device inventory gauges every interval
+// until ctx is done.
+func (m *Metrics) RunInventoryRefresh(ctx context.Context, c device.DeviceCounter, interval time.Duration, l *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := m.RefreshInventory(ctx, c); err != nil {
+			l.ErrorContext(ctx, "failed to refresh device inventory metrics", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/metrics"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type deviceCounterFunc func() ([]device.DeviceCount, error)
+
+func (f deviceCounterFunc) CountDevices(_ context.Context) ([]device.DeviceCount, error) {
+	return f()
+}
+
+func TestObserveHTTPRequest(t *testing.T) {
+	m := metrics.New()
+
+	m.ObserveHTTPRequest(http.MethodGet, "/devices/{id}", http.StatusOK, time.Millisecond)
+	m.ObserveHTTPRequest(http.MethodGet, "/devices/{id}", http.StatusOK, time.Millisecond)
+	m.ObserveHTTPRequest(http.MethodGet, "/devices/{id}", http.StatusNotFound, time.Millisecond)
+
+	want := `
+# HELP device_manager_http_requests_total Number of HTTP requests served, by method, chi route pattern and status.
+# TYPE device_manager_http_requests_total counter
+device_manager_http_requests_total{method="GET",route="/devices/{id}",status="200"} 2
+device_manager_http_requests_total{method="GET",route="/devices/{id}",status="404"} 1
+`
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(want), "device_manager_http_requests_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObserveCacheLookup(t *testing.T) {
+	m := metrics.New()
+
+	m.ObserveCacheLookup("devices", true)
+	m.ObserveCacheLookup("devices", true)
+	m.ObserveCacheLookup("devices", false)
+
+	want := `
+# HELP device_manager_cache_lookups_total Number of cache lookups, by cache and result (hit or miss).
+# TYPE device_manager_cache_lookups_total counter
+device_manager_cache_lookups_total{cache="devices",result="hit"} 2
+device_manager_cache_lookups_total{cache="devices",result="miss"} 1
+`
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(want), "device_manager_cache_lookups_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObserveHook(t *testing.T) {
+	m := metrics.New()
+
+	m.ObserveHook("naming", device.OperationCreate, device.PhaseBefore, device.HookResultOK, time.Millisecond)
+	m.ObserveHook("naming", device.OperationCreate, device.PhaseBefore, device.HookResultError, time.Millisecond)
+	m.ObserveHook("cmdb", device.OperationDelete, device.PhaseAfter, device.HookResultTimeout, time.Second)
+
+	want := `
+# HELP device_manager_device_hook_runs_total Number of device hook runs, by hook, operation, phase (before or after) and result (ok, error, timeout, abandoned or dropped).
+# TYPE device_manager_device_hook_runs_total counter
+device_manager_device_hook_runs_total{hook="cmdb",operation="delete",phase="after",result="timeout"} 1
+device_manager_device_hook_runs_total{hook="naming",operation="create",phase="before",result="error"} 1
+device_manager_device_hook_runs_total{hook="naming",operation="create",phase="before",result="ok"} 1
+`
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(want), "device_manager_device_hook_runs_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshInventory(t *testing.T) {
+	tenantID := uuid.New()
+	m := metrics.New()
+
+	// assert the gauges reflect the latest counts only
+
+	for _, counts := range [][]device.DeviceCount{
+		{
+			{TenantID: tenantID, State: device.StateAvailable, Brand: "old", Count: 1},
+		},
+		{
+			{TenantID: tenantID, State: device.StateAvailable, Brand: "acme", Count: 3},
+			{TenantID: tenantID, State: device.StateInUse, Brand: "acme", Count: 2},
+		},
+	} {
+		counter := deviceCounterFunc(func() ([]device.DeviceCount, error) {
+			return counts, nil
+		})
+
+		if err := m.RefreshInventory(context.Background(), counter); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	want := fmt.Sprintf(`
+# HELP device_manager_inventory_devices Number of devices, by tenant, state and brand.
+# TYPE device_manager_inventory_devices gauge
+device_manager_inventory_devices{brand="acme",state="available",tenant_id="%[1]s"} 3
+device_manager_inventory_devices{brand="acme",state="in_use",tenant_id="%[1]s"} 2
+`, tenantID)
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(want), "device_manager_inventory_devices")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assert counter errors are reported
+
+	failing := deviceCounterFunc(func() ([]device.DeviceCount, error) {
+		return nil, fmt.Errorf("boom")
+	})
+
+	if err := m.RefreshInventory(context.Background(), failing); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+func TestInstrumentDB(t *testing.T) {
+	db, err := gorm.Open(postgres.Open("host=localhost dbname=test"), &gorm.Config{
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := metrics.New()
+	if err := m.InstrumentDB(db, "test"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert the connection pool statistics are exported
+
+	n, err := testutil.GatherAndCount(m.Registry(), "go_sql_open_connections")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Fatalf("expected %d pool metric, got: %d", 1, n)
+	}
+}
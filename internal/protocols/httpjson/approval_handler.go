@@ -0,0 +1,192 @@
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hferr/device-manager/internal/api/approval"
+	"github.com/hferr/device-manager/internal/api/auth"
+	e "github.com/hferr/device-manager/internal/api/err"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// @Summary      List change requests
+// @Description  Get the change requests held back by approval policies, oldest first, optionally
+// @Description  only those of a device or with a status.
+// @Tags         approvals
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        device_id    query   string  false  "Device ID"
+// @Param        status       query   string  false  "Status"  Enums(pending, approved, applied, failed, rejected)
+// @Success      200  {array}   approval.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /change-requests [get]
+func (h Handler) ListChangeRequests(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := approval.Filter{Status: q.Get("status")}
+
+	if v := q.Get("device_id"); v != "" {
+		var err error
+		if f.DeviceID, err = uuid.Parse(v); err != nil {
+			writeProblem(w, r, e.InvalidQuery)
+			return
+		}
+	}
+
+	crs, err := h.approvalSvs.ListChangeRequests(r.Context(), f)
+	if err != nil {
+		h.handleError(w, r, err, e.ApprovalServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(crs.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Get change request by ID
+// @Description  Get a single change request by its ID, decided or not
+// @Tags         approvals
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id   path      string  true  "Change request ID"
+// @Success      200  {object}  approval.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /change-requests/{id} [get]
+func (h Handler) FindChangeRequestByID(w http.ResponseWriter, r *http.Request) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	cr, err := h.approvalSvs.FindByID(r.Context(), ID)
+	if err != nil {
+		h.handleError(w, r, err, e.ApprovalServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(cr.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Approve a change request
+// @Description  Approve a pending change request, applying its change to the device on behalf of
+// @Description  its requester. A change that can no longer be applied fails the request, with the
+// @Description  error of the change. Requires the admin role, and someone other than the requester.
+// @Tags         approvals
+// @Accept       json
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id        path      string                    true   "Change request ID"
+// @Param        decision  body      approval.DecisionRequest  false  "Decision request object"
+// @Success      200       {object}  approval.DTO
+// @Failure      400       {object}  err.Problem
+// @Failure      403       {object}  err.Problem
+// @Failure      404       {object}  err.Problem
+// @Failure      409       {object}  err.Problem
+// @Failure      422       {object}  err.Problem
+// @Failure      500       {object}  err.Problem
+// @Router       /change-requests/{id}/approve [post]
+func (h Handler) ApproveChangeRequest(w http.ResponseWriter, r *http.Request) {
+	h.changeRequestDecision(w, r, h.approvalSvs.Approve)
+}
+
+// @Summary      Reject a change request
+// @Description  Reject a pending change request, leaving the device as is. Requires the admin role.
+// @Tags         approvals
+// @Accept       json
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id        path      string                    true   "Change request ID"
+// @Param        decision  body      approval.DecisionRequest  false  "Decision request object"
+// @Success      200       {object}  approval.DTO
+// @Failure      400       {object}  err.Problem
+// @Failure      403       {object}  err.Problem
+// @Failure      404       {object}  err.Problem
+// @Failure      409       {object}  err.Problem
+// @Failure      422       {object}  err.Problem
+// @Failure      500       {object}  err.Problem
+// @Router       /change-requests/{id}/reject [post]
+func (h Handler) RejectChangeRequest(w http.ResponseWriter, r *http.Request) {
+	h.changeRequestDecision(w, r, h.approvalSvs.Reject)
+}
+
+// changeRequestDecision decides on the change request with the ID of the
+// request path with decide, on behalf of the principal, responding with the
+// decided request. The decision body is optional.
+func (h Handler) changeRequestDecision(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, ID uuid.UUID, input approval.DecisionRequest, approver string) (*approval.ChangeRequest, error)) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	input := approval.DecisionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	cr, err := decide(r.Context(), ID, input, principalSubject(r))
+	if err != nil {
+		h.handleError(w, r, err, e.ApprovalServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(cr.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// respondChangeRequest responds to a change held back by an approval policy
+// with its pending change request, which the Location header points to.
+func (h Handler) respondChangeRequest(w http.ResponseWriter, r *http.Request, cr *approval.ChangeRequest) {
+	w.Header().Set(HeaderKeyContentType, HeaderValueContentTypeJSON)
+	w.Header().Set("Location", "/change-requests/"+cr.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(cr.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// handleDeviceChangeError responds to the changes of devices held back by an
+// approval policy with their pending change request, and to the other errors
+// of the device service with their problem.
+func (h Handler) handleDeviceChangeError(w http.ResponseWriter, r *http.Request, err error) {
+	var pending *approval.PendingError
+	if errors.As(err, &pending) {
+		h.respondChangeRequest(w, r, pending.ChangeRequest)
+		return
+	}
+
+	h.handleError(w, r, err, e.DeviceServiceFailed)
+}
+
+// principalSubject returns the subject of the principal of r, empty for
+// anonymous requests.
+func principalSubject(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Subject
+	}
+
+	return ""
+}
@@ -0,0 +1,305 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/approval"
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+)
+
+func TestHandlerDeviceChangeApproval(t *testing.T) {
+	pending := &approval.ChangeRequest{ID: uuid.New(), Status: approval.StatusPending, RequestedBy: "alice"}
+
+	var testCases = map[string]struct {
+		wantCode  int
+		method    string
+		body      string
+		changeErr error
+	}{
+		"update held back": {
+			wantCode:  http.StatusAccepted,
+			method:    http.MethodPatch,
+			body:      `{"state": "inactive"}`,
+			changeErr: &approval.PendingError{ChangeRequest: pending},
+		},
+		"update without approval": {
+			wantCode: http.StatusNoContent,
+			method:   http.MethodPatch,
+			body:     `{"state": "inactive"}`,
+		},
+		"deletion held back": {
+			wantCode:  http.StatusAccepted,
+			method:    http.MethodDelete,
+			changeErr: fmt.Errorf("traced: %w", &approval.PendingError{ChangeRequest: pending}),
+		},
+		"deletion without approval": {
+			wantCode: http.StatusNoContent,
+			method:   http.MethodDelete,
+		},
+		"deletion of a device in use": {
+			wantCode:  http.StatusUnprocessableEntity,
+			method:    http.MethodDelete,
+			changeErr: device.ErrDeviceInUse,
+		},
+		"device not found": {
+			wantCode:  http.StatusNotFound,
+			method:    http.MethodDelete,
+			changeErr: device.ErrNotFound,
+		},
+		"service returns error": {
+			wantCode:  http.StatusInternalServerError,
+			method:    http.MethodPatch,
+			body:      `{"name": "Pixel"}`,
+			changeErr: fmt.Errorf("boom"),
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := mock.DeviceService{
+				UpdateDeviceFunc: func(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+					return tc.changeErr
+				},
+				DeleteDeviceFunc: func(ID uuid.UUID) error {
+					return tc.changeErr
+				},
+			}
+
+			handler := httpjson.NewHandler(&ds, v)
+
+			var body io.Reader
+			if tc.body != "" {
+				body = bytes.NewReader([]byte(tc.body))
+			}
+			resp := test.DoHttpRequest(
+				handler,
+				tc.method,
+				"/devices/"+uuid.New().String(),
+				body,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if gotCode != http.StatusAccepted {
+				return
+			}
+
+			// assert the pending change request is returned
+
+			if got, want := resp.Header.Get("Location"), "/change-requests/"+pending.ID.String(); got != want {
+				t.Fatalf("expected location %q, got: %q", want, got)
+			}
+
+			var dto approval.DTO
+			if err := json.NewDecoder(resp.Body).Decode(&dto); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if dto.ID != pending.ID || dto.Status != approval.StatusPending {
+				t.Fatalf("expected pending change request %s, got: %+v", pending.ID, dto)
+			}
+		})
+	}
+}
+
+func TestHandlerListChangeRequests(t *testing.T) {
+	deviceID := uuid.New()
+
+	var testCases = map[string]struct {
+		wantCode   int
+		wantFilter approval.Filter
+		query      string
+	}{
+		"successfully lists every change request": {
+			wantCode: http.StatusOK,
+		},
+		"successfully lists filtered change requests": {
+			wantCode:   http.StatusOK,
+			wantFilter: approval.Filter{DeviceID: deviceID, Status: approval.StatusPending},
+			query:      "?device_id=" + deviceID.String() + "&status=pending",
+		},
+		"bad request - invalid device id": {
+			wantCode: http.StatusBadRequest,
+			query:    "?device_id=invalid",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotFilter approval.Filter
+			as := mock.ApprovalService{
+				ListChangeRequestsFunc: func(f approval.Filter) (approval.ChangeRequests, error) {
+					gotFilter = f
+					return approval.ChangeRequests{}, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(&mock.DeviceService{}, nil, httpjson.WithApprovalService(&as))
+			resp := test.DoHttpRequest(
+				handler,
+				http.MethodGet,
+				"/change-requests"+tc.query,
+				nil,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if gotFilter != tc.wantFilter {
+				t.Fatalf("expected filter %+v, got: %+v", tc.wantFilter, gotFilter)
+			}
+		})
+	}
+}
+
+func TestHandlerDecideChangeRequest(t *testing.T) {
+	admin := http.Header{auth.HeaderKeySubject: {"bob"}, auth.HeaderKeyRole: {auth.RoleAdmin}}
+
+	var testCases = map[string]struct {
+		wantCode     int
+		wantDecision string
+		action       string
+		body         string
+		headers      http.Header
+		decideErr    error
+	}{
+		"successfully approves": {
+			wantCode:     http.StatusOK,
+			wantDecision: "approve",
+			action:       "approve",
+			body:         `{"reason": "spare device"}`,
+			headers:      admin,
+		},
+		"successfully rejects without body": {
+			wantCode:     http.StatusOK,
+			wantDecision: "reject",
+			action:       "reject",
+			headers:      admin,
+		},
+		"forbidden - not an admin": {
+			wantCode: http.StatusForbidden,
+			action:   "approve",
+			headers:  http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"forbidden - anonymous": {
+			wantCode: http.StatusForbidden,
+			action:   "reject",
+		},
+		"forbidden - self approval": {
+			wantCode:     http.StatusForbidden,
+			wantDecision: "approve",
+			action:       "approve",
+			headers:      admin,
+			decideErr:    approval.ErrSelfApproval,
+		},
+		"conflict - not pending": {
+			wantCode:     http.StatusConflict,
+			wantDecision: "reject",
+			action:       "reject",
+			headers:      admin,
+			decideErr:    approval.ErrNotPending,
+		},
+		"not found": {
+			wantCode:     http.StatusNotFound,
+			wantDecision: "approve",
+			action:       "approve",
+			headers:      admin,
+			decideErr:    approval.ErrNotFound,
+		},
+		"change cannot be applied": {
+			wantCode:     http.StatusUnprocessableEntity,
+			wantDecision: "approve",
+			action:       "approve",
+			headers:      admin,
+			decideErr:    fmt.Errorf("failed to apply change request: %w", device.ErrInvalidTransition),
+		},
+		"bad request - malformed body": {
+			wantCode: http.StatusBadRequest,
+			action:   "approve",
+			body:     `{"reason": 1}`,
+			headers:  admin,
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var decision, approver string
+			decide := func(action string) func(ID uuid.UUID, input approval.DecisionRequest, by string) (*approval.ChangeRequest, error) {
+				return func(ID uuid.UUID, input approval.DecisionRequest, by string) (*approval.ChangeRequest, error) {
+					decision, approver = action, by
+					if tc.decideErr != nil {
+						return nil, tc.decideErr
+					}
+					return &approval.ChangeRequest{ID: ID, Status: approval.StatusApplied, DecidedBy: by, Reason: input.Reason}, nil
+				}
+			}
+			as := mock.ApprovalService{
+				ApproveFunc: decide("approve"),
+				RejectFunc:  decide("reject"),
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithApprovalService(&as),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodPost,
+				"/change-requests/"+uuid.New().String()+"/"+tc.action,
+				bytes.NewReader([]byte(tc.body)),
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if decision != tc.wantDecision {
+				t.Fatalf("expected decision %q, got: %q", tc.wantDecision, decision)
+			}
+
+			if decision != "" && approver != "bob" {
+				t.Fatalf("expected decision of bob, got: %q", approver)
+			}
+		})
+	}
+}
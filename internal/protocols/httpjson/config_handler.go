@@ -0,0 +1,23 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/hferr/device-manager/internal/api/err"
+)
+
+// @Summary      Dump the configuration
+// @Description  Get every setting of the running API with its value and source (default, file, env or flag), with secrets redacted, admin only
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   config.Setting
+// @Failure      403  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /admin/config [get]
+func (h Handler) DumpConfig(w http.ResponseWriter, r *http.Request) {
+	if err := json.NewEncoder(w).Encode(h.config.Settings()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
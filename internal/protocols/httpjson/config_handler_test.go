@@ -0,0 +1,70 @@
+package httpjson_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+)
+
+func TestHandlerDumpConfig(t *testing.T) {
+	t.Setenv("DB_PASS", "topsecretpassword")
+
+	c, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var testCases = map[string]struct {
+		wantCode int
+		headers  http.Header
+	}{
+		"dumps the configuration": {
+			wantCode: http.StatusOK,
+			headers:  adminHeaders,
+		},
+		"forbidden - not an admin": {
+			wantCode: http.StatusForbidden,
+			headers: http.Header{
+				auth.HeaderKeySubject: {"user"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				nil,
+				httpjson.WithConfig(c),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/admin/config", nil, tc.headers)
+
+			if resp.StatusCode != tc.wantCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, resp.StatusCode)
+			}
+
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+
+			var settings []config.Setting
+			if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			// assert secrets are redacted
+			for _, s := range settings {
+				if s.Name == "DB_PASS" && s.Value == "topsecretpassword" {
+					t.Fatal("expected DB_PASS to be redacted")
+				}
+			}
+		})
+	}
+}
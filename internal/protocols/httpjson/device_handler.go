@@ -1,130 +1,110 @@
 package httpjson
 
 import (
-	"encoding/json"
-	"errors"
 	"net/http"
 
 	"github.com/hferr/device-manager/internal/api/device"
 	e "github.com/hferr/device-manager/internal/api/err"
-	"github.com/hferr/device-manager/utils/validator"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 // @Summary      List all devices
 // @Description  Get a list of all devices in the system
 // @Tags         devices
-// @Produce      json
+// @Produce      json,application/x-ndjson,text/csv,application/yaml,application/msgpack
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
 // @Success      200  {array}   device.DTO
-// @Failure      500  {object}  err.Error
+// @Failure      406  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
 // @Router       /devices [get]
 func (h Handler) ListDevices(w http.ResponseWriter, r *http.Request) {
-	ds, err := h.deviceSvs.ListDevices()
+	ds, err := h.deviceSvs.ListDevices(r.Context())
 	if err != nil {
-		e.ServerError(w, e.DeviceServiceFailedErrResp)
+		h.handleError(w, r, err, e.DeviceServiceFailed)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(ds.ToDto()); err != nil {
-		e.ServerError(w, e.JSONEncodeErrResp)
-		return
-	}
+	h.respond(w, r, http.StatusOK, ds.ToDto())
 }
 
 // @Summary      Create a new device
 // @Description  Create a new device in the system.
 // @Tags         devices
-// @Accept       json
-// @Produce      json
+// @Accept       json,application/x-ndjson,text/csv,application/yaml,application/msgpack
+// @Produce      json,application/x-ndjson,text/csv,application/yaml,application/msgpack
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request replay the original response"
 // @Param        device  body      device.CreateDeviceRequest  true  "Create device request object"
 // @Success      201     {object}  device.DTO
-// @Failure      400     {object}  err.Error
-// @Failure      422     {object}  err.Errors
-// @Failure      500     {object}  err.Error
+// @Failure      400     {object}  err.Problem
+// @Failure      409     {object}  err.Problem
+// @Failure      413     {object}  err.Problem
+// @Failure      422     {object}  err.Problem
+// @Failure      406     {object}  err.Problem
+// @Failure      415     {object}  err.Problem
+// @Failure      500     {object}  err.Problem
 // @Router       /devices [post]
 func (h Handler) CreateDevice(w http.ResponseWriter, r *http.Request) {
 	input := device.CreateDeviceRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		e.BadRequest(w, e.JSONDecodeErrResp)
+	if err := decode(r, &input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
 		return
 	}
 
-	if err := h.validator.Struct(input); err != nil {
-		res, err := json.Marshal(validator.ErrResponse(err))
-		if err != nil {
-			e.BadRequest(w, e.JSONDecodeErrResp)
-			return
-		}
-
-		e.UnprocessableEntity(w, res)
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
 		return
 	}
 
-	d, err := h.deviceSvs.CreateDevice(input)
+	d, err := h.deviceSvs.CreateDevice(r.Context(), input)
 	if err != nil {
-		e.ServerError(w, e.DeviceServiceFailedErrResp)
+		h.handleError(w, r, err, e.DeviceServiceFailed)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(d.ToDto()); err != nil {
-		e.ServerError(w, e.JSONEncodeErrResp)
-		return
-	}
+	h.respond(w, r, http.StatusCreated, d.ToDto())
 }
 
 // @Summary      Update a device
 // @Description  Update an existing device by its ID, only devices that are not in the
-// @Description  state 'in_use' can be updated.
+// @Description  state 'in_use' can be updated. Allowed updates matching an approval policy are
+// @Description  not applied but held in a pending change request, returned with 202 Accepted.
 // @Tags         devices
-// @Accept       json
+// @Accept       json,application/x-ndjson,text/csv,application/yaml,application/msgpack
 // @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
 // @Param        id      path      string                    true  "Device ID"
 // @Param        device  body      device.UpdateDeviceRequest  true  "Updated device request object"
 // @Success      204
-// @Failure      400     {object}  err.Error
-// @Failure		 404     {object}  err.Error
-// @Failure      422     {object}  err.Errors
-// @Failure      500     {object}  err.Error
+// @Success      202     {object}  approval.DTO
+// @Failure      400     {object}  err.Problem
+// @Failure		 404     {object}  err.Problem
+// @Failure      422     {object}  err.Problem
+// @Failure      415     {object}  err.Problem
+// @Failure      500     {object}  err.Problem
 // @Router       /devices/{id} [patch]
 func (h Handler) UpdateDevice(w http.ResponseWriter, r *http.Request) {
 	ID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		e.BadRequest(w, e.InvalidIDErrResp)
+		writeProblem(w, r, e.InvalidID)
 		return
 	}
 
 	input := device.UpdateDeviceRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		e.BadRequest(w, e.JSONDecodeErrResp)
+	if err := decode(r, &input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
 		return
 	}
 
-	if err := h.validator.Struct(input); err != nil {
-		res, err := json.Marshal(validator.ErrResponse(err))
-		if err != nil {
-			e.BadRequest(w, e.JSONDecodeErrResp)
-			return
-		}
-
-		e.UnprocessableEntity(w, res)
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
 		return
 	}
 
-	if err := h.deviceSvs.UpdateDevice(ID, input); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			e.NotFound(w, e.DeviceNotFoundErrResp)
-			return
-		}
-		if errors.Is(err, device.ErrDeviceInUse) {
-			e.UnprocessableEntity(w, e.DeviceInUseErrResp)
-			return
-		}
-
-		e.ServerError(w, e.DeviceServiceFailedErrResp)
+	if err := h.deviceSvs.UpdateDevice(r.Context(), ID, input); err != nil {
+		h.handleDeviceChangeError(w, r, err)
 		return
 	}
 
@@ -134,126 +114,102 @@ func (h Handler) UpdateDevice(w http.ResponseWriter, r *http.Request) {
 // @Summary      Get device by ID
 // @Description  Get a single device by its ID
 // @Tags         devices
-// @Produce      json
+// @Produce      json,application/x-ndjson,text/csv,application/yaml,application/msgpack
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
 // @Param        id   path      string  true  "Device ID"
 // @Success      200  {object}  device.DTO
-// @Failure      400  {object}  err.Error
-// @Failure      404  {object}  err.Error
-// @Failure      500  {object}  err.Error
+// @Failure      400  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      406  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
 // @Router       /devices/{id} [get]
 func (h Handler) FindByID(w http.ResponseWriter, r *http.Request) {
 	ID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		e.BadRequest(w, e.InvalidIDErrResp)
+		writeProblem(w, r, e.InvalidID)
 		return
 	}
 
-	d, err := h.deviceSvs.FindByID(ID)
+	d, err := h.deviceSvs.FindByID(r.Context(), ID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			e.NotFound(w, e.DeviceNotFoundErrResp)
-			return
-		}
-
-		e.ServerError(w, e.DeviceServiceFailedErrResp)
+		h.handleError(w, r, err, e.DeviceServiceFailed)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(d.ToDto()); err != nil {
-		e.ServerError(w, e.JSONEncodeErrResp)
-		return
-	}
+	h.respond(w, r, http.StatusOK, d.ToDto())
 }
 
 // @Summary      Find devices by state
 // @Description  Get all devices with a specific state
 // @Tags         devices
-// @Produce      json
+// @Produce      json,application/x-ndjson,text/csv,application/yaml,application/msgpack
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
 // @Param        state  path      string  true  "Device state"
 // @Success      200  {object}  device.DTO
-// @Failure      400  {object}  err.Error
-// @Failure      404  {object}  err.Error
-// @Failure      500  {object}  err.Error
+// @Failure      400  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      406  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
 // @Router       /devices/state/{state} [get]
 func (h Handler) FindByState(w http.ResponseWriter, r *http.Request) {
 	state := chi.URLParam(r, "state")
 
-	ds, err := h.deviceSvs.FindByState(state)
+	ds, err := h.deviceSvs.FindByState(r.Context(), state)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			e.NotFound(w, e.DeviceNotFoundErrResp)
-			return
-		}
-
-		e.ServerError(w, e.DeviceServiceFailedErrResp)
+		h.handleError(w, r, err, e.DeviceServiceFailed)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(ds.ToDto()); err != nil {
-		e.ServerError(w, e.JSONEncodeErrResp)
-		return
-	}
+	h.respond(w, r, http.StatusOK, ds.ToDto())
 }
 
 // @Summary      Find devices by brand
 // @Description  Get all devices from a specific brand
 // @Tags         devices
-// @Produce      json
+// @Produce      json,application/x-ndjson,text/csv,application/yaml,application/msgpack
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
 // @Param        brand  path      string  true  "Device brand"
 // @Success      200  {object}  device.DTO
-// @Failure      400  {object}  err.Error
-// @Failure      404  {object}  err.Error
-// @Failure      500  {object}  err.Error
+// @Failure      400  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      406  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
 // @Router       /devices/brand/{brand} [get]
 func (h Handler) FindByBrand(w http.ResponseWriter, r *http.Request) {
 	state := chi.URLParam(r, "brand")
 
-	ds, err := h.deviceSvs.FindByBrand(state)
+	ds, err := h.deviceSvs.FindByBrand(r.Context(), state)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			e.NotFound(w, e.DeviceNotFoundErrResp)
-			return
-		}
-
-		e.ServerError(w, e.DeviceServiceFailedErrResp)
+		h.handleError(w, r, err, e.DeviceServiceFailed)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(ds.ToDto()); err != nil {
-		e.ServerError(w, e.JSONEncodeErrResp)
-		return
-	}
+	h.respond(w, r, http.StatusOK, ds.ToDto())
 }
 
 // @Summary      Delete a device
-// @Description  Delete a device by its ID
+// @Description  Delete a device by its ID. Allowed deletions matching an approval policy are
+// @Description  not applied but held in a pending change request, returned with 202 Accepted.
 // @Tags         devices
 // @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
 // @Param        id   path      string  true  "Device ID"
 // @Success      204
-// @Failure      400  {object}  err.Error
-// @Failure		 404  {object}  err.Error
-// @Failure      422  {object}  err.Error
-// @Failure      500  {object}  err.Error
+// @Success      202  {object}  approval.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure		 404  {object}  err.Problem
+// @Failure      422  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
 // @Router       /devices/{id} [delete]
 func (h Handler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
 	ID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		e.BadRequest(w, e.InvalidIDErrResp)
+		writeProblem(w, r, e.InvalidID)
 		return
 	}
 
-	if err := h.deviceSvs.DeleteDevice(ID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			e.NotFound(w, e.DeviceNotFoundErrResp)
-			return
-		}
-		if errors.Is(err, device.ErrDeviceInUse) {
-			e.UnprocessableEntity(w, e.DeviceInUseErrResp)
-			return
-		}
-
-		e.ServerError(w, e.DeviceServiceFailedErrResp)
+	if err := h.deviceSvs.DeleteDevice(r.Context(), ID); err != nil {
+		h.handleDeviceChangeError(w, r, err)
 		return
 	}
 
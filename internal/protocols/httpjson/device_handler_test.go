@@ -5,16 +5,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/idempotency"
+	"github.com/hferr/device-manager/internal/api/policy"
 	"github.com/hferr/device-manager/internal/protocols/httpjson"
 	"github.com/hferr/device-manager/test"
 	"github.com/hferr/device-manager/test/mock"
 	"github.com/hferr/device-manager/utils/validator"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 func TestHandlerCreateFile(t *testing.T) {
@@ -208,7 +212,7 @@ func TestHandlerUpdateDevice(t *testing.T) {
 			},
 			s: mock.DeviceService{
 				UpdateDeviceFunc: func(id uuid.UUID, input device.UpdateDeviceRequest) error {
-					return gorm.ErrRecordNotFound
+					return device.ErrNotFound
 				},
 			},
 		},
@@ -285,7 +289,7 @@ func TestHandlerFindByID(t *testing.T) {
 			wantCode: http.StatusNotFound,
 			s: mock.DeviceService{
 				FindByIDFunc: func(id uuid.UUID) (*device.Device, error) {
-					return nil, gorm.ErrRecordNotFound
+					return nil, device.ErrNotFound
 				},
 			},
 		},
@@ -344,7 +348,7 @@ func TestHandlerFindByBrand(t *testing.T) {
 			wantCode: http.StatusNotFound,
 			s: mock.DeviceService{
 				FindByBrandFunc: func(brand string) (device.Devices, error) {
-					return nil, gorm.ErrRecordNotFound
+					return nil, device.ErrNotFound
 				},
 			},
 		},
@@ -415,7 +419,7 @@ func TestHandlerFindByState(t *testing.T) {
 			wantCode: http.StatusNotFound,
 			s: mock.DeviceService{
 				FindByStateFunc: func(state string) (device.Devices, error) {
-					return nil, gorm.ErrRecordNotFound
+					return nil, device.ErrNotFound
 				},
 			},
 		},
@@ -470,7 +474,7 @@ func TestHandlerDeleteDevice(t *testing.T) {
 			wantCode: http.StatusNotFound,
 			s: mock.DeviceService{
 				DeleteDeviceFunc: func(id uuid.UUID) error {
-					return gorm.ErrRecordNotFound
+					return device.ErrNotFound
 				},
 			},
 		},
@@ -482,6 +486,22 @@ func TestHandlerDeleteDevice(t *testing.T) {
 				},
 			},
 		},
+		"vetoed by hook": {
+			wantCode: http.StatusUnprocessableEntity,
+			s: mock.DeviceService{
+				DeleteDeviceFunc: func(id uuid.UUID) error {
+					return &device.VetoError{Hook: "cmdb", Err: fmt.Errorf("device still assigned")}
+				},
+			},
+		},
+		"denied by policy": {
+			wantCode: http.StatusForbidden,
+			s: mock.DeviceService{
+				DeleteDeviceFunc: func(id uuid.UUID) error {
+					return &policy.DeniedError{Policy: "admins-only", Message: "only admins delete devices"}
+				},
+			},
+		},
 		"service returns error": {
 			wantCode: http.StatusInternalServerError,
 			s: mock.DeviceService{
@@ -514,3 +534,298 @@ func TestHandlerDeleteDevice(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerCreateDeviceIdempotency(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		records = map[string]*idempotency.Record{}
+		created = 0
+	)
+
+	repo := &mock.IdempotencyRepository{
+		InsertRecordFunc: func(rec *idempotency.Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := records[rec.Scope+rec.Key]; ok {
+				return idempotency.ErrDuplicateKey
+			}
+			records[rec.Scope+rec.Key] = rec
+			return nil
+		},
+		UpdateRecordFunc: func(rec *idempotency.Record) error {
+			return nil
+		},
+		FindByKeyFunc: func(scope, key string) (*idempotency.Record, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return records[scope+key], nil
+		},
+	}
+
+	s := &mock.DeviceService{
+		CreateDeviceFunc: func(input device.CreateDeviceRequest) (*device.Device, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			created++
+			return device.NewDevice(input.Name, input.Brand, input.State), nil
+		},
+	}
+
+	handler := httpjson.NewHandler(
+		s,
+		validator.New(),
+		httpjson.WithIdempotencyService(idempotency.NewService(repo, time.Hour)),
+	)
+
+	post := func(key, name string) *http.Response {
+		reqJson, err := json.Marshal(device.CreateDeviceRequest{Name: name, Brand: "test", State: device.StateAvailable})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return test.DoHttpRequestWithHeaders(
+			handler,
+			http.MethodPost,
+			"/devices",
+			bytes.NewReader(reqJson),
+			http.Header{httpjson.HeaderKeyIdempotencyKey: {key}},
+		)
+	}
+
+	first := post("key-1", "test")
+	if first.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status code %d, got: %d", http.StatusCreated, first.StatusCode)
+	}
+
+	// assert a retry replays the original response
+
+	retry := post("key-1", "test")
+	if retry.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status code %d, got: %d", http.StatusCreated, retry.StatusCode)
+	}
+
+	if retry.Header.Get(httpjson.HeaderKeyIdempotentReplayed) != "true" {
+		t.Fatal("expected response to be replayed")
+	}
+
+	firstDto, retryDto := device.DTO{}, device.DTO{}
+	if err := json.NewDecoder(first.Body).Decode(&firstDto); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewDecoder(retry.Body).Decode(&retryDto); err != nil {
+		t.Fatal(err)
+	}
+
+	if firstDto.ID != retryDto.ID {
+		t.Fatalf("expected replayed device ID %s, got: %s", firstDto.ID, retryDto.ID)
+	}
+
+	if created != 1 {
+		t.Fatalf("expected %d device created, got: %d", 1, created)
+	}
+
+	// assert the key cannot be reused with a different body
+
+	if resp := post("key-1", "other"); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status code %d, got: %d", http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+
+	// assert a new key creates a new device
+
+	if resp := post("key-2", "test"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status code %d, got: %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	if created != 2 {
+		t.Fatalf("expected %d devices created, got: %d", 2, created)
+	}
+}
+
+// idempotencyRecords returns a repository of idempotency records kept in
+// memory, whose deletions are counted in released.
+func idempotencyRecords(released *int) *mock.IdempotencyRepository {
+	var (
+		mu      sync.Mutex
+		records = map[string]*idempotency.Record{}
+	)
+
+	return &mock.IdempotencyRepository{
+		InsertRecordFunc: func(rec *idempotency.Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := records[rec.Scope+rec.Key]; ok {
+				return idempotency.ErrDuplicateKey
+			}
+			records[rec.Scope+rec.Key] = rec
+			return nil
+		},
+		UpdateRecordFunc: func(rec *idempotency.Record) error {
+			return nil
+		},
+		FindByKeyFunc: func(scope, key string) (*idempotency.Record, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return records[scope+key], nil
+		},
+		DeleteRecordFunc: func(scope, key string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(records, scope+key)
+			*released++
+			return nil
+		},
+	}
+}
+
+func TestHandlerIdempotencyRelease(t *testing.T) {
+	var testCases = map[string]struct {
+		completeErr error
+		panics      bool
+	}{
+		"storing the response fails": {
+			completeErr: fmt.Errorf("boom"),
+		},
+		"handler panics": {
+			panics: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var released, created int
+			repo := idempotencyRecords(&released)
+			repo.UpdateRecordFunc = func(rec *idempotency.Record) error {
+				return tc.completeErr
+			}
+
+			s := &mock.DeviceService{
+				CreateDeviceFunc: func(input device.CreateDeviceRequest) (*device.Device, error) {
+					created++
+					if tc.panics && created == 1 {
+						panic("boom")
+					}
+					return device.NewDevice(input.Name, input.Brand, input.State), nil
+				},
+			}
+
+			handler := httpjson.NewHandler(
+				s,
+				validator.New(),
+				httpjson.WithIdempotencyService(idempotency.NewService(repo, time.Hour)),
+			)
+
+			post := func() (resp *http.Response, panicked bool) {
+				defer func() {
+					if recover() != nil {
+						panicked = true
+					}
+				}()
+
+				return test.DoHttpRequestWithHeaders(
+					handler,
+					http.MethodPost,
+					"/devices",
+					bytes.NewReader([]byte(`{"name": "test", "brand": "test", "state": "available"}`)),
+					http.Header{httpjson.HeaderKeyIdempotencyKey: {"key-1"}},
+				), false
+			}
+
+			if _, panicked := post(); panicked != tc.panics {
+				t.Fatalf("expected panic: %t, got: %t", tc.panics, panicked)
+			}
+
+			if released != 1 {
+				t.Fatalf("expected the key to be released once, got: %d", released)
+			}
+
+			// assert a retry executes the request again instead of conflicting
+
+			retry, _ := post()
+			if retry.StatusCode != http.StatusCreated {
+				t.Fatalf("expected status code %d, got: %d", http.StatusCreated, retry.StatusCode)
+			}
+
+			if retry.Header.Get(httpjson.HeaderKeyIdempotentReplayed) != "" {
+				t.Fatal("expected response not to be replayed")
+			}
+
+			if created != 2 {
+				t.Fatalf("expected %d devices created, got: %d", 2, created)
+			}
+		})
+	}
+}
+
+func TestHandlerIdempotencyRequest(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		accept   string
+		body     string
+	}{
+		"replays to the same media type": {
+			wantCode: http.StatusCreated,
+			accept:   httpjson.MediaTypeJSON,
+			body:     `{"name": "test", "brand": "test", "state": "available"}`,
+		},
+		"unprocessable - retried for another media type": {
+			wantCode: http.StatusUnprocessableEntity,
+			accept:   httpjson.MediaTypeYAML,
+			body:     `{"name": "test", "brand": "test", "state": "available"}`,
+		},
+		"too large - body over the limit": {
+			wantCode: http.StatusRequestEntityTooLarge,
+			accept:   httpjson.MediaTypeJSON,
+			body:     `{"name": "` + strings.Repeat("a", 128) + `", "brand": "test", "state": "available"}`,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var released int
+			s := &mock.DeviceService{
+				CreateDeviceFunc: func(input device.CreateDeviceRequest) (*device.Device, error) {
+					return device.NewDevice(input.Name, input.Brand, input.State), nil
+				},
+			}
+
+			handler := httpjson.NewHandler(
+				s,
+				validator.New(),
+				httpjson.WithIdempotencyService(idempotency.NewService(idempotencyRecords(&released), time.Hour)),
+				httpjson.WithIdempotencyMaxBodySize(100),
+			)
+
+			post := func(accept, body string) *http.Response {
+				return test.DoHttpRequestWithHeaders(
+					handler,
+					http.MethodPost,
+					"/devices",
+					bytes.NewReader([]byte(body)),
+					http.Header{
+						httpjson.HeaderKeyIdempotencyKey: {"key-1"},
+						httpjson.HeaderKeyAccept:         {accept},
+						httpjson.HeaderKeyContentType:    {httpjson.MediaTypeJSON},
+					},
+				)
+			}
+
+			if tc.wantCode != http.StatusRequestEntityTooLarge {
+				if resp := post(httpjson.MediaTypeJSON, tc.body); resp.StatusCode != http.StatusCreated {
+					t.Fatalf("expected status code %d, got: %d", http.StatusCreated, resp.StatusCode)
+				}
+			}
+
+			if resp := post(tc.accept, tc.body); resp.StatusCode != tc.wantCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, resp.StatusCode)
+			}
+		})
+	}
+}
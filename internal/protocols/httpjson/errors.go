@@ -0,0 +1,100 @@
+package httpjson
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hferr/device-manager/internal/api/approval"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/api/idempotency"
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/api/policy"
+	"github.com/hferr/device-manager/internal/api/reservation"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+)
+
+// errorProblems maps the domain errors to the problem reported to clients.
+// The first matching error wins, so specific errors come before the ones they
+// wrap, e.g. device.ErrDeviceInUse before device.ErrConflict.
+var errorProblems = []struct {
+	err error
+	pt  e.ProblemType
+}{
+	{device.ErrNotFound, e.DeviceNotFound},
+	{device.ErrDuplicate, e.DeviceDuplicate},
+	{device.ErrInvalidTransition, e.InvalidTransition},
+	{device.ErrDeviceInUse, e.DeviceInUse},
+	{device.ErrConflict, e.DeviceConflict},
+	{device.ErrVetoed, e.DeviceChangeVetoed},
+
+	{devicestate.ErrNotFound, e.StateNotFound},
+	{devicestate.ErrDuplicate, e.StateDuplicate},
+	{devicestate.ErrStateInUse, e.StateInUse},
+	{devicestate.ErrBuiltinState, e.BuiltinState},
+
+	{reservation.ErrNotFound, e.ReservationNotFound},
+	{reservation.ErrOverlap, e.ReservationOverlap},
+	{reservation.ErrInvalidPeriod, e.InvalidPeriod},
+	{reservation.ErrEnded, e.ReservationEnded},
+
+	{waitlist.ErrNotFound, e.WaitlistEntryNotFound},
+	{waitlist.ErrInvalidTarget, e.InvalidWaitlistTarget},
+	{waitlist.ErrNotOffered, e.WaitlistNotOffered},
+	{waitlist.ErrOfferWithdrawn, e.WaitlistOfferWithdrawn},
+	{waitlist.ErrNotPending, e.WaitlistNotPending},
+
+	{notification.ErrWebhookForbidden, e.WebhookForbidden},
+
+	{approval.ErrNotFound, e.ChangeRequestNotFound},
+	{approval.ErrNotPending, e.ChangeRequestNotPending},
+	{approval.ErrSelfApproval, e.SelfApproval},
+	{approval.ErrApprovalRequired, e.ApprovalRequired},
+
+	{policy.ErrDenied, e.PolicyDenied},
+
+	{organization.ErrNotFound, e.OrganizationNotFound},
+	{organization.ErrOrganizationHasDevices, e.OrganizationHasDevices},
+	{organization.ErrDefaultOrganization, e.DefaultOrganization},
+
+	{idempotency.ErrKeyReused, e.IdempotencyKeyReused},
+	{idempotency.ErrRequestInProgress, e.IdempotencyRequestInProgress},
+}
+
+// problemFor returns the problem type of a domain error.
+func problemFor(err error) (e.ProblemType, bool) {
+	for _, m := range errorProblems {
+		if errors.Is(err, m.err) {
+			return m.pt, true
+		}
+	}
+
+	return e.ProblemType{}, false
+}
+
+// handleError writes the problem of a domain error, or reports any other
+// error as fallback, a server error.
+func (h Handler) handleError(w http.ResponseWriter, r *http.Request, err error, fallback e.ProblemType) {
+	// vetoes and denials tell clients why, e.g. which naming convention the
+	// device breaks or which policy denied the change
+	var veto *device.VetoError
+	if errors.As(err, &veto) {
+		writeProblemDetail(w, r, e.DeviceChangeVetoed, veto.Error())
+		return
+	}
+
+	var denied *policy.DeniedError
+	if errors.As(err, &denied) {
+		writeProblemDetail(w, r, e.PolicyDenied, denied.Error())
+		return
+	}
+
+	if pt, ok := problemFor(err); ok {
+		writeProblem(w, r, pt)
+		return
+	}
+
+	h.serverError(w, r, err, fallback)
+}
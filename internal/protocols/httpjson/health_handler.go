@@ -1,12 +1,55 @@
 package httpjson
 
-import "net/http"
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
 
-// @Summary      Health check
-// @Description  Endpoint to perform a health check on the system
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/health"
+)
+
+// @Summary      Liveness probe
+// @Description  Reports whether the server is running. `GET /health` is kept as an alias.
 // @Tags         Health
-// @Success      200
-// @Router       /health [get]
-func (h Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Router       /health/live [get]
+func (h Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{
+		Status: health.StatusUp,
+		Checks: map[string]health.Result{},
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Readiness probe
+// @Description  Reports whether the server is ready to serve requests: the database is reachable and migrated, the background workers are running and the server is not shutting down.
+// @Tags         Health
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Failure      503  {object}  health.Report
+// @Router       /health/ready [get]
+func (h Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{
+		Status: health.StatusUp,
+		Checks: map[string]health.Result{},
+	}
+	if h.healthChecker != nil {
+		report = h.healthChecker.Check(r.Context())
+	}
+
+	if !report.Up() {
+		h.logger.WarnContext(r.Context(), "readiness check failed", slog.Any("checks", report.Checks))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
 }
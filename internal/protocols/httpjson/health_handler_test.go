@@ -0,0 +1,93 @@
+package httpjson_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/health"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+)
+
+func TestHandlerHealth(t *testing.T) {
+	t.Parallel()
+
+	var testCases = map[string]struct {
+		target         string
+		dbErr          error
+		wantStatusCode int
+		wantStatus     string
+		wantChecks     map[string]string
+	}{
+		"liveness ignores failing checks": {
+			target:         "/health/live",
+			dbErr:          errors.New("connection refused"),
+			wantStatusCode: http.StatusOK,
+			wantStatus:     health.StatusUp,
+			wantChecks:     map[string]string{},
+		},
+		"legacy health check is liveness": {
+			target:         "/health",
+			dbErr:          errors.New("connection refused"),
+			wantStatusCode: http.StatusOK,
+			wantStatus:     health.StatusUp,
+			wantChecks:     map[string]string{},
+		},
+		"ready": {
+			target:         "/health/ready",
+			wantStatusCode: http.StatusOK,
+			wantStatus:     health.StatusUp,
+			wantChecks:     map[string]string{"database": health.StatusUp, "workers": health.StatusUp},
+		},
+		"not ready": {
+			target:         "/health/ready",
+			dbErr:          errors.New("connection refused"),
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantStatus:     health.StatusDown,
+			wantChecks:     map[string]string{"database": health.StatusDown, "workers": health.StatusUp},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := health.NewChecker(time.Second)
+			c.Add("database", func(ctx context.Context) error { return tc.dbErr })
+			c.Add("workers", func(ctx context.Context) error { return nil })
+
+			handler := httpjson.NewHandler(&mock.DeviceService{}, nil, httpjson.WithHealthChecker(c))
+
+			resp := test.DoHttpRequest(handler, http.MethodGet, tc.target, nil)
+			if resp.StatusCode != tc.wantStatusCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantStatusCode, resp.StatusCode)
+			}
+
+			var report health.Report
+			if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+
+			if report.Status != tc.wantStatus {
+				t.Fatalf("expected status %q, got: %q", tc.wantStatus, report.Status)
+			}
+
+			if len(report.Checks) != len(tc.wantChecks) {
+				t.Fatalf("expected %d checks, got: %d", len(tc.wantChecks), len(report.Checks))
+			}
+
+			for name, want := range tc.wantChecks {
+				if got := report.Checks[name].Status; got != want {
+					t.Fatalf("expected check %q to be %q, got: %q", name, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package httpjson
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/utils/logger"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// validRequestID restricts incoming X-Request-ID values to something safe to
+// echo back and log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// middlewareRequestID honours a well-formed incoming X-Request-ID or generates
+// a new one, stores it in the request context and echoes it in the response.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(HeaderKeyRequestID)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(HeaderKeyRequestID, requestID)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// middlewareAccessLog logs every request once it has been served.
+func (h Handler) middlewareAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		// the route pattern is only known once chi has routed the request
+		h.logger.LogAttrs(r.Context(), level, "request served",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routePattern(r)),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// serverError logs the underlying error of a failed request and writes the
+// generic problem to the client.
+func (h Handler) serverError(w http.ResponseWriter, r *http.Request, err error, pt e.ProblemType) {
+	h.logger.ErrorContext(r.Context(), "request failed",
+		slog.String("route", routePattern(r)),
+		slog.Any("error", err),
+	)
+
+	writeProblem(w, r, pt)
+}
+
+// routePattern returns the chi route pattern matched by the request, e.g.
+// /devices/{id}, or unmatchedRoute when no route matched.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return unmatchedRoute
+}
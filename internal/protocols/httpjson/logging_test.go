@@ -0,0 +1,133 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/logger"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestID(t *testing.T) {
+	var testCases = map[string]struct {
+		requestID string
+		wantEcho  bool
+	}{
+		"honours incoming request ID": {
+			requestID: "abc-123",
+			wantEcho:  true,
+		},
+		"generates missing request ID": {
+			requestID: "",
+		},
+		"replaces malformed request ID": {
+			requestID: "bad id\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := httpjson.NewHandler(&mock.DeviceService{}, nil)
+
+			headers := http.Header{}
+			if tc.requestID != "" {
+				headers.Set(httpjson.HeaderKeyRequestID, tc.requestID)
+			}
+
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/health", nil, headers)
+
+			got := resp.Header.Get(httpjson.HeaderKeyRequestID)
+			if tc.wantEcho && got != tc.requestID {
+				t.Fatalf("expected request ID %q, got: %q", tc.requestID, got)
+			}
+
+			if !tc.wantEcho {
+				if _, err := uuid.Parse(got); err != nil {
+					t.Fatalf("expected generated request ID, got: %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := logger.New(buf, logger.FormatJSON, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &mock.DeviceService{
+		FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+			return nil, fmt.Errorf("connection refused")
+		},
+	}
+
+	handler := httpjson.NewHandler(s, nil, httpjson.WithLogger(l))
+	resp := test.DoHttpRequestWithHeaders(
+		handler,
+		http.MethodGet,
+		"/devices/"+uuid.New().String(),
+		nil,
+		http.Header{httpjson.HeaderKeyRequestID: {"req-1"}},
+	)
+
+	// assert the client only gets the generic message
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(body), "connection refused") {
+		t.Fatalf("expected generic error response, got: %s", body)
+	}
+
+	// assert the error and the access log are logged with the request ID
+
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		rec := map[string]any{}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected %d log records, got: %d", 2, len(records))
+	}
+
+	errRec, accessRec := records[0], records[1]
+
+	if errRec["error"] != "connection refused" || errRec["request_id"] != "req-1" {
+		t.Fatalf("expected error log with the underlying error, got: %v", errRec)
+	}
+
+	if accessRec["route"] != "/devices/{id}" {
+		t.Fatalf("expected route %q, got: %v", "/devices/{id}", accessRec["route"])
+	}
+
+	if accessRec["status"] != float64(http.StatusInternalServerError) {
+		t.Fatalf("expected status %d, got: %v", http.StatusInternalServerError, accessRec["status"])
+	}
+
+	if _, ok := accessRec["latency"]; !ok || accessRec["request_id"] != "req-1" {
+		t.Fatalf("expected access log with latency and request ID, got: %v", accessRec)
+	}
+}
@@ -0,0 +1,35 @@
+package httpjson
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute labels requests that matched no route, so scanning for
+// random paths cannot blow up the metrics cardinality.
+const unmatchedRoute = "unmatched"
+
+// middlewareMetrics records the count and latency of every request, labelled
+// by chi route pattern.
+func (h Handler) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.metrics == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		h.metrics.ObserveHTTPRequest(r.Method, routePattern(r), status, time.Since(start))
+	})
+}
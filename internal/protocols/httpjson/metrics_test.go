@@ -0,0 +1,48 @@
+package httpjson_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/metrics"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+
+	"github.com/google/uuid"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	s := &mock.DeviceService{
+		FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+			return device.NewDevice("test", "brand", device.StateAvailable), nil
+		},
+	}
+
+	handler := httpjson.NewHandler(s, nil, httpjson.WithMetrics(metrics.New()))
+
+	test.DoHttpRequest(handler, http.MethodGet, "/devices/"+uuid.New().String(), nil)
+	test.DoHttpRequest(handler, http.MethodGet, "/does-not-exist", nil)
+
+	resp := test.DoHttpRequest(handler, http.MethodGet, "/metrics", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`device_manager_http_requests_total{method="GET",route="/devices/{id}",status="200"} 1`,
+		`device_manager_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected metrics to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/api/idempotency"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+// middlewareAuthenticate stores the principal resolved by the handler's
+// authenticator in the request context.
+func (h Handler) middlewareAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.authenticator == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := h.authenticator.Authenticate(r)
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "authentication failed", slog.Any("error", err))
+			writeProblem(w, r, e.Unauthorized)
+			return
+		}
+
+		if p != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middlewareTenant resolves the tenant of the request, in order, from the
+// X-Tenant-ID header, the principal and the handler default. Admins may
+// address any tenant, other principals only their own, and anonymous or
+// tenant-less callers only the default tenant.
+func (h Handler) middlewareTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.FromContext(r.Context())
+
+		tenantID := h.defaultTenantID
+		if p != nil && p.TenantID != uuid.Nil {
+			tenantID = p.TenantID
+		}
+
+		if v := r.Header.Get(HeaderKeyTenantID); v != "" {
+			ID, err := uuid.Parse(v)
+			if err != nil {
+				writeProblem(w, r, e.InvalidTenant)
+				return
+			}
+
+			if ID != tenantID && !p.IsAdmin() {
+				writeProblem(w, r, e.TenantForbidden)
+				return
+			}
+			tenantID = ID
+		}
+
+		if tenantID == uuid.Nil {
+			writeProblem(w, r, e.MissingTenant)
+			return
+		}
+
+		if h.organizationSvs != nil {
+			if _, err := h.organizationSvs.FindByID(r.Context(), tenantID); err != nil {
+				if errors.Is(err, organization.ErrNotFound) {
+					writeProblem(w, r, e.TenantNotFound)
+					return
+				}
+
+				h.serverError(w, r, err, e.OrganizationServiceFailed)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(organization.WithTenant(r.Context(), tenantID)))
+	})
+}
+
+func middlewareRequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.FromContext(r.Context()); !ok || !p.IsAdmin() {
+			writeProblem(w, r, e.Forbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxIdempotencyKeyLen matches the size of the idempotency_keys.key column.
+const maxIdempotencyKeyLen = 255
+
+// DefaultIdempotencyMaxBodySize bounds the bodies of the requests made with an
+// Idempotency-Key, which are buffered to be fingerprinted.
+const DefaultIdempotencyMaxBodySize = 1 << 20
+
+// middlewareIdempotency replays the stored response of a request retried with
+// the same Idempotency-Key instead of executing it again. Keys are scoped to
+// the tenant and principal of the request, and released when the request
+// fails, so that it can be retried.
+func (h Handler) middlewareIdempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderKeyIdempotencyKey)
+		if h.idempotencySvs == nil || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			writeProblem(w, r, e.InvalidIdempotencyKey)
+			return
+		}
+
+		limit := h.idempotencyMaxBodySize
+		if limit <= 0 {
+			limit = DefaultIdempotencyMaxBodySize
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeProblem(w, r, e.IdempotentBodyTooLarge)
+				return
+			}
+
+			writeProblem(w, r, e.MalformedBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := idempotencyScope(r.Context())
+		fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, responseMediaType(r), body)
+
+		rec, replay, err := h.idempotencySvs.Reserve(r.Context(), scope, key, fingerprint)
+		if err != nil {
+			h.handleError(w, r, err, e.IdempotencyServiceFailed)
+			return
+		}
+
+		if replay {
+			if rec.ContentType != "" {
+				w.Header().Set(HeaderKeyContentType, rec.ContentType)
+			}
+			w.Header().Set(HeaderKeyIdempotentReplayed, "true")
+			w.WriteHeader(rec.StatusCode)
+			w.Write(rec.ResponseBody)
+			return
+		}
+
+		// a panicking handler does not hold the key until it expires
+		defer func() {
+			if v := recover(); v != nil {
+				h.releaseIdempotencyKey(r.Context(), rec)
+				panic(v)
+			}
+		}()
+
+		rw := &recordingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		// server errors are not stored so the client can retry with the same key
+		if rw.statusCode >= http.StatusInternalServerError {
+			h.releaseIdempotencyKey(r.Context(), rec)
+			return
+		}
+
+		rec.StatusCode = rw.statusCode
+		rec.ContentType = w.Header().Get(HeaderKeyContentType)
+		rec.ResponseBody = rw.body.Bytes()
+		if err := h.idempotencySvs.Complete(context.WithoutCancel(r.Context()), rec); err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to store idempotent response", slog.Any("error", err))
+			// retries execute the request again rather than wait for the
+			// key to expire
+			h.releaseIdempotencyKey(r.Context(), rec)
+		}
+	})
+}
+
+// releaseIdempotencyKey releases the key of rec, even if the request was
+// cancelled, logging failures.
+func (h Handler) releaseIdempotencyKey(ctx context.Context, rec *idempotency.Record) {
+	if err := h.idempotencySvs.Release(context.WithoutCancel(ctx), rec); err != nil {
+		h.logger.ErrorContext(ctx, "failed to release idempotency key", slog.Any("error", err))
+	}
+}
+
+func idempotencyScope(ctx context.Context) string {
+	scope := ""
+	if tenantID, err := organization.TenantFromContext(ctx); err == nil {
+		scope = tenantID.String()
+	}
+
+	if p, ok := auth.FromContext(ctx); ok {
+		scope += ":" + p.Subject
+	}
+
+	return scope
+}
+
+// recordingResponseWriter keeps a copy of the status code and body written
+// through it.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(statusCode int) {
+	if !rw.wroteHeader {
+		rw.statusCode = statusCode
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,395 @@
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	e "github.com/hferr/device-manager/internal/api/err"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeNDJSON  = "application/x-ndjson"
+	MediaTypeCSV     = "text/csv"
+	MediaTypeYAML    = "application/yaml"
+	MediaTypeMsgPack = "application/msgpack"
+
+	HeaderKeyAccept = "Accept"
+	HeaderKeyVary   = "Vary"
+)
+
+var errCSVRows = errors.New("csv body must hold a header and a single row")
+
+// codec renders device DTOs, and reads request bodies, in a media type.
+type codec struct {
+	mediaType   string
+	contentType string
+	// aliases are other media types clients use for the same format.
+	aliases []string
+	// encode writes v, a device DTO or a list of them.
+	encode func(w io.Writer, v any) error
+	// decode reads a single request object into v.
+	decode func(r io.Reader, v any) error
+}
+
+// codecs are the supported formats, in order of preference when the client
+// accepts several of them equally.
+var codecs = []*codec{
+	{
+		mediaType:   MediaTypeJSON,
+		contentType: HeaderValueContentTypeJSON,
+		encode:      func(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) },
+		decode:      func(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) },
+	},
+	{
+		mediaType:   MediaTypeNDJSON,
+		contentType: MediaTypeNDJSON,
+		aliases:     []string{"application/jsonl"},
+		encode:      encodeNDJSON,
+		decode:      func(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) },
+	},
+	{
+		mediaType:   MediaTypeCSV,
+		contentType: MediaTypeCSV + ";charset=utf-8",
+		encode:      encodeCSV,
+		decode:      decodeCSV,
+	},
+	{
+		mediaType:   MediaTypeYAML,
+		contentType: MediaTypeYAML,
+		aliases:     []string{"application/x-yaml", "text/yaml"},
+		encode:      func(w io.Writer, v any) error { return yaml.NewEncoder(w).Encode(v) },
+		decode:      func(r io.Reader, v any) error { return yaml.NewDecoder(r).Decode(v) },
+	},
+	{
+		mediaType:   MediaTypeMsgPack,
+		contentType: MediaTypeMsgPack,
+		aliases:     []string{"application/x-msgpack", "application/vnd.msgpack"},
+		encode:      encodeMsgPack,
+		decode:      decodeMsgPack,
+	},
+}
+
+func (c *codec) matches(mediaType string) bool {
+	return c.mediaType == mediaType || slices.Contains(c.aliases, mediaType)
+}
+
+type codecCtxKey struct {
+	request bool
+}
+
+// middlewareNegotiate picks the codec of the response from the Accept header
+// and, for requests with a body, the codec of the request from the
+// Content-Type header, answering 406 or 415 when no supported format fits.
+func (h Handler) middlewareNegotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// only the responses of these methods have a body other than a problem
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodPost {
+			w.Header().Add(HeaderKeyVary, HeaderKeyAccept)
+
+			res, ok := negotiate(r.Header.Get(HeaderKeyAccept))
+			if !ok {
+				writeProblem(w, r, e.NotAcceptable)
+				return
+			}
+			ctx = context.WithValue(ctx, codecCtxKey{}, res)
+		}
+
+		if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+			req, ok := requestCodec(r.Header.Get(HeaderKeyContentType))
+			if !ok {
+				writeProblem(w, r, e.UnsupportedMediaType)
+				return
+			}
+			ctx = context.WithValue(ctx, codecCtxKey{request: true}, req)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// responseMediaType returns the media type of the response picked by
+// middlewareNegotiate, empty outside of negotiated routes.
+func responseMediaType(r *http.Request) string {
+	if c, ok := r.Context().Value(codecCtxKey{}).(*codec); ok {
+		return c.mediaType
+	}
+
+	return ""
+}
+
+// negotiate returns the codec best matching an Accept header, JSON when the
+// header is empty.
+func negotiate(accept string) (*codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0], true
+	}
+
+	ranges := parseAccept(accept)
+
+	var (
+		best  *codec
+		bestQ float64
+	)
+	for _, c := range codecs {
+		if q := c.quality(ranges); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+
+	return best, best != nil
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	return ranges
+}
+
+// quality returns the weight the client gives to the codec, taken from the
+// most specific range matching one of its media types.
+func (c *codec) quality(ranges []mediaRange) float64 {
+	q, specificity := 0.0, -1
+	for _, mediaType := range append([]string{c.mediaType}, c.aliases...) {
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+
+		for _, mr := range ranges {
+			var s int
+			switch {
+			case mr.typ == typ && mr.subtype == subtype:
+				s = 2
+			case mr.typ == typ && mr.subtype == "*":
+				s = 1
+			case mr.typ == "*" && mr.subtype == "*":
+				s = 0
+			default:
+				continue
+			}
+
+			if s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+	}
+
+	return q
+}
+
+// requestCodec returns the codec of a Content-Type header, JSON when the
+// header is empty.
+func requestCodec(contentType string) (*codec, bool) {
+	if contentType == "" {
+		return codecs[0], true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, c := range codecs {
+		if c.matches(mediaType) {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+// decode reads the request body with the codec picked by middlewareNegotiate,
+// or as JSON outside of negotiated routes.
+func decode(r *http.Request, v any) error {
+	c, ok := r.Context().Value(codecCtxKey{request: true}).(*codec)
+	if !ok {
+		c = codecs[0]
+	}
+
+	return c.decode(r.Body, v)
+}
+
+// respond writes v with the codec picked by middlewareNegotiate, or as JSON
+// outside of negotiated routes. The body is encoded before the status is
+// written, so encoding failures are still reported as server errors.
+func (h Handler) respond(w http.ResponseWriter, r *http.Request, status int, v any) {
+	c, ok := r.Context().Value(codecCtxKey{}).(*codec)
+	if !ok {
+		c = codecs[0]
+	}
+
+	var buf bytes.Buffer
+	if err := c.encode(&buf, v); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+
+	w.Header().Set(HeaderKeyContentType, c.contentType)
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// dtoList returns v, a device DTO or a list of them, as a list.
+func dtoList(v any) ([]*device.DTO, error) {
+	switch dto := v.(type) {
+	case *device.DTO:
+		return []*device.DTO{dto}, nil
+	case []*device.DTO:
+		return dto, nil
+	default:
+		return nil, fmt.Errorf("cannot encode %T", v)
+	}
+}
+
+func encodeNDJSON(w io.Writer, v any) error {
+	dtos, err := dtoList(v)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	for _, dto := range dtos {
+		if err := enc.Encode(dto); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var csvHeader = []string{"id", "tenant_id", "name", "brand", "state", "created_at"}
+
+func encodeCSV(w io.Writer, v any) error {
+	dtos, err := dtoList(v)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, dto := range dtos {
+		err := cw.Write([]string{
+			dto.ID.String(),
+			dto.TenantID.String(),
+			csvCell(dto.Name),
+			csvCell(dto.Brand),
+			csvCell(dto.State),
+			dto.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell prefixes the values spreadsheets would evaluate as formulas with a
+// quote, so that exported devices cannot run formulas when opened.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+// decodeCSV reads a header and a single row, the columns naming the JSON
+// fields of v. Only the columns present are set, as with a JSON object.
+func decodeCSV(r io.Reader, v any) error {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	if len(rows) != 2 {
+		return errCSVRows
+	}
+
+	fields := make(map[string]string, len(rows[0]))
+	for i, column := range rows[0] {
+		fields[column] = rows[1][i]
+	}
+
+	return viaJSON(fields, v)
+}
+
+// MessagePack bodies mirror the JSON ones, so IDs are strings rather than the
+// binary form of uuid.UUID.
+func encodeMsgPack(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var generic any
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return err
+	}
+
+	enc := msgpack.NewEncoder(w)
+	enc.SetSortMapKeys(true)
+	return enc.Encode(generic)
+}
+
+func decodeMsgPack(r io.Reader, v any) error {
+	var generic map[string]any
+	if err := msgpack.NewDecoder(r).Decode(&generic); err != nil {
+		return err
+	}
+
+	return viaJSON(generic, v)
+}
+
+// viaJSON decodes a generic object into v following its JSON tags.
+func viaJSON(generic any, v any) error {
+	b, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
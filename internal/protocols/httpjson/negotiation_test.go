@@ -0,0 +1,384 @@
+package httpjson_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+func TestHandlerNegotiateResponse(t *testing.T) {
+	ds := device.Devices{
+		device.NewDevice("test1", "brand1", device.StateAvailable),
+		device.NewDevice("test2", "brand2", device.StateInUse),
+	}
+
+	// decoders return the names of the devices in a response body
+	decodeJSON := func(body []byte) ([]string, error) {
+		var dtos []device.DTO
+		err := json.Unmarshal(body, &dtos)
+		return dtoNames(dtos), err
+	}
+
+	decodeNDJSON := func(body []byte) ([]string, error) {
+		var names []string
+		s := bufio.NewScanner(bytes.NewReader(body))
+		for s.Scan() {
+			var dto device.DTO
+			if err := json.Unmarshal(s.Bytes(), &dto); err != nil {
+				return nil, err
+			}
+			names = append(names, dto.Name)
+		}
+		return names, s.Err()
+	}
+
+	decodeCSV := func(body []byte) ([]string, error) {
+		rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for _, row := range rows[1:] {
+			names = append(names, row[2])
+		}
+		return names, nil
+	}
+
+	decodeYAML := func(body []byte) ([]string, error) {
+		var dtos []device.DTO
+		err := yaml.Unmarshal(body, &dtos)
+		return dtoNames(dtos), err
+	}
+
+	decodeMsgPack := func(body []byte) ([]string, error) {
+		var objs []map[string]any
+		if err := msgpack.Unmarshal(body, &objs); err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for _, obj := range objs {
+			if _, err := uuid.Parse(obj["id"].(string)); err != nil {
+				return nil, err
+			}
+			names = append(names, obj["name"].(string))
+		}
+		return names, nil
+	}
+
+	var testCases = map[string]struct {
+		accept          string
+		wantContentType string
+		decode          func([]byte) ([]string, error)
+	}{
+		"no accept header": {
+			wantContentType: httpjson.HeaderValueContentTypeJSON,
+			decode:          decodeJSON,
+		},
+		"any media type": {
+			accept:          "*/*",
+			wantContentType: httpjson.HeaderValueContentTypeJSON,
+			decode:          decodeJSON,
+		},
+		"ndjson": {
+			accept:          httpjson.MediaTypeNDJSON,
+			wantContentType: httpjson.MediaTypeNDJSON,
+			decode:          decodeNDJSON,
+		},
+		"csv": {
+			accept:          "text/csv",
+			wantContentType: "text/csv;charset=utf-8",
+			decode:          decodeCSV,
+		},
+		"yaml alias": {
+			accept:          "application/x-yaml",
+			wantContentType: httpjson.MediaTypeYAML,
+			decode:          decodeYAML,
+		},
+		"msgpack": {
+			accept:          httpjson.MediaTypeMsgPack,
+			wantContentType: httpjson.MediaTypeMsgPack,
+			decode:          decodeMsgPack,
+		},
+		"highest quality wins": {
+			accept:          "application/json;q=0.5, text/csv;q=0.9, application/xml",
+			wantContentType: "text/csv;charset=utf-8",
+			decode:          decodeCSV,
+		},
+		"specific range overrides wildcard": {
+			accept:          "application/*;q=0.2, application/yaml;q=0.8, application/json;q=0",
+			wantContentType: httpjson.MediaTypeYAML,
+			decode:          decodeYAML,
+		},
+	}
+
+	s := &mock.DeviceService{
+		ListDevicesFunc: func() (device.Devices, error) {
+			return ds, nil
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := httpjson.NewHandler(s, nil)
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/devices", nil, http.Header{
+				httpjson.HeaderKeyAccept: {tc.accept},
+			})
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status code %d, got: %d", http.StatusOK, resp.StatusCode)
+			}
+
+			if ct := resp.Header.Get(httpjson.HeaderKeyContentType); ct != tc.wantContentType {
+				t.Fatalf("expected content type %q, got: %q", tc.wantContentType, ct)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names, err := tc.decode(body)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if strings.Join(names, ",") != "test1,test2" {
+				t.Fatalf("expected devices %q, got: %q", "test1,test2", names)
+			}
+		})
+	}
+
+	// assert unsupported media types are not acceptable
+
+	handler := httpjson.NewHandler(s, nil)
+	resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/devices", nil, http.Header{
+		httpjson.HeaderKeyAccept: {"application/xml, text/html;q=0.9, application/json;q=0"},
+	})
+
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Fatalf("expected status code %d, got: %d", http.StatusNotAcceptable, resp.StatusCode)
+	}
+
+	if ct := resp.Header.Get(httpjson.HeaderKeyContentType); ct != e.ContentTypeProblemJSON {
+		t.Fatalf("expected content type %q, got: %q", e.ContentTypeProblemJSON, ct)
+	}
+}
+
+func TestHandlerNegotiateSingleDevice(t *testing.T) {
+	d := device.NewDevice("test", "test", device.StateAvailable)
+	s := &mock.DeviceService{
+		FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+			return d, nil
+		},
+	}
+
+	handler := httpjson.NewHandler(s, nil)
+	resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/devices/"+d.ID.String(), nil, http.Header{
+		httpjson.HeaderKeyAccept: {"text/csv"},
+	})
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	// assert a single device is rendered as a header and a row
+
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(rows) != 2 || rows[1][0] != d.ID.String() {
+		t.Fatalf("expected header and device row, got: %v", rows)
+	}
+}
+
+func TestHandlerNegotiateCSVFormulas(t *testing.T) {
+	testCases := map[string]struct {
+		name     string
+		wantName string
+	}{
+		"equals":     {"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		"plus":       {"+1+1", "'+1+1"},
+		"minus":      {"-2+3", "'-2+3"},
+		"at":         {"@SUM(A1)", "'@SUM(A1)"},
+		"plain name": {"Laptop - 14", "Laptop - 14"},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := device.NewDevice(tc.name, "test", device.StateAvailable)
+			s := &mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return d, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(s, nil)
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/devices/"+d.ID.String(), nil, http.Header{
+				httpjson.HeaderKeyAccept: {"text/csv"},
+			})
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status code %d, got: %d", http.StatusOK, resp.StatusCode)
+			}
+
+			// assert cells starting a formula are quoted
+
+			rows, err := csv.NewReader(resp.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if len(rows) != 2 || rows[1][2] != tc.wantName {
+				t.Fatalf("expected name %q, got: %v", tc.wantName, rows)
+			}
+		})
+	}
+}
+
+func TestHandlerNegotiateRequest(t *testing.T) {
+	msgpackBody, err := msgpack.Marshal(map[string]string{
+		"name":  "test",
+		"brand": "acme",
+		"state": device.StateAvailable,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = map[string]struct {
+		contentType string
+		body        []byte
+		wantCode    int
+	}{
+		"no content type": {
+			body:     []byte(`{"name": "test", "brand": "acme", "state": "available"}`),
+			wantCode: http.StatusCreated,
+		},
+		"json with charset": {
+			contentType: "application/json; charset=utf-8",
+			body:        []byte(`{"name": "test", "brand": "acme", "state": "available"}`),
+			wantCode:    http.StatusCreated,
+		},
+		"yaml": {
+			contentType: httpjson.MediaTypeYAML,
+			body:        []byte("name: test\nbrand: acme\nstate: available\n"),
+			wantCode:    http.StatusCreated,
+		},
+		"csv": {
+			contentType: httpjson.MediaTypeCSV,
+			body:        []byte("name,brand,state\ntest,acme,available\n"),
+			wantCode:    http.StatusCreated,
+		},
+		"msgpack": {
+			contentType: httpjson.MediaTypeMsgPack,
+			body:        msgpackBody,
+			wantCode:    http.StatusCreated,
+		},
+		"csv with several rows": {
+			contentType: httpjson.MediaTypeCSV,
+			body:        []byte("name,brand,state\ntest,acme,available\ntest,acme,available\n"),
+			wantCode:    http.StatusBadRequest,
+		},
+		"invalid yaml fields are validated": {
+			contentType: httpjson.MediaTypeYAML,
+			body:        []byte("name: test\nbrand: acme\nstate: broken\n"),
+			wantCode:    http.StatusUnprocessableEntity,
+		},
+		"unsupported media type": {
+			contentType: "application/xml",
+			body:        []byte("<device/>"),
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &mock.DeviceService{
+				CreateDeviceFunc: func(input device.CreateDeviceRequest) (*device.Device, error) {
+					if input.Name != "test" || input.Brand != "acme" || input.State != device.StateAvailable {
+						t.Errorf("expected decoded request, got: %+v", input)
+					}
+					return device.NewDevice(input.Name, input.Brand, input.State), nil
+				},
+			}
+
+			headers := http.Header{}
+			if tc.contentType != "" {
+				headers.Set(httpjson.HeaderKeyContentType, tc.contentType)
+			}
+
+			handler := httpjson.NewHandler(s, v)
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodPost, "/devices", bytes.NewReader(tc.body), headers)
+
+			if resp.StatusCode != tc.wantCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, resp.StatusCode)
+			}
+		})
+	}
+
+	// assert partial updates only set the fields present
+
+	var got device.UpdateDeviceRequest
+	s := &mock.DeviceService{
+		UpdateDeviceFunc: func(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+			got = input
+			return nil
+		},
+	}
+
+	handler := httpjson.NewHandler(s, v)
+	resp := test.DoHttpRequestWithHeaders(handler, http.MethodPatch, "/devices/"+uuid.NewString(), strings.NewReader("state\nin_use\n"), http.Header{
+		httpjson.HeaderKeyContentType: {httpjson.MediaTypeCSV},
+	})
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status code %d, got: %d", http.StatusNoContent, resp.StatusCode)
+	}
+
+	if got.Name != nil || got.Brand != nil || got.State == nil || *got.State != device.StateInUse {
+		t.Fatalf("expected only the state to be set, got: %+v", got)
+	}
+}
+
+func dtoNames(dtos []device.DTO) []string {
+	names := make([]string, len(dtos))
+	for i, dto := range dtos {
+		names[i] = dto.Name
+	}
+
+	return names
+}
@@ -0,0 +1,151 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/api/notification"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// defaultNotificationsLimit and maxNotificationsLimit bound the number of
+	// notifications listed at once.
+	defaultNotificationsLimit = 100
+	maxNotificationsLimit     = 1000
+)
+
+// @Summary      List notifications
+// @Description  Get the log of the notifications, newest first, optionally only those of a
+// @Description  recipient, topic or status. Principals other than admins only get theirs.
+// @Tags         notifications
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        recipient    query   string  false  "Recipient"
+// @Param        topic        query   string  false  "Topic"
+// @Param        status       query   string  false  "Status"  Enums(queued, sending, sent, failed, skipped)
+// @Param        limit        query   int     false  "Maximum number of notifications, 100 by default, up to 1000"
+// @Success      200  {array}   notification.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /notifications [get]
+func (h Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := notification.Filter{
+		Recipient: q.Get("recipient"),
+		Topic:     q.Get("topic"),
+		Status:    q.Get("status"),
+		Limit:     defaultNotificationsLimit,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxNotificationsLimit {
+			writeProblem(w, r, e.InvalidQuery)
+			return
+		}
+		f.Limit = limit
+	}
+
+	if p, ok := auth.FromContext(r.Context()); ok && !p.IsAdmin() {
+		f.Recipient = p.Subject
+	}
+
+	ns, err := h.notificationSvs.ListNotifications(r.Context(), f)
+	if err != nil {
+		h.handleError(w, r, err, e.NotificationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(ns.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Get notification preferences
+// @Description  Get how a recipient is notified. Principals other than admins only get theirs.
+// @Tags         notifications
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        recipient  path      string  true  "Recipient"
+// @Success      200        {object}  notification.PreferenceDTO
+// @Failure      403        {object}  err.Problem
+// @Failure      500        {object}  err.Problem
+// @Router       /notifications/preferences/{recipient} [get]
+func (h Handler) FindNotificationPreference(w http.ResponseWriter, r *http.Request) {
+	recipient := chi.URLParam(r, "recipient")
+	if !canActFor(r, recipient) {
+		writeProblem(w, r, e.Forbidden)
+		return
+	}
+
+	p, err := h.notificationSvs.FindPreference(r.Context(), recipient)
+	if err != nil {
+		h.handleError(w, r, err, e.NotificationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(p.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Update notification preferences
+// @Description  Replace how a recipient is notified: by email and/or webhook, enabled by
+// @Description  setting their address, and right away or in digests. Principals other than
+// @Description  admins only update theirs, and anonymous callers none.
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        recipient   path      string                                    true  "Recipient"
+// @Param        preference  body      notification.UpdatePreferenceRequest  true  "Update preference request object"
+// @Success      200         {object}  notification.PreferenceDTO
+// @Failure      400         {object}  err.Problem
+// @Failure      401         {object}  err.Problem
+// @Failure      403         {object}  err.Problem
+// @Failure      422         {object}  err.Problem
+// @Failure      500         {object}  err.Problem
+// @Router       /notifications/preferences/{recipient} [put]
+func (h Handler) UpdateNotificationPreference(w http.ResponseWriter, r *http.Request) {
+	// the preferences choose where notifications are sent, so they are never
+	// changed anonymously
+	if _, ok := auth.FromContext(r.Context()); !ok {
+		writeProblem(w, r, e.Unauthorized)
+		return
+	}
+
+	recipient := chi.URLParam(r, "recipient")
+	if !canActFor(r, recipient) {
+		writeProblem(w, r, e.Forbidden)
+		return
+	}
+
+	input := notification.UpdatePreferenceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	p, err := h.notificationSvs.UpdatePreference(r.Context(), recipient, input)
+	if err != nil {
+		h.handleError(w, r, err, e.NotificationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(p.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
@@ -0,0 +1,205 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+)
+
+func TestHandlerListNotifications(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode   int
+		wantFilter notification.Filter
+		query      string
+		headers    http.Header
+		listErr    error
+	}{
+		"successfully lists every notification": {
+			wantCode:   http.StatusOK,
+			wantFilter: notification.Filter{Limit: 100},
+		},
+		"successfully lists filtered notifications": {
+			wantCode:   http.StatusOK,
+			wantFilter: notification.Filter{Recipient: "bob", Topic: "device.deleted", Status: notification.StatusSent, Limit: 10},
+			query:      "?recipient=bob&topic=device.deleted&status=sent&limit=10",
+		},
+		"admin lists notifications of others": {
+			wantCode:   http.StatusOK,
+			wantFilter: notification.Filter{Recipient: "bob", Limit: 100},
+			query:      "?recipient=bob",
+			headers:    http.Header{auth.HeaderKeySubject: {"alice"}, auth.HeaderKeyRole: {auth.RoleAdmin}},
+		},
+		"principal only lists theirs": {
+			wantCode:   http.StatusOK,
+			wantFilter: notification.Filter{Recipient: "alice", Limit: 100},
+			query:      "?recipient=bob",
+			headers:    http.Header{auth.HeaderKeySubject: {"alice"}},
+		},
+		"bad request - invalid limit": {
+			wantCode: http.StatusBadRequest,
+			query:    "?limit=0",
+		},
+		"bad request - limit too large": {
+			wantCode: http.StatusBadRequest,
+			query:    "?limit=1001",
+		},
+		"internal server error": {
+			wantCode:   http.StatusInternalServerError,
+			wantFilter: notification.Filter{Limit: 100},
+			listErr:    errors.New("boom"),
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotFilter notification.Filter
+			s := mock.NotificationService{
+				ListNotificationsFunc: func(f notification.Filter) (notification.Notifications, error) {
+					gotFilter = f
+					return notification.Notifications{}, tc.listErr
+				},
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithNotificationService(&s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodGet,
+				"/notifications"+tc.query,
+				nil,
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if gotFilter != tc.wantFilter {
+				t.Fatalf("expected filter %+v, got: %+v", tc.wantFilter, gotFilter)
+			}
+		})
+	}
+}
+
+func TestHandlerUpdateNotificationPreference(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode  int
+		body      string
+		headers   http.Header
+		updateErr error
+	}{
+		"successfully updates preference": {
+			wantCode: http.StatusOK,
+			body:     `{"email": "bob@example.com", "webhook_url": "https://hooks.example.com", "digest": true}`,
+			headers:  http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"admin updates those of others": {
+			wantCode: http.StatusOK,
+			body:     `{"email": "bob@example.com"}`,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}, auth.HeaderKeyRole: {auth.RoleAdmin}},
+		},
+		"unauthorized - anonymous": {
+			wantCode: http.StatusUnauthorized,
+			body:     `{"email": "bob@example.com"}`,
+		},
+		"forbidden - preference of another recipient": {
+			wantCode: http.StatusForbidden,
+			body:     `{"email": "bob@example.com"}`,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}},
+		},
+		"bad request - malformed body": {
+			wantCode: http.StatusBadRequest,
+			body:     `{"email": 1}`,
+			headers:  http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"unprocessable entity - invalid email": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     `{"email": "bob"}`,
+			headers:  http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"unprocessable entity - invalid webhook url": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     `{"webhook_url": "hooks.example.com"}`,
+			headers:  http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"unprocessable entity - webhook url of a private host": {
+			wantCode:  http.StatusUnprocessableEntity,
+			body:      `{"webhook_url": "http://10.0.0.1/hooks"}`,
+			headers:   http.Header{auth.HeaderKeySubject: {"bob"}},
+			updateErr: notification.ErrWebhookForbidden,
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := mock.NotificationService{
+				UpdatePreferenceFunc: func(recipient string, input notification.UpdatePreferenceRequest) (*notification.Preference, error) {
+					if tc.updateErr != nil {
+						return nil, tc.updateErr
+					}
+					return &notification.Preference{Recipient: recipient, Email: input.Email, WebhookURL: input.WebhookURL, Digest: input.Digest}, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithNotificationService(&s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodPut,
+				"/notifications/preferences/bob",
+				bytes.NewReader([]byte(tc.body)),
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if gotCode != http.StatusOK {
+				return
+			}
+
+			var p notification.PreferenceDTO
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if p.Recipient != "bob" || p.Email != "bob@example.com" {
+				t.Fatalf("expected the preference of bob, got: %+v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// @Summary      List all organizations
+// @Description  Get a list of all organizations (tenants), admin only
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   organization.DTO
+// @Failure      403  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /admin/organizations [get]
+func (h Handler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	os, err := h.organizationSvs.ListOrganizations(r.Context())
+	if err != nil {
+		h.handleError(w, r, err, e.OrganizationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(os.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Create a new organization
+// @Description  Create a new organization (tenant), admin only
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request replay the original response"
+// @Param        organization  body      organization.CreateOrganizationRequest  true  "Create organization request object"
+// @Success      201           {object}  organization.DTO
+// @Failure      400           {object}  err.Problem
+// @Failure      403           {object}  err.Problem
+// @Failure      409           {object}  err.Problem
+// @Failure      413           {object}  err.Problem
+// @Failure      422           {object}  err.Problem
+// @Failure      500           {object}  err.Problem
+// @Router       /admin/organizations [post]
+func (h Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	input := organization.CreateOrganizationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	o, err := h.organizationSvs.CreateOrganization(r.Context(), input)
+	if err != nil {
+		h.handleError(w, r, err, e.OrganizationServiceFailed)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(o.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Get organization by ID
+// @Description  Get a single organization (tenant) by its ID, admin only
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "Organization ID"
+// @Success      200  {object}  organization.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      403  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /admin/organizations/{id} [get]
+func (h Handler) FindOrganizationByID(w http.ResponseWriter, r *http.Request) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	o, err := h.organizationSvs.FindByID(r.Context(), ID)
+	if err != nil {
+		h.handleError(w, r, err, e.OrganizationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(o.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Delete an organization
+// @Description  Delete an organization (tenant) by its ID, admin only. Organizations
+// @Description  that still own devices and the default organization cannot be deleted.
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "Organization ID"
+// @Success      204
+// @Failure      400  {object}  err.Problem
+// @Failure      403  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      422  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /admin/organizations/{id} [delete]
+func (h Handler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	if err := h.organizationSvs.DeleteOrganization(r.Context(), ID); err != nil {
+		h.handleError(w, r, err, e.OrganizationServiceFailed)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary      List devices of an organization
+// @Description  Get all devices owned by the given organization (tenant), admin only
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "Organization ID"
+// @Success      200  {array}   device.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      403  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /admin/organizations/{id}/devices [get]
+func (h Handler) ListOrganizationDevices(w http.ResponseWriter, r *http.Request) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	if _, err := h.organizationSvs.FindByID(r.Context(), ID); err != nil {
+		h.handleError(w, r, err, e.OrganizationServiceFailed)
+		return
+	}
+
+	ds, err := h.deviceSvs.ListDevices(organization.WithTenant(r.Context(), ID))
+	if err != nil {
+		h.handleError(w, r, err, e.DeviceServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(ds.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
@@ -0,0 +1,331 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+)
+
+var adminHeaders = http.Header{
+	auth.HeaderKeySubject: {"admin"},
+	auth.HeaderKeyRole:    {auth.RoleAdmin},
+}
+
+func TestHandlerCreateOrganization(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		headers  http.Header
+		input    organization.CreateOrganizationRequest
+		s        mock.OrganizationService
+	}{
+		"successfully calls organization service": {
+			wantCode: http.StatusCreated,
+			headers:  adminHeaders,
+			input:    organization.CreateOrganizationRequest{Name: "test"},
+			s: mock.OrganizationService{
+				CreateOrganizationFunc: func(input organization.CreateOrganizationRequest) (*organization.Organization, error) {
+					return organization.NewOrganization(input.Name), nil
+				},
+			},
+		},
+		"forbidden - not an admin": {
+			wantCode: http.StatusForbidden,
+			headers: http.Header{
+				auth.HeaderKeySubject: {"user"},
+			},
+			input: organization.CreateOrganizationRequest{Name: "test"},
+			s:     mock.OrganizationService{},
+		},
+		"forbidden - anonymous": {
+			wantCode: http.StatusForbidden,
+			input:    organization.CreateOrganizationRequest{Name: "test"},
+			s:        mock.OrganizationService{},
+		},
+		"unprocessable entity - no name provided": {
+			wantCode: http.StatusUnprocessableEntity,
+			headers:  adminHeaders,
+			input:    organization.CreateOrganizationRequest{},
+			s:        mock.OrganizationService{},
+		},
+		"service returns error": {
+			wantCode: http.StatusInternalServerError,
+			headers:  adminHeaders,
+			input:    organization.CreateOrganizationRequest{Name: "test"},
+			s: mock.OrganizationService{
+				CreateOrganizationFunc: func(input organization.CreateOrganizationRequest) (*organization.Organization, error) {
+					return nil, fmt.Errorf("boom")
+				},
+			},
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reqJson, err := json.Marshal(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithOrganizationService(&tc.s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodPost,
+				"/admin/organizations",
+				bytes.NewReader(reqJson),
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+		})
+	}
+}
+
+func TestHandlerDeleteOrganization(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		s        mock.OrganizationService
+	}{
+		"successfully deletes organization": {
+			wantCode: http.StatusNoContent,
+			s: mock.OrganizationService{
+				DeleteOrganizationFunc: func(ID uuid.UUID) error {
+					return nil
+				},
+			},
+		},
+		"organization not found error": {
+			wantCode: http.StatusNotFound,
+			s: mock.OrganizationService{
+				DeleteOrganizationFunc: func(ID uuid.UUID) error {
+					return organization.ErrNotFound
+				},
+			},
+		},
+		"organization has devices error": {
+			wantCode: http.StatusUnprocessableEntity,
+			s: mock.OrganizationService{
+				DeleteOrganizationFunc: func(ID uuid.UUID) error {
+					return organization.ErrOrganizationHasDevices
+				},
+			},
+		},
+		"service returns error": {
+			wantCode: http.StatusInternalServerError,
+			s: mock.OrganizationService{
+				DeleteOrganizationFunc: func(ID uuid.UUID) error {
+					return fmt.Errorf("boom")
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				nil,
+				httpjson.WithOrganizationService(&tc.s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodDelete,
+				"/admin/organizations/"+uuid.New().String(),
+				nil,
+				adminHeaders,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+		})
+	}
+}
+
+func TestHandlerTenantResolution(t *testing.T) {
+	tenantA, tenantB := uuid.New(), uuid.New()
+
+	var testCases = map[string]struct {
+		wantCode   int
+		wantTenant uuid.UUID
+		headers    http.Header
+		opts       []httpjson.HandlerOption
+	}{
+		"falls back to the default tenant": {
+			wantCode:   http.StatusOK,
+			wantTenant: organization.DefaultID,
+		},
+		"uses the tenant header": {
+			wantCode:   http.StatusOK,
+			wantTenant: tenantA,
+			headers: http.Header{
+				auth.HeaderKeySubject:      {"admin"},
+				auth.HeaderKeyRole:         {auth.RoleAdmin},
+				httpjson.HeaderKeyTenantID: {tenantA.String()},
+			},
+		},
+		"anonymous may address the default tenant": {
+			wantCode:   http.StatusOK,
+			wantTenant: organization.DefaultID,
+			headers:    http.Header{httpjson.HeaderKeyTenantID: {organization.DefaultID.String()}},
+		},
+		"user may address their own tenant": {
+			wantCode:   http.StatusOK,
+			wantTenant: tenantA,
+			headers: http.Header{
+				auth.HeaderKeySubject:      {"user"},
+				auth.HeaderKeyTenantID:     {tenantA.String()},
+				httpjson.HeaderKeyTenantID: {tenantA.String()},
+			},
+		},
+		"uses the principal tenant": {
+			wantCode:   http.StatusOK,
+			wantTenant: tenantA,
+			headers: http.Header{
+				auth.HeaderKeySubject:  {"user"},
+				auth.HeaderKeyTenantID: {tenantA.String()},
+			},
+		},
+		"admin may address another tenant": {
+			wantCode:   http.StatusOK,
+			wantTenant: tenantB,
+			headers: http.Header{
+				auth.HeaderKeySubject:      {"admin"},
+				auth.HeaderKeyRole:         {auth.RoleAdmin},
+				auth.HeaderKeyTenantID:     {tenantA.String()},
+				httpjson.HeaderKeyTenantID: {tenantB.String()},
+			},
+		},
+		"forbidden - user addresses another tenant": {
+			wantCode: http.StatusForbidden,
+			headers: http.Header{
+				auth.HeaderKeySubject:      {"user"},
+				auth.HeaderKeyTenantID:     {tenantA.String()},
+				httpjson.HeaderKeyTenantID: {tenantB.String()},
+			},
+		},
+		"forbidden - anonymous addresses another tenant": {
+			wantCode: http.StatusForbidden,
+			headers:  http.Header{httpjson.HeaderKeyTenantID: {tenantA.String()}},
+		},
+		"forbidden - user without tenant addresses another tenant": {
+			wantCode: http.StatusForbidden,
+			headers: http.Header{
+				auth.HeaderKeySubject:      {"user"},
+				httpjson.HeaderKeyTenantID: {tenantA.String()},
+			},
+		},
+		"forbidden - anonymous when a tenant is required": {
+			wantCode: http.StatusForbidden,
+			headers:  http.Header{httpjson.HeaderKeyTenantID: {tenantA.String()}},
+			opts:     []httpjson.HandlerOption{httpjson.WithDefaultTenant(uuid.Nil)},
+		},
+		"bad request - invalid tenant header": {
+			wantCode: http.StatusBadRequest,
+			headers:  http.Header{httpjson.HeaderKeyTenantID: {"invalid"}},
+		},
+		"bad request - tenant required": {
+			wantCode: http.StatusBadRequest,
+			opts:     []httpjson.HandlerOption{httpjson.WithDefaultTenant(uuid.Nil)},
+		},
+		"not found - unknown tenant": {
+			wantCode: http.StatusNotFound,
+			headers: http.Header{
+				auth.HeaderKeySubject:      {"admin"},
+				auth.HeaderKeyRole:         {auth.RoleAdmin},
+				httpjson.HeaderKeyTenantID: {tenantA.String()},
+			},
+			opts: []httpjson.HandlerOption{
+				httpjson.WithOrganizationService(&mock.OrganizationService{
+					FindByIDFunc: func(ID uuid.UUID) (*organization.Organization, error) {
+						return nil, organization.ErrNotFound
+					},
+				}),
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotTenant uuid.UUID
+			s := &tenantRecordingDeviceService{
+				DeviceService: mock.DeviceService{},
+				tenant:        &gotTenant,
+			}
+
+			opts := append([]httpjson.HandlerOption{httpjson.WithAuthenticator(auth.HeaderAuthenticator{})}, tc.opts...)
+			handler := httpjson.NewHandler(s, nil, opts...)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodGet,
+				"/devices",
+				nil,
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if tc.wantCode == http.StatusOK && tc.wantTenant != gotTenant {
+				t.Fatalf("expected tenant %s, got: %s", tc.wantTenant, gotTenant)
+			}
+		})
+	}
+}
+
+// tenantRecordingDeviceService records the tenant the handler resolved for the request.
+type tenantRecordingDeviceService struct {
+	mock.DeviceService
+	tenant *uuid.UUID
+}
+
+func (s *tenantRecordingDeviceService) ListDevices(ctx context.Context) (device.Devices, error) {
+	tenantID, err := organization.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	*s.tenant = tenantID
+
+	return device.Devices{}, nil
+}
@@ -0,0 +1,70 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/api/policy"
+)
+
+// @Summary      List policies
+// @Description  Get the policies every change of a device is checked against, in the order they
+// @Description  are evaluated.
+// @Tags         policies
+// @Produce      json
+// @Success      200  {array}   policy.DTO
+// @Failure      500  {object}  err.Problem
+// @Router       /policies [get]
+func (h Handler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	ps, err := h.policySvs.ListPolicies(r.Context())
+	if err != nil {
+		h.handleError(w, r, err, e.PolicyServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(ps.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Evaluate policies
+// @Description  Dry-run the policies applying to a change of a device on behalf of the caller,
+// @Description  without making it. Each policy reports whether it denies the change, a policy
+// @Description  that cannot be evaluated denying it. The rules of the device states are not
+// @Description  checked.
+// @Tags         policies
+// @Accept       json
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        evaluation  body      policy.EvaluateRequest  true  "Evaluate request object"
+// @Success      200         {object}  policy.Evaluation
+// @Failure      400         {object}  err.Problem
+// @Failure      404         {object}  err.Problem
+// @Failure      422         {object}  err.Problem
+// @Failure      500         {object}  err.Problem
+// @Router       /policies/evaluate [post]
+func (h Handler) EvaluatePolicies(w http.ResponseWriter, r *http.Request) {
+	input := policy.EvaluateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	ev, err := h.policySvs.Evaluate(r.Context(), input)
+	if err != nil {
+		h.handleError(w, r, err, e.PolicyServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(ev); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
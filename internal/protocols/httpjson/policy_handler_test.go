@@ -0,0 +1,132 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/policy"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+)
+
+func TestHandlerListPolicies(t *testing.T) {
+	ps := mock.PolicyService{
+		ListPoliciesFunc: func() (policy.Policies, error) {
+			return policy.Policies{{Name: "locked", Deny: "old.state == 'in_use'"}}, nil
+		},
+	}
+
+	handler := httpjson.NewHandler(&mock.DeviceService{}, nil, httpjson.WithPolicyService(&ps))
+	resp := test.DoHttpRequest(handler, http.MethodGet, "/policies", nil)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var dtos []policy.DTO
+	if err := json.NewDecoder(resp.Body).Decode(&dtos); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert the message defaults to the name of the policy
+	if len(dtos) != 1 || dtos[0].Name != "locked" || dtos[0].Message != "locked" {
+		t.Fatalf("expected the locked policy, got: %+v", dtos)
+	}
+}
+
+func TestHandlerEvaluatePolicies(t *testing.T) {
+	denied := &policy.Evaluation{Results: []policy.Result{{Policy: "locked", Denied: true, Message: "locked"}}}
+
+	var testCases = map[string]struct {
+		wantCode    int
+		body        string
+		evaluation  *policy.Evaluation
+		evaluateErr error
+	}{
+		"successfully evaluates an update": {
+			wantCode:   http.StatusOK,
+			body:       fmt.Sprintf(`{"operation": "update", "device_id": %q, "change": {"name": "Pixel"}}`, uuid.New()),
+			evaluation: denied,
+		},
+		"successfully evaluates a creation": {
+			wantCode:   http.StatusOK,
+			body:       `{"operation": "create", "change": {"name": "Pixel", "brand": "Google", "state": "available"}}`,
+			evaluation: &policy.Evaluation{Allowed: true, Results: []policy.Result{}},
+		},
+		"bad request - malformed body": {
+			wantCode: http.StatusBadRequest,
+			body:     `{`,
+		},
+		"validation failed - unknown operation": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     `{"operation": "rename"}`,
+		},
+		"validation failed - device required": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     `{"operation": "delete"}`,
+		},
+		"device not found": {
+			wantCode:    http.StatusNotFound,
+			body:        fmt.Sprintf(`{"operation": "delete", "device_id": %q}`, uuid.New()),
+			evaluateErr: device.ErrNotFound,
+		},
+		"service returns error": {
+			wantCode:    http.StatusInternalServerError,
+			body:        fmt.Sprintf(`{"operation": "delete", "device_id": %q}`, uuid.New()),
+			evaluateErr: fmt.Errorf("boom"),
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ps := mock.PolicyService{
+				EvaluateFunc: func(input policy.EvaluateRequest) (*policy.Evaluation, error) {
+					return tc.evaluation, tc.evaluateErr
+				},
+			}
+
+			handler := httpjson.NewHandler(&mock.DeviceService{}, v, httpjson.WithPolicyService(&ps))
+			resp := test.DoHttpRequest(
+				handler,
+				http.MethodPost,
+				"/policies/evaluate",
+				bytes.NewReader([]byte(tc.body)),
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if gotCode != http.StatusOK {
+				return
+			}
+
+			// assert the evaluation is returned
+
+			var got policy.Evaluation
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if got.Allowed != tc.evaluation.Allowed || len(got.Results) != len(tc.evaluation.Results) {
+				t.Fatalf("expected evaluation %+v, got: %+v", tc.evaluation, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/utils/logger"
+	"github.com/hferr/device-manager/utils/validator"
+)
+
+// writeProblem writes an occurrence of pt, for the request r, as an RFC 7807
+// problem+json response.
+func writeProblem(w http.ResponseWriter, r *http.Request, pt e.ProblemType) {
+	writeProblemDetail(w, r, pt, pt.Detail)
+}
+
+// writeProblemDetail writes an occurrence of pt with a detail specific to it.
+func writeProblemDetail(w http.ResponseWriter, r *http.Request, pt e.ProblemType, detail string) {
+	p := pt.New()
+	p.Detail = detail
+	p.Instance = r.URL.Path
+	p.RequestID = logger.RequestID(r.Context())
+
+	encodeProblem(w, p)
+}
+
+// writeValidationProblem reports the fields of the request body which failed
+// validation with err.
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := e.ValidationFailed.New()
+	p.Instance = r.URL.Path
+	p.RequestID = logger.RequestID(r.Context())
+	p.Errors = validator.FieldErrors(err)
+
+	encodeProblem(w, p)
+}
+
+func encodeProblem(w http.ResponseWriter, p e.Problem) {
+	// the problem replaces whatever the route would have responded with
+	w.Header().Set(HeaderKeyContentType, e.ContentTypeProblemJSON)
+	w.WriteHeader(p.Status)
+
+	// the status is already sent, there is nothing left to report a failure to
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, e.RouteNotFound)
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, e.MethodNotAllowed)
+}
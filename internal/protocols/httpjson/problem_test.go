@@ -0,0 +1,179 @@
+package httpjson_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+)
+
+func TestHandlerProblems(t *testing.T) {
+	ID := uuid.New()
+
+	var testCases = map[string]struct {
+		method   string
+		target   string
+		body     string
+		s        mock.DeviceService
+		want     e.ProblemType
+		wantErrs []validator.FieldError
+	}{
+		"device not found": {
+			method: http.MethodGet,
+			target: "/devices/" + ID.String(),
+			s: mock.DeviceService{
+				FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+					return nil, device.ErrNotFound
+				},
+			},
+			want: e.DeviceNotFound,
+		},
+		"duplicate device": {
+			method: http.MethodPost,
+			target: "/devices",
+			body:   `{"name": "test", "brand": "test", "state": "available"}`,
+			s: mock.DeviceService{
+				CreateDeviceFunc: func(input device.CreateDeviceRequest) (*device.Device, error) {
+					return nil, fmt.Errorf("insert: %w", device.ErrDuplicate)
+				},
+			},
+			want: e.DeviceDuplicate,
+		},
+		"invalid transition": {
+			method: http.MethodPatch,
+			target: "/devices/" + ID.String(),
+			body:   `{"state": "in_use"}`,
+			s: mock.DeviceService{
+				UpdateDeviceFunc: func(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+					return device.ErrInvalidTransition
+				},
+			},
+			want: e.InvalidTransition,
+		},
+		"device in use": {
+			method: http.MethodDelete,
+			target: "/devices/" + ID.String(),
+			s: mock.DeviceService{
+				DeleteDeviceFunc: func(ID uuid.UUID) error {
+					return device.ErrDeviceInUse
+				},
+			},
+			want: e.DeviceInUse,
+		},
+		"device conflict": {
+			method: http.MethodDelete,
+			target: "/devices/" + ID.String(),
+			s: mock.DeviceService{
+				DeleteDeviceFunc: func(ID uuid.UUID) error {
+					return device.ErrConflict
+				},
+			},
+			want: e.DeviceConflict,
+		},
+		"service failure": {
+			method: http.MethodGet,
+			target: "/devices",
+			s: mock.DeviceService{
+				ListDevicesFunc: func() (device.Devices, error) {
+					return nil, fmt.Errorf("boom")
+				},
+			},
+			want: e.DeviceServiceFailed,
+		},
+		"invalid id": {
+			method: http.MethodGet,
+			target: "/devices/not-a-uuid",
+			want:   e.InvalidID,
+		},
+		"malformed body": {
+			method: http.MethodPost,
+			target: "/devices",
+			body:   "{",
+			want:   e.MalformedBody,
+		},
+		"validation failure": {
+			method: http.MethodPost,
+			target: "/devices",
+			body:   `{"brand": "test", "state": "broken"}`,
+			want:   e.ValidationFailed,
+			wantErrs: []validator.FieldError{
+				{Pointer: "/name", Rule: "required", Detail: "name is required"},
+				{Pointer: "/state", Rule: "device_state", Detail: "state must be one of the device states, see /states"},
+			},
+		},
+		"unknown route": {
+			method: http.MethodGet,
+			target: "/gadgets",
+			want:   e.RouteNotFound,
+		},
+		"unknown route within a group": {
+			method: http.MethodGet,
+			target: "/devices/state/available/extra",
+			want:   e.RouteNotFound,
+		},
+		"method not allowed": {
+			method: http.MethodPut,
+			target: "/devices/" + ID.String(),
+			want:   e.MethodNotAllowed,
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := httpjson.NewHandler(&tc.s, v)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				tc.method,
+				tc.target,
+				strings.NewReader(tc.body),
+				http.Header{httpjson.HeaderKeyRequestID: {"req-1"}},
+			)
+
+			if resp.StatusCode != tc.want.Status {
+				t.Fatalf("expected status code %d, got: %d", tc.want.Status, resp.StatusCode)
+			}
+
+			if ct := resp.Header.Get(httpjson.HeaderKeyContentType); ct != e.ContentTypeProblemJSON {
+				t.Fatalf("expected content type %q, got: %q", e.ContentTypeProblemJSON, ct)
+			}
+
+			var p e.Problem
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if p.Type != tc.want.Type || p.Title != tc.want.Title || p.Status != tc.want.Status {
+				t.Fatalf("expected problem %+v, got: %+v", tc.want, p)
+			}
+
+			if p.Instance != tc.target || p.RequestID != "req-1" {
+				t.Fatalf("expected instance %q and request ID %q, got: %q and %q", tc.target, "req-1", p.Instance, p.RequestID)
+			}
+
+			if len(p.Errors) != len(tc.wantErrs) {
+				t.Fatalf("expected field errors %v, got: %v", tc.wantErrs, p.Errors)
+			}
+			for i := range tc.wantErrs {
+				if p.Errors[i] != tc.wantErrs[i] {
+					t.Fatalf("expected field error %+v, got: %+v", tc.wantErrs[i], p.Errors[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/api/reservation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// @Summary      List reservations
+// @Description  Get the reservations that are not cancelled, ordered by start, optionally
+// @Description  only those of a device or holder, or overlapping the period from from to to.
+// @Tags         reservations
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        device_id    query   string  false  "Device ID"
+// @Param        holder       query   string  false  "Holder"
+// @Param        from         query   string  false  "Start of the period, RFC 3339"
+// @Param        to           query   string  false  "End of the period, RFC 3339"
+// @Success      200  {array}   reservation.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      422  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /reservations [get]
+func (h Handler) ListReservations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := reservation.Filter{Holder: q.Get("holder")}
+
+	var err error
+	if v := q.Get("device_id"); v != "" {
+		if f.DeviceID, err = uuid.Parse(v); err != nil {
+			writeProblem(w, r, e.InvalidQuery)
+			return
+		}
+	}
+
+	if f.From, f.To, err = queryPeriod(r, false); err != nil {
+		writeProblem(w, r, e.InvalidQuery)
+		return
+	}
+
+	rs, err := h.reservationSvs.ListReservations(r.Context(), f)
+	if err != nil {
+		h.handleError(w, r, err, e.ReservationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(rs.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Reserve a device
+// @Description  Reserve a device for a period, which must not overlap the other reservations
+// @Description  of the device. The holder defaults to the authenticated principal, and only
+// @Description  admins reserve devices for someone else. The device is moved to the state
+// @Description  'in_use' when the reservation starts.
+// @Tags         reservations
+// @Accept       json
+// @Produce      json
+// @Param        X-Tenant-ID      header  string  false  "Tenant (organization) ID"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request replay the original response"
+// @Param        reservation  body      reservation.CreateReservationRequest  true  "Create reservation request object"
+// @Success      201          {object}  reservation.DTO
+// @Failure      400          {object}  err.Problem
+// @Failure      403          {object}  err.Problem
+// @Failure      404          {object}  err.Problem
+// @Failure      409          {object}  err.Problem
+// @Failure      413          {object}  err.Problem
+// @Failure      422          {object}  err.Problem
+// @Failure      500          {object}  err.Problem
+// @Router       /reservations [post]
+func (h Handler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	input := reservation.CreateReservationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if p, ok := auth.FromContext(r.Context()); ok && input.Holder == "" {
+		input.Holder = p.Subject
+	}
+
+	if !canActFor(r, input.Holder) {
+		writeProblem(w, r, e.Forbidden)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	res, err := h.reservationSvs.CreateReservation(r.Context(), input)
+	if err != nil {
+		h.handleError(w, r, err, e.ReservationServiceFailed)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(res.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Get reservation by ID
+// @Description  Get a single reservation by its ID, cancelled or not
+// @Tags         reservations
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id   path      string  true  "Reservation ID"
+// @Success      200  {object}  reservation.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /reservations/{id} [get]
+func (h Handler) FindReservationByID(w http.ResponseWriter, r *http.Request) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	res, err := h.reservationSvs.FindByID(r.Context(), ID)
+	if err != nil {
+		h.handleError(w, r, err, e.ReservationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(res.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Cancel a reservation
+// @Description  Cancel a reservation that has not ended, freeing the rest of its period.
+// @Description  A device already moved to 'in_use' keeps its state. Only admins cancel the
+// @Description  reservations of someone else.
+// @Tags         reservations
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id   path      string  true  "Reservation ID"
+// @Success      200  {object}  reservation.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      403  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      422  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /reservations/{id}/cancel [post]
+func (h Handler) CancelReservation(w http.ResponseWriter, r *http.Request) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	res, err := h.reservationSvs.FindByID(r.Context(), ID)
+	if err != nil {
+		h.handleError(w, r, err, e.ReservationServiceFailed)
+		return
+	}
+
+	if !canActFor(r, res.Holder) {
+		writeProblem(w, r, e.Forbidden)
+		return
+	}
+
+	res, err = h.reservationSvs.CancelReservation(r.Context(), ID)
+	if err != nil {
+		h.handleError(w, r, err, e.ReservationServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(res.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      List available devices
+// @Description  Get the devices in assignable states without reservations overlapping the period from from to to
+// @Tags         devices
+// @Produce      json,application/x-ndjson,text/csv,application/yaml,application/msgpack
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        from  query     string  true  "Start of the period, RFC 3339"
+// @Param        to    query     string  true  "End of the period, RFC 3339"
+// @Success      200   {array}   device.DTO
+// @Failure      400   {object}  err.Problem
+// @Failure      406   {object}  err.Problem
+// @Failure      422   {object}  err.Problem
+// @Failure      500   {object}  err.Problem
+// @Router       /devices/available [get]
+func (h Handler) AvailableDevices(w http.ResponseWriter, r *http.Request) {
+	from, to, err := queryPeriod(r, true)
+	if err != nil {
+		writeProblem(w, r, e.InvalidQuery)
+		return
+	}
+
+	ds, err := h.reservationSvs.AvailableDevices(r.Context(), from, to)
+	if err != nil {
+		h.handleError(w, r, err, e.ReservationServiceFailed)
+		return
+	}
+
+	h.respond(w, r, http.StatusOK, ds.ToDto())
+}
+
+// canActFor reports whether the principal of r may act for holder: admins and
+// the holder itself, or anyone when requests are anonymous.
+func canActFor(r *http.Request, holder string) bool {
+	p, ok := auth.FromContext(r.Context())
+	return !ok || p.IsAdmin() || p.Subject == holder
+}
+
+// errMissingPeriod is returned by queryPeriod for a required period missing
+// one of its ends.
+var errMissingPeriod = errors.New("from and to are required")
+
+// queryPeriod parses the RFC 3339 from and to query parameters, leaving the
+// missing ones zero unless they are required.
+func queryPeriod(r *http.Request, required bool) (from, to time.Time, err error) {
+	q := r.URL.Query()
+	if required && (q.Get("from") == "" || q.Get("to") == "") {
+		return from, to, errMissingPeriod
+	}
+
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, err
+		}
+	}
+
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, err
+		}
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,380 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/reservation"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+)
+
+func TestHandlerCreateReservation(t *testing.T) {
+	period := `"starts_at": "2026-10-20T09:00:00Z", "ends_at": "2026-10-20T17:00:00Z"`
+	deviceID := uuid.New().String()
+
+	var testCases = map[string]struct {
+		wantCode   int
+		wantHolder string
+		headers    http.Header
+		body       string
+		s          mock.ReservationService
+	}{
+		"successfully calls reservation service": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "bob",
+			body:       fmt.Sprintf(`{"device_id": %q, "holder": "bob", %s}`, deviceID, period),
+		},
+		"holder defaults to the principal": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "alice",
+			headers:    http.Header{auth.HeaderKeySubject: {"alice"}},
+			body:       fmt.Sprintf(`{"device_id": %q, %s}`, deviceID, period),
+		},
+		"admin reserves for someone else": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "bob",
+			headers:    http.Header{auth.HeaderKeySubject: {"alice"}, auth.HeaderKeyRole: {auth.RoleAdmin}},
+			body:       fmt.Sprintf(`{"device_id": %q, "holder": "bob", %s}`, deviceID, period),
+		},
+		"principal reserves for themselves": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "alice",
+			headers:    http.Header{auth.HeaderKeySubject: {"alice"}},
+			body:       fmt.Sprintf(`{"device_id": %q, "holder": "alice", %s}`, deviceID, period),
+		},
+		"forbidden - principal reserves for someone else": {
+			wantCode: http.StatusForbidden,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}},
+			body:     fmt.Sprintf(`{"device_id": %q, "holder": "bob", %s}`, deviceID, period),
+		},
+		"unprocessable entity - anonymous without holder": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     fmt.Sprintf(`{"device_id": %q, %s}`, deviceID, period),
+		},
+		"unprocessable entity - no device provided": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     fmt.Sprintf(`{"holder": "bob", %s}`, period),
+		},
+		"bad request - time not in RFC 3339": {
+			wantCode: http.StatusBadRequest,
+			body:     fmt.Sprintf(`{"device_id": %q, "holder": "bob", "starts_at": "2026-10-20 09:00", "ends_at": "2026-10-20 17:00"}`, deviceID),
+		},
+		"device already reserved": {
+			wantCode: http.StatusConflict,
+			body:     fmt.Sprintf(`{"device_id": %q, "holder": "bob", %s}`, deviceID, period),
+			s: mock.ReservationService{
+				CreateReservationFunc: func(input reservation.CreateReservationRequest) (*reservation.Reservation, error) {
+					return nil, reservation.ErrOverlap
+				},
+			},
+		},
+		"invalid period": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     fmt.Sprintf(`{"device_id": %q, "holder": "bob", %s}`, deviceID, period),
+			s: mock.ReservationService{
+				CreateReservationFunc: func(input reservation.CreateReservationRequest) (*reservation.Reservation, error) {
+					return nil, reservation.ErrInvalidPeriod
+				},
+			},
+		},
+		"device not found": {
+			wantCode: http.StatusNotFound,
+			body:     fmt.Sprintf(`{"device_id": %q, "holder": "bob", %s}`, deviceID, period),
+			s: mock.ReservationService{
+				CreateReservationFunc: func(input reservation.CreateReservationRequest) (*reservation.Reservation, error) {
+					return nil, device.ErrNotFound
+				},
+			},
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var holder string
+			if tc.s.CreateReservationFunc == nil {
+				tc.s.CreateReservationFunc = func(input reservation.CreateReservationRequest) (*reservation.Reservation, error) {
+					holder = input.Holder
+					return reservation.NewReservation(input), nil
+				}
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithReservationService(&tc.s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodPost,
+				"/reservations",
+				bytes.NewReader([]byte(tc.body)),
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if holder != tc.wantHolder {
+				t.Fatalf("expected holder %q, got: %q", tc.wantHolder, holder)
+			}
+		})
+	}
+}
+
+func TestHandlerListReservations(t *testing.T) {
+	deviceID := uuid.New()
+
+	var testCases = map[string]struct {
+		wantCode   int
+		wantFilter reservation.Filter
+		query      string
+	}{
+		"successfully lists every reservation": {
+			wantCode: http.StatusOK,
+		},
+		"successfully lists filtered reservations": {
+			wantCode: http.StatusOK,
+			wantFilter: reservation.Filter{
+				DeviceID: deviceID,
+				Holder:   "bob",
+				From:     time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC),
+				To:       time.Date(2026, 10, 20, 17, 0, 0, 0, time.UTC),
+			},
+			query: "?device_id=" + deviceID.String() + "&holder=bob&from=2026-10-20T09:00:00Z&to=2026-10-20T17:00:00Z",
+		},
+		"bad request - invalid device id": {
+			wantCode: http.StatusBadRequest,
+			query:    "?device_id=invalid",
+		},
+		"bad request - time not in RFC 3339": {
+			wantCode: http.StatusBadRequest,
+			query:    "?from=yesterday",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var filter reservation.Filter
+			s := mock.ReservationService{
+				ListReservationsFunc: func(f reservation.Filter) (reservation.Reservations, error) {
+					filter = f
+					return reservation.Reservations{}, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(&mock.DeviceService{}, nil, httpjson.WithReservationService(&s))
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/reservations"+tc.query, nil, nil)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if filter != tc.wantFilter {
+				t.Fatalf("expected filter %+v, got: %+v", tc.wantFilter, filter)
+			}
+		})
+	}
+}
+
+func TestHandlerCancelReservation(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode      int
+		wantCancelled bool
+		headers       http.Header
+		findErr       error
+		cancelErr     error
+	}{
+		"successfully cancels reservation": {
+			wantCode:      http.StatusOK,
+			wantCancelled: true,
+		},
+		"holder cancels their reservation": {
+			wantCode:      http.StatusOK,
+			wantCancelled: true,
+			headers:       http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"admin cancels the reservation of someone else": {
+			wantCode:      http.StatusOK,
+			wantCancelled: true,
+			headers:       http.Header{auth.HeaderKeySubject: {"alice"}, auth.HeaderKeyRole: {auth.RoleAdmin}},
+		},
+		"forbidden - principal cancels the reservation of someone else": {
+			wantCode: http.StatusForbidden,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}},
+		},
+		"reservation not found error": {
+			wantCode: http.StatusNotFound,
+			findErr:  reservation.ErrNotFound,
+		},
+		"reservation ended error": {
+			wantCode:      http.StatusUnprocessableEntity,
+			wantCancelled: true,
+			cancelErr:     reservation.ErrEnded,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var cancelled bool
+			s := mock.ReservationService{
+				FindByIDFunc: func(ID uuid.UUID) (*reservation.Reservation, error) {
+					if tc.findErr != nil {
+						return nil, tc.findErr
+					}
+					return &reservation.Reservation{ID: ID, Holder: "bob"}, nil
+				},
+				CancelReservationFunc: func(ID uuid.UUID) (*reservation.Reservation, error) {
+					cancelled = true
+					if tc.cancelErr != nil {
+						return nil, tc.cancelErr
+					}
+					now := time.Now()
+					return &reservation.Reservation{ID: ID, Holder: "bob", CancelledAt: &now}, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				nil,
+				httpjson.WithReservationService(&s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodPost, "/reservations/"+uuid.New().String()+"/cancel", nil, tc.headers)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if cancelled != tc.wantCancelled {
+				t.Fatalf("expected reservation cancelled: %t, got: %t", tc.wantCancelled, cancelled)
+			}
+		})
+	}
+}
+
+func TestHandlerAvailableDevices(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		query    string
+	}{
+		"successfully lists available devices": {
+			wantCode: http.StatusOK,
+			query:    "?from=2026-10-20T09:00:00Z&to=2026-10-20T17:00:00%2B02:00",
+		},
+		"bad request - missing end": {
+			wantCode: http.StatusBadRequest,
+			query:    "?from=2026-10-20T09:00:00Z",
+		},
+		"bad request - time not in RFC 3339": {
+			wantCode: http.StatusBadRequest,
+			query:    "?from=2026-10-20&to=2026-10-21",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := mock.ReservationService{
+				AvailableDevicesFunc: func(from, to time.Time) (device.Devices, error) {
+					return device.Devices{device.NewDevice("test", "test", device.StateAvailable)}, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(&mock.DeviceService{}, nil, httpjson.WithReservationService(&s))
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/devices/available"+tc.query, nil, nil)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+		})
+	}
+}
+
+func TestHandlerReservationCalendar(t *testing.T) {
+	free, reserved := device.NewDevice("free", "test", device.StateAvailable), device.NewDevice("reserved", "test", device.StateAvailable)
+	devices := mock.DeviceService{
+		FindByIDFunc: func(ID uuid.UUID) (*device.Device, error) {
+			return reserved, nil
+		},
+		ListDevicesFunc: func() (device.Devices, error) {
+			return device.Devices{free, reserved}, nil
+		},
+	}
+
+	// the reservations are kept in memory by default
+	handler := httpjson.NewHandler(&devices, validator.New())
+
+	reserve := func(from, to string) int {
+		body := fmt.Sprintf(`{"device_id": %q, "holder": "bob", "starts_at": %q, "ends_at": %q}`, reserved.ID, from, to)
+		return test.DoHttpRequestWithHeaders(handler, http.MethodPost, "/reservations", bytes.NewReader([]byte(body)), nil).StatusCode
+	}
+
+	if code := reserve("2099-10-20T09:00:00Z", "2099-10-20T12:00:00Z"); code != http.StatusCreated {
+		t.Fatalf("expected status code %d, got: %d", http.StatusCreated, code)
+	}
+
+	// assert overlapping reservations are refused
+
+	if code := reserve("2099-10-20T11:00:00Z", "2099-10-20T13:00:00Z"); code != http.StatusConflict {
+		t.Fatalf("expected status code %d, got: %d", http.StatusConflict, code)
+	}
+
+	// assert the reserved device is only available outside of its reservation
+
+	for query, want := range map[string]int{
+		"?from=2099-10-20T10:00:00Z&to=2099-10-20T11:00:00Z": 1,
+		"?from=2099-10-20T12:00:00Z&to=2099-10-20T13:00:00Z": 2,
+	} {
+		resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/devices/available"+query, nil, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code %d, got: %d", http.StatusOK, resp.StatusCode)
+		}
+
+		var ds []device.DTO
+		if err := json.NewDecoder(resp.Body).Decode(&ds); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(ds) != want {
+			t.Fatalf("expected %d available devices for %s, got: %d", want, query, len(ds))
+		}
+	}
+}
@@ -1,43 +1,259 @@
 package httpjson
 
 import (
+	"log/slog"
 	"net/http"
 
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/api/approval"
+	"github.com/hferr/device-manager/internal/api/auth"
 	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/api/idempotency"
+	"github.com/hferr/device-manager/internal/api/notification"
+	"github.com/hferr/device-manager/internal/api/organization"
+	"github.com/hferr/device-manager/internal/api/policy"
+	"github.com/hferr/device-manager/internal/api/reservation"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+	"github.com/hferr/device-manager/internal/health"
+	"github.com/hferr/device-manager/internal/metrics"
+	"github.com/hferr/device-manager/utils/logger"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	HeaderKeyContentType       = "Content-Type"
 	HeaderValueContentTypeJSON = "application/json;charset=utf8"
+
+	HeaderKeyTenantID  = "X-Tenant-ID"
+	HeaderKeyRequestID = "X-Request-ID"
+
+	HeaderKeyIdempotencyKey     = "Idempotency-Key"
+	HeaderKeyIdempotentReplayed = "Idempotent-Replayed"
 )
 
 type Handler struct {
-	deviceSvs device.DeviceService
-	validator *validator.Validate
+	deviceSvs       device.DeviceService
+	stateSvs        devicestate.StateService
+	reservationSvs  reservation.ReservationService
+	waitlistSvs     waitlist.WaitlistService
+	notificationSvs notification.NotificationService
+	approvalSvs     approval.ApprovalService
+	policySvs       policy.PolicyService
+	organizationSvs organization.OrganizationService
+	idempotencySvs  idempotency.IdempotencyService
+	validator       *validator.Validate
+	authenticator   auth.Authenticator
+	defaultTenantID uuid.UUID
+	logger          *slog.Logger
+	metrics         *metrics.Metrics
+	tracerProvider  trace.TracerProvider
+	healthChecker   *health.Checker
+	config          *config.Conf
+
+	// idempotencyMaxBodySize bounds the bodies buffered by
+	// middlewareIdempotency.
+	idempotencyMaxBodySize int64
+}
+
+type HandlerOption func(*Handler)
+
+// WithStateService sets the device states, which are managed through the
+// /states endpoints. Only the built-in states exist by default.
+func WithStateService(s devicestate.StateService) HandlerOption {
+	return func(h *Handler) {
+		h.stateSvs = s
+	}
+}
+
+// WithReservationService sets the device reservations, which are managed
+// through the /reservations endpoints. Reservations are kept in memory by
+// default.
+func WithReservationService(s reservation.ReservationService) HandlerOption {
+	return func(h *Handler) {
+		h.reservationSvs = s
+	}
+}
+
+// WithWaitlistService sets the device waitlists, which are managed through
+// the /waitlist endpoints. Entries are kept in memory by default, and only
+// dispatched by the caller of Dispatch.
+func WithWaitlistService(s waitlist.WaitlistService) HandlerOption {
+	return func(h *Handler) {
+		h.waitlistSvs = s
+	}
+}
+
+// WithNotificationService sets the notifications, whose log and preferences
+// are served by the /notifications endpoints. Notifications are kept in
+// memory, and never sent, by default.
+func WithNotificationService(s notification.NotificationService) HandlerOption {
+	return func(h *Handler) {
+		h.notificationSvs = s
+	}
+}
+
+// WithApprovalService sets the approval policies holding back device updates
+// and deletions, and the change requests they create, which are managed
+// through the /change-requests endpoints. No change requires approval by
+// default.
+func WithApprovalService(s approval.ApprovalService) HandlerOption {
+	return func(h *Handler) {
+		h.approvalSvs = s
+	}
+}
+
+// WithPolicyService sets the policies served by the /policies endpoints,
+// which should be those the device service checks changes against. There
+// are no policies by default.
+func WithPolicyService(s policy.PolicyService) HandlerOption {
+	return func(h *Handler) {
+		h.policySvs = s
+	}
+}
+
+// WithOrganizationService enables tenant validation and the /admin/organizations endpoints.
+func WithOrganizationService(s organization.OrganizationService) HandlerOption {
+	return func(h *Handler) {
+		h.organizationSvs = s
+	}
+}
+
+// WithIdempotencyService enables Idempotency-Key support on POST endpoints.
+func WithIdempotencyService(s idempotency.IdempotencyService) HandlerOption {
+	return func(h *Handler) {
+		h.idempotencySvs = s
+	}
+}
+
+// WithIdempotencyMaxBodySize bounds the bodies of the requests made with an
+// Idempotency-Key, DefaultIdempotencyMaxBodySize bytes by default. Larger
+// bodies are refused with 413 Request Entity Too Large.
+func WithIdempotencyMaxBodySize(n int64) HandlerOption {
+	return func(h *Handler) {
+		h.idempotencyMaxBodySize = n
+	}
+}
+
+// WithLogger sets the logger used for access and error logs, which are
+// discarded by default.
+func WithLogger(l *slog.Logger) HandlerOption {
+	return func(h *Handler) {
+		h.logger = l
+	}
+}
+
+// WithMetrics enables the request metrics and the /metrics endpoint.
+func WithMetrics(m *metrics.Metrics) HandlerOption {
+	return func(h *Handler) {
+		h.metrics = m
+	}
+}
+
+// WithTracerProvider enables request tracing with the given provider.
+func WithTracerProvider(tp trace.TracerProvider) HandlerOption {
+	return func(h *Handler) {
+		h.tracerProvider = tp
+	}
+}
+
+// WithHealthChecker sets the checks run by the readiness probe, which
+// otherwise always reports ready.
+func WithHealthChecker(c *health.Checker) HandlerOption {
+	return func(h *Handler) {
+		h.healthChecker = c
+	}
+}
+
+// WithConfig enables the /admin/config endpoint, dumping the given
+// configuration.
+func WithConfig(c *config.Conf) HandlerOption {
+	return func(h *Handler) {
+		h.config = c
+	}
+}
+
+// WithAuthenticator sets how the principal of each request is resolved.
+// Without one every request is anonymous.
+func WithAuthenticator(a auth.Authenticator) HandlerOption {
+	return func(h *Handler) {
+		h.authenticator = a
+	}
+}
+
+// WithDefaultTenant sets the tenant used when neither the principal nor the
+// X-Tenant-ID header name one. uuid.Nil makes a tenant mandatory.
+func WithDefaultTenant(ID uuid.UUID) HandlerOption {
+	return func(h *Handler) {
+		h.defaultTenantID = ID
+	}
 }
 
-func NewHandler(deviceSvs device.DeviceService, v *validator.Validate) *Handler {
-	return &Handler{
-		deviceSvs: deviceSvs,
-		validator: v,
+func NewHandler(deviceSvs device.DeviceService, v *validator.Validate, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		deviceSvs:       deviceSvs,
+		stateSvs:        devicestate.NewService(devicestate.NewMemoryRepository()),
+		notificationSvs: notification.NewService(notification.NewMemoryRepository()),
+		approvalSvs:     approval.NewService(approval.NewMemoryRepository(), deviceSvs),
+		policySvs:       policy.NewService(&policy.Engine{}, deviceSvs),
+		validator:       v,
+		defaultTenantID: organization.DefaultID,
+		logger:          logger.Discard(),
+	}
+
+	for _, opt := range opts {
+		opt(h)
 	}
+
+	if h.reservationSvs == nil {
+		h.reservationSvs = reservation.NewService(reservation.NewMemoryRepository(), deviceSvs, h.stateSvs)
+	}
+
+	if h.waitlistSvs == nil {
+		h.waitlistSvs = waitlist.NewService(waitlist.NewMemoryRepository(), deviceSvs, h.stateSvs,
+			waitlist.WithReservations(h.reservationSvs),
+		)
+	}
+
+	return h
 }
 
 func (h Handler) NewRouter() *chi.Mux {
 	r := chi.NewRouter()
+	r.NotFound(routeNotFound)
+	r.MethodNotAllowed(methodNotAllowed)
 
-	r.Get("/health", h.HealthCheck)
+	r.Use(h.middlewareTracing)
+	r.Use(middlewareRequestID)
+	r.Use(h.middlewareAccessLog)
+	r.Use(h.middlewareMetrics)
+	r.Use(h.middlewareAuthenticate)
 
-	r.Route("/devices", func(r chi.Router) {
+	r.Route("/health", func(r chi.Router) {
 		r.Use(middlewareContentTypeJSON)
 
+		r.Get("/", h.Liveness)
+		r.Get("/live", h.Liveness)
+		r.Get("/ready", h.Readiness)
+	})
+
+	if h.metrics != nil {
+		r.Method(http.MethodGet, "/metrics", h.metrics.Handler())
+	}
+
+	r.Route("/devices", func(r chi.Router) {
+		r.Use(h.middlewareNegotiate)
+		r.Use(h.middlewareTenant)
+
 		r.Get("/", h.ListDevices)
+		r.Get("/available", h.AvailableDevices)
 		r.Get("/{id}", h.FindByID)
-		r.Post("/", h.CreateDevice)
+		r.With(h.middlewareIdempotency).Post("/", h.CreateDevice)
 		r.Patch("/{id}", h.UpdateDevice)
 		r.Delete("/{id}", h.DeleteDevice)
 
@@ -45,6 +261,96 @@ func (h Handler) NewRouter() *chi.Mux {
 		r.Get("/brand/{brand}", h.FindByBrand)
 	})
 
+	r.Route("/states", func(r chi.Router) {
+		r.Use(middlewareContentTypeJSON)
+
+		r.Get("/", h.ListStates)
+		r.Get("/{name}", h.FindStateByName)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewareRequireAdmin)
+
+			r.With(h.middlewareIdempotency).Post("/", h.CreateState)
+			r.Patch("/{name}", h.UpdateState)
+			r.Delete("/{name}", h.DeleteState)
+		})
+	})
+
+	r.Route("/reservations", func(r chi.Router) {
+		r.Use(middlewareContentTypeJSON)
+		r.Use(h.middlewareTenant)
+
+		r.Get("/", h.ListReservations)
+		r.With(h.middlewareIdempotency).Post("/", h.CreateReservation)
+		r.Get("/{id}", h.FindReservationByID)
+		r.Post("/{id}/cancel", h.CancelReservation)
+	})
+
+	r.Route("/waitlist", func(r chi.Router) {
+		r.Use(middlewareContentTypeJSON)
+		r.Use(h.middlewareTenant)
+
+		r.Get("/", h.ListWaitlistEntries)
+		r.With(h.middlewareIdempotency).Post("/", h.JoinWaitlist)
+		r.Get("/{id}", h.FindWaitlistEntryByID)
+		r.Post("/{id}/claim", h.ClaimWaitlistOffer)
+		r.Post("/{id}/cancel", h.LeaveWaitlist)
+	})
+
+	r.Route("/notifications", func(r chi.Router) {
+		r.Use(middlewareContentTypeJSON)
+		r.Use(h.middlewareTenant)
+
+		r.Get("/", h.ListNotifications)
+		r.Get("/preferences/{recipient}", h.FindNotificationPreference)
+		r.Put("/preferences/{recipient}", h.UpdateNotificationPreference)
+	})
+
+	r.Route("/change-requests", func(r chi.Router) {
+		r.Use(middlewareContentTypeJSON)
+		r.Use(h.middlewareTenant)
+
+		r.Get("/", h.ListChangeRequests)
+		r.Get("/{id}", h.FindChangeRequestByID)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewareRequireAdmin)
+
+			r.Post("/{id}/approve", h.ApproveChangeRequest)
+			r.Post("/{id}/reject", h.RejectChangeRequest)
+		})
+	})
+
+	r.Route("/policies", func(r chi.Router) {
+		r.Use(middlewareContentTypeJSON)
+		r.Use(h.middlewareTenant)
+
+		r.Get("/", h.ListPolicies)
+		r.Post("/evaluate", h.EvaluatePolicies)
+	})
+
+	if h.organizationSvs != nil {
+		r.Route("/admin/organizations", func(r chi.Router) {
+			r.Use(middlewareContentTypeJSON)
+			r.Use(middlewareRequireAdmin)
+
+			r.Get("/", h.ListOrganizations)
+			r.With(h.middlewareIdempotency).Post("/", h.CreateOrganization)
+			r.Get("/{id}", h.FindOrganizationByID)
+			r.Delete("/{id}", h.DeleteOrganization)
+			r.Get("/{id}/devices", h.ListOrganizationDevices)
+		})
+	}
+
+	if h.config != nil {
+		r.Route("/admin/config", func(r chi.Router) {
+			r.Use(middlewareContentTypeJSON)
+			r.Use(middlewareRequireAdmin)
+
+			r.Get("/", h.DumpConfig)
+		})
+	}
+
 	// add Swagger UI endpoint with hardcoded uri for simplicity
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
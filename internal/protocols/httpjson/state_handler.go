@@ -0,0 +1,165 @@
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	e "github.com/hferr/device-manager/internal/api/err"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary      List device states
+// @Description  Get the states devices can be in, along with their rules
+// @Tags         states
+// @Produce      json
+// @Success      200  {array}   devicestate.DTO
+// @Failure      500  {object}  err.Problem
+// @Router       /states [get]
+func (h Handler) ListStates(w http.ResponseWriter, r *http.Request) {
+	ss, err := h.stateSvs.ListStates(r.Context())
+	if err != nil {
+		h.handleError(w, r, err, e.StateServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(ss.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Create a device state
+// @Description  Create a state devices can be in, admin only
+// @Tags         states
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request replay the original response"
+// @Param        state  body      devicestate.CreateStateRequest  true  "Create state request object"
+// @Success      201    {object}  devicestate.DTO
+// @Failure      400    {object}  err.Problem
+// @Failure      403    {object}  err.Problem
+// @Failure      409    {object}  err.Problem
+// @Failure      413    {object}  err.Problem
+// @Failure      422    {object}  err.Problem
+// @Failure      500    {object}  err.Problem
+// @Router       /states [post]
+func (h Handler) CreateState(w http.ResponseWriter, r *http.Request) {
+	input := devicestate.CreateStateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	s, err := h.stateSvs.CreateState(r.Context(), input)
+	if err != nil {
+		h.handleError(w, r, err, e.StateServiceFailed)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(s.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Get device state by name
+// @Description  Get a single device state by its name
+// @Tags         states
+// @Produce      json
+// @Param        name  path      string  true  "State name"
+// @Success      200   {object}  devicestate.DTO
+// @Failure      404   {object}  err.Problem
+// @Failure      500   {object}  err.Problem
+// @Router       /states/{name} [get]
+func (h Handler) FindStateByName(w http.ResponseWriter, r *http.Request) {
+	s, err := h.stateSvs.FindByName(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		h.handleError(w, r, err, e.StateServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(s.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Update a device state
+// @Description  Update the description and rules of a device state, admin only.
+// @Description  The rules apply to the devices already in the state.
+// @Tags         states
+// @Accept       json
+// @Produce      json
+// @Param        name   path      string                          true  "State name"
+// @Param        state  body      devicestate.UpdateStateRequest  true  "Updated state request object"
+// @Success      200    {object}  devicestate.DTO
+// @Failure      400    {object}  err.Problem
+// @Failure      403    {object}  err.Problem
+// @Failure      404    {object}  err.Problem
+// @Failure      422    {object}  err.Problem
+// @Failure      500    {object}  err.Problem
+// @Router       /states/{name} [patch]
+func (h Handler) UpdateState(w http.ResponseWriter, r *http.Request) {
+	input := devicestate.UpdateStateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	s, err := h.stateSvs.UpdateState(r.Context(), chi.URLParam(r, "name"), input)
+	if err != nil {
+		h.handleError(w, r, err, e.StateServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(s.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Delete a device state
+// @Description  Delete a device state by its name, admin only. The built-in states
+// @Description  and the states devices are still in cannot be deleted.
+// @Tags         states
+// @Produce      json
+// @Param        name  path  string  true  "State name"
+// @Success      204
+// @Failure      403   {object}  err.Problem
+// @Failure      404   {object}  err.Problem
+// @Failure      422   {object}  err.Problem
+// @Failure      500   {object}  err.Problem
+// @Router       /states/{name} [delete]
+func (h Handler) DeleteState(w http.ResponseWriter, r *http.Request) {
+	if err := h.stateSvs.DeleteState(r.Context(), chi.URLParam(r, "name")); err != nil {
+		h.handleError(w, r, err, e.StateServiceFailed)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stateExists looks device states up for validation.
+func (h Handler) stateExists(ctx context.Context, name string) (bool, error) {
+	_, err := h.stateSvs.FindByName(ctx, name)
+	if errors.Is(err, devicestate.ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
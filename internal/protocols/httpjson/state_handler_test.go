@@ -0,0 +1,289 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/devicestate"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+)
+
+func TestHandlerListStates(t *testing.T) {
+	s := mock.StateService{
+		ListStatesFunc: func() (devicestate.States, error) {
+			return devicestate.Builtin(), nil
+		},
+	}
+
+	handler := httpjson.NewHandler(&mock.DeviceService{}, nil, httpjson.WithStateService(&s))
+	resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/states", nil, nil)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var got []devicestate.DTO
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert the rules of the states are listed
+
+	if len(got) != 3 || got[1].Name != devicestate.InUse || !got[1].LocksFields || !got[1].BlocksDelete {
+		t.Fatalf("expected the built-in states, got: %+v", got)
+	}
+}
+
+func TestHandlerCreateState(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		headers  http.Header
+		input    devicestate.CreateStateRequest
+		s        mock.StateService
+	}{
+		"successfully calls state service": {
+			wantCode: http.StatusCreated,
+			headers:  adminHeaders,
+			input:    devicestate.CreateStateRequest{Name: "in_repair", LocksFields: true},
+			s: mock.StateService{
+				CreateStateFunc: func(input devicestate.CreateStateRequest) (*devicestate.State, error) {
+					return devicestate.NewState(input), nil
+				},
+			},
+		},
+		"forbidden - not an admin": {
+			wantCode: http.StatusForbidden,
+			headers: http.Header{
+				auth.HeaderKeySubject: {"user"},
+			},
+			input: devicestate.CreateStateRequest{Name: "in_repair"},
+			s:     mock.StateService{},
+		},
+		"unprocessable entity - name not in snake case": {
+			wantCode: http.StatusUnprocessableEntity,
+			headers:  adminHeaders,
+			input:    devicestate.CreateStateRequest{Name: "In Repair"},
+			s:        mock.StateService{},
+		},
+		"state already exists": {
+			wantCode: http.StatusConflict,
+			headers:  adminHeaders,
+			input:    devicestate.CreateStateRequest{Name: "in_repair"},
+			s: mock.StateService{
+				CreateStateFunc: func(input devicestate.CreateStateRequest) (*devicestate.State, error) {
+					return nil, devicestate.ErrDuplicate
+				},
+			},
+		},
+		"service returns error": {
+			wantCode: http.StatusInternalServerError,
+			headers:  adminHeaders,
+			input:    devicestate.CreateStateRequest{Name: "in_repair"},
+			s: mock.StateService{
+				CreateStateFunc: func(input devicestate.CreateStateRequest) (*devicestate.State, error) {
+					return nil, fmt.Errorf("boom")
+				},
+			},
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reqJson, err := json.Marshal(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithStateService(&tc.s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodPost,
+				"/states",
+				bytes.NewReader(reqJson),
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+		})
+	}
+}
+
+func TestHandlerUpdateState(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		s        mock.StateService
+	}{
+		"successfully updates state": {
+			wantCode: http.StatusOK,
+			s: mock.StateService{
+				UpdateStateFunc: func(name string, input devicestate.UpdateStateRequest) (*devicestate.State, error) {
+					st := &devicestate.State{Name: name}
+					input.Apply(st)
+					return st, nil
+				},
+			},
+		},
+		"state not found error": {
+			wantCode: http.StatusNotFound,
+			s: mock.StateService{
+				UpdateStateFunc: func(name string, input devicestate.UpdateStateRequest) (*devicestate.State, error) {
+					return nil, devicestate.ErrNotFound
+				},
+			},
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithStateService(&tc.s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodPatch,
+				"/states/in_repair",
+				bytes.NewReader([]byte(`{"blocks_delete": true}`)),
+				adminHeaders,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+		})
+	}
+}
+
+func TestHandlerDeleteState(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		s        mock.StateService
+	}{
+		"successfully deletes state": {
+			wantCode: http.StatusNoContent,
+			s: mock.StateService{
+				DeleteStateFunc: func(name string) error {
+					return nil
+				},
+			},
+		},
+		"state not found error": {
+			wantCode: http.StatusNotFound,
+			s: mock.StateService{
+				DeleteStateFunc: func(name string) error {
+					return devicestate.ErrNotFound
+				},
+			},
+		},
+		"built-in state error": {
+			wantCode: http.StatusUnprocessableEntity,
+			s: mock.StateService{
+				DeleteStateFunc: func(name string) error {
+					return devicestate.ErrBuiltinState
+				},
+			},
+		},
+		"state in use error": {
+			wantCode: http.StatusUnprocessableEntity,
+			s: mock.StateService{
+				DeleteStateFunc: func(name string) error {
+					return devicestate.ErrStateInUse
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				nil,
+				httpjson.WithStateService(&tc.s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodDelete,
+				"/states/in_repair",
+				nil,
+				adminHeaders,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+		})
+	}
+}
+
+func TestHandlerCreateDeviceInCustomState(t *testing.T) {
+	states := devicestate.NewService(devicestate.NewMemoryRepository())
+
+	handler := httpjson.NewHandler(
+		&mock.DeviceService{
+			CreateDeviceFunc: func(input device.CreateDeviceRequest) (*device.Device, error) {
+				return device.NewDevice(input.Name, input.Brand, input.State), nil
+			},
+		},
+		validator.New(),
+		httpjson.WithStateService(states),
+	)
+
+	createDevice := func() int {
+		body := bytes.NewReader([]byte(`{"name": "test", "brand": "test", "state": "in_repair"}`))
+		return test.DoHttpRequestWithHeaders(handler, http.MethodPost, "/devices", body, nil).StatusCode
+	}
+
+	// assert unknown states are rejected until they are created
+
+	if code := createDevice(); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status code %d, got: %d", http.StatusUnprocessableEntity, code)
+	}
+
+	if _, err := states.CreateState(t.Context(), devicestate.CreateStateRequest{Name: "in_repair"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if code := createDevice(); code != http.StatusCreated {
+		t.Fatalf("expected status code %d, got: %d", http.StatusCreated, code)
+	}
+}
@@ -0,0 +1,60 @@
+package httpjson
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hferr/device-manager/utils/validator"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/hferr/device-manager/internal/protocols/httpjson"
+
+// middlewareTracing starts a server span for every request, continuing the
+// trace found in the incoming W3C trace context headers. The span is renamed
+// after the chi route pattern once the request has been routed.
+func (h Handler) middlewareTracing(next http.Handler) http.Handler {
+	if h.tracerProvider == nil {
+		return next
+	}
+
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		route := routePattern(r)
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	})
+
+	return otelhttp.NewHandler(named, "http.request",
+		otelhttp.WithTracerProvider(h.tracerProvider),
+		otelhttp.WithPropagators(otel.GetTextMapPropagator()),
+	)
+}
+
+// validate runs struct validation in its own span, checking device states
+// against the state service.
+func (h Handler) validate(ctx context.Context, input any) error {
+	ctx = validator.WithStateLookup(ctx, h.stateExists)
+
+	if h.tracerProvider != nil {
+		var span trace.Span
+		ctx, span = h.tracerProvider.Tracer(tracerName).Start(ctx, "validate")
+		defer span.End()
+
+		if err := h.validator.StructCtx(ctx, input); err != nil {
+			span.SetStatus(codes.Error, "validation failed")
+			return err
+		}
+
+		return nil
+	}
+
+	return h.validator.StructCtx(ctx, input)
+}
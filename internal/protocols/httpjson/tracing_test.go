@@ -0,0 +1,72 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	s := &mock.DeviceService{
+		UpdateDeviceFunc: func(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+			return nil
+		},
+	}
+
+	reqJson, err := json.Marshal(device.UpdateDeviceRequest{Name: test.Ptr("updated")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := httpjson.NewHandler(s, validator.New(), httpjson.WithTracerProvider(tp))
+	resp := test.DoHttpRequestWithHeaders(
+		handler,
+		http.MethodPatch,
+		"/devices/"+uuid.New().String(),
+		bytes.NewReader(reqJson),
+		http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+	)
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status code %d, got: %d", http.StatusNoContent, resp.StatusCode)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected %d spans, got: %d", 2, len(spans))
+	}
+
+	validate, server := spans[0], spans[1]
+
+	// assert the server span is named after the route and continues the incoming trace
+
+	if server.Name() != "PATCH /devices/{id}" {
+		t.Fatalf("expected span name %q, got: %q", "PATCH /devices/{id}", server.Name())
+	}
+
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected incoming trace ID, got: %s", server.SpanContext().TraceID())
+	}
+
+	if validate.Name() != "validate" || validate.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatal("expected validation span to be a child of the server span")
+	}
+}
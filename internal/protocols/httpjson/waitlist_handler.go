@@ -0,0 +1,193 @@
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	e "github.com/hferr/device-manager/internal/api/err"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// @Summary      List waitlist entries
+// @Description  Get the waitlist entries, first come first, optionally only those waiting
+// @Description  for a device, of a holder or with a status.
+// @Tags         waitlist
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        device_id    query   string  false  "Device ID"
+// @Param        holder       query   string  false  "Holder"
+// @Param        status       query   string  false  "Status"  Enums(waiting, offered, assigned, expired, cancelled)
+// @Success      200  {array}   waitlist.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /waitlist [get]
+func (h Handler) ListWaitlistEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := waitlist.Filter{Holder: q.Get("holder"), Status: q.Get("status")}
+
+	if v := q.Get("device_id"); v != "" {
+		var err error
+		if f.DeviceID, err = uuid.Parse(v); err != nil {
+			writeProblem(w, r, e.InvalidQuery)
+			return
+		}
+	}
+
+	es, err := h.waitlistSvs.ListEntries(r.Context(), f)
+	if err != nil {
+		h.handleError(w, r, err, e.WaitlistServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(es.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Join a waitlist
+// @Description  Wait for a device, by device_id, or for any device of a pool, by brand and
+// @Description  name. When a device in an assignable state matches, it is offered to the
+// @Description  first waiter, who must claim it before the offer expires, or assigned to it
+// @Description  right away with auto_assign. The holder defaults to the authenticated principal,
+// @Description  and only admins put someone else on a waitlist.
+// @Tags         waitlist
+// @Accept       json
+// @Produce      json
+// @Param        X-Tenant-ID      header  string  false  "Tenant (organization) ID"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request replay the original response"
+// @Param        entry  body      waitlist.CreateEntryRequest  true  "Join waitlist request object"
+// @Success      201    {object}  waitlist.DTO
+// @Failure      400    {object}  err.Problem
+// @Failure      403    {object}  err.Problem
+// @Failure      404    {object}  err.Problem
+// @Failure      413    {object}  err.Problem
+// @Failure      422    {object}  err.Problem
+// @Failure      500    {object}  err.Problem
+// @Router       /waitlist [post]
+func (h Handler) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	input := waitlist.CreateEntryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeProblem(w, r, e.MalformedBody)
+		return
+	}
+
+	if p, ok := auth.FromContext(r.Context()); ok && input.Holder == "" {
+		input.Holder = p.Subject
+	}
+
+	if !canActFor(r, input.Holder) {
+		writeProblem(w, r, e.Forbidden)
+		return
+	}
+
+	if err := h.validate(r.Context(), input); err != nil {
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	entry, err := h.waitlistSvs.JoinWaitlist(r.Context(), input)
+	if err != nil {
+		h.handleError(w, r, err, e.WaitlistServiceFailed)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(entry.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
+
+// @Summary      Get waitlist entry by ID
+// @Description  Get a single waitlist entry by its ID, pending or not
+// @Tags         waitlist
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id   path      string  true  "Waitlist entry ID"
+// @Success      200  {object}  waitlist.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /waitlist/{id} [get]
+func (h Handler) FindWaitlistEntryByID(w http.ResponseWriter, r *http.Request) {
+	h.waitlistEntryAction(w, r, h.waitlistSvs.FindByID, false)
+}
+
+// @Summary      Claim a waitlist offer
+// @Description  Claim the device offered to a waitlist entry, moving it to 'in_use'. When the
+// @Description  device is no longer available, the entry goes back to waiting with its place.
+// @Description  Only admins claim the offers of someone else.
+// @Tags         waitlist
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id   path      string  true  "Waitlist entry ID"
+// @Success      200  {object}  waitlist.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      403  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      409  {object}  err.Problem
+// @Failure      422  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /waitlist/{id}/claim [post]
+func (h Handler) ClaimWaitlistOffer(w http.ResponseWriter, r *http.Request) {
+	h.waitlistEntryAction(w, r, h.waitlistSvs.ClaimOffer, true)
+}
+
+// @Summary      Leave a waitlist
+// @Description  Cancel a pending waitlist entry, passing its offer, if any, on to the next waiter.
+// @Description  Only admins cancel the entries of someone else.
+// @Tags         waitlist
+// @Produce      json
+// @Param        X-Tenant-ID  header  string  false  "Tenant (organization) ID"
+// @Param        id   path      string  true  "Waitlist entry ID"
+// @Success      200  {object}  waitlist.DTO
+// @Failure      400  {object}  err.Problem
+// @Failure      403  {object}  err.Problem
+// @Failure      404  {object}  err.Problem
+// @Failure      422  {object}  err.Problem
+// @Failure      500  {object}  err.Problem
+// @Router       /waitlist/{id}/cancel [post]
+func (h Handler) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	h.waitlistEntryAction(w, r, h.waitlistSvs.LeaveWaitlist, true)
+}
+
+// waitlistEntryAction runs action on the entry with the ID of the request
+// path, responding with the resulting entry. Actions for the holder only are
+// forbidden to the principals that cannot act for it, see canActFor.
+func (h Handler) waitlistEntryAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, ID uuid.UUID) (*waitlist.Entry, error), holderOnly bool) {
+	ID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, e.InvalidID)
+		return
+	}
+
+	if holderOnly {
+		entry, err := h.waitlistSvs.FindByID(r.Context(), ID)
+		if err != nil {
+			h.handleError(w, r, err, e.WaitlistServiceFailed)
+			return
+		}
+
+		if !canActFor(r, entry.Holder) {
+			writeProblem(w, r, e.Forbidden)
+			return
+		}
+	}
+
+	entry, err := action(r.Context(), ID)
+	if err != nil {
+		h.handleError(w, r, err, e.WaitlistServiceFailed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(entry.ToDto()); err != nil {
+		h.serverError(w, r, err, e.EncodeFailed)
+		return
+	}
+}
@@ -0,0 +1,332 @@
+package httpjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/waitlist"
+	"github.com/hferr/device-manager/internal/protocols/httpjson"
+	"github.com/hferr/device-manager/test"
+	"github.com/hferr/device-manager/test/mock"
+	"github.com/hferr/device-manager/utils/validator"
+
+	"github.com/google/uuid"
+)
+
+func TestHandlerJoinWaitlist(t *testing.T) {
+	deviceID := uuid.New().String()
+
+	var testCases = map[string]struct {
+		wantCode   int
+		wantHolder string
+		headers    http.Header
+		body       string
+		s          mock.WaitlistService
+	}{
+		"successfully joins waitlist of device": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "bob",
+			body:       fmt.Sprintf(`{"device_id": %q, "holder": "bob"}`, deviceID),
+		},
+		"successfully joins waitlist of pool": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "bob",
+			body:       `{"brand": "Google", "name": "Pixel", "holder": "bob", "auto_assign": true}`,
+		},
+		"holder defaults to the principal": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "alice",
+			headers:    http.Header{auth.HeaderKeySubject: {"alice"}},
+			body:       `{"name": "Pixel"}`,
+		},
+		"admin joins for someone else": {
+			wantCode:   http.StatusCreated,
+			wantHolder: "bob",
+			headers:    http.Header{auth.HeaderKeySubject: {"alice"}, auth.HeaderKeyRole: {auth.RoleAdmin}},
+			body:       `{"name": "Pixel", "holder": "bob"}`,
+		},
+		"forbidden - principal joins for someone else": {
+			wantCode: http.StatusForbidden,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}},
+			body:     `{"name": "Pixel", "holder": "bob"}`,
+		},
+		"unprocessable entity - anonymous without holder": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     `{"name": "Pixel"}`,
+		},
+		"bad request - malformed body": {
+			wantCode: http.StatusBadRequest,
+			body:     `{"device_id": "invalid", "holder": "bob"}`,
+		},
+		"invalid target": {
+			wantCode: http.StatusUnprocessableEntity,
+			body:     `{"holder": "bob"}`,
+			s: mock.WaitlistService{
+				JoinWaitlistFunc: func(input waitlist.CreateEntryRequest) (*waitlist.Entry, error) {
+					return nil, waitlist.ErrInvalidTarget
+				},
+			},
+		},
+		"device not found": {
+			wantCode: http.StatusNotFound,
+			body:     fmt.Sprintf(`{"device_id": %q, "holder": "bob"}`, deviceID),
+			s: mock.WaitlistService{
+				JoinWaitlistFunc: func(input waitlist.CreateEntryRequest) (*waitlist.Entry, error) {
+					return nil, device.ErrNotFound
+				},
+			},
+		},
+	}
+
+	v := validator.New()
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var holder string
+			if tc.s.JoinWaitlistFunc == nil {
+				tc.s.JoinWaitlistFunc = func(input waitlist.CreateEntryRequest) (*waitlist.Entry, error) {
+					holder = input.Holder
+					return waitlist.NewEntry(input), nil
+				}
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				v,
+				httpjson.WithWaitlistService(&tc.s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(
+				handler,
+				http.MethodPost,
+				"/waitlist",
+				bytes.NewReader([]byte(tc.body)),
+				tc.headers,
+			)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if holder != tc.wantHolder {
+				t.Fatalf("expected holder %q, got: %q", tc.wantHolder, holder)
+			}
+		})
+	}
+}
+
+func TestHandlerListWaitlistEntries(t *testing.T) {
+	deviceID := uuid.New()
+
+	var testCases = map[string]struct {
+		wantCode   int
+		wantFilter waitlist.Filter
+		query      string
+	}{
+		"successfully lists every entry": {
+			wantCode: http.StatusOK,
+		},
+		"successfully lists filtered entries": {
+			wantCode:   http.StatusOK,
+			wantFilter: waitlist.Filter{DeviceID: deviceID, Holder: "bob", Status: waitlist.StatusWaiting},
+			query:      "?device_id=" + deviceID.String() + "&holder=bob&status=waiting",
+		},
+		"bad request - invalid device id": {
+			wantCode: http.StatusBadRequest,
+			query:    "?device_id=invalid",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var filter waitlist.Filter
+			s := mock.WaitlistService{
+				ListEntriesFunc: func(f waitlist.Filter) (waitlist.Entries, error) {
+					filter = f
+					return waitlist.Entries{}, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(&mock.DeviceService{}, nil, httpjson.WithWaitlistService(&s))
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodGet, "/waitlist"+tc.query, nil, nil)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if filter != tc.wantFilter {
+				t.Fatalf("expected filter %+v, got: %+v", tc.wantFilter, filter)
+			}
+		})
+	}
+}
+
+func TestHandlerClaimWaitlistOffer(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		path     string
+		headers  http.Header
+		err      error
+	}{
+		"successfully claims offer": {
+			wantCode: http.StatusOK,
+		},
+		"holder claims their offer": {
+			wantCode: http.StatusOK,
+			headers:  http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"admin claims the offer of someone else": {
+			wantCode: http.StatusOK,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}, auth.HeaderKeyRole: {auth.RoleAdmin}},
+		},
+		"forbidden - principal claims the offer of someone else": {
+			wantCode: http.StatusForbidden,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}},
+		},
+		"bad request - invalid id": {
+			wantCode: http.StatusBadRequest,
+			path:     "/waitlist/invalid/claim",
+		},
+		"entry not found error": {
+			wantCode: http.StatusNotFound,
+			err:      waitlist.ErrNotFound,
+		},
+		"no pending offer error": {
+			wantCode: http.StatusConflict,
+			err:      waitlist.ErrNotOffered,
+		},
+		"offer withdrawn error": {
+			wantCode: http.StatusConflict,
+			err:      waitlist.ErrOfferWithdrawn,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := mock.WaitlistService{
+				FindByIDFunc: func(ID uuid.UUID) (*waitlist.Entry, error) {
+					return &waitlist.Entry{ID: ID, Holder: "bob", Status: waitlist.StatusOffered}, nil
+				},
+				ClaimOfferFunc: func(ID uuid.UUID) (*waitlist.Entry, error) {
+					if tc.err != nil {
+						return nil, tc.err
+					}
+					return &waitlist.Entry{ID: ID, Status: waitlist.StatusAssigned}, nil
+				},
+			}
+
+			path := tc.path
+			if path == "" {
+				path = "/waitlist/" + uuid.New().String() + "/claim"
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				nil,
+				httpjson.WithWaitlistService(&s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodPost, path, nil, tc.headers)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+		})
+	}
+}
+
+func TestHandlerLeaveWaitlist(t *testing.T) {
+	var testCases = map[string]struct {
+		wantCode int
+		headers  http.Header
+		err      error
+	}{
+		"successfully leaves waitlist": {
+			wantCode: http.StatusOK,
+		},
+		"holder leaves the waitlist": {
+			wantCode: http.StatusOK,
+			headers:  http.Header{auth.HeaderKeySubject: {"bob"}},
+		},
+		"forbidden - principal cancels the entry of someone else": {
+			wantCode: http.StatusForbidden,
+			headers:  http.Header{auth.HeaderKeySubject: {"alice"}},
+		},
+		"entry not found error": {
+			wantCode: http.StatusNotFound,
+			err:      waitlist.ErrNotFound,
+		},
+		"entry not pending error": {
+			wantCode: http.StatusUnprocessableEntity,
+			err:      waitlist.ErrNotPending,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := mock.WaitlistService{
+				FindByIDFunc: func(ID uuid.UUID) (*waitlist.Entry, error) {
+					return &waitlist.Entry{ID: ID, Holder: "bob", Status: waitlist.StatusWaiting}, nil
+				},
+				LeaveWaitlistFunc: func(ID uuid.UUID) (*waitlist.Entry, error) {
+					if tc.err != nil {
+						return nil, tc.err
+					}
+					return &waitlist.Entry{ID: ID, Status: waitlist.StatusCancelled}, nil
+				},
+			}
+
+			handler := httpjson.NewHandler(
+				&mock.DeviceService{},
+				nil,
+				httpjson.WithWaitlistService(&s),
+				httpjson.WithAuthenticator(auth.HeaderAuthenticator{}),
+			)
+			resp := test.DoHttpRequestWithHeaders(handler, http.MethodPost, "/waitlist/"+uuid.New().String()+"/cancel", nil, tc.headers)
+
+			gotCode := resp.StatusCode
+
+			if tc.wantCode != gotCode {
+				t.Fatalf("expected status code %d, got: %d", tc.wantCode, gotCode)
+			}
+
+			if tc.wantCode == http.StatusOK {
+				var dto waitlist.DTO
+				if err := json.NewDecoder(resp.Body).Decode(&dto); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+
+				if dto.Status != waitlist.StatusCancelled {
+					t.Fatalf("expected entry %s, got: %s", waitlist.StatusCancelled, dto.Status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hferr/device-manager/utils/logger"
+)
+
+const (
+	Version12 string = "1.2"
+	Version13 string = "1.3"
+
+	// PolicyDefault uses the cipher suites Go enables by default.
+	PolicyDefault string = "default"
+	// PolicyIntermediate only allows ECDHE key exchanges with AEAD ciphers.
+	PolicyIntermediate string = "intermediate"
+	// PolicyModern only allows TLS 1.3, whose cipher suites are all AEAD.
+	PolicyModern string = "modern"
+
+	ClientAuthNone     string = "none"
+	ClientAuthOptional string = "optional"
+	ClientAuthRequired string = "required"
+)
+
+var ErrNoCACertificates = errors.New("no certificates found in the client CA bundle")
+
+// intermediateCipherSuites are the TLS 1.2 suites allowed by
+// PolicyIntermediate. TLS 1.3 suites are not configurable.
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is "1.2" or "1.3".
+	MinVersion string
+	// CipherPolicy is one of "default", "intermediate" or "modern".
+	CipherPolicy string
+	// ClientCAFile is the PEM bundle client certificates are verified
+	// against.
+	ClientCAFile string
+	// ClientAuth is one of "none", "optional", verifying the certificates
+	// clients present, or "required".
+	ClientAuth string
+}
+
+// Reloader serves the certificate, and client CA bundle, read from the files
+// of a Config and reads them again when they change, so rotated certificates
+// are picked up without a restart.
+type Reloader struct {
+	cfg    Config
+	base   *tls.Config
+	logger *slog.Logger
+
+	current atomic.Pointer[tls.Config]
+
+	mu sync.Mutex
+	// stamps identify the versions of the files currently loaded.
+	stamps []stamp
+}
+
+type Option func(*Reloader)
+
+// WithLogger sets the logger for reloads, which are discarded by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(r *Reloader) {
+		r.logger = l
+	}
+}
+
+// New loads the files of cfg, failing when any of them cannot be read.
+func New(cfg Config, opts ...Option) (*Reloader, error) {
+	base, err := baseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reloader{
+		cfg:    cfg,
+		base:   base,
+		logger: logger.Discard(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// baseConfig returns the settings of cfg that do not depend on its files.
+func baseConfig(cfg Config) (*tls.Config, error) {
+	c := &tls.Config{
+		// set, as ServeTLS only does it for the server's own config
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	switch cfg.MinVersion {
+	case Version12, "":
+		c.MinVersion = tls.VersionTLS12
+	case Version13:
+		c.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid minimum TLS version %q", cfg.MinVersion)
+	}
+
+	switch cfg.CipherPolicy {
+	case PolicyDefault:
+	case PolicyIntermediate, "":
+		c.CipherSuites = intermediateCipherSuites
+	case PolicyModern:
+		c.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid TLS cipher policy %q", cfg.CipherPolicy)
+	}
+
+	switch cfg.ClientAuth {
+	case ClientAuthNone, "":
+		c.ClientAuth = tls.NoClientCert
+	case ClientAuthOptional:
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequired:
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid TLS client auth %q", cfg.ClientAuth)
+	}
+
+	if c.ClientAuth != tls.NoClientCert && cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("TLS client auth %q requires a client CA bundle", cfg.ClientAuth)
+	}
+
+	return c, nil
+}
+
+// TLSConfig returns the config to serve with, which always hands out the
+// files last loaded.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.base.MinVersion,
+		NextProtos: r.base.NextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Reload reads the files again if any of them changed since they were last
+// loaded, reporting whether they did. The files in use are kept when reading
+// them fails.
+func (r *Reloader) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamps, err := r.stampFiles()
+	if err != nil {
+		return false, err
+	}
+	if slices.EqualFunc(stamps, r.stamps, stamp.equal) {
+		return false, nil
+	}
+
+	c := r.base.Clone()
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("load certificate: %w", err)
+	}
+	c.Certificates = []tls.Certificate{cert}
+
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("load client CA bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, ErrNoCACertificates
+		}
+		c.ClientCAs = pool
+	}
+
+	r.current.Store(c)
+	r.stamps = stamps
+
+	r.logger.Info("TLS certificate loaded",
+		slog.String("subject", cert.Leaf.Subject.String()),
+		slog.Time("not_after", cert.Leaf.NotAfter),
+	)
+
+	return true, nil
+}
+
+// Run reloads the files every interval until ctx is done.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if _, err := r.Reload(); err != nil {
+			r.logger.ErrorContext(ctx, "failed to reload TLS certificate, keeping the current one", slog.Any("error", err))
+		}
+	}
+}
+
+// stamp identifies a version of a file. Files are stat'ed through symlinks,
+// so the atomic swaps of mounted Kubernetes secrets are noticed.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func (s stamp) equal(o stamp) bool {
+	return s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+func (r *Reloader) stampFiles() ([]stamp, error) {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+
+	stamps := make([]stamp, 0, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, stamp{modTime: info.ModTime(), size: info.Size()})
+	}
+
+	return stamps, nil
+}
@@ -0,0 +1,363 @@
+package tlsconfig_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/auth"
+	"github.com/hferr/device-manager/internal/tlsconfig"
+	"github.com/hferr/device-manager/test"
+)
+
+// serve serves the subject of the request principal over TLS, returning the
+// URL of the server.
+func serve(t *testing.T, r *tlsconfig.Reloader) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &http.Server{
+		TLSConfig: r.TLSConfig(),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := auth.CertificateAuthenticator{}.Authenticate(r)
+			if p != nil {
+				io.WriteString(w, p.Subject)
+			}
+		}),
+	}
+	go s.ServeTLS(ln, "", "")
+	t.Cleanup(func() { s.Close() })
+
+	return "https://" + ln.Addr().String()
+}
+
+// get requests url on a new connection, returning the body and the common
+// name of the server certificate.
+func get(url string, c *tls.Config) (string, string, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: c, DisableKeepAlives: true}}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(body), resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+}
+
+func clientConfig(t *testing.T, ca *test.CA) *tls.Config {
+	t.Helper()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.CertPEM)
+
+	return &tls.Config{RootCAs: pool}
+}
+
+func clientCertificate(t *testing.T, ca *test.CA, cn string) tls.Certificate {
+	t.Helper()
+
+	certPEM, keyPEM := ca.Issue(t, pkix.Name{CommonName: cn})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// writeServerCertificate writes the certificate and key of cn, dated age from
+// now, so a rewrite is noticed even within the resolution of the file system
+// clock.
+func writeServerCertificate(t *testing.T, ca *test.CA, dir, cn string, age time.Duration) (string, string) {
+	t.Helper()
+
+	certPEM, keyPEM := ca.Issue(t, pkix.Name{CommonName: cn})
+	certFile := test.WriteFile(t, dir, "tls.crt", certPEM)
+	keyFile := test.WriteFile(t, dir, "tls.key", keyPEM)
+
+	mtime := time.Now().Add(age)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return certFile, keyFile
+}
+
+func TestReloader(t *testing.T) {
+	t.Parallel()
+
+	ca := test.NewCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCertificate(t, ca, dir, "server-1", -time.Minute)
+
+	r, err := tlsconfig.New(tlsconfig.Config{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	url := serve(t, r)
+
+	_, cn, err := get(url, clientConfig(t, ca))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if cn != "server-1" {
+		t.Fatalf("expected certificate %q, got: %q", "server-1", cn)
+	}
+
+	// assert unchanged files are not loaded again
+
+	if reloaded, err := r.Reload(); err != nil || reloaded {
+		t.Fatalf("expected no reload, got: %t, %v", reloaded, err)
+	}
+
+	// assert rotated certificates are served without a restart
+
+	writeServerCertificate(t, ca, dir, "server-2", 0)
+
+	if reloaded, err := r.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected a reload, got: %t, %v", reloaded, err)
+	}
+
+	_, cn, err = get(url, clientConfig(t, ca))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if cn != "server-2" {
+		t.Fatalf("expected certificate %q, got: %q", "server-2", cn)
+	}
+
+	// assert a broken certificate does not replace the served one
+
+	test.WriteFile(t, dir, "tls.crt", []byte("broken"))
+
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	if _, cn, err = get(url, clientConfig(t, ca)); err != nil || cn != "server-2" {
+		t.Fatalf("expected certificate %q, got: %q, %v", "server-2", cn, err)
+	}
+}
+
+func TestReloaderClientAuth(t *testing.T) {
+	t.Parallel()
+
+	ca := test.NewCA(t)
+	otherCA := test.NewCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCertificate(t, ca, dir, "server", 0)
+	caFile := test.WriteFile(t, dir, "ca.crt", ca.CertPEM)
+
+	var testCases = map[string]struct {
+		clientAuth string
+		// clientCA issues the client certificate, if any
+		clientCA    *test.CA
+		wantSubject string
+		wantErr     bool
+	}{
+		"required with a trusted certificate": {
+			clientAuth:  tlsconfig.ClientAuthRequired,
+			clientCA:    ca,
+			wantSubject: "client",
+		},
+		"required without a certificate": {
+			clientAuth: tlsconfig.ClientAuthRequired,
+			wantErr:    true,
+		},
+		"required with an untrusted certificate": {
+			clientAuth: tlsconfig.ClientAuthRequired,
+			clientCA:   otherCA,
+			wantErr:    true,
+		},
+		"optional without a certificate": {
+			clientAuth: tlsconfig.ClientAuthOptional,
+		},
+		"optional with a trusted certificate": {
+			clientAuth:  tlsconfig.ClientAuthOptional,
+			clientCA:    ca,
+			wantSubject: "client",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := tlsconfig.New(tlsconfig.Config{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				ClientCAFile: caFile,
+				ClientAuth:   tc.clientAuth,
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			url := serve(t, r)
+
+			c := clientConfig(t, ca)
+			if tc.clientCA != nil {
+				c.Certificates = []tls.Certificate{clientCertificate(t, tc.clientCA, "client")}
+			}
+
+			subject, _, err := get(url, c)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if subject != tc.wantSubject {
+				t.Fatalf("expected principal %q, got: %q", tc.wantSubject, subject)
+			}
+		})
+	}
+}
+
+func TestReloaderPolicies(t *testing.T) {
+	t.Parallel()
+
+	ca := test.NewCA(t)
+	certFile, keyFile := writeServerCertificate(t, ca, t.TempDir(), "server", 0)
+
+	cbc := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}
+
+	var testCases = map[string]struct {
+		minVersion   string
+		cipherPolicy string
+		maxVersion   uint16
+		cipherSuites []uint16
+		wantErr      bool
+	}{
+		"tls 1.2 client": {
+			maxVersion: tls.VersionTLS12,
+		},
+		"tls 1.2 client below the minimum version": {
+			minVersion: tlsconfig.Version13,
+			maxVersion: tls.VersionTLS12,
+			wantErr:    true,
+		},
+		"modern policy requires tls 1.3": {
+			cipherPolicy: tlsconfig.PolicyModern,
+			maxVersion:   tls.VersionTLS12,
+			wantErr:      true,
+		},
+		"intermediate policy rejects cbc suites": {
+			cipherPolicy: tlsconfig.PolicyIntermediate,
+			maxVersion:   tls.VersionTLS12,
+			cipherSuites: cbc,
+			wantErr:      true,
+		},
+		"default policy accepts cbc suites": {
+			cipherPolicy: tlsconfig.PolicyDefault,
+			maxVersion:   tls.VersionTLS12,
+			cipherSuites: cbc,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := tlsconfig.New(tlsconfig.Config{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				MinVersion:   tc.minVersion,
+				CipherPolicy: tc.cipherPolicy,
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			url := serve(t, r)
+
+			c := clientConfig(t, ca)
+			c.MaxVersion = tc.maxVersion
+			c.CipherSuites = tc.cipherSuites
+
+			_, _, err = get(url, c)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error, got none")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	ca := test.NewCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCertificate(t, ca, dir, "server", 0)
+
+	var testCases = map[string]struct {
+		cfg     tlsconfig.Config
+		wantErr error
+	}{
+		"invalid minimum version": {
+			cfg: tlsconfig.Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"},
+		},
+		"client auth without a CA bundle": {
+			cfg: tlsconfig.Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: tlsconfig.ClientAuthRequired},
+		},
+		"empty CA bundle": {
+			cfg: tlsconfig.Config{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				ClientCAFile: test.WriteFile(t, dir, "empty.crt", nil),
+				ClientAuth:   tlsconfig.ClientAuthRequired,
+			},
+			wantErr: tlsconfig.ErrNoCACertificates,
+		},
+		"missing key": {
+			cfg: tlsconfig.Config{CertFile: certFile, KeyFile: dir + "/missing.key"},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tlsconfig.New(tc.cfg)
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package tracing_test
+
+import (
+	"testing"
+
+	"github.com/hferr/device-manager/internal/tracing"
+)
+
+func TestSetup(t *testing.T) {
+	var testCases = map[string]struct {
+		exporter string
+		wantErr  bool
+	}{
+		"none":             {exporter: tracing.ExporterNone},
+		"stdout":           {exporter: tracing.ExporterStdout},
+		"invalid exporter": {exporter: "zipkin", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			_, shutdown, err := tracing.Setup(t.Context(), tracing.Config{
+				Exporter:    tc.exporter,
+				SampleRatio: 1,
+				ServiceName: "test",
+			})
+			if err != nil && !tc.wantErr {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err == nil {
+				if tc.wantErr {
+					t.Fatal("expected error, got none")
+				}
+
+				if err := shutdown(t.Context()); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+		})
+	}
+}
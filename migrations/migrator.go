@@ -1,27 +1,201 @@
 package migrations
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
 
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
 )
 
-const dialect = "postgres"
+// Dialects with a migrations directory, named after their gorm dialector.
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// versionLayout formats the version of new migrations from their creation
+// time.
+const versionLayout = "20060102150405"
+
+var (
+	ErrPendingMigrations = errors.New("database migrations are pending")
+	ErrUnknownDialect    = errors.New("unknown migrations dialect")
+	ErrInvalidName       = errors.New("migration names may only contain lowercase letters, digits and underscores")
+)
 
-//go:embed *.sql
+//go:embed postgres/*.sql sqlite/*.sql
 var embedMigrations embed.FS
 
-func MaybeApplyMigrations(dbHandle *sql.DB) error {
-	goose.SetBaseFS(embedMigrations)
+// gooseDialects maps each dialect to the goose dialect running its migrations.
+var gooseDialects = map[string]goose.Dialect{
+	DialectPostgres: goose.DialectPostgres,
+	DialectSQLite:   goose.DialectSQLite3,
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// migrationTemplate is the content of new migrations.
+const migrationTemplate = `-- +goose Up
+
+-- +goose Down
+`
 
-	if err := goose.SetDialect(dialect); err != nil {
+func MaybeApplyMigrations(dbHandle *sql.DB, dialect string) error {
+	m, err := NewMigrator(dbHandle, dialect)
+	if err != nil {
 		return err
 	}
 
-	if err := goose.Up(dbHandle, "."); err != nil {
+	if _, err := m.Up(context.Background()); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// Migrator runs the embedded migrations of a dialect. On PostgreSQL, it holds
+// an advisory lock while migrating, so replicas starting together do not run
+// the same migrations concurrently.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func NewMigrator(dbHandle *sql.DB, dialect string) (*Migrator, error) {
+	p, err := newProvider(dbHandle, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{provider: p}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Redo rolls back the latest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := m.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	up, err := m.provider.ApplyVersion(ctx, down.Source.Version, true)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Status returns every migration, applied or pending.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Version returns the version of the database and of the latest migration.
+func (m *Migrator) Version(ctx context.Context) (current, latest int64, err error) {
+	return m.provider.GetVersions(ctx)
+}
+
+// VersionChecker compares the database version with the embedded migrations.
+type VersionChecker struct {
+	provider *goose.Provider
+}
+
+func NewVersionChecker(dbHandle *sql.DB, dialect string) (*VersionChecker, error) {
+	p, err := newProvider(dbHandle, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VersionChecker{provider: p}, nil
+}
+
+// Check returns ErrPendingMigrations if the database is not at the latest
+// embedded migration.
+func (c *VersionChecker) Check(ctx context.Context) error {
+	current, target, err := c.provider.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current < target {
+		return fmt.Errorf("%w: database is at version %d, latest is %d", ErrPendingMigrations, current, target)
+	}
+
+	return nil
+}
+
+// Create writes an empty migration named name, with the same version, to the
+// directory of every dialect under dir, returning the paths of the files.
+func Create(dir, name string, now time.Time) ([]string, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	version := now.UTC().Format(versionLayout)
+
+	var paths []string
+	for _, dialect := range []string{DialectPostgres, DialectSQLite} {
+		path := filepath.Join(dir, dialect, version+"_"+name+".sql")
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return paths, err
+		}
+
+		_, err = f.WriteString(migrationTemplate)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// newProvider returns a goose provider running the migrations of dialect.
+func newProvider(dbHandle *sql.DB, dialect string) (*goose.Provider, error) {
+	gooseDialect, ok := gooseDialects[dialect]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDialect, dialect)
+	}
+
+	fsys, err := fs.Sub(embedMigrations, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []goose.ProviderOption
+
+	// SQLite serializes writers, so only PostgreSQL needs a lock
+	if dialect == DialectPostgres {
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, goose.WithSessionLocker(locker))
+	}
+
+	return goose.NewProvider(gooseDialect, dbHandle, fsys, opts...)
+}
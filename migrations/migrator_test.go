@@ -0,0 +1,171 @@
+package migrations_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/hferr/device-manager/migrations"
+	"github.com/hferr/device-manager/test"
+)
+
+func TestMigrator(t *testing.T) {
+	for _, driver := range test.Drivers {
+		driver := driver
+
+		t.Run(driver, func(t *testing.T) {
+			cleanup, db := test.SetupTestDB(t, driver)
+			t.Cleanup(cleanup)
+
+			dbHandle, err := db.DB()
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			m, err := migrations.NewMigrator(dbHandle, db.Dialector.Name())
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			ctx := context.Background()
+
+			current, latest, err := m.Version(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if current != latest {
+				t.Fatalf("expected the database at version %d, got: %d", latest, current)
+			}
+
+			// assert every migration rolls back
+
+			for current > 0 {
+				if _, err := m.Down(ctx); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+
+				if current, _, err = m.Version(ctx); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+			}
+
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			for _, s := range statuses {
+				if s.State != goose.StatePending {
+					t.Fatalf("expected migration %s pending, got: %s", s.Source.Path, s.State)
+				}
+			}
+
+			// assert every migration applies again
+
+			results, err := m.Up(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if len(results) != len(statuses) {
+				t.Fatalf("expected %d migrations applied, got: %d", len(statuses), len(results))
+			}
+
+			// assert redo rolls back and applies the latest migration
+
+			results, err = m.Redo(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if len(results) != 2 || results[0].Direction != "down" || results[1].Direction != "up" {
+				t.Fatalf("expected a down and an up migration, got: %v", results)
+			}
+
+			if current, _, err = m.Version(ctx); err != nil || current != latest {
+				t.Fatalf("expected the database at version %d, got: %d, %v", latest, current, err)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+
+	var testCases = map[string]struct {
+		name      string
+		wantFiles []string
+		wantErr   error
+	}{
+		"valid name": {
+			name: "add_things",
+			wantFiles: []string{
+				filepath.Join("postgres", "20261019123000_add_things.sql"),
+				filepath.Join("sqlite", "20261019123000_add_things.sql"),
+			},
+		},
+		"invalid name": {
+			name:    "Add things",
+			wantErr: migrations.ErrInvalidName,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for _, dialect := range []string{migrations.DialectPostgres, migrations.DialectSQLite} {
+				if err := os.Mkdir(filepath.Join(dir, dialect), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			paths, err := migrations.Create(dir, tc.name, now)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected error %v, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if len(paths) != len(tc.wantFiles) {
+				t.Fatalf("expected %d files, got: %v", len(tc.wantFiles), paths)
+			}
+
+			for i, want := range tc.wantFiles {
+				if paths[i] != filepath.Join(dir, want) {
+					t.Fatalf("expected file %s, got: %s", filepath.Join(dir, want), paths[i])
+				}
+
+				content, err := os.ReadFile(paths[i])
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+
+				if string(content) != "-- +goose Up\n\n-- +goose Down\n" {
+					t.Fatalf("expected an empty migration, got: %q", content)
+				}
+			}
+
+			// assert existing migrations are not overwritten
+
+			if _, err := migrations.Create(dir, tc.name, now); !errors.Is(err, os.ErrExist) {
+				t.Fatalf("expected error %v, got: %v", os.ErrExist, err)
+			}
+		})
+	}
+}
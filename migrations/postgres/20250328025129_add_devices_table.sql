@@ -10,5 +10,5 @@ CREATE TABLE devices(
 );
 
 -- +goose Down
-DROP TYPE IF EXISTS device_states;
 DROP TABLE IF EXISTS devices;
+DROP TYPE IF EXISTS device_states;
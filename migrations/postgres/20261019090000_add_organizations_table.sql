@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE organizations(
+    id uuid PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- existing devices are moved to the default organization
+INSERT INTO organizations(id, name, created_at)
+VALUES ('00000000-0000-0000-0000-000000000001', 'default', NOW());
+
+ALTER TABLE devices
+    ADD COLUMN tenant_id uuid NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001'
+    REFERENCES organizations(id) ON DELETE RESTRICT;
+ALTER TABLE devices ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX devices_tenant_id_idx ON devices(tenant_id);
+
+-- defense in depth: once the application sets app.tenant_id for a transaction
+-- only that tenant's rows are visible. Superusers always bypass row-level security.
+ALTER TABLE devices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE devices FORCE ROW LEVEL SECURITY;
+CREATE POLICY devices_tenant_isolation ON devices
+    USING (
+        COALESCE(current_setting('app.tenant_id', true), '') = ''
+        OR tenant_id = current_setting('app.tenant_id', true)::uuid
+    );
+
+-- +goose Down
+DROP POLICY IF EXISTS devices_tenant_isolation ON devices;
+ALTER TABLE devices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE devices DISABLE ROW LEVEL SECURITY;
+DROP INDEX IF EXISTS devices_tenant_id_idx;
+ALTER TABLE devices DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS organizations;
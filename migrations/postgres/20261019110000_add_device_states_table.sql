@@ -0,0 +1,36 @@
+-- +goose Up
+-- states become rows instead of enum values, so they can be managed through
+-- the API. The enum type is dropped first, as the table takes its name.
+ALTER TABLE devices ALTER COLUMN state TYPE VARCHAR(64) USING state::text;
+DROP TYPE device_states;
+
+CREATE TABLE device_states(
+    name VARCHAR(64) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    is_assignable BOOLEAN NOT NULL DEFAULT FALSE,
+    is_terminal BOOLEAN NOT NULL DEFAULT FALSE,
+    locks_fields BOOLEAN NOT NULL DEFAULT FALSE,
+    blocks_delete BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- the built-in states keep the rules the service used to hardcode
+INSERT INTO device_states(name, description, is_assignable, is_terminal, locks_fields, blocks_delete, created_at)
+VALUES
+    ('available', 'ready to be assigned', TRUE, FALSE, FALSE, FALSE, NOW()),
+    ('in_use', 'assigned, its name and brand cannot change and it cannot be deleted', FALSE, FALSE, TRUE, TRUE, NOW()),
+    ('inactive', 'out of service', FALSE, FALSE, FALSE, FALSE, NOW());
+
+ALTER TABLE devices ADD CONSTRAINT devices_state_fkey
+    FOREIGN KEY (state) REFERENCES device_states(name) ON DELETE RESTRICT;
+
+CREATE INDEX devices_state_idx ON devices(state);
+
+-- +goose Down
+-- fails while devices are in states added through the API
+DROP INDEX IF EXISTS devices_state_idx;
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_state_fkey;
+DROP TABLE IF EXISTS device_states;
+
+CREATE TYPE device_states AS ENUM('available', 'in_use', 'inactive');
+ALTER TABLE devices ALTER COLUMN state TYPE device_states USING state::device_states;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- lets the exclusion constraint compare device ids with =
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE reservations(
+    id uuid PRIMARY KEY,
+    tenant_id uuid NOT NULL REFERENCES organizations(id) ON DELETE RESTRICT,
+    device_id uuid NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    holder VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT reservations_period_check CHECK (ends_at > starts_at),
+    -- a device cannot be reserved twice at the same time, cancelled
+    -- reservations aside
+    CONSTRAINT reservations_no_overlap EXCLUDE USING gist (
+        device_id WITH =,
+        tstzrange(starts_at, ends_at) WITH &&
+    ) WHERE (cancelled_at IS NULL)
+);
+
+CREATE INDEX reservations_tenant_id_idx ON reservations(tenant_id, starts_at);
+CREATE INDEX reservations_pending_idx ON reservations(starts_at)
+    WHERE started_at IS NULL AND cancelled_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS reservations;
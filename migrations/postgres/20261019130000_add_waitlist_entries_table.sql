@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE waitlist_entries(
+    id uuid PRIMARY KEY,
+    tenant_id uuid NOT NULL REFERENCES organizations(id) ON DELETE RESTRICT,
+    holder VARCHAR(255) NOT NULL,
+    device_id uuid REFERENCES devices(id) ON DELETE CASCADE,
+    brand VARCHAR(255) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    auto_assign BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(16) NOT NULL,
+    offered_device_id uuid,
+    offer_expires_at TIMESTAMPTZ,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX waitlist_entries_tenant_id_idx ON waitlist_entries(tenant_id, created_at);
+CREATE INDEX waitlist_entries_pending_idx ON waitlist_entries(created_at)
+    WHERE status IN ('waiting', 'offered');
+-- a device is offered to a single waiter at a time, whichever replica
+-- dispatches it
+CREATE UNIQUE INDEX waitlist_entries_offer_idx ON waitlist_entries(offered_device_id)
+    WHERE status = 'offered';
+
+-- +goose Down
+DROP TABLE IF EXISTS waitlist_entries;
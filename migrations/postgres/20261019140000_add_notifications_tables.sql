@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE notifications(
+    id uuid PRIMARY KEY,
+    tenant_id uuid NOT NULL REFERENCES organizations(id) ON DELETE RESTRICT,
+    recipient VARCHAR(255) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    address VARCHAR(2048) NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(16) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX notifications_tenant_id_idx ON notifications(tenant_id, created_at);
+CREATE INDEX notifications_queued_idx ON notifications(created_at) WHERE status = 'queued';
+
+CREATE TABLE notification_preferences(
+    tenant_id uuid NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    recipient VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    webhook_url VARCHAR(2048) NOT NULL DEFAULT '',
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant_id, recipient)
+);
+
+-- reservations are checked for being overdue once, when they end
+ALTER TABLE reservations ADD COLUMN overdue_checked_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE reservations DROP COLUMN IF EXISTS overdue_checked_at;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS notifications;
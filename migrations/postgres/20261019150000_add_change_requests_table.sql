@@ -0,0 +1,24 @@
+-- +goose Up
+-- change requests outlive their device, which they may delete, so device_id
+-- has no foreign key
+CREATE TABLE change_requests(
+    id uuid PRIMARY KEY,
+    tenant_id uuid NOT NULL REFERENCES organizations(id) ON DELETE RESTRICT,
+    device_id uuid NOT NULL,
+    operation VARCHAR(16) NOT NULL,
+    payload JSONB,
+    policy VARCHAR(255) NOT NULL,
+    requested_by VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    decided_by VARCHAR(255) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX change_requests_tenant_id_idx ON change_requests(tenant_id, created_at);
+CREATE INDEX change_requests_device_id_idx ON change_requests(device_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS change_requests;
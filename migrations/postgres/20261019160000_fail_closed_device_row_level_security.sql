@@ -0,0 +1,22 @@
+-- +goose Up
+-- the tenant isolation fails closed: connections that did not set
+-- app.tenant_id see no devices at all. The queries spanning every tenant, such
+-- as the inventory counts, set app.all_tenants instead, which may only read
+-- devices. Superusers and roles with BYPASSRLS still bypass row-level
+-- security.
+DROP POLICY IF EXISTS devices_tenant_isolation ON devices;
+CREATE POLICY devices_tenant_isolation ON devices
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
+
+CREATE POLICY devices_all_tenants ON devices
+    FOR SELECT
+    USING (current_setting('app.all_tenants', true) = 'on');
+
+-- +goose Down
+DROP POLICY IF EXISTS devices_all_tenants ON devices;
+DROP POLICY IF EXISTS devices_tenant_isolation ON devices;
+CREATE POLICY devices_tenant_isolation ON devices
+    USING (
+        COALESCE(current_setting('app.tenant_id', true), '') = ''
+        OR tenant_id = current_setting('app.tenant_id', true)::uuid
+    );
@@ -0,0 +1,16 @@
+-- +goose Up
+-- reservations whose device could not be handed over when they started
+ALTER TABLE reservations ADD COLUMN failed_at TIMESTAMPTZ;
+ALTER TABLE reservations ADD COLUMN failure VARCHAR(255) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS reservations_pending_idx;
+CREATE INDEX reservations_pending_idx ON reservations(starts_at)
+    WHERE started_at IS NULL AND cancelled_at IS NULL AND failed_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS reservations_pending_idx;
+CREATE INDEX reservations_pending_idx ON reservations(starts_at)
+    WHERE started_at IS NULL AND cancelled_at IS NULL;
+
+ALTER TABLE reservations DROP COLUMN failure;
+ALTER TABLE reservations DROP COLUMN failed_at;
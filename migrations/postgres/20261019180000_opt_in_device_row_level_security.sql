@@ -0,0 +1,29 @@
+-- +goose Up
+-- row-level security is enabled on the devices table by the API when
+-- DB_ROW_LEVEL_SECURITY is set, see device.SetupRowLevelSecurity, since its
+-- fail-closed policies hide every device from connections that do not set
+-- app.tenant_id. The policies are kept, and only apply once it is enabled.
+ALTER TABLE devices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE devices DISABLE ROW LEVEL SECURITY;
+
+-- the inventory counts no longer switch to the device_manager_all_tenants
+-- role, created by earlier versions of the migrations, but set
+-- app.all_tenants
+DROP POLICY IF EXISTS devices_all_tenants ON devices;
+CREATE POLICY devices_all_tenants ON devices
+    FOR SELECT
+    USING (current_setting('app.all_tenants', true) = 'on');
+
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT FROM pg_roles WHERE rolname = 'device_manager_all_tenants') THEN
+        REVOKE SELECT ON devices FROM device_manager_all_tenants;
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+ALTER TABLE devices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE devices FORCE ROW LEVEL SECURITY;
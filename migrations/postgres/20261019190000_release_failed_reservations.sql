@@ -0,0 +1,14 @@
+-- +goose Up
+-- failed reservations no longer hold their device, as cancelled ones
+ALTER TABLE reservations DROP CONSTRAINT reservations_no_overlap;
+ALTER TABLE reservations ADD CONSTRAINT reservations_no_overlap EXCLUDE USING gist (
+    device_id WITH =,
+    tstzrange(starts_at, ends_at) WITH &&
+) WHERE (cancelled_at IS NULL AND failed_at IS NULL);
+
+-- +goose Down
+ALTER TABLE reservations DROP CONSTRAINT reservations_no_overlap;
+ALTER TABLE reservations ADD CONSTRAINT reservations_no_overlap EXCLUDE USING gist (
+    device_id WITH =,
+    tstzrange(starts_at, ends_at) WITH &&
+) WHERE (cancelled_at IS NULL);
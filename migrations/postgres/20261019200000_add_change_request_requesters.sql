@@ -0,0 +1,7 @@
+-- +goose Up
+-- the principal approved changes are applied on behalf of, null for the
+-- requests made anonymously or before it was recorded
+ALTER TABLE change_requests ADD COLUMN requester JSONB;
+
+-- +goose Down
+ALTER TABLE change_requests DROP COLUMN requester;
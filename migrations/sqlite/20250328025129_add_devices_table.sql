@@ -0,0 +1,12 @@
+-- +goose Up
+-- SQLite has no enum types, the states are enforced with a CHECK constraint
+CREATE TABLE devices(
+    id TEXT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    state TEXT NOT NULL CHECK (state IN ('available', 'in_use', 'inactive')),
+    created_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS devices;
@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE organizations(
+    id TEXT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL
+);
+
+-- existing devices are moved to the default organization
+INSERT INTO organizations(id, name, created_at)
+VALUES ('00000000-0000-0000-0000-000000000001', 'default', CURRENT_TIMESTAMP);
+
+-- SQLite cannot add a foreign key column with a default to an existing table,
+-- so the table is rebuilt. The foreign key keeps the default NO ACTION, which
+-- like RESTRICT is checked at the end of each statement, because SQLite reports
+-- RESTRICT violations as trigger errors. Row-level security is not available.
+CREATE TABLE devices_new(
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES organizations(id),
+    name VARCHAR(255) NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    state TEXT NOT NULL CHECK (state IN ('available', 'in_use', 'inactive')),
+    created_at DATETIME NOT NULL
+);
+
+INSERT INTO devices_new(id, tenant_id, name, brand, state, created_at)
+SELECT id, '00000000-0000-0000-0000-000000000001', name, brand, state, created_at FROM devices;
+
+DROP TABLE devices;
+ALTER TABLE devices_new RENAME TO devices;
+
+CREATE INDEX devices_tenant_id_idx ON devices(tenant_id);
+
+-- +goose Down
+CREATE TABLE devices_old(
+    id TEXT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    state TEXT NOT NULL CHECK (state IN ('available', 'in_use', 'inactive')),
+    created_at DATETIME NOT NULL
+);
+
+INSERT INTO devices_old(id, name, brand, state, created_at)
+SELECT id, name, brand, state, created_at FROM devices;
+
+DROP TABLE devices;
+ALTER TABLE devices_old RENAME TO devices;
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,58 @@
+-- +goose Up
+CREATE TABLE device_states(
+    name VARCHAR(64) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    is_assignable BOOLEAN NOT NULL DEFAULT FALSE,
+    is_terminal BOOLEAN NOT NULL DEFAULT FALSE,
+    locks_fields BOOLEAN NOT NULL DEFAULT FALSE,
+    blocks_delete BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL
+);
+
+-- the built-in states keep the rules the service used to hardcode
+INSERT INTO device_states(name, description, is_assignable, is_terminal, locks_fields, blocks_delete, created_at)
+VALUES
+    ('available', 'ready to be assigned', TRUE, FALSE, FALSE, FALSE, CURRENT_TIMESTAMP),
+    ('in_use', 'assigned, its name and brand cannot change and it cannot be deleted', FALSE, FALSE, TRUE, TRUE, CURRENT_TIMESTAMP),
+    ('inactive', 'out of service', FALSE, FALSE, FALSE, FALSE, CURRENT_TIMESTAMP);
+
+-- SQLite cannot drop the CHECK constraint on the states or add a foreign key
+-- to an existing table, so the table is rebuilt
+CREATE TABLE devices_new(
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES organizations(id),
+    name VARCHAR(255) NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    state VARCHAR(64) NOT NULL REFERENCES device_states(name),
+    created_at DATETIME NOT NULL
+);
+
+INSERT INTO devices_new(id, tenant_id, name, brand, state, created_at)
+SELECT id, tenant_id, name, brand, state, created_at FROM devices;
+
+DROP TABLE devices;
+ALTER TABLE devices_new RENAME TO devices;
+
+CREATE INDEX devices_tenant_id_idx ON devices(tenant_id);
+CREATE INDEX devices_state_idx ON devices(state);
+
+-- +goose Down
+-- fails while devices are in states added through the API
+CREATE TABLE devices_old(
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES organizations(id),
+    name VARCHAR(255) NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    state TEXT NOT NULL CHECK (state IN ('available', 'in_use', 'inactive')),
+    created_at DATETIME NOT NULL
+);
+
+INSERT INTO devices_old(id, tenant_id, name, brand, state, created_at)
+SELECT id, tenant_id, name, brand, state, created_at FROM devices;
+
+DROP TABLE devices;
+ALTER TABLE devices_old RENAME TO devices;
+
+CREATE INDEX devices_tenant_id_idx ON devices(tenant_id);
+
+DROP TABLE IF EXISTS device_states;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE reservations(
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES organizations(id),
+    device_id TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    holder VARCHAR(255) NOT NULL,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME NOT NULL,
+    started_at DATETIME,
+    cancelled_at DATETIME,
+    created_at DATETIME NOT NULL,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX reservations_tenant_id_idx ON reservations(tenant_id, starts_at);
+CREATE INDEX reservations_device_id_idx ON reservations(device_id, starts_at);
+
+-- SQLite has no exclusion constraints, so overlaps are rejected by a trigger.
+-- Times are stored in UTC, so they compare as text.
+-- +goose StatementBegin
+CREATE TRIGGER reservations_no_overlap BEFORE INSERT ON reservations
+WHEN NEW.cancelled_at IS NULL AND EXISTS (
+    SELECT 1 FROM reservations
+    WHERE device_id = NEW.device_id
+        AND cancelled_at IS NULL
+        AND starts_at < NEW.ends_at
+        AND NEW.starts_at < ends_at
+)
+BEGIN
+    SELECT RAISE(ABORT, 'reservation overlaps another reservation of the device');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS reservations;
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE waitlist_entries(
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES organizations(id),
+    holder VARCHAR(255) NOT NULL,
+    device_id TEXT REFERENCES devices(id) ON DELETE CASCADE,
+    brand VARCHAR(255) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    auto_assign BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(16) NOT NULL,
+    offered_device_id TEXT,
+    offer_expires_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX waitlist_entries_tenant_id_idx ON waitlist_entries(tenant_id, created_at);
+CREATE INDEX waitlist_entries_pending_idx ON waitlist_entries(created_at)
+    WHERE status IN ('waiting', 'offered');
+CREATE UNIQUE INDEX waitlist_entries_offer_idx ON waitlist_entries(offered_device_id)
+    WHERE status = 'offered';
+
+-- +goose Down
+DROP TABLE IF EXISTS waitlist_entries;
@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE notifications(
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES organizations(id),
+    recipient VARCHAR(255) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    address VARCHAR(2048) NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(16) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME
+);
+
+CREATE INDEX notifications_tenant_id_idx ON notifications(tenant_id, created_at);
+CREATE INDEX notifications_queued_idx ON notifications(created_at) WHERE status = 'queued';
+
+CREATE TABLE notification_preferences(
+    tenant_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    recipient VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    webhook_url VARCHAR(2048) NOT NULL DEFAULT '',
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (tenant_id, recipient)
+);
+
+ALTER TABLE reservations ADD COLUMN overdue_checked_at DATETIME;
+
+-- +goose Down
+ALTER TABLE reservations DROP COLUMN overdue_checked_at;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS notifications;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE change_requests(
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL REFERENCES organizations(id),
+    device_id TEXT NOT NULL,
+    operation VARCHAR(16) NOT NULL,
+    payload TEXT,
+    policy VARCHAR(255) NOT NULL,
+    requested_by VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    decided_by VARCHAR(255) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    decided_at DATETIME
+);
+
+CREATE INDEX change_requests_tenant_id_idx ON change_requests(tenant_id, created_at);
+CREATE INDEX change_requests_device_id_idx ON change_requests(device_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS change_requests;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Row-level security is not available in SQLite, the version only keeps the
+-- migrations in step with PostgreSQL.
+SELECT 1;
+
+-- +goose Down
+SELECT 1;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- reservations whose device could not be handed over when they started
+ALTER TABLE reservations ADD COLUMN failed_at DATETIME;
+ALTER TABLE reservations ADD COLUMN failure VARCHAR(255) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE reservations DROP COLUMN failure;
+ALTER TABLE reservations DROP COLUMN failed_at;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- failed reservations no longer hold their device, as cancelled ones
+DROP TRIGGER reservations_no_overlap;
+
+-- +goose StatementBegin
+CREATE TRIGGER reservations_no_overlap BEFORE INSERT ON reservations
+WHEN NEW.cancelled_at IS NULL AND NEW.failed_at IS NULL AND EXISTS (
+    SELECT 1 FROM reservations
+    WHERE device_id = NEW.device_id
+        AND cancelled_at IS NULL
+        AND failed_at IS NULL
+        AND starts_at < NEW.ends_at
+        AND NEW.starts_at < ends_at
+)
+BEGIN
+    SELECT RAISE(ABORT, 'reservation overlaps another reservation of the device');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER reservations_no_overlap;
+
+-- +goose StatementBegin
+CREATE TRIGGER reservations_no_overlap BEFORE INSERT ON reservations
+WHEN NEW.cancelled_at IS NULL AND EXISTS (
+    SELECT 1 FROM reservations
+    WHERE device_id = NEW.device_id
+        AND cancelled_at IS NULL
+        AND starts_at < NEW.ends_at
+        AND NEW.starts_at < ends_at
+)
+BEGIN
+    SELECT RAISE(ABORT, 'reservation overlaps another reservation of the device');
+END;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE change_requests ADD COLUMN requester TEXT;
+
+-- +goose Down
+ALTER TABLE change_requests DROP COLUMN requester;
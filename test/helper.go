@@ -7,11 +7,13 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/hferr/device-manager/config"
+	"github.com/hferr/device-manager/internal/database"
 	"github.com/hferr/device-manager/internal/protocols/httpjson"
-	"github.com/hferr/device-manager/migrations"
 
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -19,7 +21,44 @@ import (
 	"gorm.io/gorm"
 )
 
+// Drivers are the database drivers the repository tests run against.
+var Drivers = []string{config.DBDriverSQLite, config.DBDriverPostgres}
+
+// SetupTestDB returns a migrated database of the given driver, without devices.
+func SetupTestDB(t *testing.T, driver string) (func(), *gorm.DB) {
+	switch driver {
+	case config.DBDriverSQLite:
+		return SetupTestSQLiteDB(t)
+	case config.DBDriverPostgres:
+		return SetupTestDBContainer(t)
+	default:
+		t.Fatalf("unknown database driver %q", driver)
+		return nil, nil
+	}
+}
+
+// SetupTestSQLiteDB returns a migrated SQLite database stored in a temporary
+// directory.
+func SetupTestSQLiteDB(t *testing.T) (func(), *gorm.DB) {
+	db, err := database.Open(database.SQLite(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to setup test database: %v", err)
+	}
+
+	cleanup := func() {
+		if dbHandle, err := db.DB(); err == nil {
+			dbHandle.Close()
+		}
+	}
+
+	return cleanup, db
+}
+
+// SetupTestDBContainer returns a migrated database running in a postgres
+// container, skipping the test when Docker is not available.
 func SetupTestDBContainer(t *testing.T) (func(), *gorm.DB) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
 	// create a postgres container for testing
 	r := testcontainers.ContainerRequest{
 		Image:        "postgres:alpine",
@@ -50,19 +89,10 @@ func SetupTestDBContainer(t *testing.T) (func(), *gorm.DB) {
 		port.Port(),
 	)
 
-	db, err := gorm.Open(postgres.Open(connString))
-	if err != nil {
-		log.Fatalf("failed to connect to test database: %v", err)
-	}
-
-	dbHandle, err := db.DB()
+	// connect and run migrations
+	db, err := database.Open(postgres.Open(connString), &gorm.Config{})
 	if err != nil {
-		log.Fatalf("failed to connect to test database handle: %v", err)
-	}
-
-	// run migrations
-	if err := migrations.MaybeApplyMigrations(dbHandle); err != nil {
-		log.Fatalf("failed to run migrations on test database: %v", err)
+		log.Fatalf("failed to setup test database: %v", err)
 	}
 
 	// cleanup before each test
@@ -79,7 +109,16 @@ func SetupTestDBContainer(t *testing.T) (func(), *gorm.DB) {
 func Ptr[T any](v T) *T { return &v }
 
 func DoHttpRequest(handler *httpjson.Handler, method, target string, body io.Reader) *http.Response {
+	return DoHttpRequestWithHeaders(handler, method, target, body, nil)
+}
+
+func DoHttpRequestWithHeaders(handler *httpjson.Handler, method, target string, body io.Reader, headers http.Header) *http.Response {
 	req := httptest.NewRequest(method, target, body)
+	for k, vs := range headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
 	w := httptest.NewRecorder()
 
 	handler.NewRouter().ServeHTTP(w, req)
@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/approval"
+
+	"github.com/google/uuid"
+)
+
+type ApprovalService struct {
+	ListChangeRequestsFunc func(f approval.Filter) (approval.ChangeRequests, error)
+	FindByIDFunc           func(ID uuid.UUID) (*approval.ChangeRequest, error)
+	ApproveFunc            func(ID uuid.UUID, input approval.DecisionRequest, approver string) (*approval.ChangeRequest, error)
+	RejectFunc             func(ID uuid.UUID, input approval.DecisionRequest, approver string) (*approval.ChangeRequest, error)
+}
+
+func (as *ApprovalService) ListChangeRequests(_ context.Context, f approval.Filter) (approval.ChangeRequests, error) {
+	return as.ListChangeRequestsFunc(f)
+}
+
+func (as *ApprovalService) FindByID(_ context.Context, ID uuid.UUID) (*approval.ChangeRequest, error) {
+	return as.FindByIDFunc(ID)
+}
+
+func (as *ApprovalService) Approve(_ context.Context, ID uuid.UUID, input approval.DecisionRequest, approver string) (*approval.ChangeRequest, error) {
+	return as.ApproveFunc(ID, input, approver)
+}
+
+func (as *ApprovalService) Reject(_ context.Context, ID uuid.UUID, input approval.DecisionRequest, approver string) (*approval.ChangeRequest, error) {
+	return as.RejectFunc(ID, input, approver)
+}
@@ -1,6 +1,8 @@
 package mock
 
 import (
+	"context"
+
 	"github.com/hferr/device-manager/internal/api/device"
 
 	"github.com/google/uuid"
@@ -16,30 +18,30 @@ type DeviceRepository struct {
 	DeleteDeviceFunc func(ID uuid.UUID) error
 }
 
-func (r *DeviceRepository) InsertDevice(d *device.Device) error {
+func (r *DeviceRepository) InsertDevice(_ context.Context, d *device.Device) error {
 	return r.InsertDeviceFunc(d)
 }
 
-func (r *DeviceRepository) UpdateDevice(d *device.Device) error {
+func (r *DeviceRepository) UpdateDevice(_ context.Context, d *device.Device) error {
 	return r.UpdateDeviceFunc(d)
 }
 
-func (r *DeviceRepository) ListDevices() (device.Devices, error) {
+func (r *DeviceRepository) ListDevices(_ context.Context) (device.Devices, error) {
 	return r.ListDevicesFunc()
 }
 
-func (r *DeviceRepository) FindByID(ID uuid.UUID) (*device.Device, error) {
+func (r *DeviceRepository) FindByID(_ context.Context, ID uuid.UUID) (*device.Device, error) {
 	return r.FindByIDFunc(ID)
 }
 
-func (r *DeviceRepository) FindByState(state string) (device.Devices, error) {
+func (r *DeviceRepository) FindByState(_ context.Context, state string) (device.Devices, error) {
 	return r.FindByStateFunc(state)
 }
 
-func (r *DeviceRepository) FindByBrand(brand string) (device.Devices, error) {
+func (r *DeviceRepository) FindByBrand(_ context.Context, brand string) (device.Devices, error) {
 	return r.FindByBrandFunc(brand)
 }
 
-func (r *DeviceRepository) DeleteDevice(ID uuid.UUID) error {
+func (r *DeviceRepository) DeleteDevice(_ context.Context, ID uuid.UUID) error {
 	return r.DeleteDeviceFunc(ID)
 }
@@ -1,6 +1,8 @@
 package mock
 
 import (
+	"context"
+
 	"github.com/hferr/device-manager/internal/api/device"
 
 	"github.com/google/uuid"
@@ -16,30 +18,30 @@ type DeviceService struct {
 	DeleteDeviceFunc func(ID uuid.UUID) error
 }
 
-func (ds *DeviceService) CreateDevice(input device.CreateDeviceRequest) (*device.Device, error) {
+func (ds *DeviceService) CreateDevice(_ context.Context, input device.CreateDeviceRequest) (*device.Device, error) {
 	return ds.CreateDeviceFunc(input)
 }
 
-func (ds *DeviceService) UpdateDevice(ID uuid.UUID, input device.UpdateDeviceRequest) error {
+func (ds *DeviceService) UpdateDevice(_ context.Context, ID uuid.UUID, input device.UpdateDeviceRequest) error {
 	return ds.UpdateDeviceFunc(ID, input)
 }
 
-func (ds *DeviceService) ListDevices() (device.Devices, error) {
+func (ds *DeviceService) ListDevices(_ context.Context) (device.Devices, error) {
 	return ds.ListDevicesFunc()
 }
 
-func (ds *DeviceService) FindByID(ID uuid.UUID) (*device.Device, error) {
+func (ds *DeviceService) FindByID(_ context.Context, ID uuid.UUID) (*device.Device, error) {
 	return ds.FindByIDFunc(ID)
 }
 
-func (ds *DeviceService) FindByState(state string) (device.Devices, error) {
+func (ds *DeviceService) FindByState(_ context.Context, state string) (device.Devices, error) {
 	return ds.FindByStateFunc(state)
 }
 
-func (ds *DeviceService) FindByBrand(brand string) (device.Devices, error) {
+func (ds *DeviceService) FindByBrand(_ context.Context, brand string) (device.Devices, error) {
 	return ds.FindByBrandFunc(brand)
 }
 
-func (ds *DeviceService) DeleteDevice(ID uuid.UUID) error {
+func (ds *DeviceService) DeleteDevice(_ context.Context, ID uuid.UUID) error {
 	return ds.DeleteDeviceFunc(ID)
 }
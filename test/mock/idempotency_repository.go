@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/idempotency"
+)
+
+type IdempotencyRepository struct {
+	InsertRecordFunc  func(rec *idempotency.Record) error
+	UpdateRecordFunc  func(rec *idempotency.Record) error
+	FindByKeyFunc     func(scope, key string) (*idempotency.Record, error)
+	DeleteRecordFunc  func(scope, key string) error
+	DeleteExpiredFunc func(now time.Time) (int64, error)
+}
+
+func (r *IdempotencyRepository) InsertRecord(_ context.Context, rec *idempotency.Record) error {
+	return r.InsertRecordFunc(rec)
+}
+
+func (r *IdempotencyRepository) UpdateRecord(_ context.Context, rec *idempotency.Record) error {
+	return r.UpdateRecordFunc(rec)
+}
+
+func (r *IdempotencyRepository) FindByKey(_ context.Context, scope, key string) (*idempotency.Record, error) {
+	return r.FindByKeyFunc(scope, key)
+}
+
+func (r *IdempotencyRepository) DeleteRecord(_ context.Context, scope, key string) error {
+	return r.DeleteRecordFunc(scope, key)
+}
+
+func (r *IdempotencyRepository) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	return r.DeleteExpiredFunc(now)
+}
@@ -0,0 +1,35 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/notification"
+)
+
+type NotificationService struct {
+	NotifyFunc            func(ev notification.Event) error
+	ListNotificationsFunc func(f notification.Filter) (notification.Notifications, error)
+	FindPreferenceFunc    func(recipient string) (*notification.Preference, error)
+	UpdatePreferenceFunc  func(recipient string, input notification.UpdatePreferenceRequest) (*notification.Preference, error)
+	DeliverFunc           func() (int, error)
+}
+
+func (ns *NotificationService) Notify(_ context.Context, ev notification.Event) error {
+	return ns.NotifyFunc(ev)
+}
+
+func (ns *NotificationService) ListNotifications(_ context.Context, f notification.Filter) (notification.Notifications, error) {
+	return ns.ListNotificationsFunc(f)
+}
+
+func (ns *NotificationService) FindPreference(_ context.Context, recipient string) (*notification.Preference, error) {
+	return ns.FindPreferenceFunc(recipient)
+}
+
+func (ns *NotificationService) UpdatePreference(_ context.Context, recipient string, input notification.UpdatePreferenceRequest) (*notification.Preference, error) {
+	return ns.UpdatePreferenceFunc(recipient, input)
+}
+
+func (ns *NotificationService) Deliver(_ context.Context) (int, error) {
+	return ns.DeliverFunc()
+}
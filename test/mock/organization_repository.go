@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationRepository struct {
+	InsertOrganizationFunc func(o *organization.Organization) error
+	ListOrganizationsFunc  func() (organization.Organizations, error)
+	FindByIDFunc           func(ID uuid.UUID) (*organization.Organization, error)
+	DeleteOrganizationFunc func(ID uuid.UUID) error
+}
+
+func (r *OrganizationRepository) InsertOrganization(_ context.Context, o *organization.Organization) error {
+	return r.InsertOrganizationFunc(o)
+}
+
+func (r *OrganizationRepository) ListOrganizations(_ context.Context) (organization.Organizations, error) {
+	return r.ListOrganizationsFunc()
+}
+
+func (r *OrganizationRepository) FindByID(_ context.Context, ID uuid.UUID) (*organization.Organization, error) {
+	return r.FindByIDFunc(ID)
+}
+
+func (r *OrganizationRepository) DeleteOrganization(_ context.Context, ID uuid.UUID) error {
+	return r.DeleteOrganizationFunc(ID)
+}
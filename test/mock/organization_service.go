@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/organization"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationService struct {
+	CreateOrganizationFunc func(input organization.CreateOrganizationRequest) (*organization.Organization, error)
+	ListOrganizationsFunc  func() (organization.Organizations, error)
+	FindByIDFunc           func(ID uuid.UUID) (*organization.Organization, error)
+	DeleteOrganizationFunc func(ID uuid.UUID) error
+}
+
+func (os *OrganizationService) CreateOrganization(_ context.Context, input organization.CreateOrganizationRequest) (*organization.Organization, error) {
+	return os.CreateOrganizationFunc(input)
+}
+
+func (os *OrganizationService) ListOrganizations(_ context.Context) (organization.Organizations, error) {
+	return os.ListOrganizationsFunc()
+}
+
+func (os *OrganizationService) FindByID(_ context.Context, ID uuid.UUID) (*organization.Organization, error) {
+	return os.FindByIDFunc(ID)
+}
+
+func (os *OrganizationService) DeleteOrganization(_ context.Context, ID uuid.UUID) error {
+	return os.DeleteOrganizationFunc(ID)
+}
@@ -0,0 +1,20 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/policy"
+)
+
+type PolicyService struct {
+	ListPoliciesFunc func() (policy.Policies, error)
+	EvaluateFunc     func(input policy.EvaluateRequest) (*policy.Evaluation, error)
+}
+
+func (ps *PolicyService) ListPolicies(_ context.Context) (policy.Policies, error) {
+	return ps.ListPoliciesFunc()
+}
+
+func (ps *PolicyService) Evaluate(_ context.Context, input policy.EvaluateRequest) (*policy.Evaluation, error) {
+	return ps.EvaluateFunc(input)
+}
@@ -0,0 +1,43 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/reservation"
+
+	"github.com/google/uuid"
+)
+
+type ReservationRepository struct {
+	InsertReservationFunc func(r *reservation.Reservation) error
+	UpdateReservationFunc func(r *reservation.Reservation) error
+	ListReservationsFunc  func(f reservation.Filter) (reservation.Reservations, error)
+	FindByIDFunc          func(ID uuid.UUID) (*reservation.Reservation, error)
+	ListDueFunc           func(now time.Time) (reservation.Reservations, error)
+	ListEndedFunc         func(now time.Time) (reservation.Reservations, error)
+}
+
+func (r *ReservationRepository) InsertReservation(_ context.Context, res *reservation.Reservation) error {
+	return r.InsertReservationFunc(res)
+}
+
+func (r *ReservationRepository) UpdateReservation(_ context.Context, res *reservation.Reservation) error {
+	return r.UpdateReservationFunc(res)
+}
+
+func (r *ReservationRepository) ListReservations(_ context.Context, f reservation.Filter) (reservation.Reservations, error) {
+	return r.ListReservationsFunc(f)
+}
+
+func (r *ReservationRepository) FindByID(_ context.Context, ID uuid.UUID) (*reservation.Reservation, error) {
+	return r.FindByIDFunc(ID)
+}
+
+func (r *ReservationRepository) ListDue(_ context.Context, now time.Time) (reservation.Reservations, error) {
+	return r.ListDueFunc(now)
+}
+
+func (r *ReservationRepository) ListEnded(_ context.Context, now time.Time) (reservation.Reservations, error) {
+	return r.ListEndedFunc(now)
+}
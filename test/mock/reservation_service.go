@@ -0,0 +1,49 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/hferr/device-manager/internal/api/device"
+	"github.com/hferr/device-manager/internal/api/reservation"
+
+	"github.com/google/uuid"
+)
+
+type ReservationService struct {
+	CreateReservationFunc func(input reservation.CreateReservationRequest) (*reservation.Reservation, error)
+	ListReservationsFunc  func(f reservation.Filter) (reservation.Reservations, error)
+	FindByIDFunc          func(ID uuid.UUID) (*reservation.Reservation, error)
+	CancelReservationFunc func(ID uuid.UUID) (*reservation.Reservation, error)
+	AvailableDevicesFunc  func(from, to time.Time) (device.Devices, error)
+	StartDueFunc          func() (reservation.Reservations, error)
+	ReportOverdueFunc     func() (reservation.Reservations, error)
+}
+
+func (rs *ReservationService) CreateReservation(_ context.Context, input reservation.CreateReservationRequest) (*reservation.Reservation, error) {
+	return rs.CreateReservationFunc(input)
+}
+
+func (rs *ReservationService) ListReservations(_ context.Context, f reservation.Filter) (reservation.Reservations, error) {
+	return rs.ListReservationsFunc(f)
+}
+
+func (rs *ReservationService) FindByID(_ context.Context, ID uuid.UUID) (*reservation.Reservation, error) {
+	return rs.FindByIDFunc(ID)
+}
+
+func (rs *ReservationService) CancelReservation(_ context.Context, ID uuid.UUID) (*reservation.Reservation, error) {
+	return rs.CancelReservationFunc(ID)
+}
+
+func (rs *ReservationService) AvailableDevices(_ context.Context, from, to time.Time) (device.Devices, error) {
+	return rs.AvailableDevicesFunc(from, to)
+}
+
+func (rs *ReservationService) StartDue(_ context.Context) (reservation.Reservations, error) {
+	return rs.StartDueFunc()
+}
+
+func (rs *ReservationService) ReportOverdue(_ context.Context) (reservation.Reservations, error) {
+	return rs.ReportOverdueFunc()
+}
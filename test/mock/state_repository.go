@@ -0,0 +1,35 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/devicestate"
+)
+
+type StateRepository struct {
+	InsertStateFunc func(s *devicestate.State) error
+	UpdateStateFunc func(s *devicestate.State) error
+	ListStatesFunc  func() (devicestate.States, error)
+	FindByNameFunc  func(name string) (*devicestate.State, error)
+	DeleteStateFunc func(name string) error
+}
+
+func (r *StateRepository) InsertState(_ context.Context, s *devicestate.State) error {
+	return r.InsertStateFunc(s)
+}
+
+func (r *StateRepository) UpdateState(_ context.Context, s *devicestate.State) error {
+	return r.UpdateStateFunc(s)
+}
+
+func (r *StateRepository) ListStates(_ context.Context) (devicestate.States, error) {
+	return r.ListStatesFunc()
+}
+
+func (r *StateRepository) FindByName(_ context.Context, name string) (*devicestate.State, error) {
+	return r.FindByNameFunc(name)
+}
+
+func (r *StateRepository) DeleteState(_ context.Context, name string) error {
+	return r.DeleteStateFunc(name)
+}
@@ -0,0 +1,35 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/devicestate"
+)
+
+type StateService struct {
+	CreateStateFunc func(input devicestate.CreateStateRequest) (*devicestate.State, error)
+	UpdateStateFunc func(name string, input devicestate.UpdateStateRequest) (*devicestate.State, error)
+	ListStatesFunc  func() (devicestate.States, error)
+	FindByNameFunc  func(name string) (*devicestate.State, error)
+	DeleteStateFunc func(name string) error
+}
+
+func (ss *StateService) CreateState(_ context.Context, input devicestate.CreateStateRequest) (*devicestate.State, error) {
+	return ss.CreateStateFunc(input)
+}
+
+func (ss *StateService) UpdateState(_ context.Context, name string, input devicestate.UpdateStateRequest) (*devicestate.State, error) {
+	return ss.UpdateStateFunc(name, input)
+}
+
+func (ss *StateService) ListStates(_ context.Context) (devicestate.States, error) {
+	return ss.ListStatesFunc()
+}
+
+func (ss *StateService) FindByName(_ context.Context, name string) (*devicestate.State, error) {
+	return ss.FindByNameFunc(name)
+}
+
+func (ss *StateService) DeleteState(_ context.Context, name string) error {
+	return ss.DeleteStateFunc(name)
+}
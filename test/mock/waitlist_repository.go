@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/waitlist"
+
+	"github.com/google/uuid"
+)
+
+type WaitlistRepository struct {
+	InsertEntryFunc func(e *waitlist.Entry) error
+	UpdateEntryFunc func(e *waitlist.Entry) error
+	ListEntriesFunc func(f waitlist.Filter) (waitlist.Entries, error)
+	FindByIDFunc    func(ID uuid.UUID) (*waitlist.Entry, error)
+	ListPendingFunc func() (waitlist.Entries, error)
+}
+
+func (r *WaitlistRepository) InsertEntry(_ context.Context, e *waitlist.Entry) error {
+	return r.InsertEntryFunc(e)
+}
+
+func (r *WaitlistRepository) UpdateEntry(_ context.Context, e *waitlist.Entry) error {
+	return r.UpdateEntryFunc(e)
+}
+
+func (r *WaitlistRepository) ListEntries(_ context.Context, f waitlist.Filter) (waitlist.Entries, error) {
+	return r.ListEntriesFunc(f)
+}
+
+func (r *WaitlistRepository) FindByID(_ context.Context, ID uuid.UUID) (*waitlist.Entry, error) {
+	return r.FindByIDFunc(ID)
+}
+
+func (r *WaitlistRepository) ListPending(_ context.Context) (waitlist.Entries, error) {
+	return r.ListPendingFunc()
+}
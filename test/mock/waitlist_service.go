@@ -0,0 +1,42 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hferr/device-manager/internal/api/waitlist"
+
+	"github.com/google/uuid"
+)
+
+type WaitlistService struct {
+	JoinWaitlistFunc  func(input waitlist.CreateEntryRequest) (*waitlist.Entry, error)
+	ListEntriesFunc   func(f waitlist.Filter) (waitlist.Entries, error)
+	FindByIDFunc      func(ID uuid.UUID) (*waitlist.Entry, error)
+	ClaimOfferFunc    func(ID uuid.UUID) (*waitlist.Entry, error)
+	LeaveWaitlistFunc func(ID uuid.UUID) (*waitlist.Entry, error)
+	DispatchFunc      func() ([]waitlist.Event, error)
+}
+
+func (ws *WaitlistService) JoinWaitlist(_ context.Context, input waitlist.CreateEntryRequest) (*waitlist.Entry, error) {
+	return ws.JoinWaitlistFunc(input)
+}
+
+func (ws *WaitlistService) ListEntries(_ context.Context, f waitlist.Filter) (waitlist.Entries, error) {
+	return ws.ListEntriesFunc(f)
+}
+
+func (ws *WaitlistService) FindByID(_ context.Context, ID uuid.UUID) (*waitlist.Entry, error) {
+	return ws.FindByIDFunc(ID)
+}
+
+func (ws *WaitlistService) ClaimOffer(_ context.Context, ID uuid.UUID) (*waitlist.Entry, error) {
+	return ws.ClaimOfferFunc(ID)
+}
+
+func (ws *WaitlistService) LeaveWaitlist(_ context.Context, ID uuid.UUID) (*waitlist.Entry, error) {
+	return ws.LeaveWaitlistFunc(ID)
+}
+
+func (ws *WaitlistService) Dispatch(_ context.Context) ([]waitlist.Event, error) {
+	return ws.DispatchFunc()
+}
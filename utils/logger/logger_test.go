@@ -0,0 +1,65 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hferr/device-manager/utils/logger"
+)
+
+func TestNew(t *testing.T) {
+	var testCases = map[string]struct {
+		format  string
+		level   string
+		wantErr bool
+	}{
+		"json":           {format: "json", level: "info"},
+		"text":           {format: "text", level: "debug"},
+		"invalid format": {format: "xml", level: "info", wantErr: true},
+		"invalid level":  {format: "json", level: "loud", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := logger.New(&bytes.Buffer{}, tc.format, tc.level)
+			if err != nil && !tc.wantErr {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err == nil && tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestRequestIDAttr(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	l, err := logger.New(buf, logger.FormatJSON, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	l.With("component", "test").InfoContext(ctx, "hello")
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["request_id"] != "req-1" {
+		t.Fatalf("expected request_id %q, got: %v", "req-1", got["request_id"])
+	}
+
+	if got["component"] != "test" {
+		t.Fatalf("expected component %q, got: %v", "test", got["component"])
+	}
+}
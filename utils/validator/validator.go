@@ -1,15 +1,49 @@
 package validator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
 
-type ValidationErrors struct {
-	Errors []string `json:"errors"`
+// FieldError describes why a field of a request failed validation.
+type FieldError struct {
+	// Pointer is the RFC 6901 JSON pointer to the field in the request body.
+	Pointer string `json:"pointer" example:"/state"`
+	// Rule is the validation rule the field broke, e.g. required or
+	// device_state.
+	Rule string `json:"rule" example:"device_state"`
+	// Param is the parameter of the rule, e.g. the maximum length of max.
+	Param  string `json:"param,omitempty"`
+	Detail string `json:"detail" example:"state must be one of the device states, see /states"`
+}
+
+// Custom rules, in addition to the validator built-ins.
+const (
+	// RuleSnakeCase accepts lowercase letters, digits and underscores,
+	// starting with a letter.
+	RuleSnakeCase = "snake_case"
+	// RuleDeviceState accepts the device states known to the StateLookup of
+	// the context, see WithStateLookup.
+	RuleDeviceState = "device_state"
+)
+
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// StateLookup reports whether a device state exists.
+type StateLookup func(ctx context.Context, name string) (bool, error)
+
+type stateLookupKey struct{}
+
+// WithStateLookup returns a copy of ctx validating device states with lookup,
+// when passed to StructCtx. Without one, every state is accepted.
+func WithStateLookup(ctx context.Context, lookup StateLookup) context.Context {
+	return context.WithValue(ctx, stateLookupKey{}, lookup)
 }
 
 func New() *validator.Validate {
@@ -24,33 +58,78 @@ func New() *validator.Validate {
 		return n
 	})
 
+	v.RegisterValidation(RuleSnakeCase, func(fl validator.FieldLevel) bool {
+		return snakeCase.MatchString(fl.Field().String())
+	})
+
+	v.RegisterValidationCtx(RuleDeviceState, func(ctx context.Context, fl validator.FieldLevel) bool {
+		lookup, ok := ctx.Value(stateLookupKey{}).(StateLookup)
+		if !ok {
+			return true
+		}
+
+		// a failed lookup is left to the service, to be reported as an error
+		// rather than as an invalid request
+		exists, err := lookup(ctx, fl.Field().String())
+		return err != nil || exists
+	})
+
 	return v
 }
 
-func ErrResponse(err error) *ValidationErrors {
-	if valErrors, ok := err.(validator.ValidationErrors); ok {
-		resp := ValidationErrors{
-			Errors: make([]string, len(valErrors)),
+// FieldErrors returns the field errors of a validation error, or nil if err
+// is not one.
+func FieldErrors(err error) []FieldError {
+	var valErrors validator.ValidationErrors
+	if !errors.As(err, &valErrors) {
+		return nil
+	}
+
+	fieldErrs := make([]FieldError, len(valErrors))
+	for i, fieldErr := range valErrors {
+		fieldName := fieldErr.Field()
+
+		var detail string
+		switch fieldErr.Tag() {
+		case "required":
+			detail = fmt.Sprintf("%s is required", fieldName)
+		case "oneof":
+			detail = fmt.Sprintf("%s must be one of: %s", fieldName, fieldErr.Param())
+		case "max":
+			detail = fmt.Sprintf("%s must be at most %s characters", fieldName, fieldErr.Param())
+		case RuleSnakeCase:
+			detail = fmt.Sprintf("%s must only contain lowercase letters, digits and underscores, starting with a letter", fieldName)
+		case RuleDeviceState:
+			detail = fmt.Sprintf("%s must be one of the device states, see /states", fieldName)
+		default:
+			detail = fmt.Sprintf("%s %s", fieldName, fieldErr.ActualTag())
 		}
 
-		// loop through the validation errors
-		for i, fieldErr := range valErrors {
-			// get the field name and error message
-			fieldName := fieldErr.StructField()
-			errorMessage := fieldErr.ActualTag()
-
-			switch fieldErr.Tag() {
-			case "required":
-				resp.Errors[i] = fmt.Sprintf("%s is required", fieldName)
-			case "oneof":
-				resp.Errors[i] = fmt.Sprintf("%s must be one of: %s", fieldName, fieldErr.Param())
-			default:
-				resp.Errors[i] = fmt.Sprintf("%s %s", fieldName, errorMessage)
-			}
+		fieldErrs[i] = FieldError{
+			Pointer: jsonPointer(fieldErr.Namespace()),
+			Rule:    fieldErr.Tag(),
+			Param:   fieldErr.Param(),
+			Detail:  detail,
 		}
+	}
+
+	return fieldErrs
+}
 
-		return &resp
+// jsonPointer converts a validator namespace, such as Request.items[0].name,
+// to a JSON pointer relative to the request body, such as /items/0/name.
+func jsonPointer(namespace string) string {
+	// the first element is the name of the validated struct
+	_, path, _ := strings.Cut(namespace, ".")
+
+	var b strings.Builder
+	for _, part := range strings.Split(path, ".") {
+		for _, token := range strings.FieldsFunc(part, func(r rune) bool { return r == '[' || r == ']' }) {
+			token = strings.ReplaceAll(token, "~", "~0")
+			token = strings.ReplaceAll(token, "/", "~1")
+			b.WriteString("/" + token)
+		}
 	}
 
-	return nil
+	return b.String()
 }
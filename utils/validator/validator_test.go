@@ -1,31 +1,84 @@
 package validator_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/hferr/device-manager/utils/validator"
 )
 
-func TestErrResponse(t *testing.T) {
+type nameRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type statusRequest struct {
+	Status string `json:"status" validate:"oneof=active inactive"`
+}
+
+type slugRequest struct {
+	Slug string `json:"slug" validate:"snake_case"`
+}
+
+type stateRequest struct {
+	State string `json:"state" validate:"device_state"`
+}
+
+type itemsRequest struct {
+	Items []nameRequest `json:"items" validate:"dive"`
+}
+
+func TestFieldErrors(t *testing.T) {
 	var testCases = map[string]struct {
 		input    any
-		expected string
+		expected validator.FieldError
 	}{
 		"required": {
-			input: struct {
-				Name string `json:"name" validate:"required"`
-			}{},
-			expected: "Name is required",
+			input: nameRequest{},
+			expected: validator.FieldError{
+				Pointer: "/name",
+				Rule:    "required",
+				Detail:  "name is required",
+			},
 		},
 		"oneof": {
-			input: struct {
-				Status string `json:"status" validate:"oneof=active inactive"`
-			}{},
-			expected: "Status must be one of: active inactive",
+			input: statusRequest{},
+			expected: validator.FieldError{
+				Pointer: "/status",
+				Rule:    "oneof",
+				Param:   "active inactive",
+				Detail:  "status must be one of: active inactive",
+			},
+		},
+		"snake case": {
+			input: slugRequest{Slug: "In Repair"},
+			expected: validator.FieldError{
+				Pointer: "/slug",
+				Rule:    "snake_case",
+				Detail:  "slug must only contain lowercase letters, digits and underscores, starting with a letter",
+			},
+		},
+		"device state": {
+			input: stateRequest{State: "broken"},
+			expected: validator.FieldError{
+				Pointer: "/state",
+				Rule:    "device_state",
+				Detail:  "state must be one of the device states, see /states",
+			},
+		},
+		"nested": {
+			input: itemsRequest{Items: []nameRequest{{Name: "ok"}, {}}},
+			expected: validator.FieldError{
+				Pointer: "/items/1/name",
+				Rule:    "required",
+				Detail:  "name is required",
+			},
 		},
 	}
 
 	v := validator.New()
+	ctx := validator.WithStateLookup(context.Background(), func(_ context.Context, name string) (bool, error) {
+		return name == "available", nil
+	})
 
 	for name, tc := range testCases {
 		tc := tc
@@ -33,12 +86,35 @@ func TestErrResponse(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			err := v.Struct(tc.input)
-			if res := validator.ErrResponse(err); res == nil || len(res.Errors) != 1 {
-				t.Fatalf("expected error, got nil")
-			} else if res.Errors[0] != tc.expected {
-				t.Fatalf("expected %s, got %s", tc.expected, res.Errors[0])
+			err := v.StructCtx(ctx, tc.input)
+			if res := validator.FieldErrors(err); len(res) != 1 {
+				t.Fatalf("expected 1 field error, got: %v", res)
+			} else if res[0] != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, res[0])
 			}
 		})
 	}
+
+	if res := validator.FieldErrors(nil); res != nil {
+		t.Fatalf("expected no field errors, got: %v", res)
+	}
+}
+
+func TestDeviceState(t *testing.T) {
+	v := validator.New()
+
+	// assert states are accepted without a lookup, for the service to check
+
+	if err := v.StructCtx(context.Background(), stateRequest{State: "broken"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// assert known states are accepted
+
+	ctx := validator.WithStateLookup(context.Background(), func(_ context.Context, name string) (bool, error) {
+		return name == "available", nil
+	})
+	if err := v.StructCtx(ctx, stateRequest{State: "available"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
 }